@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/client"
+)
+
+// batchSize is the most accounts fetched by one batch lookup.
+const batchSize = 100
+
+type options struct {
+	accounts       int
+	firstAccount   int64
+	initialBalance decimal.Decimal
+	maxAmount      decimal.Decimal
+	rate           float64
+	duration       time.Duration
+	concurrency    int
+	pairs          float64
+	seed           int64
+}
+
+func (o options) validate() error {
+	switch {
+	case o.accounts < 2:
+		return errors.New("--accounts must be at least 2")
+	case o.firstAccount < 0:
+		return errors.New("--first-account must not be negative")
+	case o.rate <= 0:
+		return errors.New("--rate must be positive")
+	case o.duration <= 0:
+		return errors.New("--duration must be positive")
+	case o.concurrency < 1:
+		return errors.New("--concurrency must be at least 1")
+	case o.pairs < 0 || o.pairs > 1:
+		return errors.New("--pairs must be between 0 and 1")
+	}
+	return nil
+}
+
+// generator sends the load and keeps what it needs to verify the balances
+// afterwards: the net change of every account by the transfers that
+// succeeded and the fees they were charged.
+type generator struct {
+	client  *client.Client
+	options options
+	ids     []int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	outcomes  map[string]int
+	deltas    map[int64]decimal.Decimal
+	fees      decimal.Decimal
+	// unknown counts transfers that may or may not have committed: the
+	// server failed or did not answer.
+	unknown int
+}
+
+func newGenerator(client *client.Client, opts options) *generator {
+	ids := make([]int64, opts.accounts)
+	for i := range ids {
+		ids[i] = opts.firstAccount + int64(i)
+	}
+	return &generator{
+		client:   client,
+		options:  opts,
+		ids:      ids,
+		outcomes: map[string]int{},
+		deltas:   map[int64]decimal.Decimal{},
+	}
+}
+
+// createAccounts creates the accounts with up to concurrency requests at a
+// time and fails on the first that cannot be created.
+func (g *generator) createAccounts(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	ids := make(chan int64)
+	var wg sync.WaitGroup
+	for range min(g.options.concurrency, len(g.ids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				if _, err := g.client.CreateAccount(ctx, id, g.options.initialBalance); err != nil {
+					cancel(fmt.Errorf("create account %d: %w", id, err))
+				}
+			}
+		}()
+	}
+feed:
+	for _, id := range g.ids {
+		select {
+		case ids <- id:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ids)
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// send starts transfers at the configured rate until the duration has passed
+// or ctx is canceled, waits for those in flight and returns how long it took.
+// A tick that finds every worker busy waits for one, so a server slower than
+// the rate lowers the throughput rather than piling up requests.
+func (g *generator) send(ctx context.Context) time.Duration {
+	ticks := make(chan struct{})
+	var wg sync.WaitGroup
+	for worker := range g.options.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			random := rand.New(rand.NewPCG(uint64(g.options.seed), uint64(worker)))
+			for range ticks {
+				g.transferRandom(ctx, random)
+			}
+		}()
+	}
+
+	startedAt := time.Now()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / g.options.rate))
+	defer ticker.Stop()
+	deadline := time.NewTimer(g.options.duration)
+	defer deadline.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline.C:
+			break loop
+		case <-ticker.C:
+			select {
+			case ticks <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			case <-deadline.C:
+				break loop
+			}
+		}
+	}
+	close(ticks)
+	wg.Wait()
+	return time.Since(startedAt)
+}
+
+// transferRandom moves a random amount between two random accounts. A share
+// of the time it moves money both ways between them at once, which is what
+// exercises the lock ordering of the repository.
+func (g *generator) transferRandom(ctx context.Context, random *rand.Rand) {
+	source := g.ids[random.IntN(len(g.ids))]
+	destination := g.ids[random.IntN(len(g.ids)-1)]
+	if destination >= source {
+		destination++
+	}
+	if random.Float64() >= g.options.pairs {
+		g.transfer(ctx, source, destination, g.amount(random))
+		return
+	}
+	forward, backward := g.amount(random), g.amount(random)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.transfer(ctx, destination, source, backward)
+	}()
+	g.transfer(ctx, source, destination, forward)
+	wg.Wait()
+}
+
+func (g *generator) amount(random *rand.Rand) decimal.Decimal {
+	cents := g.options.maxAmount.Shift(2).IntPart()
+	return decimal.New(random.Int64N(cents)+1, -2)
+}
+
+func (g *generator) transfer(ctx context.Context, source, destination int64, amount decimal.Decimal) {
+	startedAt := time.Now()
+	transaction, err := g.client.TransferMoney(ctx, source, destination, amount)
+	latency := time.Since(startedAt)
+	if ctx.Err() != nil && err != nil {
+		// Cut short by the end of the run, not a response of the server.
+		g.mu.Lock()
+		g.unknown++
+		g.mu.Unlock()
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.latencies = append(g.latencies, latency)
+	var apiErr *client.APIError
+	switch {
+	case err == nil:
+		g.outcomes["succeeded"]++
+		debit := transaction.Amount
+		if transaction.Fee != nil {
+			debit = debit.Add(transaction.Fee.Amount)
+			g.fees = g.fees.Add(transaction.Fee.Amount)
+		}
+		g.deltas[source] = g.deltas[source].Sub(debit)
+		g.deltas[destination] = g.deltas[destination].Add(transaction.Amount)
+	case errors.As(err, &apiErr):
+		g.outcomes[apiErr.Code]++
+		if apiErr.StatusCode >= 500 {
+			g.unknown++
+		}
+	default:
+		g.outcomes["transport_error"]++
+		g.unknown++
+	}
+}
+
+// result is the report of a run. The per-account check is skipped when some
+// transfers have an unknown outcome, since the expected balances are then
+// unknown too. So are the fees those transfers charged: when fees were seen,
+// the total is only checked not to exceed the expected one.
+type result struct {
+	accounts   int
+	first      int64
+	elapsed    time.Duration
+	target     float64
+	outcomes   map[string]int
+	latencies  []time.Duration
+	unknown    int
+	expected   decimal.Decimal
+	atMost     bool
+	total      decimal.Decimal
+	negative   []int64
+	mismatched []int64
+	violations []string
+}
+
+// verify fetches every account and checks that money was conserved: the
+// balances add up to what the accounts were created with less the fees
+// charged, none is negative and, when every outcome is known, each matches
+// the transfers that succeeded. Transfers of unknown outcome may have charged
+// fees of their own, so with fees seen the total may fall short of the
+// expected one but not exceed it.
+func (g *generator) verify(ctx context.Context, elapsed time.Duration) (*result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := &result{
+		accounts:  len(g.ids),
+		first:     g.options.firstAccount,
+		elapsed:   elapsed,
+		target:    g.options.rate,
+		outcomes:  g.outcomes,
+		latencies: slices.Sorted(slices.Values(g.latencies)),
+		unknown:   g.unknown,
+		expected:  g.options.initialBalance.Mul(decimal.NewFromInt(int64(len(g.ids)))).Sub(g.fees),
+		atMost:    g.unknown > 0 && g.fees.IsPositive(),
+	}
+
+	for chunk := range slices.Chunk(g.ids, batchSize) {
+		batch, err := g.client.BatchGetAccounts(ctx, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("fetch accounts: %w", err)
+		}
+		if len(batch.NotFound) > 0 {
+			return nil, fmt.Errorf("fetch accounts: %d not found", len(batch.NotFound))
+		}
+		for _, account := range batch.Accounts {
+			r.total = r.total.Add(account.Balance)
+			if account.Balance.IsNegative() {
+				r.negative = append(r.negative, account.ID)
+			}
+			expected := g.options.initialBalance.Add(g.deltas[account.ID])
+			if r.unknown == 0 && !account.Balance.Equal(expected) {
+				r.mismatched = append(r.mismatched, account.ID)
+			}
+		}
+	}
+
+	switch {
+	case r.atMost && r.total.GreaterThan(r.expected):
+		r.violations = append(r.violations, fmt.Sprintf("total balance is %s, expected at most %s", r.total, r.expected))
+	case !r.atMost && !r.total.Equal(r.expected):
+		r.violations = append(r.violations, fmt.Sprintf("total balance is %s, expected %s", r.total, r.expected))
+	}
+	if len(r.negative) > 0 {
+		r.violations = append(r.violations, fmt.Sprintf("%d accounts have a negative balance: %s", len(r.negative), formatIDs(r.negative)))
+	}
+	if len(r.mismatched) > 0 {
+		r.violations = append(r.violations, fmt.Sprintf("%d accounts do not match the transfers that succeeded: %s", len(r.mismatched), formatIDs(r.mismatched)))
+	}
+	return r, nil
+}
+
+func (r *result) print(w io.Writer) {
+	sent := 0
+	for _, count := range r.outcomes {
+		sent += count
+	}
+	fmt.Fprintf(w, "accounts     %d (%d to %d)\n", r.accounts, r.first, r.first+int64(r.accounts)-1)
+	fmt.Fprintf(w, "transfers    %d in %s, %.1f/s (target %.0f/s)\n", sent, r.elapsed.Round(time.Millisecond), float64(sent)/r.elapsed.Seconds(), r.target)
+	fmt.Fprintf(w, "outcomes     %s\n", formatOutcomes(r.outcomes))
+	fmt.Fprintf(w, "latency      p50 %s  p90 %s  p99 %s  max %s\n",
+		percentile(r.latencies, 0.50), percentile(r.latencies, 0.90), percentile(r.latencies, 0.99), percentile(r.latencies, 1))
+	if r.atMost {
+		fmt.Fprintf(w, "total        %s (expected at most %s)\n", r.total, r.expected)
+	} else {
+		fmt.Fprintf(w, "total        %s (expected %s)\n", r.total, r.expected)
+	}
+	if r.unknown > 0 {
+		fmt.Fprintf(w, "unknown      %d transfers may or may not have committed, balances were not checked one by one\n", r.unknown)
+	}
+	if len(r.violations) == 0 {
+		fmt.Fprintln(w, "result       OK")
+		return
+	}
+	fmt.Fprintln(w, "result       FAILED")
+	for _, violation := range r.violations {
+		fmt.Fprintf(w, "  %s\n", violation)
+	}
+}
+
+// percentile returns the latency below which the share p of the sorted
+// latencies falls.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted))*p+0.999999) - 1
+	index = max(0, min(index, len(sorted)-1))
+	return sorted[index].Round(10 * time.Microsecond)
+}
+
+func formatOutcomes(outcomes map[string]int) string {
+	if len(outcomes) == 0 {
+		return "none"
+	}
+	codes := make([]string, 0, len(outcomes))
+	for code := range outcomes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if outcomes[codes[i]] != outcomes[codes[j]] {
+			return outcomes[codes[i]] > outcomes[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("%s %d", code, outcomes[code])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatIDs lists at most ten account IDs.
+func formatIDs(ids []int64) string {
+	parts := make([]string, 0, min(len(ids), 10))
+	for _, id := range ids[:min(len(ids), 10)] {
+		parts = append(parts, fmt.Sprint(id))
+	}
+	if len(ids) > 10 {
+		parts = append(parts, "...")
+	}
+	return strings.Join(parts, ", ")
+}
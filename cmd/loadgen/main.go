@@ -0,0 +1,150 @@
+// Command loadgen drives a running transfer-system with concurrent random
+// transfers between accounts it creates, reports throughput and latency, and
+// then checks that the transfers conserved money and left no balance
+// negative.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/client"
+)
+
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitViolation = 3
+)
+
+const usage = `usage: loadgen [flags]
+
+Creates accounts through the API, then fires random transfers between them
+at a fixed rate. A share of the transfers is sent as two opposing transfers
+between the same accounts at the same time. Once done, it reports throughput
+and latency percentiles and verifies that the balances add up to the money
+the accounts were created with, less the fees charged, and that none is
+negative.
+
+flags:
+  --api-url URL          REST API base URL (env LOADGEN_API_URL, default http://localhost:9000)
+  --accounts N           accounts to create (default 100)
+  --first-account ID     ID of the first account, the others follow it (default derived from the current time)
+  --initial-balance A    balance every account is created with (default 1000)
+  --max-amount A         largest transfer amount, amounts are random cents up to it (default 100)
+  --rate N               transfers started per second (default 200)
+  --duration D           how long transfers are sent for (default 30s)
+  --concurrency N        requests in flight at most (default 64)
+  --pairs F              share of transfers sent as opposing pairs, 0 to 1 (default 0.2)
+  --timeout D            timeout of each request (default 10s)
+  --seed N               seed of the random transfers (default the current time)
+
+Transfers the server answered with a 5xx status or not at all may or may not
+have committed; when there are any, only the total is verified, not every
+balance, and when fees were charged the total may fall short of the expected
+one by the fees of those transfers, but not exceed it.
+
+exit codes:
+  0 invariants hold, 1 error, 2 usage, 3 invariant violated`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Getenv, os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, getenv func(string) string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	apiURL := flags.String("api-url", envOr(getenv, "LOADGEN_API_URL", "http://localhost:9000"), "")
+	accounts := flags.Int("accounts", 100, "")
+	firstAccount := flags.Int64("first-account", 0, "")
+	initialBalance := flags.String("initial-balance", "1000", "")
+	maxAmount := flags.String("max-amount", "100", "")
+	rate := flags.Float64("rate", 200, "")
+	duration := flags.Duration("duration", 30*time.Second, "")
+	concurrency := flags.Int("concurrency", 64, "")
+	pairs := flags.Float64("pairs", 0.2, "")
+	timeout := flags.Duration("timeout", 10*time.Second, "")
+	seed := flags.Int64("seed", 0, "")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(stdout, usage)
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "%v\n\n%s\n", err, usage)
+		return exitUsage
+	}
+
+	opts := options{
+		accounts:     *accounts,
+		firstAccount: *firstAccount,
+		rate:         *rate,
+		duration:     *duration,
+		concurrency:  *concurrency,
+		pairs:        *pairs,
+		seed:         *seed,
+	}
+	var err error
+	if opts.initialBalance, err = decimal.NewFromString(*initialBalance); err != nil || opts.initialBalance.IsNegative() {
+		fmt.Fprintf(stderr, "--initial-balance: %q is not a non-negative amount\n", *initialBalance)
+		return exitUsage
+	}
+	if opts.maxAmount, err = decimal.NewFromString(*maxAmount); err != nil || opts.maxAmount.LessThan(decimal.New(1, -2)) {
+		fmt.Fprintf(stderr, "--max-amount: %q is not an amount of at least 0.01\n", *maxAmount)
+		return exitUsage
+	}
+	if err := opts.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if opts.firstAccount == 0 {
+		// Every run gets accounts of its own so that earlier runs and other
+		// activity do not skew the totals.
+		opts.firstAccount = time.Now().UnixMilli() * 1000
+	}
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+
+	g := newGenerator(client.New(*apiURL, &http.Client{Timeout: *timeout}), opts)
+	fmt.Fprintf(stderr, "creating %d accounts from %d\n", opts.accounts, opts.firstAccount)
+	if err := g.createAccounts(ctx); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "sending %.0f transfers per second for %s\n", opts.rate, opts.duration)
+	elapsed := g.send(ctx)
+
+	// Verification reads the balances after the load, even when it was cut
+	// short by a signal.
+	verifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	result, err := g.verify(verifyCtx, elapsed)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	result.print(stdout)
+	if len(result.violations) > 0 {
+		return exitViolation
+	}
+	return exitOK
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if value := getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tareqpi/transfer-system/internal/api"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/service"
+)
+
+func runAgainst(t *testing.T, handler http.Handler, args ...string) (int, string, string) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	getenv := func(key string) string {
+		if key == "LOADGEN_API_URL" {
+			return server.URL
+		}
+		return ""
+	}
+	code := run(context.Background(), args, getenv, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// newLedgerServer serves the account and transfer routes of the API over a
+// memory repository.
+func newLedgerServer() http.Handler {
+	gin.SetMode(gin.TestMode)
+	handler := api.NewHandler(service.NewService(repository.NewMemoryRepository()))
+	router := gin.New()
+	router.POST("/api/v1/accounts", handler.CreateAccount)
+	router.POST("/api/v1/transactions", handler.TransferMoney)
+	router.POST("/api/v1/:method", handler.CustomMethod)
+	return router
+}
+
+func TestLoadConservesMoney(t *testing.T) {
+	code, stdout, stderr := runAgainst(t, newLedgerServer(),
+		"--accounts", "12", "--first-account", "100", "--initial-balance", "50", "--max-amount", "20",
+		"--rate", "400", "--duration", "300ms", "--concurrency", "8", "--pairs", "0.5", "--seed", "1")
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d: %s%s", exitOK, code, stdout, stderr)
+	}
+	for _, want := range []string{"accounts     12 (100 to 111)", "succeeded", "total        600 (expected 600)", "result       OK"} {
+		if !strings.Contains(stdout, want) {
+			t.Fatalf("expected output to contain %q, got %s", want, stdout)
+		}
+	}
+}
+
+func TestLoadReportsViolations(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/accounts":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"account_id":1,"balance":"10","status":"active"}`))
+		case "/api/v1/transactions":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"request_id":"r","error":{"code":"insufficient_balance","message":"insufficient balance"}}`))
+		case "/api/v1/accounts:batchGet":
+			_, _ = w.Write([]byte(`{"accounts":[{"account_id":1,"balance":"-1"},{"account_id":2,"balance":"21"}]}`))
+		default:
+			// The handler runs on the server's goroutine, where t.Fatalf
+			// must not be called.
+			t.Errorf("unexpected path %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	})
+
+	code, stdout, _ := runAgainst(t, handler,
+		"--accounts", "2", "--first-account", "1", "--initial-balance", "10", "--rate", "200", "--duration", "50ms", "--seed", "1")
+	if code != exitViolation {
+		t.Fatalf("expected exit code %d, got %d: %s", exitViolation, code, stdout)
+	}
+	for _, want := range []string{"insufficient_balance", "1 accounts have a negative balance: 1", "2 accounts do not match the transfers that succeeded: 1, 2", "result       FAILED"} {
+		if !strings.Contains(stdout, want) {
+			t.Fatalf("expected output to contain %q, got %s", want, stdout)
+		}
+	}
+}
+
+func TestLoadBoundsTotalWhenFeesAreUnknown(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		balances      [2]string
+		expectedCode  int
+		expectedTotal string
+	}{
+		{testName: "short_by_unknown_fees", balances: [2]string{"9", "9"}, expectedCode: exitOK, expectedTotal: "total        18 (expected at most 19)"},
+		{testName: "money_created", balances: [2]string{"10", "10"}, expectedCode: exitViolation, expectedTotal: "total balance is 20, expected at most 19"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			// The first transfer is charged a fee of 1; the others fail with
+			// a 5xx, so their fees are unknown.
+			var transfers atomic.Int32
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v1/accounts":
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"account_id":1,"balance":"10","status":"active"}`))
+				case "/api/v1/transactions":
+					if transfers.Add(1) == 1 {
+						w.WriteHeader(http.StatusCreated)
+						_, _ = w.Write([]byte(`{"transaction_id":1,"source_account_id":1,"destination_account_id":2,"amount":"1","fee":{"account_id":9,"amount":"1","breakdown":[]}}`))
+						return
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_, _ = w.Write([]byte(`{"request_id":"r","error":{"code":"unavailable","message":"unavailable"}}`))
+				case "/api/v1/accounts:batchGet":
+					_, _ = fmt.Fprintf(w, `{"accounts":[{"account_id":1,"balance":%q},{"account_id":2,"balance":%q}]}`, testCase.balances[0], testCase.balances[1])
+				default:
+					t.Errorf("unexpected path %s", r.URL.Path)
+					http.NotFound(w, r)
+				}
+			})
+
+			code, stdout, _ := runAgainst(t, handler,
+				"--accounts", "2", "--first-account", "1", "--initial-balance", "10", "--rate", "200", "--duration", "50ms", "--concurrency", "1", "--seed", "1")
+			if code != testCase.expectedCode {
+				t.Fatalf("expected exit code %d, got %d: %s", testCase.expectedCode, code, stdout)
+			}
+			if !strings.Contains(stdout, testCase.expectedTotal) {
+				t.Fatalf("expected output to contain %q, got %s", testCase.expectedTotal, stdout)
+			}
+		})
+	}
+}
+
+func TestInvalidFlagsExitWithUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"--accounts", "1"},
+		{"--pairs", "2"},
+		{"--max-amount", "0.001"},
+		{"--unknown"},
+	} {
+		code, _, _ := runAgainst(t, http.NotFoundHandler(), args...)
+		if code != exitUsage {
+			t.Fatalf("expected exit code %d for %v, got %d", exitUsage, args, code)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/bulk"
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/service"
+)
+
+const importUsage = `usage: transfer-system import <accounts|transfers> FILE [flags]
+
+Every row of FILE, a .csv or .jsonl file, is validated before any is
+executed. Progress is kept in FILE.import.json and the outcome of every row
+is written to FILE.results.csv; running the same command again resumes an
+import that was interrupted. Remove both files to import FILE again.
+
+CSV files start with a header naming their columns:
+  accounts    account_id, initial_balance and optionally tier and tags,
+              separated by spaces
+  transfers   source_account_id, destination_account_id, amount
+
+JSON lines files hold one object per line with the fields of the bodies of
+POST /api/v1/accounts or POST /api/v1/transactions.`
+
+// importCommand imports a file and prints the job as JSON. It exits with 3
+// when the file was rejected or some rows failed.
+func importCommand(args []string) int {
+	if len(args) < 2 || !bulk.IsKind(args[0]) {
+		fmt.Fprintln(os.Stderr, importUsage)
+		return 2
+	}
+	kind, path, args := args[0], args[1], args[2:]
+	format, err := bulk.FormatOf(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	appConfig, ok := loadConfig(args)
+	if !ok {
+		return 2
+	}
+	if err := logger.Init(appConfig.Environment, appConfig.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	files := bulk.Files{Input: path, State: path + ".import.json", Results: path + ".results.csv"}
+	job, err := bulk.LoadJob(files.State)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	case job.Kind != kind:
+		fmt.Fprintf(os.Stderr, "%s holds an import of %s\n", files.State, job.Kind)
+		return 2
+	case job.Finished():
+		fmt.Fprintf(os.Stderr, "%s has already been imported, remove %s to import it again\n", path, files.State)
+		return printImport(job, nil)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	databaseConfig := appConfig.Database
+	databaseConfig.AutoMigrate = false
+	storage, err := repository.Open(ctx, databaseConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer storage.Close()
+
+	applicationService, err := service.NewFromConfig(storage, appConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	importer := bulk.NewImporter(applicationService, int(appConfig.Imports.ChunkSize))
+	if job == nil {
+		actor := "import:" + os.Getenv("USER")
+		if os.Getenv("USER") == "" {
+			actor = "import:" + audit.AnonymousActor
+		}
+		if _, err := importer.Create(files, kind, format, actor); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	job, err = importer.Run(ctx, files, func(job bulk.Job) {
+		fmt.Fprintf(os.Stderr, "%d/%d rows, %d succeeded, %d failed\n", job.Processed, job.Rows, job.Succeeded, job.Failed)
+	})
+	return printImport(job, err)
+}
+
+func printImport(job *bulk.Job, err error) int {
+	if job != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(job); encodeErr != nil {
+			fmt.Fprintln(os.Stderr, encodeErr)
+			return 1
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if job.Status == bulk.StatusRejected || job.Failed > 0 {
+		return 3
+	}
+	return 0
+}
@@ -2,32 +2,211 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/tareqpi/transfer-system/internal/api"
+	"github.com/tareqpi/transfer-system/internal/bulk"
 	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/health"
+	"github.com/tareqpi/transfer-system/internal/interest"
 	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"github.com/tareqpi/transfer-system/internal/reconciliation"
 	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/server"
 	"github.com/tareqpi/transfer-system/internal/service"
+	"github.com/tareqpi/transfer-system/internal/snapshot"
+	"github.com/tareqpi/transfer-system/internal/telemetry"
 	"go.uber.org/zap"
 )
 
+const usage = `usage: transfer-system [command] [flags]
+
+commands:
+  serve          run the HTTP API (default)
+  healthcheck    probe /readyz of a locally running server
+  config print   print the effective configuration with secrets redacted
+  migrate        apply, roll back or inspect database migrations
+  reconcile      check balances against transaction history and print a report
+  import         create accounts or run transfers from a CSV or JSON lines file
+
+Run "transfer-system serve -h" to list configuration flags.`
+
 func main() {
-	appConfig, err := config.Load()
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		os.Exit(serve(args))
+	case "healthcheck":
+		os.Exit(healthcheck(args))
+	case "config":
+		os.Exit(configCommand(args))
+	case "migrate":
+		os.Exit(migrateCommand(args))
+	case "reconcile":
+		os.Exit(reconcileCommand(args))
+	case "import":
+		os.Exit(importCommand(args))
+	case "help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", command, usage)
+		os.Exit(2)
+	}
+}
+
+func loadConfig(args []string) (*config.Config, bool) {
+	appConfig, err := config.Load(args)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+	return appConfig, true
+}
+
+func serve(args []string) int {
+	appConfig, ok := loadConfig(args)
+	if !ok {
+		return 2
 	}
 
-	if err := logger.Init(appConfig.Environment); err != nil {
-		panic(err)
+	if err := logger.Init(appConfig.Environment, appConfig.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
 	defer logger.Sync()
 
-	databasePool, err := repository.Setup(context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := telemetry.Setup(ctx, appConfig.Tracing.Exporter, appConfig.Tracing.ServiceName, appConfig.Environment)
+	if err != nil {
+		logger.L().Error("tracing setup failed", zap.Error(err))
+		return 1
+	}
+
+	storage, err := repository.Open(ctx, appConfig.Database)
 	if err != nil {
-		logger.L().Fatal("repository setup failed", zap.Error(err))
+		logger.L().Error("repository setup failed", zap.Error(err))
+		return 1
+	}
+	// Covers the setup failures below; on a normal run the shutdown hook has
+	// closed it already, and closing again is harmless.
+	defer storage.Close()
+
+	if postgresRepository, ok := storage.(*repository.PGRepository); ok {
+		if err := metrics.Register(metrics.NewPoolCollector(postgresRepository.Pool())); err != nil {
+			logger.L().Error("metrics setup failed", zap.Error(err))
+			return 1
+		}
 	}
 
-	postgresRepository := repository.NewPGRepository(databasePool)
-	applicationService := service.NewService(postgresRepository)
-	api.Setup(applicationService)
+	applicationService, err := service.NewFromConfig(storage, appConfig)
+	if err != nil {
+		logger.L().Error("service setup failed", zap.Error(err))
+		return 1
+	}
+
+	checker := health.NewChecker(2 * time.Second)
+	checker.Register("database", storage.Ping)
+	checker.Register("migrations", storage.CheckSchema)
+
+	reconciliationJob := reconciliation.NewJob(storage, appConfig.Reconciliation.ReportDir)
+	var importManager *bulk.Manager
+	if appConfig.Imports.Dir != "" {
+		importManager = bulk.NewManager(appConfig.Imports.Dir, bulk.NewImporter(applicationService, int(appConfig.Imports.ChunkSize)))
+	}
+	var interestJob *interest.Job
+	if appConfig.Interest.ExpenseAccountID != 0 {
+		interestJob = interest.NewJob(storage, interest.Options{
+			ExpenseAccountID: appConfig.Interest.ExpenseAccountID,
+			DayCount:         appConfig.Interest.DayCount,
+		})
+	}
+
+	httpServer := server.New(server.Options{
+		Addr:              appConfig.ListenAddr(),
+		Handler:           api.NewRouter(applicationService, checker, api.NewAdminHandler(applicationService, reconciliationJob, interestJob, importManager)),
+		Checker:           checker,
+		DrainTimeout:      appConfig.Server.DrainTimeout,
+		ShutdownDelay:     appConfig.Server.ShutdownDelay,
+		ReadTimeout:       appConfig.Server.ReadTimeout,
+		ReadHeaderTimeout: appConfig.Server.ReadHeaderTimeout,
+		WriteTimeout:      appConfig.Server.WriteTimeout,
+		IdleTimeout:       appConfig.Server.IdleTimeout,
+	})
+	if appConfig.Reconciliation.Interval > 0 {
+		httpServer.AddWorker("reconciliation", reconciliationJob.Schedule(appConfig.Reconciliation.Interval))
+	}
+	if interestJob != nil && appConfig.Interest.Interval > 0 {
+		httpServer.AddWorker("interest", interestJob.Schedule(appConfig.Interest.Interval))
+	}
+	if appConfig.Snapshots.Interval > 0 {
+		httpServer.AddWorker("balance snapshots", snapshot.NewJob(storage).Schedule(appConfig.Snapshots.Interval))
+	}
+	if importManager != nil {
+		httpServer.AddWorker("imports", importManager.Run)
+	}
+	if postgresRepository, ok := storage.(*repository.PGRepository); ok && appConfig.Database.ShardConsolidationInterval > 0 {
+		httpServer.AddWorker("shard consolidation", postgresRepository.ShardConsolidationWorker(appConfig.Database.ShardConsolidationInterval))
+	}
+	httpServer.OnShutdown("tracing", func(ctx context.Context) error { return shutdownTracing(ctx) })
+	httpServer.OnShutdown("database", func(context.Context) error {
+		storage.Close()
+		return nil
+	})
+
+	if err := httpServer.Run(ctx); err != nil {
+		logger.L().Error("server stopped with error", zap.Error(err))
+		return 1
+	}
+	return 0
+}
+
+// healthcheck probes the readiness endpoint of a locally running server. It is
+// used by container healthchecks since the runtime image ships without curl.
+func healthcheck(args []string) int {
+	appConfig, ok := loadConfig(args)
+	if !ok {
+		return 2
+	}
+
+	client := http.Client{Timeout: 3 * time.Second}
+	response, err := client.Get("http://127.0.0.1:" + appConfig.Server.Port + "/readyz")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		fmt.Fprintln(os.Stderr, "readiness check returned", response.Status)
+		return 1
+	}
+	return 0
+}
+
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintf(os.Stderr, "usage: transfer-system config print [flags]\n")
+		return 2
+	}
+	appConfig, ok := loadConfig(args[1:])
+	if !ok {
+		return 2
+	}
+	if err := appConfig.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+const migrateUsage = `usage: transfer-system migrate <action> [flags]
+
+actions:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  status      show the current and expected schema version
+  force V     set the schema version to V and clear the dirty flag`
+
+func migrateCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	action, args := args[0], args[1:]
+	var number int
+	if action == "down" || action == "force" {
+		if len(args) == 0 {
+			fmt.Fprintf(os.Stderr, "migrate %s requires a number\n\n%s\n", action, migrateUsage)
+			return 2
+		}
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate %s: %q is not a number\n", action, args[0])
+			return 2
+		}
+		number, args = parsed, args[1:]
+	}
+
+	appConfig, ok := loadConfig(args)
+	if !ok {
+		return 2
+	}
+	if err := logger.Init(appConfig.Environment, appConfig.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	databaseURL := appConfig.Database.URL
+	var err error
+	switch action {
+	case "up":
+		err = repository.MigrateUp(databaseURL)
+	case "down":
+		err = repository.MigrateDown(databaseURL, number)
+	case "force":
+		err = repository.ForceMigrationVersion(databaseURL, number)
+	case "status":
+		err = printMigrationStatus(databaseURL)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate action %q\n\n%s\n", action, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate", action+":", err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(databaseURL string) error {
+	status, err := repository.GetMigrationStatus(databaseURL)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("current version:  %d\n", status.Version)
+	fmt.Printf("expected version: %d\n", status.Expected)
+	fmt.Printf("dirty:            %t\n", status.Dirty)
+	if len(status.Pending) == 0 {
+		fmt.Println("pending:          none")
+	} else {
+		fmt.Printf("pending:          %v\n", status.Pending)
+	}
+	return nil
+}
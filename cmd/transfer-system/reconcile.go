@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/reconciliation"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+// reconcileCommand runs a single reconciliation and prints the report as
+// JSON. It exits with 3 when discrepancies were found so that scripts and
+// cron jobs can alert on the exit code alone.
+func reconcileCommand(args []string) int {
+	appConfig, ok := loadConfig(args)
+	if !ok {
+		return 2
+	}
+	if err := logger.Init(appConfig.Environment, appConfig.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	databaseConfig := appConfig.Database
+	databaseConfig.AutoMigrate = false
+	storage, err := repository.Open(ctx, databaseConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer storage.Close()
+
+	report, err := reconciliation.NewJob(storage, appConfig.Reconciliation.ReportDir).Run(ctx)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(report); encodeErr != nil {
+		fmt.Fprintln(os.Stderr, encodeErr)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if report.Status == reconciliation.StatusDiscrepancies {
+		return 3
+	}
+	return 0
+}
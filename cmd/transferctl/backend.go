@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/client"
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/service"
+)
+
+// backend is implemented by the REST client and, in admin mode, by a service
+// wired directly to the database.
+type backend interface {
+	CreateAccount(ctx context.Context, accountID int64, initialBalance decimal.Decimal) (*domain.Account, error)
+	GetAccount(ctx context.Context, accountID int64) (*domain.Account, error)
+	ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, int64, error)
+	TransferMoney(ctx context.Context, sourceAccountID, destinationAccountID int64, amount decimal.Decimal) (*domain.Transaction, error)
+	ReverseTransaction(ctx context.Context, transactionID int64) (*domain.Transaction, error)
+	FreezeAccount(ctx context.Context, accountID int64) (*domain.Account, error)
+	UnfreezeAccount(ctx context.Context, accountID int64) (*domain.Account, error)
+}
+
+var _ backend = (*client.Client)(nil)
+
+type databaseBackend struct {
+	storage repository.Backend
+	service service.Service
+}
+
+// newDatabaseBackend connects to the database without running migrations; an
+// operator tool must never change the schema as a side effect. The service is
+// built from appConfig as the server builds it, so that direct transfers pay
+// the same fees and pass the same risk rules, precision policy and funding
+// limits as those made through the API.
+func newDatabaseBackend(ctx context.Context, appConfig *config.Config) (*databaseBackend, error) {
+	databaseConfig := appConfig.Database
+	databaseConfig.MaxConns = 2
+	databaseConfig.MinConns = 0
+	databaseConfig.AutoMigrate = false
+
+	storage, err := repository.Open(ctx, databaseConfig)
+	if err != nil {
+		return nil, err
+	}
+	applicationService, err := service.NewFromConfig(storage, appConfig)
+	if err != nil {
+		storage.Close()
+		return nil, err
+	}
+	return &databaseBackend{storage: storage, service: applicationService}, nil
+}
+
+func (b *databaseBackend) Close() {
+	b.storage.Close()
+}
+
+func (b *databaseBackend) CreateAccount(ctx context.Context, accountID int64, initialBalance decimal.Decimal) (*domain.Account, error) {
+	return b.service.CreateAccount(ctx, domain.Account{ID: accountID, Balance: initialBalance})
+}
+
+func (b *databaseBackend) GetAccount(ctx context.Context, accountID int64) (*domain.Account, error) {
+	return b.service.GetAccount(ctx, strconv.FormatInt(accountID, 10))
+}
+
+func (b *databaseBackend) ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, int64, error) {
+	transactions, err := b.service.ListTransactions(ctx, accountID, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = service.DefaultPageSize
+	}
+	limit = min(limit, service.MaxPageSize)
+	var next int64
+	if len(transactions) == limit {
+		next = transactions[len(transactions)-1].ID
+	}
+	return transactions, next, nil
+}
+
+func (b *databaseBackend) TransferMoney(ctx context.Context, sourceAccountID, destinationAccountID int64, amount decimal.Decimal) (*domain.Transaction, error) {
+	return b.service.TransferMoney(ctx, domain.Transaction{
+		SourceAccountID:      sourceAccountID,
+		DestinationAccountID: destinationAccountID,
+		Amount:               amount,
+	})
+}
+
+func (b *databaseBackend) ReverseTransaction(ctx context.Context, transactionID int64) (*domain.Transaction, error) {
+	return b.service.ReverseTransaction(ctx, transactionID)
+}
+
+func (b *databaseBackend) FreezeAccount(ctx context.Context, accountID int64) (*domain.Account, error) {
+	return b.service.FreezeAccount(ctx, accountID, 0)
+}
+
+func (b *databaseBackend) UnfreezeAccount(ctx context.Context, accountID int64) (*domain.Account, error) {
+	return b.service.UnfreezeAccount(ctx, accountID, 0)
+}
+
+// exitCode maps API and service errors onto the documented exit codes so
+// that scripts can tell a missing account from a rejected transfer.
+func exitCode(err error) int {
+	var usage usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+
+	var apiError *client.APIError
+	if errors.As(err, &apiError) {
+		switch {
+		case apiError.StatusCode == http.StatusNotFound:
+			return exitNotFound
+		case apiError.StatusCode >= 500:
+			return exitUnavailable
+		case apiError.StatusCode >= 400:
+			return exitRejected
+		}
+		return exitError
+	}
+
+	switch {
+	case errors.Is(err, service.ErrAccountNotFound),
+		errors.Is(err, service.ErrTransactionNotFound):
+		return exitNotFound
+	case errors.Is(err, service.ErrSameSourceAndDestination),
+		errors.Is(err, service.ErrNonPositiveAmount),
+		errors.Is(err, service.ErrInvalidAccountIDs),
+		errors.Is(err, service.ErrInsufficientBalance),
+		errors.Is(err, service.ErrAccountExists),
+		errors.Is(err, service.ErrAccountFrozen),
+		errors.Is(err, service.ErrAlreadyReversed),
+		errors.Is(err, service.ErrNotReversible),
+		errors.Is(err, service.ErrTransferDenied):
+		return exitRejected
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) || errors.Is(err, context.DeadlineExceeded) {
+		return exitUnavailable
+	}
+	return exitError
+}
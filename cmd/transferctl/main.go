@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/client"
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitRejected    = 4
+	exitUnavailable = 5
+)
+
+const usage = `usage: transferctl [global flags] <command> [flags]
+
+commands:
+  accounts create --id ID --balance AMOUNT   create an account
+  accounts get ID                            show an account and its balance
+  balance ID                                 alias for "accounts get"
+  history ID [--limit N] [--before TXID]     list an account's transactions, newest first
+  transfer --from ID --to ID --amount AMOUNT move money between accounts
+  reverse TXID                               reverse a transaction
+  freeze ID                                  reject transfers touching the account
+  unfreeze ID                                allow transfers again
+  export ID [--format csv|json] [--out FILE] write an account's full history
+
+global flags:
+  --api-url URL        REST API base URL (env TRANSFERCTL_API_URL, default http://localhost:9000)
+  --database-url URL   talk to the database directly instead of the API, postgres://, mysql:// or sqlite:// (env TRANSFERCTL_DATABASE_URL)
+  --config FILE        server configuration whose fees, risk rules, precision and funding limits apply
+                       with --database-url, as the server reads it along with its environment (env CONFIG_FILE)
+  --output table|json  output format (default table)
+  --timeout DURATION   per-command timeout (default 30s)
+
+exit codes:
+  0 success, 1 error, 2 usage, 3 not found, 4 rejected, 5 unavailable`
+
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{message: fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Getenv, os.Stdout, os.Stderr))
+}
+
+func run(args []string, getenv func(string) string, stdout, stderr io.Writer) int {
+	globals := flag.NewFlagSet("transferctl", flag.ContinueOnError)
+	globals.SetOutput(io.Discard)
+	apiURL := globals.String("api-url", envOr(getenv, "TRANSFERCTL_API_URL", "http://localhost:9000"), "")
+	databaseURL := globals.String("database-url", getenv("TRANSFERCTL_DATABASE_URL"), "")
+	configFile := globals.String("config", "", "")
+	outputFormat := globals.String("output", "table", "")
+	timeout := globals.Duration("timeout", 30*time.Second, "")
+	if err := globals.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(stdout, usage)
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "%v\n\n%s\n", err, usage)
+		return exitUsage
+	}
+	if globals.NArg() == 0 {
+		fmt.Fprintln(stderr, usage)
+		return exitUsage
+	}
+	if *outputFormat != "table" && *outputFormat != "json" {
+		fmt.Fprintf(stderr, "--output: %q is not one of table, json\n", *outputFormat)
+		return exitUsage
+	}
+	command, commandArgs := globals.Arg(0), globals.Args()[1:]
+	if command == "help" {
+		fmt.Fprintln(stdout, usage)
+		return exitOK
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var target backend
+	if *databaseURL != "" {
+		configArgs := []string{"--database.url", *databaseURL}
+		if *configFile != "" {
+			configArgs = append(configArgs, "--config", *configFile)
+		}
+		appConfig, err := config.Load(configArgs)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		databaseBackend, err := newDatabaseBackend(ctx, appConfig)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUnavailable
+		}
+		defer databaseBackend.Close()
+		target = databaseBackend
+		// There is no gateway in front of direct database access, so the audit
+		// log attributes changes to the operator's login instead.
+		ctx = audit.WithMetadata(ctx, audit.Metadata{Actor: "transferctl:" + envOr(getenv, "USER", audit.AnonymousActor)})
+	} else {
+		target = client.New(*apiURL, &http.Client{Timeout: *timeout})
+	}
+
+	cli := &cli{backend: target, out: newPrinter(stdout, *outputFormat)}
+	if err := cli.dispatch(ctx, command, commandArgs); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if value := getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+type cli struct {
+	backend backend
+	out     *printer
+}
+
+func (c *cli) dispatch(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "accounts":
+		if len(args) == 0 {
+			return usagef("usage: transferctl accounts create|get ...")
+		}
+		switch args[0] {
+		case "create":
+			return c.createAccount(ctx, args[1:])
+		case "get":
+			return c.getAccount(ctx, args[1:])
+		}
+		return usagef("unknown accounts command %q", args[0])
+	case "balance":
+		return c.getAccount(ctx, args)
+	case "history":
+		return c.history(ctx, args)
+	case "transfer":
+		return c.transfer(ctx, args)
+	case "reverse":
+		return c.reverse(ctx, args)
+	case "freeze":
+		return c.setStatus(ctx, "freeze", args, c.backend.FreezeAccount)
+	case "unfreeze":
+		return c.setStatus(ctx, "unfreeze", args, c.backend.UnfreezeAccount)
+	case "export":
+		return c.export(ctx, args)
+	}
+	return usagef("unknown command %q; run \"transferctl help\"", command)
+}
+
+func (c *cli) createAccount(ctx context.Context, args []string) error {
+	flags := newFlagSet("accounts create")
+	accountID := flags.Int64("id", 0, "account ID")
+	balance := flags.String("balance", "0", "initial balance")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if *accountID <= 0 {
+		return usagef("accounts create: --id is required")
+	}
+	initialBalance, err := decimal.NewFromString(*balance)
+	if err != nil {
+		return usagef("accounts create: --balance %q is not a number", *balance)
+	}
+
+	account, err := c.backend.CreateAccount(ctx, *accountID, initialBalance)
+	if err != nil {
+		return err
+	}
+	return c.out.account(*account)
+}
+
+func (c *cli) getAccount(ctx context.Context, args []string) error {
+	accountID, err := singleID("accounts get", args)
+	if err != nil {
+		return err
+	}
+	account, err := c.backend.GetAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return c.out.account(*account)
+}
+
+func (c *cli) history(ctx context.Context, args []string) error {
+	flags := newFlagSet("history")
+	limit := flags.Int("limit", 0, "page size")
+	before := flags.Int64("before", 0, "only list transactions older than this transaction ID")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	accountID, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	transactions, next, err := c.backend.ListTransactions(ctx, accountID, domain.TransactionFilter{Limit: *limit, BeforeID: *before})
+	if err != nil {
+		return err
+	}
+	return c.out.transactions(transactions, next)
+}
+
+func (c *cli) transfer(ctx context.Context, args []string) error {
+	flags := newFlagSet("transfer")
+	from := flags.Int64("from", 0, "source account ID")
+	to := flags.Int64("to", 0, "destination account ID")
+	amountValue := flags.String("amount", "", "amount to transfer")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if *from == 0 || *to == 0 || *amountValue == "" {
+		return usagef("transfer: --from, --to and --amount are required")
+	}
+	amount, err := decimal.NewFromString(*amountValue)
+	if err != nil {
+		return usagef("transfer: --amount %q is not a number", *amountValue)
+	}
+
+	transaction, err := c.backend.TransferMoney(ctx, *from, *to, amount)
+	if err != nil {
+		return err
+	}
+	return c.out.transaction(*transaction)
+}
+
+func (c *cli) reverse(ctx context.Context, args []string) error {
+	transactionID, err := singleID("reverse", args)
+	if err != nil {
+		return err
+	}
+	transaction, err := c.backend.ReverseTransaction(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+	return c.out.transaction(*transaction)
+}
+
+func (c *cli) setStatus(ctx context.Context, command string, args []string, update func(context.Context, int64) (*domain.Account, error)) error {
+	accountID, err := singleID(command, args)
+	if err != nil {
+		return err
+	}
+	account, err := update(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return c.out.account(*account)
+}
+
+func (c *cli) export(ctx context.Context, args []string) error {
+	flags := newFlagSet("export")
+	format := flags.String("format", "csv", "csv or json")
+	outPath := flags.String("out", "", "write to this file instead of stdout")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "json" {
+		return usagef("export: --format %q is not one of csv, json", *format)
+	}
+	accountID, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var all []domain.Transaction
+	filter := domain.TransactionFilter{Limit: exportPageSize}
+	for {
+		page, next, err := c.backend.ListTransactions(ctx, accountID, filter)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == 0 {
+			break
+		}
+		filter.BeforeID = next
+	}
+
+	output := c.out.w
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+	if *format == "json" {
+		return writeJSON(output, all)
+	}
+	return writeTransactionsCSV(output, all)
+}
+
+const exportPageSize = 500
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// parseFlags accepts flags before and after the positional arguments so that
+// both "history 1 --limit 5" and "history --limit 5 1" work.
+func parseFlags(flags *flag.FlagSet, args []string, positional int) error {
+	var rest []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return usagef("%s: %v", flags.Name(), err)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		rest = append(rest, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(rest) != positional {
+		return usagef("%s: expected %d positional argument(s), got %d", flags.Name(), positional, len(rest))
+	}
+	return flags.Parse(rest)
+}
+
+func singleID(command string, args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, usagef("usage: transferctl %s ID", command)
+	}
+	return parseID(args[0])
+}
+
+func parseID(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, usagef("%q is not a valid ID", value)
+	}
+	return id, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+func runAgainst(t *testing.T, handler http.HandlerFunc, args ...string) (int, string, string) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	getenv := func(key string) string {
+		if key == "TRANSFERCTL_API_URL" {
+			return server.URL
+		}
+		return ""
+	}
+	code := run(args, getenv, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(`{"request_id":"r","error":{"code":"` + code + `","message":"` + code + `"}}`))
+}
+
+func TestBalancePrintsJSON(t *testing.T) {
+	code, stdout, _ := runAgainst(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/accounts/5" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"account_id":5,"balance":"12.5","status":"active"}`))
+	}, "--output", "json", "balance", "5")
+
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	var account map[string]any
+	if err := json.Unmarshal([]byte(stdout), &account); err != nil {
+		t.Fatalf("expected JSON output, got %q", stdout)
+	}
+	if account["balance"] != "12.5" {
+		t.Fatalf("expected balance 12.5, got %v", account["balance"])
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		code     string
+		args     []string
+		expected int
+	}{
+		{"not found", http.StatusNotFound, "account_not_found", []string{"accounts", "get", "1"}, exitNotFound},
+		{"rejected", http.StatusConflict, "insufficient_balance", []string{"transfer", "--from", "1", "--to", "2", "--amount", "5"}, exitRejected},
+		{"unavailable", http.StatusServiceUnavailable, "unavailable", []string{"reverse", "3"}, exitUnavailable},
+		{"usage", 0, "", []string{"transfer", "--from", "1"}, exitUsage},
+		{"unknown command", 0, "", []string{"launch"}, exitUsage},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			code, _, stderr := runAgainst(t, func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, testCase.status, testCase.code)
+			}, testCase.args...)
+			if code != testCase.expected {
+				t.Fatalf("expected exit code %d, got %d (stderr %q)", testCase.expected, code, stderr)
+			}
+		})
+	}
+}
+
+func TestExportPagesThroughHistory(t *testing.T) {
+	var requests int
+	code, stdout, stderr := runAgainst(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Query().Get("before_id") {
+		case "":
+			_, _ = w.Write([]byte(`{"transactions":[{"transaction_id":3,"source_account_id":1,"destination_account_id":2,"amount":"1"}],"next_before_id":3}`))
+		case "3":
+			_, _ = w.Write([]byte(`{"transactions":[{"transaction_id":1,"type":"reversal","source_account_id":2,"destination_account_id":1,"amount":"4","reversal_of":2}]}`))
+		default:
+			t.Fatalf("unexpected cursor %s", r.URL.RawQuery)
+		}
+	}, "export", "1", "--format", "csv")
+
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr %q)", exitOK, code, stderr)
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header and 2 rows, got %q", stdout)
+	}
+	if !strings.HasPrefix(lines[2], "1,reversal,2,1,4,2,") {
+		t.Fatalf("unexpected CSV row %q", lines[2])
+	}
+}
+
+func TestDatabaseModeAppliesRiskRules(t *testing.T) {
+	dir := t.TempDir()
+	databaseURL := "sqlite://" + filepath.Join(dir, "ledger.db")
+	if err := repository.MigrateUp(databaseURL); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	rulesFile := filepath.Join(dir, "rules.yaml")
+	rules := "rules:\n  - name: huge\n    type: amount_threshold\n    action: deny\n    amount: \"50\"\n"
+	if err := os.WriteFile(rulesFile, []byte(rules), 0o644); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	t.Setenv("RISK_RULES_FILE", rulesFile)
+
+	transferctl := func(args ...string) (int, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"--database-url", databaseURL}, args...), func(string) string { return "" }, &stdout, &stderr)
+		return code, stderr.String()
+	}
+	for _, args := range [][]string{{"accounts", "create", "--id", "1", "--balance", "100"}, {"accounts", "create", "--id", "2", "--balance", "0"}} {
+		if code, stderr := transferctl(args...); code != exitOK {
+			t.Fatalf("expected %v to succeed, got exit code %d: %s", args, code, stderr)
+		}
+	}
+	code, stderr := transferctl("transfer", "--from", "1", "--to", "2", "--amount", "60")
+	if code != exitRejected || !strings.Contains(stderr, "huge") {
+		t.Fatalf("expected the transfer to be denied by the rule, got exit code %d: %s", code, stderr)
+	}
+	if code, stderr := transferctl("transfer", "--from", "1", "--to", "2", "--amount", "40"); code != exitOK {
+		t.Fatalf("expected a transfer below the limit to succeed, got exit code %d: %s", code, stderr)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, format: format}
+}
+
+func (p *printer) account(account domain.Account) error {
+	if p.format == "json" {
+		return writeJSON(p.w, account)
+	}
+	table := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ACCOUNT\tBALANCE\tSTATUS")
+	fmt.Fprintf(table, "%d\t%s\t%s\n", account.ID, account.Balance.String(), account.Status)
+	return table.Flush()
+}
+
+func (p *printer) transaction(transaction domain.Transaction) error {
+	if p.format == "json" {
+		return writeJSON(p.w, transaction)
+	}
+	return p.transactions([]domain.Transaction{transaction}, 0)
+}
+
+func (p *printer) transactions(transactions []domain.Transaction, next int64) error {
+	if p.format == "json" {
+		page := struct {
+			Transactions []domain.Transaction `json:"transactions"`
+			NextBeforeID int64                `json:"next_before_id,omitempty"`
+		}{Transactions: transactions, NextBeforeID: next}
+		if page.Transactions == nil {
+			page.Transactions = []domain.Transaction{}
+		}
+		return writeJSON(p.w, page)
+	}
+	table := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TRANSACTION\tTYPE\tFROM\tTO\tAMOUNT\tREVERSAL_OF\tCREATED_AT")
+	for _, transaction := range transactions {
+		row := transactionRow(transaction)
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", row[0], row[1], row[2], row[3], row[4], row[5], row[6])
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if next != 0 {
+		fmt.Fprintf(p.w, "\nmore results: --before %d\n", next)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func writeTransactionsCSV(w io.Writer, transactions []domain.Transaction) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"transaction_id", "type", "source_account_id", "destination_account_id", "amount", "reversal_of", "created_at"}); err != nil {
+		return err
+	}
+	for _, transaction := range transactions {
+		if err := writer.Write(transactionRow(transaction)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func transactionRow(transaction domain.Transaction) []string {
+	reversalOf := ""
+	if transaction.ReversalOf != nil {
+		reversalOf = strconv.FormatInt(*transaction.ReversalOf, 10)
+	}
+	return []string{
+		strconv.FormatInt(transaction.ID, 10),
+		transaction.Type,
+		strconv.FormatInt(transaction.SourceAccountID, 10),
+		strconv.FormatInt(transaction.DestinationAccountID, 10),
+		transaction.Amount.String(),
+		reversalOf,
+		transaction.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,385 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/bulk"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/interest"
+	"github.com/tareqpi/transfer-system/internal/reconciliation"
+	"github.com/tareqpi/transfer-system/internal/service"
+	"go.uber.org/zap"
+)
+
+// AdminHandler serves the /admin endpoints. Interest is nil when no interest
+// expense account is configured and Imports when no imports directory is.
+type AdminHandler struct {
+	Service        service.Service
+	Reconciliation *reconciliation.Job
+	Interest       *interest.Job
+	Imports        *bulk.Manager
+}
+
+func NewAdminHandler(applicationService service.Service, reconciliationJob *reconciliation.Job, interestJob *interest.Job, importManager *bulk.Manager) *AdminHandler {
+	return &AdminHandler{Service: applicationService, Reconciliation: reconciliationJob, Interest: interestJob, Imports: importManager}
+}
+
+func (handler *AdminHandler) LatestReconciliation(c *gin.Context) {
+	report, ok := handler.Reconciliation.Latest()
+	if !ok {
+		NotFound(c, "report_not_found", "no reconciliation has completed yet")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+type SetAccountShardsRequest struct {
+	Shards *int `json:"shards" binding:"required"`
+}
+
+// SetAccountShards splits the balance of a high-volume account across shard
+// rows, or gathers it back into one when shards is 0.
+func (handler *AdminHandler) SetAccountShards(c *gin.Context) {
+	accountID, ok := int64Param(c, "account_id", "invalid_account_ids")
+	if !ok {
+		return
+	}
+	var request SetAccountShardsRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	account, err := handler.Service.SetAccountShards(c.Request.Context(), accountID, *request.Shards, expectedVersion)
+	if err != nil {
+		writeServiceError(c, err, "set account shards failed", zap.Int64("account_id", accountID), zap.Int("shards", *request.Shards))
+		return
+	}
+	setAccountETag(c, account)
+	c.JSON(http.StatusOK, account)
+}
+
+type AuditListResponse struct {
+	Entries      []domain.AuditEntry `json:"entries"`
+	NextBeforeID *int64              `json:"next_before_id,omitempty"`
+}
+
+func (handler *AdminHandler) ListAuditEntries(c *gin.Context) {
+	limit, beforeID, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+	filter := domain.AuditFilter{
+		Actor:     c.Query("actor"),
+		Operation: c.Query("operation"),
+		RequestID: c.Query("request_id"),
+		Limit:     limit,
+		BeforeID:  beforeID,
+	}
+	if value := c.Query("account_id"); value != "" {
+		accountID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || accountID <= 0 {
+			BadRequest(c, "invalid_request", "account_id must be a positive integer")
+			return
+		}
+		filter.AccountID = accountID
+	}
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			BadRequest(c, "invalid_request", bound.name+" must be an RFC 3339 timestamp")
+			return
+		}
+		*bound.target = parsed
+	}
+
+	entries, err := handler.Service.ListAuditEntries(c.Request.Context(), filter)
+	if err != nil {
+		writeServiceError(c, err, "list audit entries failed", zap.Any("filter", filter))
+		return
+	}
+
+	response := AuditListResponse{Entries: entries}
+	if len(entries) == filter.Limit {
+		next := entries[len(entries)-1].ID
+		response.NextBeforeID = &next
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type FeeRuleListResponse struct {
+	Rules []domain.FeeRule `json:"rules"`
+}
+
+func (handler *AdminHandler) ListFeeRules(c *gin.Context) {
+	rules, err := handler.Service.ListFeeRules(c.Request.Context())
+	if err != nil {
+		writeServiceError(c, err, "list fee rules failed")
+		return
+	}
+	c.JSON(http.StatusOK, FeeRuleListResponse{Rules: rules})
+}
+
+func (handler *AdminHandler) CreateFeeRule(c *gin.Context) {
+	var rule domain.FeeRule
+	if !bindJSON(c, &rule) {
+		return
+	}
+	rule.ID = 0
+
+	created, err := handler.Service.CreateFeeRule(c.Request.Context(), rule)
+	if err != nil {
+		writeServiceError(c, err, "create fee rule failed", zap.String("name", rule.Name))
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateFeeRule replaces a fee rule with the one in the body.
+func (handler *AdminHandler) UpdateFeeRule(c *gin.Context) {
+	ruleID, ok := int64Param(c, "rule_id", "invalid_request")
+	if !ok {
+		return
+	}
+	var rule domain.FeeRule
+	if !bindJSON(c, &rule) {
+		return
+	}
+	rule.ID = ruleID
+
+	updated, err := handler.Service.UpdateFeeRule(c.Request.Context(), rule)
+	if err != nil {
+		writeServiceError(c, err, "update fee rule failed", zap.Int64("rule_id", ruleID))
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (handler *AdminHandler) DeleteFeeRule(c *gin.Context) {
+	ruleID, ok := int64Param(c, "rule_id", "invalid_request")
+	if !ok {
+		return
+	}
+	if err := handler.Service.DeleteFeeRule(c.Request.Context(), ruleID); err != nil {
+		writeServiceError(c, err, "delete fee rule failed", zap.Int64("rule_id", ruleID))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type SetInterestRateRequest struct {
+	AnnualRate *decimal.Decimal `json:"annual_rate" binding:"required"`
+}
+
+// SetInterestRate sets the annual rate, in percent, an account earns from the
+// next accrual on. A rate of 0 stops the account from earning interest.
+func (handler *AdminHandler) SetInterestRate(c *gin.Context) {
+	accountID, ok := int64Param(c, "account_id", "invalid_account_ids")
+	if !ok {
+		return
+	}
+	var request SetInterestRateRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	rate, err := handler.Service.SetInterestRate(c.Request.Context(), accountID, *request.AnnualRate)
+	if err != nil {
+		writeServiceError(c, err, "set interest rate failed", zap.Int64("account_id", accountID), zap.Stringer("annual_rate", request.AnnualRate))
+		return
+	}
+	c.JSON(http.StatusOK, rate)
+}
+
+type InterestRateListResponse struct {
+	Rates []domain.InterestRate `json:"rates"`
+}
+
+func (handler *AdminHandler) ListInterestRates(c *gin.Context) {
+	rates, err := handler.Service.ListInterestRates(c.Request.Context())
+	if err != nil {
+		writeServiceError(c, err, "list interest rates failed")
+		return
+	}
+	c.JSON(http.StatusOK, InterestRateListResponse{Rates: rates})
+}
+
+func (handler *AdminHandler) InterestReport(c *gin.Context) {
+	var filter domain.InterestAccrualFilter
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		parsed, err := time.Parse(time.DateOnly, c.Query(bound.name))
+		if err != nil {
+			BadRequest(c, "invalid_request", bound.name+" must be a date such as 2026-01-31")
+			return
+		}
+		*bound.target = parsed
+	}
+	if value := c.Query("account_id"); value != "" {
+		accountID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || accountID <= 0 {
+			BadRequest(c, "invalid_request", "account_id must be a positive integer")
+			return
+		}
+		filter.AccountID = accountID
+	}
+
+	report, err := handler.Service.InterestReport(c.Request.Context(), filter)
+	if err != nil {
+		writeServiceError(c, err, "interest report failed", zap.Any("filter", filter))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+type RunInterestRequest struct {
+	Date string `json:"date" binding:"required"`
+}
+
+// RunInterest accrues interest for a day that is over, posting it when the
+// day ends a month. Days that have already run record and post nothing.
+func (handler *AdminHandler) RunInterest(c *gin.Context) {
+	if handler.Interest == nil {
+		WriteError(c, http.StatusNotImplemented, "interest_disabled", "no interest expense account is configured")
+		return
+	}
+	var request RunInterestRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	date, err := time.Parse(time.DateOnly, request.Date)
+	if err != nil {
+		BadRequest(c, "invalid_request", "date must be a date such as 2026-01-31")
+		return
+	}
+
+	result, err := handler.Interest.Run(c.Request.Context(), date)
+	if err != nil {
+		if errors.Is(err, interest.ErrDayNotOver) {
+			BadRequest(c, "day_not_over", err.Error())
+			return
+		}
+		writeServiceError(c, err, "interest run failed", zap.String("date", request.Date))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateImport stores the body as a file of account creations or transfers
+// and queues its import, which runs in the background. The format defaults to
+// the one of the Content-Type.
+func (handler *AdminHandler) CreateImport(c *gin.Context) {
+	if !handler.importsEnabled(c) {
+		return
+	}
+	format := c.Query("format")
+	if format == "" {
+		switch c.ContentType() {
+		case "text/csv":
+			format = bulk.FormatCSV
+		case "application/jsonl", "application/x-ndjson":
+			format = bulk.FormatJSONL
+		}
+	}
+
+	job, err := handler.Imports.Submit(c.Query("kind"), format, audit.FromContext(c.Request.Context()).Actor, c.Request.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			RequestTooLarge(c, err.Error())
+			return
+		}
+		writeImportError(c, err, "create import failed", zap.String("kind", c.Query("kind")))
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (handler *AdminHandler) GetImport(c *gin.Context) {
+	if !handler.importsEnabled(c) {
+		return
+	}
+	job, err := handler.Imports.Job(c.Param("import_id"))
+	if err != nil {
+		writeImportError(c, err, "get import failed", zap.String("import_id", c.Param("import_id")))
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// GetImportResults returns the CSV file with the outcome of every row run so
+// far.
+func (handler *AdminHandler) GetImportResults(c *gin.Context) {
+	if !handler.importsEnabled(c) {
+		return
+	}
+	path, err := handler.Imports.ResultsPath(c.Param("import_id"))
+	if err != nil {
+		writeImportError(c, err, "get import results failed", zap.String("import_id", c.Param("import_id")))
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.File(path)
+}
+
+// ResumeImport queues an import that was interrupted.
+func (handler *AdminHandler) ResumeImport(c *gin.Context) {
+	if !handler.importsEnabled(c) {
+		return
+	}
+	job, err := handler.Imports.Resume(c.Param("import_id"))
+	if err != nil {
+		writeImportError(c, err, "resume import failed", zap.String("import_id", c.Param("import_id")))
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (handler *AdminHandler) importsEnabled(c *gin.Context) bool {
+	if handler.Imports == nil {
+		WriteError(c, http.StatusNotImplemented, "imports_disabled", "no imports directory is configured")
+		return false
+	}
+	return true
+}
+
+func writeImportError(c *gin.Context, err error, message string, fields ...zap.Field) {
+	switch {
+	case errors.Is(err, bulk.ErrUnknownKind), errors.Is(err, bulk.ErrUnknownFormat):
+		BadRequest(c, "invalid_request", err.Error())
+	case errors.Is(err, bulk.ErrJobNotFound):
+		NotFound(c, "import_not_found", err.Error())
+	case errors.Is(err, bulk.ErrNoResults):
+		NotFound(c, "results_not_found", err.Error())
+	case errors.Is(err, bulk.ErrJobActive):
+		Conflict(c, "import_active", err.Error())
+	case errors.Is(err, bulk.ErrJobFinished):
+		Conflict(c, "import_finished", err.Error())
+	default:
+		writeServiceError(c, err, message, fields...)
+	}
+}
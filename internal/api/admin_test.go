@@ -0,0 +1,590 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/bulk"
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/interest"
+	"github.com/tareqpi/transfer-system/internal/reconciliation"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/service"
+)
+
+const testAdminToken = "admin-secret"
+
+func newAdminRouter(token string, applicationService service.Service, job *reconciliation.Job) *gin.Engine {
+	return newAdminRouterWithInterest(token, applicationService, job, nil)
+}
+
+func newAdminRouterWithInterest(token string, applicationService service.Service, job *reconciliation.Job, interestJob *interest.Job) *gin.Engine {
+	return newAdminRouterWithJobs(token, applicationService, job, interestJob, nil)
+}
+
+func newAdminRouterWithJobs(token string, applicationService service.Service, job *reconciliation.Job, interestJob *interest.Job, importManager *bulk.Manager) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Audit(nil), Recovery())
+	handler := NewAdminHandler(applicationService, job, interestJob, importManager)
+	admin := router.Group("/admin", AdminAuth(token))
+	admin.GET("/reconciliation/latest", handler.LatestReconciliation)
+	admin.GET("/audit", handler.ListAuditEntries)
+	admin.PUT("/accounts/:account_id/shards", handler.SetAccountShards)
+	admin.GET("/fees/rules", handler.ListFeeRules)
+	admin.POST("/fees/rules", handler.CreateFeeRule)
+	admin.PUT("/fees/rules/:rule_id", handler.UpdateFeeRule)
+	admin.DELETE("/fees/rules/:rule_id", handler.DeleteFeeRule)
+	admin.PUT("/accounts/:account_id/interest", handler.SetInterestRate)
+	admin.GET("/interest/rates", handler.ListInterestRates)
+	admin.GET("/interest/report", handler.InterestReport)
+	admin.POST("/interest/runs", handler.RunInterest)
+	admin.POST("/imports", handler.CreateImport)
+	admin.GET("/imports/:import_id", handler.GetImport)
+	admin.GET("/imports/:import_id/results", handler.GetImportResults)
+	admin.POST("/imports/:import_id/resume", handler.ResumeImport)
+	return router
+}
+
+func TestAdminAuth(t *testing.T) {
+	testCases := []struct {
+		testName           string
+		token              string
+		authorization      string
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"disabled_without_token", "", "Bearer " + testAdminToken, http.StatusForbidden, "admin_disabled"},
+		{"missing_header", testAdminToken, "", http.StatusUnauthorized, "unauthorized"},
+		{"wrong_token", testAdminToken, "Bearer wrong", http.StatusUnauthorized, "unauthorized"},
+		{"wrong_scheme", testAdminToken, "Basic " + testAdminToken, http.StatusUnauthorized, "unauthorized"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			router := newAdminRouter(testCase.token, fakeService{}, reconciliation.NewJob(repository.NewMemoryRepository(), ""))
+			request := httptest.NewRequest(http.MethodGet, "/admin/reconciliation/latest", nil)
+			if testCase.authorization != "" {
+				request.Header.Set("Authorization", testCase.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatusCode, recorder.Code)
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedCode {
+				t.Fatalf("expected error code %q, got %q", testCase.expectedCode, response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestLatestReconciliation(t *testing.T) {
+	job := reconciliation.NewJob(repository.NewMemoryRepository(), "")
+	router := newAdminRouter(testAdminToken, fakeService{}, job)
+
+	get := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/admin/reconciliation/latest", nil)
+		request.Header.Set("Authorization", "Bearer "+testAdminToken)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := get()
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d before the first run, got %d", http.StatusNotFound, recorder.Code)
+	}
+
+	if _, err := job.Run(context.Background()); err != nil {
+		t.Fatalf("run reconciliation: %v", err)
+	}
+	recorder = get()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var report reconciliation.Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Status != reconciliation.StatusOK {
+		t.Fatalf("expected status %q, got %q", reconciliation.StatusOK, report.Status)
+	}
+}
+
+func TestSetAccountShards(t *testing.T) {
+	testCases := []struct {
+		testName           string
+		path               string
+		body               string
+		serviceErr         error
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"enable", "/admin/accounts/7/shards", `{"shards": 8}`, nil, http.StatusOK, ""},
+		{"disable", "/admin/accounts/7/shards", `{"shards": 0}`, nil, http.StatusOK, ""},
+		{"missing_shards", "/admin/accounts/7/shards", `{}`, nil, http.StatusBadRequest, "invalid_request"},
+		{"invalid_account", "/admin/accounts/abc/shards", `{"shards": 8}`, nil, http.StatusBadRequest, "invalid_account_ids"},
+		{"invalid_count", "/admin/accounts/7/shards", `{"shards": 1000}`, service.ErrInvalidShardCount, http.StatusBadRequest, "invalid_shard_count"},
+		{"unsupported", "/admin/accounts/7/shards", `{"shards": 8}`, service.ErrShardingUnsupported, http.StatusNotImplemented, "sharding_unsupported"},
+		{"not_found", "/admin/accounts/7/shards", `{"shards": 8}`, service.ErrAccountNotFound, http.StatusNotFound, "account_not_found"},
+		{"stale_version", "/admin/accounts/7/shards", `{"shards": 8}`, service.ErrVersionMismatch, http.StatusPreconditionFailed, "precondition_failed"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			applicationService := fakeService{setAccountShardsFunc: func(accountID int64, shards int, expectedVersion int64) (*domain.Account, error) {
+				if testCase.serviceErr != nil {
+					return nil, testCase.serviceErr
+				}
+				return &domain.Account{ID: accountID, Balance: decimal.RequireFromString("100"), Status: domain.AccountStatusActive, Shards: shards}, nil
+			}}
+			router := newAdminRouter(testAdminToken, applicationService, reconciliation.NewJob(repository.NewMemoryRepository(), ""))
+
+			request := httptest.NewRequest(http.MethodPut, testCase.path, strings.NewReader(testCase.body))
+			request.Header.Set("Authorization", "Bearer "+testAdminToken)
+			request.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if testCase.expectedCode == "" {
+				var account domain.Account
+				if err := json.Unmarshal(recorder.Body.Bytes(), &account); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if account.ID != 7 || !account.Balance.Equal(decimal.RequireFromString("100")) {
+					t.Fatalf("expected account 7 with balance 100, got %+v", account)
+				}
+				return
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedCode {
+				t.Fatalf("expected error code %q, got %q", testCase.expectedCode, response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestListAuditEntries(t *testing.T) {
+	var gotFilter domain.AuditFilter
+	applicationService := fakeService{listAuditEntriesFunc: func(filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+		gotFilter = filter
+		entries := make([]domain.AuditEntry, 0, filter.Limit)
+		for id := int64(10); id > 10-int64(filter.Limit); id-- {
+			entries = append(entries, domain.AuditEntry{ID: id, Actor: filter.Actor, Operation: domain.AuditOperationTransfer, AccountID: filter.AccountID})
+		}
+		return entries, nil
+	}}
+	router := newAdminRouter(testAdminToken, applicationService, reconciliation.NewJob(repository.NewMemoryRepository(), ""))
+
+	request := httptest.NewRequest(http.MethodGet, "/admin/audit?actor=alice&account_id=7&since=2026-01-01T00:00:00Z&limit=2&before_id=11", nil)
+	request.Header.Set("Authorization", "Bearer "+testAdminToken)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	expectedSince := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if gotFilter.Actor != "alice" || gotFilter.AccountID != 7 || !gotFilter.Since.Equal(expectedSince) || gotFilter.Limit != 2 || gotFilter.BeforeID != 11 {
+		t.Fatalf("unexpected filter %+v", gotFilter)
+	}
+	var response AuditListResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(response.Entries) != 2 || response.NextBeforeID == nil || *response.NextBeforeID != 9 {
+		t.Fatalf("expected 2 entries and next_before_id 9, got %+v", response)
+	}
+}
+
+func TestListAuditEntries_InvalidQuery(t *testing.T) {
+	router := newAdminRouter(testAdminToken, fakeService{}, reconciliation.NewJob(repository.NewMemoryRepository(), ""))
+
+	for _, query := range []string{"account_id=abc", "account_id=0", "since=yesterday", "until=2026-13-01", "limit=0", "before_id=-1"} {
+		t.Run(query, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/admin/audit?"+query, nil)
+			request.Header.Set("Authorization", "Bearer "+testAdminToken)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestFeeRules(t *testing.T) {
+	applicationService := service.NewService(repository.NewMemoryRepository())
+	router := newAdminRouter(testAdminToken, applicationService, reconciliation.NewJob(repository.NewMemoryRepository(), ""))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+testAdminToken)
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := send(http.MethodPost, "/admin/fees/rules", `{"name": "standard", "kind": "percentage", "percent": "0.5", "min_fee": "1"}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, recorder.Code, recorder.Body.String())
+	}
+	var created domain.FeeRule
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if created.ID == 0 || created.Percent == nil || !created.Percent.Equal(decimal.RequireFromString("0.5")) {
+		t.Fatalf("unexpected rule %+v", created)
+	}
+
+	path := "/admin/fees/rules/" + strconv.FormatInt(created.ID, 10)
+	if recorder := send(http.MethodPut, path, `{"name": "standard", "kind": "flat", "amount": "2"}`); recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+
+	recorder = send(http.MethodGet, "/admin/fees/rules", "")
+	var list FeeRuleListResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(list.Rules) != 1 || list.Rules[0].Kind != domain.FeeKindFlat {
+		t.Fatalf("expected the updated flat rule, got %+v", list.Rules)
+	}
+
+	if recorder := send(http.MethodDelete, path, ""); recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+
+	testCases := []struct {
+		testName           string
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"invalid_rule", http.MethodPost, "/admin/fees/rules", `{"name": "x", "kind": "flat"}`, http.StatusBadRequest, "invalid_fee_rule"},
+		{"deleted_rule", http.MethodDelete, path, "", http.StatusNotFound, "fee_rule_not_found"},
+		{"update_missing", http.MethodPut, path, `{"name": "x", "kind": "flat", "amount": "1"}`, http.StatusNotFound, "fee_rule_not_found"},
+		{"invalid_id", http.MethodDelete, "/admin/fees/rules/abc", "", http.StatusBadRequest, "invalid_request"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			recorder := send(testCase.method, testCase.path, testCase.body)
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedCode {
+				t.Fatalf("expected error code %q, got %q", testCase.expectedCode, response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestAuditMetadata(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		path          string
+		remoteIP      string
+		actor         string
+		expectedActor string
+	}{
+		{"anonymous_api_call", "/api/v1/ping", "10.0.0.2", "", audit.AnonymousActor},
+		{"named_api_call", "/api/v1/ping", "10.0.0.2", "alice", "alice"},
+		{"spoofed_api_call", "/api/v1/ping", "203.0.113.7", "alice", audit.AnonymousActor},
+		{"admin_call", "/admin/ping", "203.0.113.7", "", adminActor},
+		{"named_admin_call", "/admin/ping", "203.0.113.7", "bob", "bob"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Audit([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}))
+	var got audit.Metadata
+	capture := func(c *gin.Context) {
+		got = audit.FromContext(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	}
+	router.GET("/api/v1/ping", capture)
+	router.Group("/admin", AdminAuth(testAdminToken)).GET("/ping", capture)
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, testCase.path, nil)
+			request.Header.Set("Authorization", "Bearer "+testAdminToken)
+			request.Header.Set(headerRequestID, "req-1")
+			if testCase.actor != "" {
+				request.Header.Set(headerActor, testCase.actor)
+			}
+			request.RemoteAddr = testCase.remoteIP + ":5000"
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			if got.Actor != testCase.expectedActor || got.RequestID != "req-1" || got.ClientIP != testCase.remoteIP {
+				t.Fatalf("expected actor %q, request req-1 and client %s, got %+v", testCase.expectedActor, testCase.remoteIP, got)
+			}
+		})
+	}
+}
+
+func TestNewRouter_TrustedProxies(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		trustedProxies string
+		remoteIP       string
+		expectedIP     string
+	}{
+		{"untrusted_peer", "10.0.0.0/8", "203.0.113.7", "203.0.113.7"},
+		{"trusted_peer", "10.0.0.0/8", "10.0.0.2", "198.51.100.1"},
+		{"no_trusted_proxies", "", "10.0.0.2", "10.0.0.2"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	appConfig := config.Get()
+	saved := *appConfig
+	t.Cleanup(func() { *appConfig = saved })
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			appConfig.Server.TrustedProxies = testCase.trustedProxies
+			router := NewRouter(fakeService{}, nil, nil)
+			var got audit.Metadata
+			router.GET("/ping", func(c *gin.Context) {
+				got = audit.FromContext(c.Request.Context())
+				c.Status(http.StatusNoContent)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			request.Header.Set("X-Forwarded-For", "198.51.100.1")
+			request.RemoteAddr = testCase.remoteIP + ":5000"
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			if got.ClientIP != testCase.expectedIP {
+				t.Fatalf("expected client %s, got %+v", testCase.expectedIP, got)
+			}
+		})
+	}
+}
+
+func TestInterest(t *testing.T) {
+	memory := repository.NewMemoryRepository()
+	for _, id := range []int64{1, 9} {
+		if _, err := memory.CreateAccount(context.Background(), domain.Account{ID: id, Balance: decimal.RequireFromString("100")}); err != nil {
+			t.Fatalf("create account %d: %v", id, err)
+		}
+	}
+	applicationService := service.NewService(memory)
+	interestJob := interest.NewJob(memory, interest.Options{ExpenseAccountID: 9, DayCount: domain.DayCountActual365})
+	router := newAdminRouterWithInterest(testAdminToken, applicationService, reconciliation.NewJob(memory, ""), interestJob)
+	disabledRouter := newAdminRouter(testAdminToken, applicationService, reconciliation.NewJob(memory, ""))
+
+	send := func(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+testAdminToken)
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := send(router, http.MethodPut, "/admin/accounts/1/interest", `{"annual_rate": "2.5"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var rates InterestRateListResponse
+	recorder = send(router, http.MethodGet, "/admin/interest/rates", "")
+	if err := json.Unmarshal(recorder.Body.Bytes(), &rates); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(rates.Rates) != 1 || rates.Rates[0].AccountID != 1 || !rates.Rates[0].AnnualRate.Equal(decimal.RequireFromString("2.5")) {
+		t.Fatalf("unexpected rates %+v", rates.Rates)
+	}
+
+	yesterday := interest.Day(time.Now()).AddDate(0, 0, -1).Format(time.DateOnly)
+	for _, expected := range []interest.Result{{Recorded: 1}, {AlreadyRecorded: 1}} {
+		recorder = send(router, http.MethodPost, "/admin/interest/runs", `{"date": "`+yesterday+`"}`)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		var result interest.Result
+		if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if result.Recorded != expected.Recorded || result.AlreadyRecorded != expected.AlreadyRecorded {
+			t.Fatalf("expected %d recorded and %d already recorded, got %+v", expected.Recorded, expected.AlreadyRecorded, result)
+		}
+	}
+
+	recorder = send(router, http.MethodGet, "/admin/interest/report?from="+yesterday+"&to="+yesterday+"&account_id=1", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var report domain.InterestReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(report.Accounts) != 1 || report.Accounts[0].Days != 1 || len(report.Accruals) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	tomorrow := interest.Day(time.Now()).AddDate(0, 0, 1).Format(time.DateOnly)
+	testCases := []struct {
+		testName           string
+		router             *gin.Engine
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"negative_rate", router, http.MethodPut, "/admin/accounts/1/interest", `{"annual_rate": "-1"}`, http.StatusBadRequest, "invalid_interest_rate"},
+		{"missing_rate", router, http.MethodPut, "/admin/accounts/1/interest", `{}`, http.StatusBadRequest, "invalid_request"},
+		{"unknown_account", router, http.MethodPut, "/admin/accounts/5/interest", `{"annual_rate": "1"}`, http.StatusNotFound, "account_not_found"},
+		{"report_without_period", router, http.MethodGet, "/admin/interest/report?from=" + yesterday, "", http.StatusBadRequest, "invalid_request"},
+		{"report_reversed_period", router, http.MethodGet, "/admin/interest/report?from=" + tomorrow + "&to=" + yesterday, "", http.StatusBadRequest, "invalid_request"},
+		{"invalid_run_date", router, http.MethodPost, "/admin/interest/runs", `{"date": "tomorrow"}`, http.StatusBadRequest, "invalid_request"},
+		{"day_not_over", router, http.MethodPost, "/admin/interest/runs", `{"date": "` + tomorrow + `"}`, http.StatusBadRequest, "day_not_over"},
+		{"interest_disabled", disabledRouter, http.MethodPost, "/admin/interest/runs", `{"date": "` + yesterday + `"}`, http.StatusNotImplemented, "interest_disabled"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			recorder := send(testCase.router, testCase.method, testCase.path, testCase.body)
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedCode {
+				t.Fatalf("expected error code %q, got %q", testCase.expectedCode, response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestImports(t *testing.T) {
+	memory := repository.NewMemoryRepository()
+	for _, id := range []int64{1, 2} {
+		if _, err := memory.CreateAccount(context.Background(), domain.Account{ID: id, Balance: decimal.RequireFromString("100")}); err != nil {
+			t.Fatalf("create account %d: %v", id, err)
+		}
+	}
+	applicationService := service.NewService(memory)
+	manager := bulk.NewManager(t.TempDir(), bulk.NewImporter(applicationService, bulk.DefaultChunkSize))
+	router := newAdminRouterWithJobs(testAdminToken, applicationService, reconciliation.NewJob(memory, ""), nil, manager)
+	disabledRouter := newAdminRouter(testAdminToken, applicationService, reconciliation.NewJob(memory, ""))
+
+	send := func(router *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+testAdminToken)
+		request.Header.Set(headerActor, "ops")
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := send(router, http.MethodPost, "/admin/imports?kind=transfers", "application/x-ndjson", `{"source_account_id": 1, "destination_account_id": 2, "amount": "25"}`+"\n")
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, recorder.Code, recorder.Body.String())
+	}
+	var job bulk.Job
+	if err := json.Unmarshal(recorder.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if job.Status != bulk.StatusPending || job.Format != bulk.FormatJSONL || job.Actor != "ops" {
+		t.Fatalf("expected a pending jsonl import by ops, got %+v", job)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != bulk.StatusCompleted {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the import to complete, got %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		recorder = send(router, http.MethodGet, "/admin/imports/"+job.ID, "", "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &job); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+
+	recorder = send(router, http.MethodGet, "/admin/imports/"+job.ID+"/results", "", "")
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected a CSV file, got %d %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "1,succeeded,") {
+		t.Fatalf("unexpected results %q", recorder.Body.String())
+	}
+	entries, err := applicationService.ListAuditEntries(context.Background(), domain.AuditFilter{Actor: "ops", Operation: domain.AuditOperationTransfer})
+	if err != nil || len(entries) != 1 || entries[0].RequestID != "import:"+job.ID+":1" {
+		t.Fatalf("expected the transfer to be audited under the import, got %+v, %v", entries, err)
+	}
+
+	testCases := []struct {
+		testName           string
+		router             *gin.Engine
+		method             string
+		path               string
+		contentType        string
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"unknown_kind", router, http.MethodPost, "/admin/imports?kind=payments&format=csv", "", http.StatusBadRequest, "invalid_request"},
+		{"unknown_format", router, http.MethodPost, "/admin/imports?kind=accounts", "application/json", http.StatusBadRequest, "invalid_request"},
+		{"unknown_import", router, http.MethodGet, "/admin/imports/0123456789abcdef", "", http.StatusNotFound, "import_not_found"},
+		{"resume_completed", router, http.MethodPost, "/admin/imports/" + job.ID + "/resume", "", http.StatusConflict, "import_finished"},
+		{"imports_disabled", disabledRouter, http.MethodPost, "/admin/imports?kind=accounts&format=csv", "", http.StatusNotImplemented, "imports_disabled"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			recorder := send(testCase.router, testCase.method, testCase.path, testCase.contentType, "")
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedCode {
+				t.Fatalf("expected error code %q, got %q", testCase.expectedCode, response.Error.Code)
+			}
+		})
+	}
+}
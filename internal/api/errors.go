@@ -37,6 +37,13 @@ func NotFound(c *gin.Context, code, message string) {
 func Conflict(c *gin.Context, code, message string) {
 	WriteError(c, http.StatusConflict, code, message)
 }
+func PreconditionFailed(c *gin.Context, message string) {
+	WriteError(c, http.StatusPreconditionFailed, "precondition_failed", message)
+}
 func Internal(c *gin.Context, message string) {
 	WriteError(c, http.StatusInternalServerError, "internal_error", message)
 }
+
+func RequestTooLarge(c *gin.Context, message string) {
+	WriteError(c, http.StatusRequestEntityTooLarge, "request_too_large", message)
+}
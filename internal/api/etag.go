@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// accountETag is the strong entity tag of an account, its quoted version.
+func accountETag(account *domain.Account) string {
+	return `"` + strconv.FormatInt(account.Version, 10) + `"`
+}
+
+func setAccountETag(c *gin.Context, account *domain.Account) {
+	c.Header("ETag", accountETag(account))
+}
+
+// ifMatchVersion reads the account version an update is conditional on from
+// If-Match. It returns 0 when the header is absent or "*". A tag that cannot
+// name a version, such as a weak one, never matches and fails the request
+// with 412 before the account is touched; several tags are rejected because
+// the update can only be made conditional on one version.
+func ifMatchVersion(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return 0, true
+	}
+	tags := strings.Split(header, ",")
+	if len(tags) > 1 {
+		BadRequest(c, "invalid_request", "If-Match must name a single entity tag")
+		return 0, false
+	}
+	tag := strings.TrimSpace(tags[0])
+	if tag == "*" {
+		return 0, true
+	}
+	if len(tag) >= 2 && tag[0] == '"' && tag[len(tag)-1] == '"' {
+		if version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil && version > 0 {
+			return version, true
+		}
+	}
+	PreconditionFailed(c, "account does not match the If-Match entity tag")
+	return 0, false
+}
+
+// notModified answers 304 when If-None-Match names the current tag of the
+// account. The tag of a sharded account does not change when money moves
+// through its shards, so its reads are always answered in full.
+func notModified(c *gin.Context, account *domain.Account) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" || account.Shards > 0 {
+		return false
+	}
+	current := accountETag(account)
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
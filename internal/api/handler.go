@@ -1,8 +1,12 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
@@ -15,11 +19,37 @@ import (
 type CreateAccountRequest struct {
 	AccountID      int64           `json:"account_id" binding:"required"`
 	InitialBalance decimal.Decimal `json:"initial_balance" binding:"required"`
+	Tier           string          `json:"tier"`
+	Tags           []string        `json:"tags"`
 }
 
 type AccountResponse struct {
 	AccountID int64           `json:"account_id" binding:"required"`
 	Balance   decimal.Decimal `json:"balance" binding:"required"`
+	Status    string          `json:"status"`
+	Tier      string          `json:"tier"`
+	Tags      []string        `json:"tags,omitempty"`
+	Version   int64           `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AccountListResponse is a page of accounts. NextPageToken is set when there
+// may be more, and is passed back as page_token with the same sort and order
+// to read them.
+type AccountListResponse struct {
+	Accounts      []AccountResponse `json:"accounts"`
+	NextPageToken string            `json:"next_page_token,omitempty"`
+}
+
+type BatchGetAccountsRequest struct {
+	AccountIDs []int64 `json:"account_ids" binding:"required"`
+}
+
+// BatchGetAccountsResponse holds the accounts found in the order they were
+// asked for; NotFound lists the IDs that matched no account.
+type BatchGetAccountsResponse struct {
+	Accounts []AccountResponse `json:"accounts"`
+	NotFound []int64           `json:"not_found,omitempty"`
 }
 
 type TransferMoneyRequest struct {
@@ -28,6 +58,38 @@ type TransferMoneyRequest struct {
 	Amount               decimal.Decimal `json:"amount" binding:"required"`
 }
 
+// FundingRequest is the body of a deposit into or a withdrawal from an
+// account.
+type FundingRequest struct {
+	AccountID int64           `json:"account_id" binding:"required"`
+	Amount    decimal.Decimal `json:"amount" binding:"required"`
+}
+
+type TransactionResponse struct {
+	TransactionID        int64                `json:"transaction_id"`
+	Type                 string               `json:"type"`
+	SourceAccountID      int64                `json:"source_account_id"`
+	DestinationAccountID int64                `json:"destination_account_id"`
+	Amount               decimal.Decimal      `json:"amount"`
+	ReversalOf           *int64               `json:"reversal_of,omitempty"`
+	Fee                  *domain.Fee          `json:"fee,omitempty"`
+	Risk                 *domain.RiskDecision `json:"risk,omitempty"`
+	CreatedAt            time.Time            `json:"created_at"`
+}
+
+type TransactionListResponse struct {
+	Transactions []TransactionResponse `json:"transactions"`
+	NextBeforeID *int64                `json:"next_before_id,omitempty"`
+}
+
+// BalanceResponse is the balance of an account at AsOf, recomputed from its
+// transaction history.
+type BalanceResponse struct {
+	AccountID int64           `json:"account_id"`
+	Balance   decimal.Decimal `json:"balance"`
+	AsOf      time.Time       `json:"as_of"`
+}
+
 type Handler struct {
 	Service service.Service
 }
@@ -38,67 +100,458 @@ func NewHandler(applicationService service.Service) *Handler {
 
 func (handler *Handler) CreateAccount(c *gin.Context) {
 	var request CreateAccountRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		BadRequest(c, "invalid_request", err.Error())
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	_, err := handler.Service.CreateAccount(c.Request.Context(), domain.Account{
+	account, err := handler.Service.CreateAccount(c.Request.Context(), domain.Account{
 		ID:      request.AccountID,
 		Balance: request.InitialBalance,
+		Tier:    request.Tier,
+		Tags:    request.Tags,
 	})
 	if err != nil {
-		logger.L().Error("create account failed", zap.Error(err))
-		Internal(c, http.StatusText(http.StatusInternalServerError))
+		writeServiceError(c, err, "create account failed", zap.Int64("account_id", request.AccountID))
 		return
 	}
-	c.Status(http.StatusCreated)
+	setAccountETag(c, account)
+	c.JSON(http.StatusCreated, newAccountResponse(account))
 }
 
 func (handler *Handler) GetAccount(c *gin.Context) {
 	accountID := c.Param("account_id")
 	account, err := handler.Service.GetAccount(c.Request.Context(), accountID)
 	if err != nil {
-		logger.L().Error("get account failed", zap.Error(err), zap.String("account_id", accountID))
-		Internal(c, http.StatusText(http.StatusInternalServerError))
+		writeServiceError(c, err, "get account failed", zap.String("account_id", accountID))
 		return
 	}
-	accountResponse := AccountResponse{
-		AccountID: account.ID,
-		Balance:   account.Balance,
+	setAccountETag(c, account)
+	if notModified(c, account) {
+		return
 	}
-	c.JSON(http.StatusOK, accountResponse)
+	c.JSON(http.StatusOK, newAccountResponse(account))
+}
+
+// ListAccounts pages through the accounts that match the query filters.
+func (handler *Handler) ListAccounts(c *gin.Context) {
+	limit, ok := limitQuery(c)
+	if !ok {
+		return
+	}
+	filter := domain.AccountFilter{
+		Status: c.Query("status"),
+		Sort:   c.DefaultQuery("sort", domain.AccountSortID),
+		Limit:  limit,
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		BadRequest(c, "invalid_request", "order must be asc or desc")
+		return
+	}
+	for _, bound := range []struct {
+		name   string
+		target **decimal.Decimal
+	}{
+		{"min_balance", &filter.MinBalance},
+		{"max_balance", &filter.MaxBalance},
+	} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := decimal.NewFromString(value)
+		if err != nil {
+			BadRequest(c, "invalid_request", bound.name+" must be a decimal number")
+			return
+		}
+		*bound.target = &parsed
+	}
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"created_since", &filter.CreatedSince},
+		{"created_until", &filter.CreatedUntil},
+	} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			BadRequest(c, "invalid_request", bound.name+" must be an RFC 3339 timestamp")
+			return
+		}
+		*bound.target = parsed
+	}
+	if value := c.Query("tags"); value != "" {
+		for _, tag := range strings.Split(value, ",") {
+			filter.Tags = append(filter.Tags, strings.TrimSpace(tag))
+		}
+	}
+	if value := c.Query("page_token"); value != "" {
+		after, err := decodeAccountPageToken(value, filter.Sort, filter.Descending)
+		if err != nil {
+			BadRequest(c, "invalid_request", err.Error())
+			return
+		}
+		filter.After = after
+	}
+
+	accounts, err := handler.Service.ListAccounts(c.Request.Context(), filter)
+	if err != nil {
+		writeServiceError(c, err, "list accounts failed", zap.Any("filter", filter))
+		return
+	}
+
+	response := AccountListResponse{Accounts: make([]AccountResponse, 0, len(accounts))}
+	for i := range accounts {
+		response.Accounts = append(response.Accounts, newAccountResponse(&accounts[i]))
+	}
+	if len(accounts) == filter.Limit {
+		response.NextPageToken = encodeAccountPageToken(&accounts[len(accounts)-1], filter.Sort, filter.Descending)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CustomMethod dispatches the custom methods of collections, such as
+// POST /api/v1/accounts:batchGet. The router reads a colon in a path segment
+// as the start of a parameter, so they all share the /api/v1/:method route.
+func (handler *Handler) CustomMethod(c *gin.Context) {
+	switch method := c.Param("method"); method {
+	case "accounts:batchGet":
+		handler.BatchGetAccounts(c)
+	default:
+		NotFound(c, "not_found", "unknown method "+method)
+	}
+}
+
+// BatchGetAccounts looks many accounts up in one round trip.
+func (handler *Handler) BatchGetAccounts(c *gin.Context) {
+	var request BatchGetAccountsRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	batch, err := handler.Service.BatchGetAccounts(c.Request.Context(), request.AccountIDs)
+	if err != nil {
+		writeServiceError(c, err, "batch get accounts failed", zap.Int64s("account_ids", request.AccountIDs))
+		return
+	}
+
+	response := BatchGetAccountsResponse{Accounts: make([]AccountResponse, 0, len(batch.Accounts)), NotFound: batch.NotFound}
+	for i := range batch.Accounts {
+		response.Accounts = append(response.Accounts, newAccountResponse(&batch.Accounts[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (handler *Handler) FreezeAccount(c *gin.Context) {
+	handler.setAccountStatus(c, handler.Service.FreezeAccount)
+}
+
+func (handler *Handler) UnfreezeAccount(c *gin.Context) {
+	handler.setAccountStatus(c, handler.Service.UnfreezeAccount)
+}
+
+func (handler *Handler) setAccountStatus(c *gin.Context, update func(ctx context.Context, accountID int64, expectedVersion int64) (*domain.Account, error)) {
+	accountID, ok := int64Param(c, "account_id", "invalid_account_ids")
+	if !ok {
+		return
+	}
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	account, err := update(c.Request.Context(), accountID, expectedVersion)
+	if err != nil {
+		writeServiceError(c, err, "update account status failed", zap.Int64("account_id", accountID))
+		return
+	}
+	setAccountETag(c, account)
+	c.JSON(http.StatusOK, newAccountResponse(account))
+}
+
+func (handler *Handler) ListTransactions(c *gin.Context) {
+	accountID, ok := int64Param(c, "account_id", "invalid_account_ids")
+	if !ok {
+		return
+	}
+
+	limit, beforeID, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+	filter := domain.TransactionFilter{Limit: limit, BeforeID: beforeID, Type: c.Query("type")}
+
+	transactions, err := handler.Service.ListTransactions(c.Request.Context(), accountID, filter)
+	if err != nil {
+		writeServiceError(c, err, "list transactions failed", zap.Int64("account_id", accountID))
+		return
+	}
+
+	response := TransactionListResponse{Transactions: make([]TransactionResponse, 0, len(transactions))}
+	for i := range transactions {
+		response.Transactions = append(response.Transactions, newTransactionResponse(&transactions[i]))
+	}
+	if len(transactions) == filter.Limit {
+		next := transactions[len(transactions)-1].ID
+		response.NextBeforeID = &next
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (handler *Handler) TransferMoney(c *gin.Context) {
 	var request TransferMoneyRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		BadRequest(c, "invalid_request", err.Error())
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	err := handler.Service.TransferMoney(c.Request.Context(), domain.Transaction{
+	transaction, err := handler.Service.TransferMoney(c.Request.Context(), domain.Transaction{
 		SourceAccountID:      request.SourceAccountID,
 		DestinationAccountID: request.DestinationAccountID,
 		Amount:               request.Amount,
 	})
+	if err != nil {
+		writeServiceError(c, err, "transfer failed", zap.Any("request", request))
+		return
+	}
 
+	c.JSON(http.StatusOK, newTransactionResponse(transaction))
+}
+
+func (handler *Handler) Deposit(c *gin.Context) {
+	handler.fund(c, handler.Service.Deposit, "deposit failed")
+}
+
+func (handler *Handler) Withdraw(c *gin.Context) {
+	handler.fund(c, handler.Service.Withdraw, "withdrawal failed")
+}
+
+func (handler *Handler) fund(c *gin.Context, move func(ctx context.Context, accountID int64, amount decimal.Decimal) (*domain.Transaction, error), message string) {
+	var request FundingRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	transaction, err := move(c.Request.Context(), request.AccountID, request.Amount)
 	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrSameSourceAndDestination):
-			BadRequest(c, "same_account", err.Error())
-		case errors.Is(err, service.ErrNonPositiveAmount):
-			BadRequest(c, "invalid_amount", err.Error())
-		case errors.Is(err, service.ErrInvalidAccountIDs):
-			BadRequest(c, "invalid_account_ids", err.Error())
-		case errors.Is(err, service.ErrInsufficientBalance):
-			Conflict(c, "insufficient_balance", err.Error())
-		default:
-			logger.L().Error("transfer failed", zap.Error(err), zap.Any("request", request))
-			Internal(c, http.StatusText(http.StatusInternalServerError))
+		writeServiceError(c, err, message, zap.Any("request", request))
+		return
+	}
+	c.JSON(http.StatusCreated, newTransactionResponse(transaction))
+}
+
+func (handler *Handler) ReverseTransaction(c *gin.Context) {
+	transactionID, ok := int64Param(c, "transaction_id", "invalid_transaction_id")
+	if !ok {
+		return
+	}
+	reversal, err := handler.Service.ReverseTransaction(c.Request.Context(), transactionID)
+	if err != nil {
+		writeServiceError(c, err, "reverse transaction failed", zap.Int64("transaction_id", transactionID))
+		return
+	}
+	c.JSON(http.StatusCreated, newTransactionResponse(reversal))
+}
+
+func (handler *Handler) GetBalance(c *gin.Context) {
+	accountID, ok := int64Param(c, "account_id", "invalid_account_ids")
+	if !ok {
+		return
+	}
+	asOf, ok := asOfQuery(c)
+	if !ok {
+		return
+	}
+	balance, err := handler.Service.BalanceAt(c.Request.Context(), accountID, asOf)
+	if err != nil {
+		writeServiceError(c, err, "get balance failed", zap.Int64("account_id", accountID))
+		return
+	}
+	c.JSON(http.StatusOK, BalanceResponse{AccountID: balance.AccountID, Balance: balance.Balance, AsOf: asOf})
+}
+
+// ListBalances returns the balances of the comma-separated account_ids at the
+// same instant.
+func (handler *Handler) ListBalances(c *gin.Context) {
+	var accountIDs []int64
+	for _, value := range strings.Split(c.Query("account_ids"), ",") {
+		accountID, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || accountID <= 0 {
+			BadRequest(c, "invalid_account_ids", "account_ids must be a comma-separated list of positive integers")
+			return
 		}
+		accountIDs = append(accountIDs, accountID)
+	}
+	asOf, ok := asOfQuery(c)
+	if !ok {
 		return
 	}
+	report, err := handler.Service.BalancesAt(c.Request.Context(), accountIDs, asOf)
+	if err != nil {
+		writeServiceError(c, err, "list balances failed", zap.Int64s("account_ids", accountIDs))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func newAccountResponse(account *domain.Account) AccountResponse {
+	return AccountResponse{
+		AccountID: account.ID,
+		Balance:   account.Balance,
+		Status:    account.Status,
+		Tier:      account.Tier,
+		Tags:      account.Tags,
+		Version:   account.Version,
+		CreatedAt: account.CreatedAt,
+	}
+}
+
+func newTransactionResponse(transaction *domain.Transaction) TransactionResponse {
+	return TransactionResponse{
+		TransactionID:        transaction.ID,
+		Type:                 transaction.Type,
+		SourceAccountID:      transaction.SourceAccountID,
+		DestinationAccountID: transaction.DestinationAccountID,
+		Amount:               transaction.Amount,
+		ReversalOf:           transaction.ReversalOf,
+		Fee:                  transaction.Fee,
+		Risk:                 transaction.Risk,
+		CreatedAt:            transaction.CreatedAt,
+	}
+}
+
+func int64Param(c *gin.Context, name, code string) (int64, bool) {
+	value, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || value <= 0 {
+		BadRequest(c, code, name+" must be a positive integer")
+		return 0, false
+	}
+	return value, true
+}
 
-	c.Status(http.StatusOK)
+// pageQuery reads the limit and before_id cursor shared by paginated listings.
+func pageQuery(c *gin.Context) (int, int64, bool) {
+	limit, ok := limitQuery(c)
+	if !ok {
+		return 0, 0, false
+	}
+	var beforeID int64
+	if value := c.Query("before_id"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			BadRequest(c, "invalid_request", "before_id must be a positive integer")
+			return 0, 0, false
+		}
+		beforeID = parsed
+	}
+	return limit, beforeID, true
+}
+
+// limitQuery reads the page size of a paginated listing.
+func limitQuery(c *gin.Context) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return service.DefaultPageSize, true
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		BadRequest(c, "invalid_request", "limit must be a positive integer")
+		return 0, false
+	}
+	return min(parsed, service.MaxPageSize), true
+}
+
+// asOfQuery reads the instant of a point-in-time query, now when it is not
+// given.
+func asOfQuery(c *gin.Context) (time.Time, bool) {
+	value := c.Query("as_of")
+	if value == "" {
+		return time.Now().UTC(), true
+	}
+	asOf, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		BadRequest(c, "invalid_request", "as_of must be a timestamp such as 2026-01-31T23:59:59Z")
+		return time.Time{}, false
+	}
+	return asOf.UTC(), true
+}
+
+func bindJSON(c *gin.Context, request any) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			RequestTooLarge(c, err.Error())
+			return false
+		}
+		BadRequest(c, "invalid_request", err.Error())
+		return false
+	}
+	return true
+}
+
+func writeServiceError(c *gin.Context, err error, message string, fields ...zap.Field) {
+	switch {
+	case errors.Is(err, service.ErrSameSourceAndDestination):
+		BadRequest(c, "same_account", err.Error())
+	case errors.Is(err, service.ErrNonPositiveAmount):
+		BadRequest(c, "invalid_amount", err.Error())
+	case errors.Is(err, service.ErrInvalidAmountPrecision):
+		BadRequest(c, "invalid_amount_precision", err.Error())
+	case errors.Is(err, service.ErrAmountTooLarge):
+		BadRequest(c, "amount_too_large", err.Error())
+	case errors.Is(err, service.ErrInvalidAccountIDs):
+		BadRequest(c, "invalid_account_ids", err.Error())
+	case errors.Is(err, service.ErrAccountNotFound):
+		NotFound(c, "account_not_found", err.Error())
+	case errors.Is(err, service.ErrTransactionNotFound):
+		NotFound(c, "transaction_not_found", err.Error())
+	case errors.Is(err, service.ErrInsufficientBalance):
+		Conflict(c, "insufficient_balance", err.Error())
+	case errors.Is(err, service.ErrAccountExists):
+		Conflict(c, "account_exists", err.Error())
+	case errors.Is(err, service.ErrAccountFrozen):
+		Conflict(c, "account_frozen", err.Error())
+	case errors.Is(err, service.ErrAlreadyReversed):
+		Conflict(c, "already_reversed", err.Error())
+	case errors.Is(err, service.ErrNotReversible):
+		Conflict(c, "not_reversible", err.Error())
+	case errors.Is(err, service.ErrTransactionConflict):
+		Conflict(c, "transaction_conflict", err.Error())
+	case errors.Is(err, service.ErrTransferDenied):
+		WriteError(c, http.StatusForbidden, "transfer_denied", err.Error())
+	case errors.Is(err, service.ErrInvalidAuditFilter):
+		BadRequest(c, "invalid_request", err.Error())
+	case errors.Is(err, service.ErrInvalidShardCount):
+		BadRequest(c, "invalid_shard_count", err.Error())
+	case errors.Is(err, service.ErrShardingUnsupported):
+		WriteError(c, http.StatusNotImplemented, "sharding_unsupported", err.Error())
+	case errors.Is(err, service.ErrVersionMismatch):
+		PreconditionFailed(c, err.Error())
+	case errors.Is(err, service.ErrClearingAccount):
+		BadRequest(c, "clearing_account", err.Error())
+	case errors.Is(err, service.ErrAmountLimitExceeded):
+		BadRequest(c, "amount_limit_exceeded", err.Error())
+	case errors.Is(err, service.ErrInvalidTransactionType):
+		BadRequest(c, "invalid_transaction_type", err.Error())
+	case errors.Is(err, service.ErrInvalidFeeRule):
+		BadRequest(c, "invalid_fee_rule", err.Error())
+	case errors.Is(err, service.ErrFeeRuleNotFound):
+		NotFound(c, "fee_rule_not_found", err.Error())
+	case errors.Is(err, service.ErrInvalidInterestRate):
+		BadRequest(c, "invalid_interest_rate", err.Error())
+	case errors.Is(err, service.ErrInvalidAccountTags):
+		BadRequest(c, "invalid_tags", err.Error())
+	case errors.Is(err, service.ErrInvalidInterestQuery), errors.Is(err, service.ErrInvalidBalanceQuery), errors.Is(err, service.ErrInvalidAccountQuery):
+		BadRequest(c, "invalid_request", err.Error())
+	case errors.Is(err, service.ErrFundingUnavailable):
+		WriteError(c, http.StatusNotImplemented, "funding_unavailable", err.Error())
+	default:
+		logger.L().Error(message, append(fields, zap.Error(err))...)
+		Internal(c, http.StatusText(http.StatusInternalServerError))
+	}
 }
@@ -4,23 +4,52 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/shopspring/decimal"
 	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/metrics"
 	"github.com/tareqpi/transfer-system/internal/service"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 var errTest = errors.New("assert error")
 
 type fakeService struct {
-	createAccountFunc func(domain.Account) (*domain.Account, error)
-	getAccountFunc    func(string) (*domain.Account, error)
-	transferMoneyFunc func(domain.Transaction) error
+	createAccountFunc      func(domain.Account) (*domain.Account, error)
+	getAccountFunc         func(string) (*domain.Account, error)
+	transferMoneyFunc      func(domain.Transaction) error
+	listTransactionsFunc   func(int64, domain.TransactionFilter) ([]domain.Transaction, error)
+	reverseTransactionFunc func(int64) (*domain.Transaction, error)
+	setAccountStatusFunc   func(int64, string, int64) (*domain.Account, error)
+	setAccountShardsFunc   func(int64, int, int64) (*domain.Account, error)
+	listAuditEntriesFunc   func(domain.AuditFilter) ([]domain.AuditEntry, error)
+	depositFunc            func(int64, decimal.Decimal) (*domain.Transaction, error)
+	withdrawFunc           func(int64, decimal.Decimal) (*domain.Transaction, error)
+	listFeeRulesFunc       func() ([]domain.FeeRule, error)
+	createFeeRuleFunc      func(domain.FeeRule) (*domain.FeeRule, error)
+	updateFeeRuleFunc      func(domain.FeeRule) (*domain.FeeRule, error)
+	deleteFeeRuleFunc      func(int64) error
+	setInterestRateFunc    func(int64, decimal.Decimal) (*domain.InterestRate, error)
+	listInterestRatesFunc  func() ([]domain.InterestRate, error)
+	interestReportFunc     func(domain.InterestAccrualFilter) (*domain.InterestReport, error)
+	balanceAtFunc          func(int64, time.Time) (*domain.AccountBalance, error)
+	balancesAtFunc         func([]int64, time.Time) (*domain.BalanceReport, error)
+	listAccountsFunc       func(domain.AccountFilter) ([]domain.Account, error)
+	batchGetAccountsFunc   func([]int64) (*domain.AccountBatch, error)
+	validateAccountFunc    func(domain.Account) error
+	validateTransferFunc   func(domain.Transaction) error
 }
 
 func (m fakeService) CreateAccount(ctx context.Context, account domain.Account) (*domain.Account, error) {
@@ -29,8 +58,75 @@ func (m fakeService) CreateAccount(ctx context.Context, account domain.Account)
 func (m fakeService) GetAccount(ctx context.Context, accountID string) (*domain.Account, error) {
 	return m.getAccountFunc(accountID)
 }
-func (m fakeService) TransferMoney(ctx context.Context, transaction domain.Transaction) error {
-	return m.transferMoneyFunc(transaction)
+func (m fakeService) TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	if err := m.transferMoneyFunc(transaction); err != nil {
+		return nil, err
+	}
+	transaction.ID = 1
+	return &transaction, nil
+}
+func (m fakeService) ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	return m.listTransactionsFunc(accountID, filter)
+}
+func (m fakeService) ReverseTransaction(ctx context.Context, transactionID int64) (*domain.Transaction, error) {
+	return m.reverseTransactionFunc(transactionID)
+}
+func (m fakeService) Deposit(ctx context.Context, accountID int64, amount decimal.Decimal) (*domain.Transaction, error) {
+	return m.depositFunc(accountID, amount)
+}
+func (m fakeService) Withdraw(ctx context.Context, accountID int64, amount decimal.Decimal) (*domain.Transaction, error) {
+	return m.withdrawFunc(accountID, amount)
+}
+func (m fakeService) FreezeAccount(ctx context.Context, accountID int64, expectedVersion int64) (*domain.Account, error) {
+	return m.setAccountStatusFunc(accountID, domain.AccountStatusFrozen, expectedVersion)
+}
+func (m fakeService) UnfreezeAccount(ctx context.Context, accountID int64, expectedVersion int64) (*domain.Account, error) {
+	return m.setAccountStatusFunc(accountID, domain.AccountStatusActive, expectedVersion)
+}
+func (m fakeService) SetAccountShards(ctx context.Context, accountID int64, shards int, expectedVersion int64) (*domain.Account, error) {
+	return m.setAccountShardsFunc(accountID, shards, expectedVersion)
+}
+func (m fakeService) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	return m.listAuditEntriesFunc(filter)
+}
+func (m fakeService) ListFeeRules(ctx context.Context) ([]domain.FeeRule, error) {
+	return m.listFeeRulesFunc()
+}
+func (m fakeService) CreateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	return m.createFeeRuleFunc(rule)
+}
+func (m fakeService) UpdateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	return m.updateFeeRuleFunc(rule)
+}
+func (m fakeService) DeleteFeeRule(ctx context.Context, ruleID int64) error {
+	return m.deleteFeeRuleFunc(ruleID)
+}
+func (m fakeService) SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (*domain.InterestRate, error) {
+	return m.setInterestRateFunc(accountID, annualRate)
+}
+func (m fakeService) ListInterestRates(ctx context.Context) ([]domain.InterestRate, error) {
+	return m.listInterestRatesFunc()
+}
+func (m fakeService) InterestReport(ctx context.Context, filter domain.InterestAccrualFilter) (*domain.InterestReport, error) {
+	return m.interestReportFunc(filter)
+}
+func (m fakeService) BalanceAt(ctx context.Context, accountID int64, asOf time.Time) (*domain.AccountBalance, error) {
+	return m.balanceAtFunc(accountID, asOf)
+}
+func (m fakeService) BalancesAt(ctx context.Context, accountIDs []int64, asOf time.Time) (*domain.BalanceReport, error) {
+	return m.balancesAtFunc(accountIDs, asOf)
+}
+func (m fakeService) ListAccounts(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error) {
+	return m.listAccountsFunc(filter)
+}
+func (m fakeService) BatchGetAccounts(ctx context.Context, accountIDs []int64) (*domain.AccountBatch, error) {
+	return m.batchGetAccountsFunc(accountIDs)
+}
+func (m fakeService) ValidateAccount(account domain.Account) error {
+	return m.validateAccountFunc(account)
+}
+func (m fakeService) ValidateTransfer(transaction domain.Transaction) error {
+	return m.validateTransferFunc(transaction)
 }
 
 func newTestRouter() *gin.Engine {
@@ -40,13 +136,30 @@ func newTestRouter() *gin.Engine {
 	handler := NewHandler(fakeService{
 		createAccountFunc: func(account domain.Account) (*domain.Account, error) { return &account, nil },
 		getAccountFunc: func(accountID string) (*domain.Account, error) {
-			return &domain.Account{ID: 7, Balance: decimal.RequireFromString("42.50")}, nil
+			return &domain.Account{ID: 7, Balance: decimal.RequireFromString("42.50"), Version: 3}, nil
 		},
 		transferMoneyFunc: func(transaction domain.Transaction) error { return nil },
+		listTransactionsFunc: func(accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+			transactions := make([]domain.Transaction, 0, filter.Limit)
+			for id := int64(100); id > 100-int64(filter.Limit); id-- {
+				transactions = append(transactions, domain.Transaction{ID: id, SourceAccountID: accountID, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+			}
+			return transactions, nil
+		},
+		reverseTransactionFunc: func(transactionID int64) (*domain.Transaction, error) {
+			return &domain.Transaction{ID: 200, SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(5), ReversalOf: &transactionID}, nil
+		},
+		setAccountStatusFunc: func(accountID int64, status string, expectedVersion int64) (*domain.Account, error) {
+			return &domain.Account{ID: accountID, Balance: decimal.NewFromInt(10), Status: status, Version: 2}, nil
+		},
 	})
 	router.POST("/api/v1/accounts", handler.CreateAccount)
 	router.GET("/api/v1/accounts/:account_id", handler.GetAccount)
+	router.GET("/api/v1/accounts/:account_id/transactions", handler.ListTransactions)
+	router.POST("/api/v1/accounts/:account_id/freeze", handler.FreezeAccount)
+	router.POST("/api/v1/accounts/:account_id/unfreeze", handler.UnfreezeAccount)
 	router.POST("/api/v1/transactions", handler.TransferMoney)
+	router.POST("/api/v1/transactions/:transaction_id/reverse", handler.ReverseTransaction)
 	return router
 }
 
@@ -166,6 +279,13 @@ func TestTransferMoney_Success(t *testing.T) {
 	if recorder.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", recorder.Code)
 	}
+	var response TransactionResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if response.TransactionID == 0 || response.SourceAccountID != 1 || response.DestinationAccountID != 2 {
+		t.Fatalf("unexpected transaction response: %+v", response)
+	}
 }
 
 func TestTransferMoney_BadRequests(t *testing.T) {
@@ -177,7 +297,12 @@ func TestTransferMoney_BadRequests(t *testing.T) {
 	}{
 		{"same_account", service.ErrSameSourceAndDestination, http.StatusBadRequest, "same_account"},
 		{"invalid_amount", service.ErrNonPositiveAmount, http.StatusBadRequest, "invalid_amount"},
+		{"invalid_amount_precision", service.ErrInvalidAmountPrecision, http.StatusBadRequest, "invalid_amount_precision"},
+		{"amount_too_large", service.ErrAmountTooLarge, http.StatusBadRequest, "amount_too_large"},
 		{"invalid_account_ids", service.ErrInvalidAccountIDs, http.StatusBadRequest, "invalid_account_ids"},
+		{"account_not_found", service.ErrAccountNotFound, http.StatusNotFound, "account_not_found"},
+		{"account_frozen", service.ErrAccountFrozen, http.StatusConflict, "account_frozen"},
+		{"transaction_conflict", service.ErrTransactionConflict, http.StatusConflict, "transaction_conflict"},
 	}
 
 	for _, testCase := range testCases {
@@ -235,6 +360,98 @@ func TestTransferMoney_InsufficientBalance(t *testing.T) {
 	}
 }
 
+func TestTransferMoney_Denied(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	handler := NewHandler(fakeService{transferMoneyFunc: func(domain.Transaction) error {
+		return fmt.Errorf("%w: large: amount 1000 exceeds 500", service.ErrTransferDenied)
+	}})
+	router.POST("/api/v1/transactions", handler.TransferMoney)
+
+	requestBody := `{"source_account_id": 1, "destination_account_id": 2, "amount": "1000"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions", strings.NewReader(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", recorder.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if response.Error.Code != "transfer_denied" || !strings.Contains(response.Error.Message, "large") {
+		t.Fatalf("expected transfer_denied naming the rule, got %+v", response.Error)
+	}
+}
+
+// reviewingService lets every transfer through with decision attached.
+type reviewingService struct {
+	fakeService
+	decision *domain.RiskDecision
+}
+
+func (s reviewingService) TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	transaction.ID = 1
+	transaction.Risk = s.decision
+	return &transaction, nil
+}
+
+func TestTransferMoney_RiskDecision(t *testing.T) {
+	review := &domain.RiskDecision{
+		SourceAccountID:      1,
+		DestinationAccountID: 2,
+		Amount:               decimal.NewFromInt(1000),
+		Decision:             domain.RiskReview,
+		Reasons:              []domain.RiskReason{{Rule: "large", Action: domain.RiskReview, Reason: "amount 1000 exceeds 500"}},
+	}
+	testCases := []struct {
+		name     string
+		decision *domain.RiskDecision
+	}{
+		{"allow", nil},
+		{"review", review},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(RequestID(), Recovery())
+			handler := NewHandler(reviewingService{decision: testCase.decision})
+			router.POST("/api/v1/transactions", handler.TransferMoney)
+
+			requestBody := `{"source_account_id": 1, "destination_account_id": 2, "amount": "1000"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions", strings.NewReader(requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", recorder.Code)
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			encoded, ok := body["risk"]
+			if testCase.decision == nil {
+				if ok {
+					t.Fatalf("expected no risk field on an allowed transfer, got %s", encoded)
+				}
+				return
+			}
+			var decision domain.RiskDecision
+			if err := json.Unmarshal(encoded, &decision); err != nil {
+				t.Fatalf("failed to parse risk decision: %v", err)
+			}
+			if decision.Decision != domain.RiskReview || len(decision.Reasons) != 1 || decision.Reasons[0] != review.Reasons[0] {
+				t.Fatalf("expected the review decision %+v, got %+v", review, decision)
+			}
+		})
+	}
+}
+
 func TestTransferMoney_InternalError(t *testing.T) {
 	router := gin.New()
 	router.Use(RequestID(), Recovery())
@@ -258,3 +475,702 @@ func TestTransferMoney_InternalError(t *testing.T) {
 		t.Fatalf("expected error code internal_error, got %s", response.Error.Code)
 	}
 }
+
+func TestMetrics_RecordsRequestsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Metrics(), Recovery())
+	router.GET("/api/v1/accounts/:account_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	counter := metrics.HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/api/v1/accounts/:account_id", "200")
+	before := testutil.ToFloat64(counter)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts/3", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Fatalf("expected request counter to increase by 1, got %v", got)
+	}
+}
+
+func TestRequestID_LinksTraceFromTraceparent(t *testing.T) {
+	recorderExporter := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorderExporter))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	router := gin.New()
+	router.Use(
+		otelgin.Middleware("transfer-system-test",
+			otelgin.WithTracerProvider(provider),
+			otelgin.WithPropagators(propagation.TraceContext{}),
+		),
+		RequestID(), Recovery(),
+	)
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set(headerRequestID, "rid-trace")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if got := recorder.Header().Get(headerTraceID); got != traceID {
+		t.Fatalf("expected %s header %s, got %q", headerTraceID, traceID, got)
+	}
+	spans := recorderExporter.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	var found bool
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "request.id" && attr.Value.AsString() == "rid-trace" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected span to carry request.id attribute, got %v", spans[0].Attributes())
+	}
+}
+
+func TestBodyLimit_RejectsLargeBodies(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestID(), Recovery(), BodyLimit(16))
+	handler := NewHandler(fakeService{createAccountFunc: func(account domain.Account) (*domain.Account, error) { return &account, nil }})
+	router.POST("/api/v1/accounts", handler.CreateAccount)
+
+	requestBody := `{"account_id": 1, "initial_balance": "100.00"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/accounts", strings.NewReader(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", recorder.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if response.Error.Code != "request_too_large" {
+		t.Fatalf("expected error code request_too_large, got %s", response.Error.Code)
+	}
+}
+
+func TestGetAccount_NotFound(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	handler := NewHandler(fakeService{getAccountFunc: func(accountID string) (*domain.Account, error) { return nil, service.ErrAccountNotFound }})
+	router.GET("/api/v1/accounts/:account_id", handler.GetAccount)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts/404", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", recorder.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if response.Error.Code != "account_not_found" {
+		t.Fatalf("expected error code account_not_found, got %s", response.Error.Code)
+	}
+}
+
+func TestListTransactions_Pagination(t *testing.T) {
+	router := newTestRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts/1/transactions?limit=3", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response TransactionListResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(response.Transactions) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(response.Transactions))
+	}
+	if response.NextBeforeID == nil || *response.NextBeforeID != 98 {
+		t.Fatalf("expected next_before_id 98, got %v", response.NextBeforeID)
+	}
+}
+
+func TestListTransactions_InvalidQuery(t *testing.T) {
+	router := newTestRouter()
+
+	for _, target := range []string{
+		"/api/v1/accounts/abc/transactions",
+		"/api/v1/accounts/1/transactions?limit=0",
+		"/api/v1/accounts/1/transactions?before_id=x",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", target, recorder.Code)
+		}
+	}
+}
+
+func TestReverseTransaction(t *testing.T) {
+	testCases := []struct {
+		testName           string
+		serviceError       error
+		expectedStatusCode int
+		expectedErrorCode  string
+	}{
+		{"success", nil, http.StatusCreated, ""},
+		{"not_found", service.ErrTransactionNotFound, http.StatusNotFound, "transaction_not_found"},
+		{"already_reversed", service.ErrAlreadyReversed, http.StatusConflict, "already_reversed"},
+		{"insufficient_balance", service.ErrInsufficientBalance, http.StatusConflict, "insufficient_balance"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			router := gin.New()
+			router.Use(RequestID(), Recovery())
+			handler := NewHandler(fakeService{reverseTransactionFunc: func(transactionID int64) (*domain.Transaction, error) {
+				if testCase.serviceError != nil {
+					return nil, testCase.serviceError
+				}
+				return &domain.Transaction{ID: 2, SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(1), ReversalOf: &transactionID}, nil
+			}})
+			router.POST("/api/v1/transactions/:transaction_id/reverse", handler.ReverseTransaction)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions/1/reverse", nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatusCode, recorder.Code)
+			}
+			if testCase.serviceError == nil {
+				var response TransactionResponse
+				if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to parse response: %v", err)
+				}
+				if response.ReversalOf == nil || *response.ReversalOf != 1 {
+					t.Fatalf("expected reversal_of 1, got %v", response.ReversalOf)
+				}
+				return
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedErrorCode {
+				t.Fatalf("expected error code %s, got %s", testCase.expectedErrorCode, response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestFreezeAndUnfreezeAccount(t *testing.T) {
+	router := newTestRouter()
+
+	for action, expectedStatus := range map[string]string{"freeze": domain.AccountStatusFrozen, "unfreeze": domain.AccountStatusActive} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/accounts/5/"+action, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", action, recorder.Code)
+		}
+		var response AccountResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if response.AccountID != 5 || response.Status != expectedStatus {
+			t.Fatalf("%s: unexpected response %+v", action, response)
+		}
+	}
+}
+
+func TestGetAccount_ETag(t *testing.T) {
+	testCases := []struct {
+		testName           string
+		ifNoneMatch        string
+		shards             int
+		expectedStatusCode int
+	}{
+		{"no_condition", "", 0, http.StatusOK},
+		{"current_tag", `"3"`, 0, http.StatusNotModified},
+		{"weak_current_tag", `W/"3"`, 0, http.StatusNotModified},
+		{"tag_in_list", `"1", "3"`, 0, http.StatusNotModified},
+		{"any", "*", 0, http.StatusNotModified},
+		{"stale_tag", `"2"`, 0, http.StatusOK},
+		{"sharded_account", `"3"`, 4, http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			handler := NewHandler(fakeService{getAccountFunc: func(accountID string) (*domain.Account, error) {
+				return &domain.Account{ID: 7, Balance: decimal.RequireFromString("42.50"), Version: 3, Shards: testCase.shards}, nil
+			}})
+			router.GET("/api/v1/accounts/:account_id", handler.GetAccount)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts/7", nil)
+			if testCase.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", testCase.ifNoneMatch)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatusCode, recorder.Code)
+			}
+			if etag := recorder.Header().Get("ETag"); etag != `"3"` {
+				t.Fatalf(`expected ETag "3", got %q`, etag)
+			}
+			if testCase.expectedStatusCode == http.StatusNotModified && recorder.Body.Len() != 0 {
+				t.Fatalf("expected an empty body, got %q", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestFreezeAccount_IfMatch(t *testing.T) {
+	testCases := []struct {
+		testName           string
+		ifMatch            string
+		expectedStatusCode int
+		expectedCode       string
+		expectedVersion    int64
+		expectCall         bool
+	}{
+		{"no_condition", "", http.StatusOK, "", 0, true},
+		{"any", "*", http.StatusOK, "", 0, true},
+		{"current_tag", `"2"`, http.StatusOK, "", 2, true},
+		{"stale_tag", `"1"`, http.StatusPreconditionFailed, "precondition_failed", 1, true},
+		{"weak_tag", `W/"2"`, http.StatusPreconditionFailed, "precondition_failed", 0, false},
+		{"malformed_tag", `2`, http.StatusPreconditionFailed, "precondition_failed", 0, false},
+		{"several_tags", `"1", "2"`, http.StatusBadRequest, "invalid_request", 0, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			called := false
+			var gotVersion int64
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			handler := NewHandler(fakeService{setAccountStatusFunc: func(accountID int64, status string, expectedVersion int64) (*domain.Account, error) {
+				called, gotVersion = true, expectedVersion
+				if expectedVersion != 0 && expectedVersion != 2 {
+					return nil, service.ErrVersionMismatch
+				}
+				return &domain.Account{ID: accountID, Balance: decimal.NewFromInt(10), Status: status, Version: 3}, nil
+			}})
+			router.POST("/api/v1/accounts/:account_id/freeze", handler.FreezeAccount)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/accounts/5/freeze", nil)
+			if testCase.ifMatch != "" {
+				req.Header.Set("If-Match", testCase.ifMatch)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if called != testCase.expectCall || gotVersion != testCase.expectedVersion {
+				t.Fatalf("expected call %t with version %d, got %t with %d", testCase.expectCall, testCase.expectedVersion, called, gotVersion)
+			}
+			if testCase.expectedCode == "" {
+				if etag := recorder.Header().Get("ETag"); etag != `"3"` {
+					t.Fatalf(`expected ETag "3", got %q`, etag)
+				}
+				return
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedCode {
+				t.Fatalf("expected error code %s, got %s", testCase.expectedCode, response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestListTransactions_TypeFilter(t *testing.T) {
+	var gotFilter domain.TransactionFilter
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	handler := NewHandler(fakeService{listTransactionsFunc: func(accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+		gotFilter = filter
+		if filter.Type == "refund" {
+			return nil, service.ErrInvalidTransactionType
+		}
+		return []domain.Transaction{{ID: 9, Type: domain.TransactionTypeDeposit, SourceAccountID: 900, DestinationAccountID: accountID, Amount: decimal.NewFromInt(5)}}, nil
+	}})
+	router.GET("/api/v1/accounts/:account_id/transactions", handler.ListTransactions)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts/1/transactions?type=deposit", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if gotFilter.Type != domain.TransactionTypeDeposit {
+		t.Fatalf("expected type filter deposit, got %q", gotFilter.Type)
+	}
+	var response TransactionListResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(response.Transactions) != 1 || response.Transactions[0].Type != domain.TransactionTypeDeposit {
+		t.Fatalf("expected one deposit, got %+v", response.Transactions)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/accounts/1/transactions?type=refund", nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", recorder.Code)
+	}
+}
+
+func TestDepositAndWithdraw(t *testing.T) {
+	testCases := []struct {
+		testName           string
+		path               string
+		body               string
+		serviceError       error
+		expectedStatusCode int
+		expectedErrorCode  string
+	}{
+		{"deposit", "/api/v1/deposits", `{"account_id":1,"amount":"25.50"}`, nil, http.StatusCreated, ""},
+		{"withdrawal", "/api/v1/withdrawals", `{"account_id":1,"amount":"25.50"}`, nil, http.StatusCreated, ""},
+		{"missing_account", "/api/v1/deposits", `{"amount":"25.50"}`, nil, http.StatusBadRequest, "invalid_request"},
+		{"over_limit", "/api/v1/deposits", `{"account_id":1,"amount":"25.50"}`, service.ErrAmountLimitExceeded, http.StatusBadRequest, "amount_limit_exceeded"},
+		{"clearing_account", "/api/v1/withdrawals", `{"account_id":1,"amount":"25.50"}`, service.ErrClearingAccount, http.StatusBadRequest, "clearing_account"},
+		{"not_configured", "/api/v1/withdrawals", `{"account_id":1,"amount":"25.50"}`, service.ErrFundingUnavailable, http.StatusNotImplemented, "funding_unavailable"},
+		{"insufficient_balance", "/api/v1/withdrawals", `{"account_id":1,"amount":"25.50"}`, service.ErrInsufficientBalance, http.StatusConflict, "insufficient_balance"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			move := func(transactionType string) func(int64, decimal.Decimal) (*domain.Transaction, error) {
+				return func(accountID int64, amount decimal.Decimal) (*domain.Transaction, error) {
+					if testCase.serviceError != nil {
+						return nil, testCase.serviceError
+					}
+					return &domain.Transaction{ID: 3, Type: transactionType, SourceAccountID: accountID, DestinationAccountID: 900, Amount: amount}, nil
+				}
+			}
+			router := gin.New()
+			router.Use(RequestID(), Recovery())
+			handler := NewHandler(fakeService{
+				depositFunc:  move(domain.TransactionTypeDeposit),
+				withdrawFunc: move(domain.TransactionTypeWithdrawal),
+			})
+			router.POST("/api/v1/deposits", handler.Deposit)
+			router.POST("/api/v1/withdrawals", handler.Withdraw)
+
+			req := httptest.NewRequest(http.MethodPost, testCase.path, strings.NewReader(testCase.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatusCode, recorder.Code)
+			}
+			if testCase.expectedErrorCode == "" {
+				var response TransactionResponse
+				if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to parse response: %v", err)
+				}
+				if response.Type != testCase.testName || !response.Amount.Equal(decimal.RequireFromString("25.50")) {
+					t.Fatalf("unexpected response %+v", response)
+				}
+				return
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if response.Error.Code != testCase.expectedErrorCode {
+				t.Fatalf("expected error code %s, got %s", testCase.expectedErrorCode, response.Error.Code)
+			}
+		})
+	}
+}
+
+func newBalanceRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	handler := NewHandler(fakeService{
+		balanceAtFunc: func(accountID int64, asOf time.Time) (*domain.AccountBalance, error) {
+			if accountID == 404 {
+				return nil, service.ErrAccountNotFound
+			}
+			return &domain.AccountBalance{AccountID: accountID, Balance: decimal.RequireFromString("70.5")}, nil
+		},
+		balancesAtFunc: func(accountIDs []int64, asOf time.Time) (*domain.BalanceReport, error) {
+			if len(accountIDs) > service.MaxBalanceAccounts {
+				return nil, service.ErrInvalidBalanceQuery
+			}
+			report := &domain.BalanceReport{AsOf: asOf}
+			for _, accountID := range accountIDs {
+				report.Balances = append(report.Balances, domain.AccountBalance{AccountID: accountID, Balance: decimal.NewFromInt(accountID)})
+			}
+			return report, nil
+		},
+	})
+	router.GET("/api/v1/accounts/:account_id/balance", handler.GetBalance)
+	router.GET("/api/v1/balances", handler.ListBalances)
+	return router
+}
+
+func TestGetBalance(t *testing.T) {
+	router := newBalanceRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts/1/balance?as_of=2026-01-31T23:59:59%2B02:00", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var response BalanceResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if response.AccountID != 1 || !response.Balance.Equal(decimal.RequireFromString("70.5")) ||
+		!response.AsOf.Equal(time.Date(2026, time.January, 31, 21, 59, 59, 0, time.UTC)) {
+		t.Fatalf("unexpected balance %+v", response)
+	}
+
+	before := time.Now()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/accounts/1/balance", nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if response.AsOf.Before(before) {
+		t.Fatalf("expected as_of to default to now, got %s", response.AsOf)
+	}
+
+	for _, testCase := range []struct {
+		target string
+		status int
+		code   string
+	}{
+		{"/api/v1/accounts/1/balance?as_of=2026-01-31", http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts/x/balance", http.StatusBadRequest, "invalid_account_ids"},
+		{"/api/v1/accounts/404/balance", http.StatusNotFound, "account_not_found"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, testCase.target, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != testCase.status {
+			t.Fatalf("%s: expected status %d, got %d", testCase.target, testCase.status, recorder.Code)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if response.Error.Code != testCase.code {
+			t.Fatalf("%s: expected error code %s, got %s", testCase.target, testCase.code, response.Error.Code)
+		}
+	}
+}
+
+func TestListBalances(t *testing.T) {
+	router := newBalanceRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/balances?account_ids=3,1&as_of=2026-01-31T23:59:59Z", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	var report domain.BalanceReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if !report.AsOf.Equal(time.Date(2026, time.January, 31, 23, 59, 59, 0, time.UTC)) || len(report.Balances) != 2 ||
+		report.Balances[0].AccountID != 3 || report.Balances[1].AccountID != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	tooMany := strings.Repeat("1,", service.MaxBalanceAccounts) + "1"
+	for _, target := range []string{
+		"/api/v1/balances",
+		"/api/v1/balances?account_ids=1,x",
+		"/api/v1/balances?account_ids=1,-2",
+		"/api/v1/balances?account_ids=1&as_of=yesterday",
+		"/api/v1/balances?account_ids=" + tooMany,
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", target, recorder.Code)
+		}
+	}
+}
+
+func newAccountListRouter(listed *domain.AccountFilter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	handler := NewHandler(fakeService{
+		createAccountFunc: func(account domain.Account) (*domain.Account, error) { return &account, nil },
+		listAccountsFunc: func(filter domain.AccountFilter) ([]domain.Account, error) {
+			*listed = filter
+			if filter.Status == "closed" {
+				return nil, service.ErrInvalidAccountQuery
+			}
+			if len(filter.Tags) > 0 && filter.Tags[0] == "a/b" {
+				return nil, service.ErrInvalidAccountTags
+			}
+			accounts := make([]domain.Account, 0, filter.Limit)
+			for i := range filter.Limit {
+				accounts = append(accounts, domain.Account{ID: int64(i + 1), Balance: decimal.NewFromInt(int64(10 * (i + 1)))})
+			}
+			return accounts, nil
+		},
+		batchGetAccountsFunc: func(accountIDs []int64) (*domain.AccountBatch, error) {
+			batch := &domain.AccountBatch{}
+			for _, accountID := range accountIDs {
+				if accountID == 404 {
+					batch.NotFound = append(batch.NotFound, accountID)
+					continue
+				}
+				batch.Accounts = append(batch.Accounts, domain.Account{ID: accountID, Status: domain.AccountStatusActive})
+			}
+			return batch, nil
+		},
+	})
+	v1 := router.Group("/api/v1")
+	v1.GET("/accounts", handler.ListAccounts)
+	v1.POST("/accounts", handler.CreateAccount)
+	v1.POST("/:method", handler.CustomMethod)
+	return router
+}
+
+func TestListAccounts(t *testing.T) {
+	var listed domain.AccountFilter
+	router := newAccountListRouter(&listed)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts?status=active&min_balance=10&max_balance=99.5&tags=vip,%20retail"+
+		"&created_since=2026-01-01T00:00:00Z&sort=balance&order=desc&limit=2", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if listed.Status != domain.AccountStatusActive || !listed.MinBalance.Equal(decimal.NewFromInt(10)) || !listed.MaxBalance.Equal(decimal.RequireFromString("99.5")) ||
+		!slices.Equal(listed.Tags, []string{"vip", "retail"}) || !listed.CreatedSince.Equal(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)) ||
+		!listed.CreatedUntil.IsZero() || listed.Sort != domain.AccountSortBalance || !listed.Descending || listed.Limit != 2 || listed.After != nil {
+		t.Fatalf("unexpected filter %+v", listed)
+	}
+	var response AccountListResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(response.Accounts) != 2 || response.NextPageToken == "" {
+		t.Fatalf("expected a full page with a next page token, got %+v", response)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/accounts?sort=balance&order=desc&limit=2&page_token="+response.NextPageToken, nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if listed.After == nil || listed.After.ID != 2 || !listed.After.Balance.Equal(decimal.NewFromInt(20)) {
+		t.Fatalf("expected the page to continue after account 2, got %+v", listed.After)
+	}
+
+	for _, testCase := range []struct {
+		target string
+		status int
+		code   string
+	}{
+		{"/api/v1/accounts?sort=balance&page_token=" + response.NextPageToken, http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts?page_token=not-a-token", http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts?order=sideways", http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts?min_balance=ten", http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts?created_until=yesterday", http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts?limit=0", http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts?status=closed", http.StatusBadRequest, "invalid_request"},
+		{"/api/v1/accounts?tags=a/b", http.StatusBadRequest, "invalid_tags"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, testCase.target, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != testCase.status {
+			t.Fatalf("%s: expected status %d, got %d", testCase.target, testCase.status, recorder.Code)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if response.Error.Code != testCase.code {
+			t.Fatalf("%s: expected error code %s, got %s", testCase.target, testCase.code, response.Error.Code)
+		}
+	}
+}
+
+func TestBatchGetAccounts(t *testing.T) {
+	var listed domain.AccountFilter
+	router := newAccountListRouter(&listed)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/accounts:batchGet", strings.NewReader(`{"account_ids":[3,404,1]}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response BatchGetAccountsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(response.Accounts) != 2 || response.Accounts[0].AccountID != 3 || response.Accounts[1].AccountID != 1 || !slices.Equal(response.NotFound, []int64{404}) {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/accounts:batchDelete", strings.NewReader(`{"account_ids":[1]}`))
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for an unknown method, got %d", recorder.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/accounts", strings.NewReader(`{"account_id":1,"initial_balance":"10","tags":["vip"]}`))
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected account creation to keep its route, got %d", recorder.Code)
+	}
+	var created AccountResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if !slices.Equal(created.Tags, []string{"vip"}) {
+		t.Fatalf("expected the account to be created with its tags, got %+v", created)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tareqpi/transfer-system/internal/health"
+)
+
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{Checker: checker}
+}
+
+func (handler *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+func (handler *HealthHandler) Readiness(c *gin.Context) {
+	if handler.Checker.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": health.StatusDraining})
+		return
+	}
+	report := handler.Checker.Run(c.Request.Context())
+	c.JSON(reportStatusCode(report), gin.H{"status": report.Status})
+}
+
+func (handler *HealthHandler) Status(c *gin.Context) {
+	report := handler.Checker.Run(c.Request.Context())
+	c.JSON(reportStatusCode(report), report)
+}
+
+func reportStatusCode(report health.Report) int {
+	if report.Status != health.StatusUp {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tareqpi/transfer-system/internal/health"
+)
+
+func newHealthRouter(checker *health.Checker) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	handler := NewHealthHandler(checker)
+	router.GET("/healthz", handler.Liveness)
+	router.GET("/readyz", handler.Readiness)
+	router.GET("/status", handler.Status)
+	return router
+}
+
+func TestReadiness(t *testing.T) {
+	testCases := []struct {
+		testName           string
+		checkErr           error
+		draining           bool
+		expectedStatusCode int
+		expectedStatus     string
+	}{
+		{"ready", nil, false, http.StatusOK, health.StatusUp},
+		{"failing_check", errTest, false, http.StatusServiceUnavailable, health.StatusDown},
+		{"draining", nil, true, http.StatusServiceUnavailable, health.StatusDraining},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			checker.Register("database", func(context.Context) error { return testCase.checkErr })
+			checker.SetDraining(testCase.draining)
+			router := newHealthRouter(checker)
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatusCode, recorder.Code)
+			}
+			var response map[string]string
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if response["status"] != testCase.expectedStatus {
+				t.Fatalf("expected status %s, got %s", testCase.expectedStatus, response["status"])
+			}
+		})
+	}
+}
+
+func TestLiveness_IgnoresChecks(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Register("database", func(context.Context) error { return errTest })
+	router := newHealthRouter(checker)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+}
+
+func TestStatus_ReportsComponents(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Register("database", func(context.Context) error { return nil })
+	checker.Register("migrations", func(context.Context) error { return errTest })
+	router := newHealthRouter(checker)
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", recorder.Code)
+	}
+	var report health.Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(report.Components) != 2 {
+		t.Fatalf("expected 2 components, got %d", len(report.Components))
+	}
+	if report.Components[0].Status != health.StatusUp || report.Components[1].Status != health.StatusDown {
+		t.Fatalf("unexpected component statuses: %+v", report.Components)
+	}
+	if report.Components[1].Error != errTest.Error() {
+		t.Fatalf("expected component error %q, got %q", errTest.Error(), report.Components[1].Error)
+	}
+}
@@ -1,16 +1,33 @@
 package api
 
 import (
+	"cmp"
+	"crypto/subtle"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/tareqpi/transfer-system/internal/audit"
 	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-const headerRequestID = "X-Request-ID"
+const (
+	headerRequestID = "X-Request-ID"
+	headerTraceID   = "X-Trace-ID"
+	headerActor     = "X-Actor"
+)
+
+// adminActor is recorded for admin requests that do not name an actor.
+const adminActor = "admin"
 
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -21,6 +38,34 @@ func RequestID() gin.HandlerFunc {
 		}
 		c.Writer.Header().Set(headerRequestID, requestID)
 		c.Set("request_id", requestID)
+
+		if span := trace.SpanFromContext(c.Request.Context()); span.SpanContext().IsValid() {
+			traceID := span.SpanContext().TraceID().String()
+			span.SetAttributes(attribute.String("request.id", requestID))
+			c.Writer.Header().Set(headerTraceID, traceID)
+			c.Set("trace_id", traceID)
+		}
+		c.Next()
+	}
+}
+
+// Audit attaches who made the request and from where to the request context,
+// so that the repository records it with every mutation. The actor is read
+// from X-Actor, which the gateway in front of the API is expected to set, but
+// only on connections from trustedProxies; anyone else could name any actor.
+func Audit(trustedProxies []netip.Prefix) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var actor string
+		if remote, err := netip.ParseAddr(c.RemoteIP()); err == nil && slices.ContainsFunc(trustedProxies, func(prefix netip.Prefix) bool {
+			return prefix.Contains(remote.Unmap())
+		}) {
+			actor = c.GetHeader(headerActor)
+		}
+		c.Request = c.Request.WithContext(audit.WithMetadata(c.Request.Context(), audit.Metadata{
+			Actor:     actor,
+			RequestID: c.GetString("request_id"),
+			ClientIP:  c.ClientIP(),
+		}))
 		c.Next()
 	}
 }
@@ -32,6 +77,7 @@ func Logging() gin.HandlerFunc {
 		query := c.Request.URL.RawQuery
 		method := c.Request.Method
 		requestID := c.GetString("request_id")
+		traceID := c.GetString("trace_id")
 
 		c.Next()
 
@@ -41,6 +87,7 @@ func Logging() gin.HandlerFunc {
 
 		fields := []zap.Field{
 			zap.String("request_id", requestID),
+			zap.String("trace_id", traceID),
 			zap.Int("status", status),
 			zap.Duration("latency_ms", latency),
 			zap.String("method", method),
@@ -58,6 +105,54 @@ func Logging() gin.HandlerFunc {
 	}
 }
 
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// AdminAuth requires the configured bearer token. Without a token the admin
+// endpoints are disabled rather than left open. Holders of the token are
+// trusted to name themselves in X-Actor.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			WriteError(c, http.StatusForbidden, "admin_disabled", "admin endpoints are disabled; set ADMIN_TOKEN to enable them")
+			return
+		}
+		presented, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			WriteError(c, http.StatusUnauthorized, "unauthorized", "a valid admin bearer token is required")
+			return
+		}
+		if metadata := audit.FromContext(c.Request.Context()); metadata.Actor == audit.AnonymousActor {
+			metadata.Actor = cmp.Or(c.GetHeader(headerActor), adminActor)
+			c.Request = c.Request.WithContext(audit.WithMetadata(c.Request.Context(), metadata))
+		}
+		c.Next()
+	}
+}
+
 func Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
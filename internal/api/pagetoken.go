@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+var errInvalidPageToken = errors.New("page_token is not valid for this sort and order")
+
+// accountPageToken is the position of the last account of a page, with the
+// sort and order the page was listed in so that a token is not applied to a
+// different ordering.
+type accountPageToken struct {
+	Sort       string          `json:"s"`
+	Descending bool            `json:"d,omitempty"`
+	ID         int64           `json:"i"`
+	Balance    decimal.Decimal `json:"b"`
+	CreatedAt  time.Time       `json:"c"`
+}
+
+// encodeAccountPageToken returns the opaque token of the accounts listed
+// after account.
+func encodeAccountPageToken(account *domain.Account, sort string, descending bool) string {
+	encoded, _ := json.Marshal(accountPageToken{
+		Sort:       sort,
+		Descending: descending,
+		ID:         account.ID,
+		Balance:    account.Balance,
+		CreatedAt:  account.CreatedAt,
+	})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeAccountPageToken(value, sort string, descending bool) (*domain.AccountCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidPageToken
+	}
+	var token accountPageToken
+	if err := json.Unmarshal(decoded, &token); err != nil {
+		return nil, errInvalidPageToken
+	}
+	if token.Sort != sort || token.Descending != descending || token.ID <= 0 {
+		return nil, errInvalidPageToken
+	}
+	return &domain.AccountCursor{ID: token.ID, Balance: token.Balance, CreatedAt: token.CreatedAt}, nil
+}
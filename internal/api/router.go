@@ -1,17 +1,101 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/tareqpi/transfer-system/internal/config"
-	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/health"
+	"github.com/tareqpi/transfer-system/internal/metrics"
 	"github.com/tareqpi/transfer-system/internal/service"
-	"go.uber.org/zap"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func Setup(applicationService service.Service) {
+func NewRouter(applicationService service.Service, checker *health.Checker, adminHandler *AdminHandler) *gin.Engine {
+	appConfig := config.Get()
+	// The configuration was validated when it was loaded.
+	trustedProxies, _ := appConfig.Server.TrustedProxyPrefixes()
+
 	router := gin.New()
-	router.Use(RequestID(), Logging(), Recovery())
+	// gin believes X-Forwarded-For from any peer by default, which would let
+	// clients choose the address written to the audit log. The prefixes were
+	// parsed already, so they cannot be rejected here.
+	var proxies []string
+	for _, prefix := range trustedProxies {
+		proxies = append(proxies, prefix.String())
+	}
+	_ = router.SetTrustedProxies(proxies)
+	router.Use(
+		otelgin.Middleware(appConfig.Tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/metrics", "/healthz", "/readyz":
+				return false
+			}
+			return true
+		})),
+		RequestID(), Audit(trustedProxies), Logging(), Metrics(), Recovery(), BodyLimit(appConfig.Server.MaxBodyBytes),
+	)
+
+	if appConfig.Features.Metrics {
+		router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
+	healthHandler := NewHealthHandler(checker)
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+	router.GET("/status", healthHandler.Status)
+
+	if appConfig.Features.Docs {
+		registerDocs(router)
+	}
+
+	v1 := router.Group("/api/v1")
+	handler := NewHandler(applicationService)
 
+	account := v1.Group("/accounts")
+	{
+		account.GET("", handler.ListAccounts)
+		account.POST("", handler.CreateAccount)
+		account.GET("/:account_id", handler.GetAccount)
+		account.GET("/:account_id/balance", handler.GetBalance)
+		account.GET("/:account_id/transactions", handler.ListTransactions)
+		account.POST("/:account_id/freeze", handler.FreezeAccount)
+		account.POST("/:account_id/unfreeze", handler.UnfreezeAccount)
+	}
+
+	transaction := v1.Group("/transactions")
+	{
+		transaction.POST("", handler.TransferMoney)
+		transaction.POST("/:transaction_id/reverse", handler.ReverseTransaction)
+	}
+
+	v1.GET("/balances", handler.ListBalances)
+	v1.POST("/deposits", handler.Deposit)
+	v1.POST("/withdrawals", handler.Withdraw)
+	v1.POST("/:method", handler.CustomMethod)
+
+	admin := router.Group("/admin", AdminAuth(appConfig.Admin.Token))
+	{
+		admin.GET("/reconciliation/latest", adminHandler.LatestReconciliation)
+		admin.GET("/audit", adminHandler.ListAuditEntries)
+		admin.PUT("/accounts/:account_id/shards", adminHandler.SetAccountShards)
+		admin.GET("/fees/rules", adminHandler.ListFeeRules)
+		admin.POST("/fees/rules", adminHandler.CreateFeeRule)
+		admin.PUT("/fees/rules/:rule_id", adminHandler.UpdateFeeRule)
+		admin.DELETE("/fees/rules/:rule_id", adminHandler.DeleteFeeRule)
+		admin.PUT("/accounts/:account_id/interest", adminHandler.SetInterestRate)
+		admin.GET("/interest/rates", adminHandler.ListInterestRates)
+		admin.GET("/interest/report", adminHandler.InterestReport)
+		admin.POST("/interest/runs", adminHandler.RunInterest)
+		admin.POST("/imports", adminHandler.CreateImport)
+		admin.GET("/imports/:import_id", adminHandler.GetImport)
+		admin.GET("/imports/:import_id/results", adminHandler.GetImportResults)
+		admin.POST("/imports/:import_id/resume", adminHandler.ResumeImport)
+	}
+	return router
+}
+
+func registerDocs(router *gin.Engine) {
 	router.StaticFile("/openapi.yaml", "/docs/openapi.yaml")
 
 	router.GET("/docs", func(c *gin.Context) {
@@ -31,20 +115,4 @@ func Setup(applicationService service.Service) {
 </html>`
 		c.Data(200, "text/html; charset=utf-8", []byte(html))
 	})
-	v1 := router.Group("/api/v1")
-	handler := NewHandler(applicationService)
-
-	account := v1.Group("/accounts")
-	{
-		account.POST("", handler.CreateAccount)
-		account.GET("/:account_id", handler.GetAccount)
-	}
-
-	transaction := v1.Group("/transactions")
-	{
-		transaction.POST("", handler.TransferMoney)
-	}
-	if err := router.Run(":" + config.Get().Port); err != nil {
-		logger.L().Fatal("failed to start HTTP server", zap.Error(err))
-	}
 }
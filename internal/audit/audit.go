@@ -0,0 +1,31 @@
+// Package audit carries the identity of the caller from the edge of the
+// system down to the repository, which records it next to every mutation.
+package audit
+
+import "context"
+
+// AnonymousActor is recorded when the caller did not identify itself.
+const AnonymousActor = "anonymous"
+
+// Metadata describes who made a request and from where.
+type Metadata struct {
+	Actor     string
+	RequestID string
+	ClientIP  string
+}
+
+type contextKey struct{}
+
+func WithMetadata(ctx context.Context, metadata Metadata) context.Context {
+	return context.WithValue(ctx, contextKey{}, metadata)
+}
+
+// FromContext returns the metadata attached to ctx. The actor falls back to
+// AnonymousActor so that every audit entry names one.
+func FromContext(ctx context.Context) Metadata {
+	metadata, _ := ctx.Value(contextKey{}).(Metadata)
+	if metadata.Actor == "" {
+		metadata.Actor = AnonymousActor
+	}
+	return metadata
+}
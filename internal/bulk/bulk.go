@@ -0,0 +1,205 @@
+// Package bulk imports files of account creations or transfers. Every row of
+// a file is validated before any is executed; the rows are then executed in
+// order, in chunks after each of which the progress is checkpointed so that
+// an interrupted import resumes where it stopped, and the outcome of every
+// row is written to a results file.
+package bulk
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+const (
+	KindAccounts  = "accounts"
+	KindTransfers = "transfers"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// A job is pending until its rows have been validated, rejected when any
+// row is invalid, in which case nothing is executed, and interrupted when it
+// stopped before its last row and can be resumed.
+const (
+	StatusPending     = "pending"
+	StatusRejected    = "rejected"
+	StatusRunning     = "running"
+	StatusInterrupted = "interrupted"
+	StatusCompleted   = "completed"
+)
+
+// Outcomes of the rows in a results file. Valid rows of a rejected file are
+// reported as valid and were not executed.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeInvalid   = "invalid"
+	OutcomeValid     = "valid"
+)
+
+var (
+	ErrUnknownKind   = errors.New("kind must be accounts or transfers")
+	ErrUnknownFormat = errors.New("format must be csv or jsonl")
+	ErrInvalidFile   = errors.New("invalid import file")
+	ErrInputChanged  = errors.New("the import file has changed since the import started")
+	ErrJobNotFound   = errors.New("import not found")
+	ErrJobActive     = errors.New("import is already queued or running")
+	ErrJobFinished   = errors.New("import has already finished")
+	ErrNoResults     = errors.New("import has no results yet")
+)
+
+// Service is the part of service.Service an import executes rows with.
+type Service interface {
+	CreateAccount(ctx context.Context, newAccount domain.Account) (*domain.Account, error)
+	TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error)
+	BatchGetAccounts(ctx context.Context, accountIDs []int64) (*domain.AccountBatch, error)
+	ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
+	ValidateAccount(newAccount domain.Account) error
+	ValidateTransfer(transaction domain.Transaction) error
+}
+
+// Job is the progress of an import. It is stored as JSON and rewritten at
+// every checkpoint.
+type Job struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Format      string    `json:"format"`
+	Status      string    `json:"status"`
+	Actor       string    `json:"actor"`
+	InputSHA256 string    `json:"input_sha256"`
+	Rows        int       `json:"rows"`
+	Invalid     int       `json:"invalid"`
+	Processed   int       `json:"processed"`
+	Succeeded   int       `json:"succeeded"`
+	Failed      int       `json:"failed"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// ResultsSize is the length of the results file up to the last processed
+	// row. A run that stopped between checkpoints may have written past it;
+	// that part is discarded on resume.
+	ResultsSize int64 `json:"results_size"`
+}
+
+// Finished reports whether the job has nothing left to execute.
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusRejected
+}
+
+// Files are the paths of the file a job imports, of its stored progress and
+// of its results.
+type Files struct {
+	Input   string
+	State   string
+	Results string
+}
+
+func IsKind(value string) bool {
+	return value == KindAccounts || value == KindTransfers
+}
+
+func IsFormat(value string) bool {
+	return value == FormatCSV || value == FormatJSONL
+}
+
+// FormatOf returns the format of a file from its extension.
+func FormatOf(path string) (string, error) {
+	switch filepath.Ext(path) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".jsonl", ".ndjson":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("%s: %w, use a .csv or .jsonl file", path, ErrUnknownFormat)
+}
+
+// LoadJob reads the job stored at path. The error wraps os.ErrNotExist when
+// there is none.
+func LoadJob(path string) (*Job, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(contents, &job); err != nil {
+		return nil, fmt.Errorf("read import %s: %w", path, err)
+	}
+	return &job, nil
+}
+
+// saveJob writes job to a temporary file first so that a reader, or a
+// resume after a crash, never sees a partial one.
+func saveJob(path string, job *Job) error {
+	contents, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("save import: %w", err)
+	}
+	temporary, err := os.CreateTemp(filepath.Dir(path), ".import-*.json")
+	if err != nil {
+		return fmt.Errorf("save import: %w", err)
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(append(contents, '\n')); err != nil {
+		temporary.Close()
+		return fmt.Errorf("save import: %w", err)
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return fmt.Errorf("save import: %w", err)
+	}
+	if err := temporary.Close(); err != nil {
+		return fmt.Errorf("save import: %w", err)
+	}
+	if err := os.Rename(temporary.Name(), path); err != nil {
+		return fmt.Errorf("save import: %w", err)
+	}
+	return nil
+}
+
+func newJobID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func isJobID(value string) bool {
+	if len(value) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+// requestID is recorded in the audit log next to the mutation of a row, which
+// is how a resumed import finds the rows that ran after its last checkpoint.
+func requestID(jobID string, line int) string {
+	return "import:" + jobID + ":" + strconv.Itoa(line)
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
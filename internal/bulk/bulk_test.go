@@ -0,0 +1,355 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/service"
+)
+
+// newLedger returns a service over a memory repository holding accounts with
+// the given balances, keyed by ID.
+func newLedger(t *testing.T, balances map[int64]string) (service.Service, *repository.MemoryRepository) {
+	t.Helper()
+	repo := repository.NewMemoryRepository()
+	for id, balance := range balances {
+		if _, err := repo.CreateAccount(context.Background(), domain.Account{ID: id, Balance: decimal.RequireFromString(balance)}); err != nil {
+			t.Fatalf("create account %d: %v", id, err)
+		}
+	}
+	return service.NewService(repo), repo
+}
+
+// writeImport writes contents to a file named name and returns the files of
+// its import.
+func writeImport(t *testing.T, name, contents string) Files {
+	t.Helper()
+	dir := t.TempDir()
+	files := Files{
+		Input:   filepath.Join(dir, name),
+		State:   filepath.Join(dir, name+".import.json"),
+		Results: filepath.Join(dir, name+".results.csv"),
+	}
+	if err := os.WriteFile(files.Input, []byte(contents), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	return files
+}
+
+func runImport(t *testing.T, importer *Importer, files Files, kind string) *Job {
+	t.Helper()
+	format, err := FormatOf(files.Input)
+	if err != nil {
+		t.Fatalf("expected a known format, got %v", err)
+	}
+	if _, err := importer.Create(files, kind, format, "importer"); err != nil {
+		t.Fatalf("create import: %v", err)
+	}
+	job, err := importer.Run(context.Background(), files, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return job
+}
+
+func readResults(t *testing.T, files Files) []string {
+	t.Helper()
+	contents, err := os.ReadFile(files.Results)
+	if err != nil {
+		t.Fatalf("read results: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(contents)), "\n")
+}
+
+func balanceOf(t *testing.T, applicationService service.Service, id string) string {
+	t.Helper()
+	account, err := applicationService.GetAccount(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get account %s: %v", id, err)
+	}
+	return account.Balance.String()
+}
+
+func TestImporter_CreatesAccounts(t *testing.T) {
+	t.Parallel()
+	applicationService, _ := newLedger(t, nil)
+	files := writeImport(t, "accounts.csv", "account_id,initial_balance,tier,tags\n1,100,gold,vip region:eu\n2,0.5,,\n\n3,7,,\n")
+
+	job := runImport(t, NewImporter(applicationService, 2), files, KindAccounts)
+	if job.Status != StatusCompleted || job.Rows != 3 || job.Processed != 3 || job.Succeeded != 3 || job.Failed != 0 {
+		t.Fatalf("expected 3 succeeded rows, got %+v", job)
+	}
+	expected := []string{"line,outcome,account_id,error", "2,succeeded,1,", "3,succeeded,2,", "5,succeeded,3,"}
+	if results := readResults(t, files); !slices.Equal(results, expected) {
+		t.Fatalf("expected results %q, got %q", expected, results)
+	}
+
+	account, err := applicationService.GetAccount(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("get account: %v", err)
+	}
+	if account.Tier != "gold" || !slices.Equal(account.Tags, []string{"region:eu", "vip"}) || !account.Balance.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("unexpected account %+v", account)
+	}
+	stored, err := LoadJob(files.State)
+	if err != nil || stored.Status != StatusCompleted {
+		t.Fatalf("expected the completed job to be stored, got %+v, %v", stored, err)
+	}
+}
+
+func TestImporter_RecordsFailedTransfers(t *testing.T) {
+	t.Parallel()
+	applicationService, _ := newLedger(t, map[int64]string{1: "100", 2: "0"})
+	files := writeImport(t, "transfers.jsonl", `{"source_account_id": 1, "destination_account_id": 2, "amount": "60"}
+{"source_account_id": 1, "destination_account_id": 2, "amount": "60"}
+{"source_account_id": 2, "destination_account_id": 1, "amount": "10.25"}
+`)
+
+	job := runImport(t, NewImporter(applicationService, DefaultChunkSize), files, KindTransfers)
+	if job.Status != StatusCompleted || job.Succeeded != 2 || job.Failed != 1 {
+		t.Fatalf("expected 2 succeeded and 1 failed rows, got %+v", job)
+	}
+	results := readResults(t, files)
+	if len(results) != 4 || !strings.HasPrefix(results[1], "1,succeeded,") || results[2] != "2,failed,,1,2,60,insufficient balance" || !strings.HasPrefix(results[3], "3,succeeded,") {
+		t.Fatalf("unexpected results %q", results)
+	}
+	if balance := balanceOf(t, applicationService, "1"); balance != "50.25" {
+		t.Fatalf("expected balance 50.25, got %s", balance)
+	}
+}
+
+func TestImporter_RejectsFilesWithInvalidRows(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		testName        string
+		kind            string
+		name            string
+		contents        string
+		expectedInvalid int
+		expectedResults []string
+		expectedError   string
+	}{
+		{
+			testName: "transfers", kind: KindTransfers, name: "transfers.csv",
+			contents:        "source_account_id,destination_account_id,amount\n1,2,10\n1,1,5\n1,9,5\n1,2,abc\n1,2,0.00001\n1,2\n",
+			expectedInvalid: 5,
+			expectedResults: []string{
+				"line,outcome,transaction_id,source_account_id,destination_account_id,amount,error",
+				"2,valid,,1,2,10,",
+				"3,invalid,,1,1,5,source and destination account IDs cannot be the same",
+				"4,invalid,,1,9,5,account 9 not found",
+				`5,invalid,,,,,"amount: ""abc"" is not a decimal amount"`,
+				"6,invalid,,1,2,0.00001,amount has too many decimal places: amounts may have at most 4 decimal places",
+				"7,invalid,,,,,\"expected 3 fields, got 2\"",
+			},
+		},
+		{
+			testName: "accounts", kind: KindAccounts, name: "accounts.jsonl",
+			contents: `{"account_id": 5, "initial_balance": "1"}
+{"account_id": 5, "initial_balance": "2"}
+{"account_id": 1, "initial_balance": "3"}
+{"account_id": 6}
+{"account_id": 7, "initial_balance": "1", "owner": "x"}
+{"account_id": 8, "initial_balance": "1", "tags": ["no spaces"]}
+`,
+			expectedInvalid: 5,
+			expectedResults: []string{
+				"line,outcome,account_id,error",
+				"1,valid,5,",
+				"2,invalid,5,account 5 is also created on line 1",
+				"3,invalid,1,account 1 already exists",
+				"4,invalid,,initial_balance is required",
+				`5,invalid,,"invalid JSON: json: unknown field ""owner"""`,
+			},
+		},
+		{
+			testName: "unknown_column", kind: KindTransfers, name: "transfers.csv",
+			contents:        "source,destination_account_id,amount\n1,2,10\n",
+			expectedResults: []string{"line,outcome,transaction_id,source_account_id,destination_account_id,amount,error"},
+			expectedError:   `invalid import file: unknown column "source"`,
+		},
+		{
+			testName: "empty", kind: KindAccounts, name: "accounts.csv",
+			contents:        "account_id,initial_balance\n",
+			expectedResults: []string{"line,outcome,account_id,error"},
+			expectedError:   "the file has no rows",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			t.Parallel()
+			applicationService, _ := newLedger(t, map[int64]string{1: "100", 2: "0"})
+			files := writeImport(t, testCase.name, testCase.contents)
+
+			job := runImport(t, NewImporter(applicationService, DefaultChunkSize), files, testCase.kind)
+			if job.Status != StatusRejected || job.Invalid != testCase.expectedInvalid || job.Processed != 0 || job.Error != testCase.expectedError {
+				t.Fatalf("expected a rejected job with %d invalid rows and error %q, got %+v", testCase.expectedInvalid, testCase.expectedError, job)
+			}
+			results := readResults(t, files)
+			if len(results) < len(testCase.expectedResults) || !slices.Equal(results[:len(testCase.expectedResults)], testCase.expectedResults) {
+				t.Fatalf("expected results starting with %q, got %q", testCase.expectedResults, results)
+			}
+			if balance := balanceOf(t, applicationService, "1"); balance != "100" {
+				t.Fatalf("expected nothing to be executed, got balance %s", balance)
+			}
+			if _, err := NewImporter(applicationService, DefaultChunkSize).Run(context.Background(), files, nil); !errors.Is(err, ErrJobFinished) {
+				t.Fatalf("expected %v, got %v", ErrJobFinished, err)
+			}
+		})
+	}
+}
+
+var errConnectionLost = errors.New("connection lost")
+
+// lossyService loses the response of the n-th transfer, which is committed
+// all the same.
+type lossyService struct {
+	Service
+	n     int
+	calls int
+}
+
+func (s *lossyService) TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	s.calls++
+	created, err := s.Service.TransferMoney(ctx, transaction)
+	if s.calls == s.n {
+		return nil, errConnectionLost
+	}
+	return created, err
+}
+
+func TestImporter_ResumesWithoutRepeatingRows(t *testing.T) {
+	t.Parallel()
+	applicationService, repo := newLedger(t, map[int64]string{1: "100", 2: "0"})
+	files := writeImport(t, "transfers.csv", "source_account_id,destination_account_id,amount\n1,2,1\n1,2,2\n1,2,3\n1,2,4\n1,2,5\n")
+
+	if _, err := NewImporter(applicationService, 2).Create(files, KindTransfers, FormatCSV, "importer"); err != nil {
+		t.Fatalf("create import: %v", err)
+	}
+	var checkpoints []int
+	job, err := NewImporter(&lossyService{Service: applicationService, n: 3}, 2).Run(context.Background(), files, func(job Job) {
+		checkpoints = append(checkpoints, job.Processed)
+	})
+	if !errors.Is(err, errConnectionLost) {
+		t.Fatalf("expected %v, got %v", errConnectionLost, err)
+	}
+	if job.Status != StatusInterrupted || job.Processed != 2 || !slices.Equal(checkpoints, []int{2}) {
+		t.Fatalf("expected the import to stop after 2 rows, got %+v and checkpoints %v", job, checkpoints)
+	}
+
+	// A crash could also have lost the checkpoint; rows past it are written
+	// again.
+	stored, err := LoadJob(files.State)
+	if err != nil {
+		t.Fatalf("load job: %v", err)
+	}
+	stored.Processed, stored.Succeeded = 1, 1
+	stored.ResultsSize = int64(len("line,outcome,transaction_id,source_account_id,destination_account_id,amount,error\n2,succeeded,1,1,2,1,\n"))
+	if err := saveJob(files.State, stored); err != nil {
+		t.Fatalf("save job: %v", err)
+	}
+
+	job, err = NewImporter(applicationService, 2).Run(context.Background(), files, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if job.Status != StatusCompleted || job.Succeeded != 5 || job.Failed != 0 {
+		t.Fatalf("expected 5 succeeded rows, got %+v", job)
+	}
+	results := readResults(t, files)
+	if len(results) != 6 || results[3] != "4,succeeded,3,1,2,3," {
+		t.Fatalf("unexpected results %q", results)
+	}
+	if balance := balanceOf(t, applicationService, "2"); balance != "15" {
+		t.Fatalf("expected every transfer to run once, got balance %s", balance)
+	}
+	transactions, err := repo.ListTransactions(context.Background(), 2, domain.TransactionFilter{Limit: 10})
+	if err != nil || len(transactions) != 5 {
+		t.Fatalf("expected 5 transactions, got %d, %v", len(transactions), err)
+	}
+}
+
+func TestImporter_RefusesChangedInput(t *testing.T) {
+	t.Parallel()
+	applicationService, _ := newLedger(t, nil)
+	files := writeImport(t, "accounts.csv", "account_id,initial_balance\n1,1\n")
+	importer := NewImporter(applicationService, DefaultChunkSize)
+	if _, err := importer.Create(files, KindAccounts, FormatCSV, "importer"); err != nil {
+		t.Fatalf("create import: %v", err)
+	}
+	if err := os.WriteFile(files.Input, []byte("account_id,initial_balance\n2,1\n"), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	if _, err := importer.Run(context.Background(), files, nil); !errors.Is(err, ErrInputChanged) {
+		t.Fatalf("expected %v, got %v", ErrInputChanged, err)
+	}
+}
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+	applicationService, _ := newLedger(t, map[int64]string{1: "100", 2: "0"})
+	manager := NewManager(t.TempDir(), NewImporter(applicationService, DefaultChunkSize))
+
+	if _, err := manager.Submit("payments", FormatCSV, "alice", strings.NewReader("")); !errors.Is(err, ErrUnknownKind) {
+		t.Fatalf("expected %v, got %v", ErrUnknownKind, err)
+	}
+	job, err := manager.Submit(KindTransfers, FormatCSV, "alice", strings.NewReader("source_account_id,destination_account_id,amount\n1,2,30\n"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if job.Status != StatusPending || job.Actor != "alice" {
+		t.Fatalf("expected a pending job, got %+v", job)
+	}
+	if _, err := manager.Resume(job.ID); !errors.Is(err, ErrJobActive) {
+		t.Fatalf("expected %v, got %v", ErrJobActive, err)
+	}
+	if _, err := manager.ResultsPath(job.ID); !errors.Is(err, ErrNoResults) {
+		t.Fatalf("expected %v, got %v", ErrNoResults, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		manager.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != StatusCompleted {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the import to complete, got %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if job, err = manager.Job(job.ID); err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+	}
+	if job.Succeeded != 1 {
+		t.Fatalf("expected 1 succeeded row, got %+v", job)
+	}
+	if path, err := manager.ResultsPath(job.ID); err != nil || filepath.Base(path) != "results.csv" {
+		t.Fatalf("expected the results file, got %q, %v", path, err)
+	}
+	if _, err := manager.Resume(job.ID); !errors.Is(err, ErrJobFinished) {
+		t.Fatalf("expected %v, got %v", ErrJobFinished, err)
+	}
+	for _, id := range []string{"0123456789abcdef", "../../etc"} {
+		if _, err := manager.Job(id); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected %v for %q, got %v", ErrJobNotFound, id, err)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package bulk
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"github.com/tareqpi/transfer-system/internal/service"
+)
+
+// DefaultChunkSize is the number of rows executed between checkpoints.
+const DefaultChunkSize = 100
+
+// rowErrors fail a single row, which is recorded and skipped. Any other
+// error stops the import, which can then be resumed.
+var rowErrors = []error{
+	service.ErrSameSourceAndDestination,
+	service.ErrNonPositiveAmount,
+	service.ErrInvalidAccountIDs,
+	service.ErrInsufficientBalance,
+	service.ErrAccountNotFound,
+	service.ErrAccountExists,
+	service.ErrAccountFrozen,
+	service.ErrTransactionConflict,
+	service.ErrClearingAccount,
+	service.ErrInvalidAmountPrecision,
+	service.ErrAmountTooLarge,
+	service.ErrInvalidAccountTags,
+	service.ErrTransferDenied,
+}
+
+func isRowError(err error) bool {
+	for _, rowErr := range rowErrors {
+		if errors.Is(err, rowErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Importer validates and executes import jobs.
+type Importer struct {
+	service   Service
+	chunkSize int
+	now       func() time.Time
+}
+
+func NewImporter(bulkService Service, chunkSize int) *Importer {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &Importer{service: bulkService, chunkSize: chunkSize, now: time.Now}
+}
+
+// Create stores a pending job for the file at files.Input. Rows run on behalf
+// of actor.
+func (im *Importer) Create(files Files, kind, format, actor string) (*Job, error) {
+	return im.create(files, newJobID(), kind, format, actor)
+}
+
+func (im *Importer) create(files Files, id, kind, format, actor string) (*Job, error) {
+	if !IsKind(kind) {
+		return nil, ErrUnknownKind
+	}
+	if !IsFormat(format) {
+		return nil, ErrUnknownFormat
+	}
+	checksum, err := fileSHA256(files.Input)
+	if err != nil {
+		return nil, err
+	}
+	now := im.now().UTC()
+	job := &Job{
+		ID:          id,
+		Kind:        kind,
+		Format:      format,
+		Status:      StatusPending,
+		Actor:       actor,
+		InputSHA256: checksum,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := saveJob(files.State, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Run validates a pending job and executes it when every row is valid, or
+// resumes a job that was interrupted. progress, when not nil, is called with
+// the job after every checkpoint. The job is returned along with any error
+// that stopped it, which leaves it pending or interrupted.
+func (im *Importer) Run(ctx context.Context, files Files, progress func(Job)) (*Job, error) {
+	job, err := LoadJob(files.State)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return job, ErrJobFinished
+	}
+
+	checksum, err := fileSHA256(files.Input)
+	if err != nil {
+		return job, err
+	}
+	if checksum != job.InputSHA256 {
+		return job, ErrInputChanged
+	}
+
+	resuming := job.Status != StatusPending
+	if !resuming {
+		check, err := im.validate(ctx, files, job)
+		if errors.Is(err, ErrInvalidFile) {
+			job.Error = err.Error()
+			return job, im.reject(files, job, nil)
+		}
+		if err != nil {
+			job.Error = err.Error()
+			return job, errors.Join(err, im.save(files, job))
+		}
+		if job.Invalid > 0 || job.Rows == 0 {
+			if job.Rows == 0 {
+				job.Error = "the file has no rows"
+			}
+			return job, im.reject(files, job, check)
+		}
+	}
+
+	job.Status, job.Error = StatusRunning, ""
+	if err := im.save(files, job); err != nil {
+		return job, err
+	}
+	results, err := openResults(files.Results, job.Kind, job.ResultsSize)
+	if err != nil {
+		return job, err
+	}
+	defer results.file.Close()
+
+	// The file is read again, skipping the rows processed before. Rows up to
+	// a chunk past the last checkpoint may have run before a crash without
+	// being recorded; they are looked up in the audit log instead of being
+	// executed again.
+	recovering := resuming
+	skip, pending := job.Processed, 0
+	err = im.each(files, job, func(r row) error {
+		if skip > 0 {
+			skip--
+			return nil
+		}
+		result, err := im.execute(ctx, job, r, recovering)
+		if err != nil {
+			return err
+		}
+		r.err = result.err
+		results.write(r, result.outcome, result.id)
+		job.Processed++
+		if pending++; pending < im.chunkSize {
+			return nil
+		}
+		pending, recovering = 0, false
+		return im.reached(files, job, results, progress)
+	})
+	if err == nil && pending > 0 {
+		err = im.reached(files, job, results, progress)
+	}
+	if err != nil {
+		job.Status, job.Error = StatusInterrupted, err.Error()
+		return job, errors.Join(err, im.checkpoint(files, job, results))
+	}
+
+	job.Status = StatusCompleted
+	return job, im.save(files, job)
+}
+
+// reached records a checkpoint and reports it to progress.
+func (im *Importer) reached(files Files, job *Job, results *resultsFile, progress func(Job)) error {
+	if err := im.checkpoint(files, job, results); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(*job)
+	}
+	return nil
+}
+
+// each reads the input of a job, calling fn with every row.
+func (im *Importer) each(files Files, job *Job, fn func(row) error) error {
+	input, err := os.Open(files.Input)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	return parse(bufio.NewReader(input), job.Kind, job.Format, fn)
+}
+
+// validate counts the rows of a job and those that are invalid, returning the
+// check that tells them apart. Only the accounts the file refers to are kept
+// in memory, not its rows: the file is read once to find them and once more
+// to check every row.
+func (im *Importer) validate(ctx context.Context, files Files, job *Job) (func(*row), error) {
+	job.Rows, job.Invalid = 0, 0
+	lines := map[int64]int{}
+	err := im.each(files, job, func(r row) error {
+		job.Rows++
+		if r.err != nil {
+			return nil
+		}
+		if job.Kind == KindAccounts {
+			if im.service.ValidateAccount(r.account) == nil {
+				if _, ok := lines[r.account.ID]; !ok {
+					lines[r.account.ID] = r.line
+				}
+			}
+			return nil
+		}
+		if im.service.ValidateTransfer(r.transfer) == nil {
+			for _, id := range []int64{r.transfer.SourceAccountID, r.transfer.DestinationAccountID} {
+				if _, ok := lines[id]; !ok {
+					lines[id] = r.line
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := slices.Sorted(maps.Keys(lines))
+	accounts := make(map[int64]domain.Account, len(ids))
+	for start := 0; start < len(ids); start += service.MaxBatchGetAccounts {
+		batch, err := im.service.BatchGetAccounts(ctx, ids[start:min(start+service.MaxBatchGetAccounts, len(ids))])
+		if err != nil {
+			return nil, fmt.Errorf("look up accounts: %w", err)
+		}
+		for _, account := range batch.Accounts {
+			accounts[account.ID] = account
+		}
+	}
+
+	check := func(r *row) {
+		if r.err == nil {
+			r.err = im.checkRow(job.Kind, r, lines, accounts)
+		}
+	}
+	err = im.each(files, job, func(r row) error {
+		if check(&r); r.err != nil {
+			job.Invalid++
+		}
+		return nil
+	})
+	return check, err
+}
+
+// checkRow checks a row with the service and against the accounts in the
+// ledger: new accounts must not exist yet or be created on an earlier line,
+// and the accounts of transfers must exist and not be frozen.
+func (im *Importer) checkRow(kind string, r *row, lines map[int64]int, accounts map[int64]domain.Account) error {
+	if kind == KindAccounts {
+		if err := im.service.ValidateAccount(r.account); err != nil {
+			return err
+		}
+		if first := lines[r.account.ID]; first != r.line {
+			return fmt.Errorf("account %d is also created on line %d", r.account.ID, first)
+		}
+	} else if err := im.service.ValidateTransfer(r.transfer); err != nil {
+		return err
+	}
+	return checkAccounts(kind, r, accounts)
+}
+
+func checkAccounts(kind string, r *row, accounts map[int64]domain.Account) error {
+	if kind == KindAccounts {
+		if _, ok := accounts[r.account.ID]; ok {
+			return fmt.Errorf("account %d already exists", r.account.ID)
+		}
+		return nil
+	}
+	for _, id := range []int64{r.transfer.SourceAccountID, r.transfer.DestinationAccountID} {
+		account, ok := accounts[id]
+		if !ok {
+			return fmt.Errorf("account %d not found", id)
+		}
+		if account.Status == domain.AccountStatusFrozen {
+			return fmt.Errorf("account %d is frozen", id)
+		}
+	}
+	return nil
+}
+
+// rowResult is the outcome of an executed row. id is the ID of the account
+// or transaction it created and err why it failed.
+type rowResult struct {
+	outcome string
+	id      int64
+	err     error
+}
+
+// execute runs a row on behalf of the actor of the job. An error is returned
+// only when the import has to stop.
+func (im *Importer) execute(ctx context.Context, job *Job, r row, recovering bool) (rowResult, error) {
+	ctx = audit.WithMetadata(ctx, audit.Metadata{Actor: job.Actor, RequestID: requestID(job.ID, r.line)})
+
+	result, err := im.recovered(ctx, job, r, recovering)
+	if err != nil {
+		return rowResult{}, err
+	}
+	if result.outcome == "" {
+		if result, err = im.run(ctx, job.Kind, r); err != nil {
+			return rowResult{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+	}
+
+	if result.outcome == OutcomeSucceeded {
+		job.Succeeded++
+	} else {
+		job.Failed++
+	}
+	metrics.ImportRowsTotal.WithLabelValues(job.Kind, result.outcome).Inc()
+	return result, nil
+}
+
+// run executes a row, returning an error only when it did not fail on its
+// own account.
+func (im *Importer) run(ctx context.Context, kind string, r row) (rowResult, error) {
+	var id int64
+	var err error
+	if kind == KindAccounts {
+		var account *domain.Account
+		if account, err = im.service.CreateAccount(ctx, r.account); err == nil {
+			id = account.ID
+		}
+	} else {
+		var transaction *domain.Transaction
+		if transaction, err = im.service.TransferMoney(ctx, r.transfer); err == nil {
+			id = transaction.ID
+		}
+	}
+	switch {
+	case err == nil:
+		return rowResult{outcome: OutcomeSucceeded, id: id}, nil
+	case isRowError(err):
+		return rowResult{outcome: OutcomeFailed, err: err}, nil
+	}
+	return rowResult{}, err
+}
+
+// recovered looks up in the audit log whether a row ran before the import
+// was interrupted, returning a result without an outcome when it did not.
+func (im *Importer) recovered(ctx context.Context, job *Job, r row, recovering bool) (rowResult, error) {
+	if !recovering {
+		return rowResult{}, nil
+	}
+	operation := domain.AuditOperationTransfer
+	if job.Kind == KindAccounts {
+		operation = domain.AuditOperationAccountCreate
+	}
+	entries, err := im.service.ListAuditEntries(ctx, domain.AuditFilter{RequestID: requestID(job.ID, r.line), Operation: operation, Limit: 1})
+	if err != nil {
+		return rowResult{}, fmt.Errorf("look up line %d in the audit log: %w", r.line, err)
+	}
+	if len(entries) == 0 {
+		return rowResult{}, nil
+	}
+	if entries[0].TransactionID != nil {
+		return rowResult{outcome: OutcomeSucceeded, id: *entries[0].TransactionID}, nil
+	}
+	return rowResult{outcome: OutcomeSucceeded, id: entries[0].AccountID}, nil
+}
+
+// reject records a job none of whose rows is executed. When check is not nil,
+// the file is read again to write the outcome of every row it checks.
+func (im *Importer) reject(files Files, job *Job, check func(*row)) error {
+	results, err := openResults(files.Results, job.Kind, 0)
+	if err != nil {
+		return err
+	}
+	defer results.file.Close()
+	if check != nil {
+		err := im.each(files, job, func(r row) error {
+			check(&r)
+			outcome := OutcomeValid
+			if r.err != nil {
+				outcome = OutcomeInvalid
+			}
+			results.write(r, outcome, 0)
+			metrics.ImportRowsTotal.WithLabelValues(job.Kind, outcome).Inc()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	job.Status = StatusRejected
+	return im.checkpoint(files, job, results)
+}
+
+// checkpoint makes the results written so far durable before recording in
+// the job that they were.
+func (im *Importer) checkpoint(files Files, job *Job, results *resultsFile) error {
+	size, err := results.sync()
+	if err != nil {
+		return err
+	}
+	job.ResultsSize = size
+	return im.save(files, job)
+}
+
+func (im *Importer) save(files Files, job *Job) error {
+	job.UpdatedAt = im.now().UTC()
+	return saveJob(files.State, job)
+}
+
+// resultsFile is the CSV file the outcome of every row is written to.
+type resultsFile struct {
+	file   *os.File
+	writer *csv.Writer
+	kind   string
+}
+
+// openResults opens the results file for appending after its first size
+// bytes, starting it with a header when size is 0.
+func openResults(path, kind string, size int64) (*resultsFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	results := &resultsFile{file: file, writer: csv.NewWriter(file), kind: kind}
+	if size == 0 {
+		header := []string{"line", "outcome", "transaction_id", "source_account_id", "destination_account_id", "amount", "error"}
+		if kind == KindAccounts {
+			header = []string{"line", "outcome", "account_id", "error"}
+		}
+		_ = results.writer.Write(header)
+	}
+	return results, nil
+}
+
+// write records the outcome of a row; id is the ID of the transaction a
+// transfer created.
+func (f *resultsFile) write(r row, outcome string, id int64) {
+	message := ""
+	if r.err != nil {
+		message = r.err.Error()
+	}
+	if f.kind == KindAccounts {
+		_ = f.writer.Write([]string{strconv.Itoa(r.line), outcome, formatID(r.account.ID), message})
+		return
+	}
+	amount := ""
+	if !r.transfer.Amount.IsZero() {
+		amount = r.transfer.Amount.String()
+	}
+	_ = f.writer.Write([]string{
+		strconv.Itoa(r.line), outcome, formatID(id),
+		formatID(r.transfer.SourceAccountID), formatID(r.transfer.DestinationAccountID), amount, message,
+	})
+}
+
+// sync flushes the results to disk and returns the size of the file.
+func (f *resultsFile) sync() (int64, error) {
+	f.writer.Flush()
+	if err := f.writer.Error(); err != nil {
+		return 0, err
+	}
+	if err := f.file.Sync(); err != nil {
+		return 0, err
+	}
+	return f.file.Seek(0, io.SeekCurrent)
+}
+
+func formatID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,232 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Manager keeps uploaded imports in a directory, one subdirectory per job,
+// and runs them one at a time in the background.
+type Manager struct {
+	dir      string
+	importer *Importer
+
+	mu     sync.Mutex
+	queue  []string
+	active map[string]bool
+	wake   chan struct{}
+}
+
+func NewManager(dir string, importer *Importer) *Manager {
+	return &Manager{dir: dir, importer: importer, active: map[string]bool{}, wake: make(chan struct{}, 1)}
+}
+
+func (m *Manager) files(id string) Files {
+	return Files{
+		Input:   filepath.Join(m.dir, id, "input"),
+		State:   filepath.Join(m.dir, id, "job.json"),
+		Results: filepath.Join(m.dir, id, "results.csv"),
+	}
+}
+
+// Submit stores an uploaded file and queues its import.
+func (m *Manager) Submit(kind, format, actor string, input io.Reader) (*Job, error) {
+	if !IsKind(kind) {
+		return nil, ErrUnknownKind
+	}
+	if !IsFormat(format) {
+		return nil, ErrUnknownFormat
+	}
+	id := newJobID()
+	if err := os.MkdirAll(filepath.Join(m.dir, id), 0o755); err != nil {
+		return nil, err
+	}
+	files := m.files(id)
+	if err := writeInput(files.Input, input); err != nil {
+		os.RemoveAll(filepath.Join(m.dir, id))
+		return nil, err
+	}
+
+	job, err := m.importer.create(files, id, kind, format, actor)
+	if err != nil {
+		os.RemoveAll(filepath.Join(m.dir, id))
+		return nil, err
+	}
+	m.enqueue(id)
+	return job, nil
+}
+
+func writeInput(path string, input io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, input); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Job returns the progress of an import.
+func (m *Manager) Job(id string) (*Job, error) {
+	if !isJobID(id) {
+		return nil, ErrJobNotFound
+	}
+	job, err := LoadJob(m.files(id).State)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
+
+// ResultsPath returns the path of the results file of an import, which grows
+// as its rows run.
+func (m *Manager) ResultsPath(id string) (string, error) {
+	if _, err := m.Job(id); err != nil {
+		return "", err
+	}
+	path := m.files(id).Results
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoResults
+	}
+	return path, nil
+}
+
+// Resume queues an import that was interrupted, or that stopped before its
+// rows were validated.
+func (m *Manager) Resume(id string) (*Job, error) {
+	job, err := m.Job(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return job, ErrJobFinished
+	}
+	if !m.enqueue(id) {
+		return job, ErrJobActive
+	}
+	return job, nil
+}
+
+// enqueue queues an import unless it is queued or running already.
+func (m *Manager) enqueue(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active[id] {
+		return false
+	}
+	m.active[id] = true
+	m.queue = append(m.queue, id)
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (m *Manager) next() (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) == 0 {
+		return "", false
+	}
+	id := m.queue[0]
+	m.queue = m.queue[1:]
+	return id, true
+}
+
+func (m *Manager) done(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.active, id)
+}
+
+// Run is a worker that first queues the imports left unfinished by a
+// previous process and then runs queued imports until ctx is canceled. An
+// import stopped by the cancellation is left interrupted.
+func (m *Manager) Run(ctx context.Context) {
+	m.recover()
+	for {
+		id, ok := m.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.wake:
+			}
+			continue
+		}
+		m.run(ctx, id)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, id string) {
+	defer m.done(id)
+	startedAt := time.Now()
+	job, err := m.importer.Run(ctx, m.files(id), nil)
+	if job == nil {
+		logger.L().Error("import failed", zap.String("import_id", id), zap.Error(err))
+		return
+	}
+	fields := []zap.Field{
+		zap.String("import_id", id),
+		zap.String("kind", job.Kind),
+		zap.String("status", job.Status),
+		zap.Int("rows", job.Rows),
+		zap.Int("succeeded", job.Succeeded),
+		zap.Int("failed", job.Failed),
+		zap.Int("invalid", job.Invalid),
+		zap.Duration("duration", time.Since(startedAt)),
+	}
+	if err != nil {
+		logger.L().Error("import stopped", append(fields, zap.Error(err))...)
+		return
+	}
+	logger.L().Info("import finished", fields...)
+}
+
+// recover queues every import in the directory that has not finished, oldest
+// first.
+func (m *Manager) recover() {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.L().Error("list imports failed", zap.Error(err))
+		}
+		return
+	}
+	var unfinished []*Job
+	for _, entry := range entries {
+		if !entry.IsDir() || !isJobID(entry.Name()) {
+			continue
+		}
+		job, err := LoadJob(m.files(entry.Name()).State)
+		if err != nil {
+			continue
+		}
+		if !job.Finished() {
+			unfinished = append(unfinished, job)
+		}
+	}
+	slices.SortFunc(unfinished, func(a, b *Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, job := range unfinished {
+		m.enqueue(job.ID)
+	}
+}
@@ -0,0 +1,255 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// maxLineBytes bounds a single line of a JSON lines file.
+const maxLineBytes = 1 << 20
+
+// row is one record of an import file, with the line it starts on. err says
+// why the row is invalid.
+type row struct {
+	line     int
+	account  domain.Account
+	transfer domain.Transaction
+	err      error
+}
+
+// accountRecord and transferRecord are the fields of a row, as in the bodies
+// of POST /api/v1/accounts and POST /api/v1/transactions. Fields are pointers
+// so that missing ones are told apart from zero ones.
+type accountRecord struct {
+	AccountID      *int64           `json:"account_id"`
+	InitialBalance *decimal.Decimal `json:"initial_balance"`
+	Tier           string           `json:"tier"`
+	Tags           []string         `json:"tags"`
+}
+
+type transferRecord struct {
+	SourceAccountID      *int64           `json:"source_account_id"`
+	DestinationAccountID *int64           `json:"destination_account_id"`
+	Amount               *decimal.Decimal `json:"amount"`
+}
+
+var columns = map[string]struct{ required, optional []string }{
+	KindAccounts:  {required: []string{"account_id", "initial_balance"}, optional: []string{"tier", "tags"}},
+	KindTransfers: {required: []string{"source_account_id", "destination_account_id", "amount"}},
+}
+
+// parse reads the rows of an import file one at a time, calling fn with
+// each so that the file is never held in memory. Rows that cannot be read are
+// passed with their error. The error returned is about the file as a whole,
+// or the first one fn returned, which stops the parse.
+func parse(input io.Reader, kind, format string, fn func(row) error) error {
+	if format == FormatJSONL {
+		return parseJSONL(input, kind, fn)
+	}
+	return parseCSV(input, kind, fn)
+}
+
+// parseCSV reads a CSV file whose header names the columns. Tags are
+// separated by spaces.
+func parseCSV(input io.Reader, kind string, fn func(row) error) error {
+	reader := csv.NewReader(input)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: header: %v", ErrInvalidFile, err)
+	}
+	header = slices.Clone(header)
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	if err := checkHeader(header, kind); err != nil {
+		return err
+	}
+
+	fields := make(map[string]string, len(header))
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			if err := fn(row{line: parseError.StartLine, err: parseError.Err}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		r := row{line: line, err: fmt.Errorf("expected %d fields, got %d", len(header), len(record))}
+		if len(record) == len(header) {
+			for i, name := range header {
+				fields[name] = strings.TrimSpace(record[i])
+			}
+			r = csvRow(line, kind, fields)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
+
+func checkHeader(header []string, kind string) error {
+	known := columns[kind]
+	for i, name := range header {
+		if !slices.Contains(known.required, name) && !slices.Contains(known.optional, name) {
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidFile, name)
+		}
+		if slices.Contains(header[:i], name) {
+			return fmt.Errorf("%w: column %q appears twice", ErrInvalidFile, name)
+		}
+	}
+	for _, name := range known.required {
+		if !slices.Contains(header, name) {
+			return fmt.Errorf("%w: missing column %q", ErrInvalidFile, name)
+		}
+	}
+	return nil
+}
+
+func csvRow(line int, kind string, fields map[string]string) row {
+	var err error
+	if kind == KindAccounts {
+		var record accountRecord
+		if record.AccountID, err = csvID(fields, "account_id"); err != nil {
+			return row{line: line, err: err}
+		}
+		if record.InitialBalance, err = csvAmount(fields, "initial_balance"); err != nil {
+			return row{line: line, err: err}
+		}
+		record.Tier = fields["tier"]
+		record.Tags = strings.Fields(fields["tags"])
+		return accountRow(line, record)
+	}
+
+	var record transferRecord
+	if record.SourceAccountID, err = csvID(fields, "source_account_id"); err != nil {
+		return row{line: line, err: err}
+	}
+	if record.DestinationAccountID, err = csvID(fields, "destination_account_id"); err != nil {
+		return row{line: line, err: err}
+	}
+	if record.Amount, err = csvAmount(fields, "amount"); err != nil {
+		return row{line: line, err: err}
+	}
+	return transferRow(line, record)
+}
+
+func csvID(fields map[string]string, name string) (*int64, error) {
+	if fields[name] == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(fields[name], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q is not an integer", name, fields[name])
+	}
+	return &id, nil
+}
+
+func csvAmount(fields map[string]string, name string) (*decimal.Decimal, error) {
+	if fields[name] == "" {
+		return nil, nil
+	}
+	amount, err := decimal.NewFromString(fields[name])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q is not a decimal amount", name, fields[name])
+	}
+	return &amount, nil
+}
+
+// parseJSONL reads a file with a JSON object on every line. Blank lines are
+// skipped.
+func parseJSONL(input io.Reader, kind string, fn func(row) error) error {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err := fn(jsonRow(line, kind, scanner.Bytes())); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return nil
+}
+
+func jsonRow(line int, kind string, data []byte) row {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var err error
+	if kind == KindAccounts {
+		var record accountRecord
+		if err = decoder.Decode(&record); err == nil {
+			return accountRow(line, record)
+		}
+	} else {
+		var record transferRecord
+		if err = decoder.Decode(&record); err == nil {
+			return transferRow(line, record)
+		}
+	}
+	return row{line: line, err: fmt.Errorf("invalid JSON: %v", err)}
+}
+
+func accountRow(line int, record accountRecord) row {
+	switch {
+	case record.AccountID == nil:
+		return row{line: line, err: errors.New("account_id is required")}
+	case *record.AccountID <= 0:
+		return row{line: line, err: errors.New("account_id must be a positive integer")}
+	case record.InitialBalance == nil:
+		return row{line: line, err: errors.New("initial_balance is required")}
+	}
+	return row{line: line, account: domain.Account{
+		ID:      *record.AccountID,
+		Balance: *record.InitialBalance,
+		Tier:    record.Tier,
+		Tags:    record.Tags,
+	}}
+}
+
+func transferRow(line int, record transferRecord) row {
+	switch {
+	case record.SourceAccountID == nil || record.DestinationAccountID == nil:
+		return row{line: line, err: errors.New("source_account_id and destination_account_id are required")}
+	case *record.SourceAccountID <= 0 || *record.DestinationAccountID <= 0:
+		return row{line: line, err: errors.New("account IDs must be positive integers")}
+	case record.Amount == nil:
+		return row{line: line, err: errors.New("amount is required")}
+	}
+	return row{line: line, transfer: domain.Transaction{
+		SourceAccountID:      *record.SourceAccountID,
+		DestinationAccountID: *record.DestinationAccountID,
+		Amount:               *record.Amount,
+	}}
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// APIError is returned for every non-2xx response that carries the API's
+// error envelope.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s (status %d, request %s)", e.Code, e.Message, e.StatusCode, e.RequestID)
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+func (c *Client) CreateAccount(ctx context.Context, accountID int64, initialBalance decimal.Decimal) (*domain.Account, error) {
+	request := map[string]any{"account_id": accountID, "initial_balance": initialBalance}
+	var account domain.Account
+	if err := c.do(ctx, http.MethodPost, "/api/v1/accounts", request, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (c *Client) GetAccount(ctx context.Context, accountID int64) (*domain.Account, error) {
+	var account domain.Account
+	if err := c.do(ctx, http.MethodGet, "/api/v1/accounts/"+strconv.FormatInt(accountID, 10), nil, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// BatchGetAccounts looks up to 100 accounts up in one request, returning
+// them in the order they were asked for.
+func (c *Client) BatchGetAccounts(ctx context.Context, accountIDs []int64) (*domain.AccountBatch, error) {
+	request := map[string]any{"account_ids": accountIDs}
+	var batch domain.AccountBatch
+	if err := c.do(ctx, http.MethodPost, "/api/v1/accounts:batchGet", request, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (c *Client) FreezeAccount(ctx context.Context, accountID int64) (*domain.Account, error) {
+	var account domain.Account
+	if err := c.do(ctx, http.MethodPost, "/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/freeze", nil, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (c *Client) UnfreezeAccount(ctx context.Context, accountID int64) (*domain.Account, error) {
+	var account domain.Account
+	if err := c.do(ctx, http.MethodPost, "/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/unfreeze", nil, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListTransactions returns one page of an account's history, newest first,
+// and the cursor for the next page or zero when there are no more pages.
+func (c *Client) ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, int64, error) {
+	query := url.Values{}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.BeforeID > 0 {
+		query.Set("before_id", strconv.FormatInt(filter.BeforeID, 10))
+	}
+	path := "/api/v1/accounts/" + strconv.FormatInt(accountID, 10) + "/transactions"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var response struct {
+		Transactions []domain.Transaction `json:"transactions"`
+		NextBeforeID int64                `json:"next_before_id"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return nil, 0, err
+	}
+	return response.Transactions, response.NextBeforeID, nil
+}
+
+func (c *Client) TransferMoney(ctx context.Context, sourceAccountID, destinationAccountID int64, amount decimal.Decimal) (*domain.Transaction, error) {
+	request := map[string]any{
+		"source_account_id":      sourceAccountID,
+		"destination_account_id": destinationAccountID,
+		"amount":                 amount,
+	}
+	var transaction domain.Transaction
+	if err := c.do(ctx, http.MethodPost, "/api/v1/transactions", request, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (c *Client) ReverseTransaction(ctx context.Context, transactionID int64) (*domain.Transaction, error) {
+	var transaction domain.Transaction
+	if err := c.do(ctx, http.MethodPost, "/api/v1/transactions/"+strconv.FormatInt(transactionID, 10)+"/reverse", nil, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, requestBody, responseBody any) error {
+	var body io.Reader
+	if requestBody != nil {
+		encoded, err := json.Marshal(requestBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if requestBody != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		apiError := &APIError{StatusCode: response.StatusCode, RequestID: response.Header.Get("X-Request-ID")}
+		var envelope struct {
+			RequestID string `json:"request_id"`
+			Error     struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&envelope); err == nil && envelope.Error.Code != "" {
+			apiError.Code, apiError.Message = envelope.Error.Code, envelope.Error.Message
+			if envelope.RequestID != "" {
+				apiError.RequestID = envelope.RequestID
+			}
+		} else {
+			apiError.Code, apiError.Message = "http_error", http.StatusText(response.StatusCode)
+		}
+		return apiError
+	}
+
+	if responseBody == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(responseBody)
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+func TestTransferMoneySendsRequestAndDecodesTransaction(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/transactions" {
+			t.Fatalf("expected POST /api/v1/transactions, got %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			SourceAccountID      int64           `json:"source_account_id"`
+			DestinationAccountID int64           `json:"destination_account_id"`
+			Amount               decimal.Decimal `json:"amount"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("expected JSON body, got %v", err)
+		}
+		if body.SourceAccountID != 1 || body.DestinationAccountID != 2 || !body.Amount.Equal(decimal.RequireFromString("2.50")) {
+			t.Fatalf("unexpected request body %+v", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"transaction_id":7,"source_account_id":1,"destination_account_id":2,"amount":"2.5","created_at":"2024-01-01T00:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	transaction, err := New(server.URL+"/", server.Client()).TransferMoney(context.Background(), 1, 2, decimal.RequireFromString("2.50"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if transaction.ID != 7 {
+		t.Fatalf("expected transaction 7, got %d", transaction.ID)
+	}
+}
+
+func TestErrorResponseIsReturnedAsAPIError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "header-id")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"request_id":"body-id","error":{"code":"account_not_found","message":"account not found"}}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, nil).GetAccount(context.Background(), 42)
+	var apiError *APIError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	if apiError.StatusCode != http.StatusNotFound || apiError.Code != "account_not_found" || apiError.RequestID != "body-id" {
+		t.Fatalf("unexpected API error %+v", apiError)
+	}
+}
+
+func TestNonJSONErrorFallsBackToStatusText(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, nil).GetAccount(context.Background(), 1)
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.Code != "http_error" || apiError.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected http_error with status 502, got %v", err)
+	}
+}
+
+func TestListTransactionsEncodesFilter(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/accounts/3/transactions" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("limit") != "2" || r.URL.Query().Get("before_id") != "10" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"transactions":[{"transaction_id":9},{"transaction_id":8}],"next_before_id":8}`))
+	}))
+	defer server.Close()
+
+	transactions, next, err := New(server.URL, nil).ListTransactions(context.Background(), 3, domain.TransactionFilter{Limit: 2, BeforeID: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(transactions) != 2 || next != 8 {
+		t.Fatalf("expected 2 transactions and cursor 8, got %d and %d", len(transactions), next)
+	}
+}
+
+func TestBatchGetAccountsDecodesAccountsAndNotFound(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/accounts:batchGet" {
+			t.Fatalf("expected POST /api/v1/accounts:batchGet, got %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			AccountIDs []int64 `json:"account_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.AccountIDs) != 2 {
+			t.Fatalf("unexpected request body %+v, %v", body, err)
+		}
+		_, _ = w.Write([]byte(`{"accounts":[{"account_id":2,"balance":"5","status":"active"}],"not_found":[9]}`))
+	}))
+	defer server.Close()
+
+	batch, err := New(server.URL, nil).BatchGetAccounts(context.Background(), []int64{2, 9})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(batch.Accounts) != 1 || batch.Accounts[0].ID != 2 || !batch.Accounts[0].Balance.Equal(decimal.NewFromInt(5)) || len(batch.NotFound) != 1 || batch.NotFound[0] != 9 {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+}
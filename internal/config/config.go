@@ -1,46 +1,527 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultEnvFile = "../../env/.env"
+	fileSuffix     = "_FILE"
 )
 
 type Config struct {
-	DatabaseURL string
-	Port        string
-	Environment string
+	Environment    string
+	Server         ServerConfig
+	Database       DatabaseConfig
+	Log            LogConfig
+	Tracing        TracingConfig
+	Features       FeatureConfig
+	Admin          AdminConfig
+	Reconciliation ReconciliationConfig
+	Funding        FundingConfig
+	Fees           FeesConfig
+	Interest       InterestConfig
+	Money          MoneyConfig
+	Snapshots      SnapshotsConfig
+	Imports        ImportsConfig
+	Risk           RiskConfig
+}
+
+type ServerConfig struct {
+	Port              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxBodyBytes      int64
+	DrainTimeout      time.Duration
+	ShutdownDelay     time.Duration
+	// TrustedProxies lists, separated by commas, the addresses and CIDR
+	// ranges of the gateways allowed to name the actor and client address of
+	// a request.
+	TrustedProxies string
+}
+
+// TrustedProxyPrefixes parses TrustedProxies. A bare address stands for
+// itself alone.
+func (s ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(s.TrustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither an IP address nor a CIDR range", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+type DatabaseConfig struct {
+	URL             string
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+	AutoMigrate     bool
+
+	RetryMaxAttempts      int32
+	RetryBaseDelay        time.Duration
+	RetryMaxDelay         time.Duration
+	SerializableTransfers bool
+
+	ShardConsolidationInterval time.Duration
+}
+
+type LogConfig struct {
+	Level string
+}
+
+type TracingConfig struct {
+	Exporter    string
+	ServiceName string
+}
+
+type FeatureConfig struct {
+	Metrics bool
+	Docs    bool
+}
+
+type AdminConfig struct {
+	Token string
+}
+
+type ReconciliationConfig struct {
+	Interval  time.Duration
+	ReportDir string
+}
+
+// FundingConfig names the clearing accounts deposits are drawn from and
+// withdrawals are paid into. Amount limits are decimal strings, empty for no
+// limit.
+type FundingConfig struct {
+	DepositClearingAccountID    int64
+	WithdrawalClearingAccountID int64
+	MaxDepositAmount            string
+	MaxWithdrawalAmount         string
+}
+
+// FeesConfig names the account transfer fees are paid into; 0 charges no
+// fees.
+type FeesConfig struct {
+	RevenueAccountID int64
+}
+
+// MoneyConfig is the precision policy for amounts. Scale is at most 4, the
+// scale amounts are stored with, and MaxAmount is a decimal string, empty for
+// the largest amount the database can store. Rounding is reject, half_even,
+// half_up or down.
+type MoneyConfig struct {
+	Scale     int32
+	MaxAmount string
+	Rounding  string
+}
+
+// InterestConfig names the account interest is paid out of; 0 disables
+// interest. DayCount is actual/365, actual/360 or actual/actual.
+type InterestConfig struct {
+	ExpenseAccountID int64
+	DayCount         string
+	Interval         time.Duration
+}
+
+// SnapshotsConfig sets how often account balances are snapshotted for
+// point-in-time balance queries; 0 disables the schedule.
+type SnapshotsConfig struct {
+	Interval time.Duration
+}
+
+// ImportsConfig sets where uploaded account and transfer files are kept,
+// empty disabling uploads, and how many rows are executed between progress
+// checkpoints.
+type ImportsConfig struct {
+	Dir       string
+	ChunkSize int64
+}
+
+// RiskConfig names the YAML file of the rules transfers are checked against
+// before they run; empty checks none.
+type RiskConfig struct {
+	RulesFile string
 }
 
 var appConfig Config
 
-func Load() (*Config, error) {
-	_ = godotenv.Load("../../env/.env")
+var databaseSchemes = []string{"postgres", "postgresql", "mysql", "sqlite", "memory"}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		return nil, fmt.Errorf("PORT is not set")
-	}
+func Default() Config {
+	return Config{
+		Environment: "production",
+		Server: ServerConfig{
+			Port:              "9000",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxBodyBytes:      1 << 20,
+			DrainTimeout:      30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxConns:        10,
+			MinConns:        0,
+			MaxConnLifetime: time.Hour,
+			MaxConnIdleTime: 30 * time.Minute,
+			AutoMigrate:     true,
 
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL is not set")
-	}
+			RetryMaxAttempts: 5,
+			RetryBaseDelay:   10 * time.Millisecond,
+			RetryMaxDelay:    500 * time.Millisecond,
 
-	env := os.Getenv("ENV")
-	if env == "" {
-		env = "production"
+			ShardConsolidationInterval: time.Minute,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "transfer-system",
+		},
+		Features: FeatureConfig{
+			Metrics: true,
+			Docs:    true,
+		},
+		Reconciliation: ReconciliationConfig{
+			Interval: time.Hour,
+		},
+		Interest: InterestConfig{
+			DayCount: "actual/365",
+			Interval: time.Hour,
+		},
+		Money: MoneyConfig{
+			Scale:    4,
+			Rounding: "reject",
+		},
+		Snapshots: SnapshotsConfig{
+			Interval: 24 * time.Hour,
+		},
+		Imports: ImportsConfig{
+			ChunkSize: 100,
+		},
 	}
+}
 
-	appConfig = Config{
-		DatabaseURL: databaseURL,
-		Port:        port,
-		Environment: env,
+// Load builds the configuration from defaults, an optional YAML or TOML file,
+// the environment and command-line flags, in increasing order of precedence.
+// The file is selected with --config or CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	if err := loadEnvFile(); err != nil {
+		return nil, err
+	}
+	loaded, err := load(args, os.LookupEnv, io.Discard)
+	if err != nil {
+		return nil, err
 	}
+	appConfig = *loaded
 	return &appConfig, nil
 }
 
 func Get() *Config {
 	return &appConfig
 }
+
+func load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	flagSet := flag.NewFlagSet("transfer-system", flag.ContinueOnError)
+	flagSet.SetOutput(output)
+	configFile := flagSet.String("config", "", "path to a YAML or TOML configuration file (env CONFIG_FILE)")
+
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		name := s.key
+		usage := fmt.Sprintf("%s (env %s)", s.help, s.env)
+		if s.isBool() {
+			flagSet.BoolFunc(name, usage, func(value string) error {
+				flagValues[name] = value
+				return nil
+			})
+			continue
+		}
+		flagSet.Func(name, usage, func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+	if flagSet.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyFileValues(settings, values); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		value, ok, err := lookupSettingEnv(s.env, lookupEnv)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+	for _, s := range settings {
+		value, ok := flagValues[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", s.key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadEnvFile loads a dotenv file into the process environment. Variables
+// that are already set are not overridden.
+func loadEnvFile() error {
+	path := os.Getenv("ENV_FILE")
+	if path == "" {
+		_ = godotenv.Load(defaultEnvFile)
+		return nil
+	}
+	if err := godotenv.Load(path); err != nil {
+		return fmt.Errorf("env file %s: %w", path, err)
+	}
+	return nil
+}
+
+// lookupSettingEnv reads key from the environment, falling back to the file
+// named by key_FILE so that secrets can be mounted instead of exported.
+func lookupSettingEnv(key string, lookupEnv func(string) (string, bool)) (string, bool, error) {
+	value, ok := lookupEnv(key)
+	filePath, fileOK := lookupEnv(key + fileSuffix)
+	if ok && fileOK {
+		return "", false, fmt.Errorf("both %s and %s%s are set", key, key, fileSuffix)
+	}
+	if !fileOK {
+		return value, ok, nil
+	}
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", false, fmt.Errorf("%s%s: %w", key, fileSuffix, err)
+	}
+	return strings.TrimRight(string(contents), "\r\n"), true, nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Environment == "" {
+		errs = append(errs, errors.New("environment must not be empty"))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port: %q is not a valid TCP port", c.Server.Port))
+	}
+	for _, timeout := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_delay", c.Server.ShutdownDelay},
+	} {
+		if timeout.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative", timeout.key))
+		}
+	}
+	if c.Server.DrainTimeout <= 0 {
+		errs = append(errs, errors.New("server.drain_timeout: must be positive"))
+	}
+	if c.Server.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("server.max_body_bytes: must be positive"))
+	}
+	if _, err := c.Server.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, fmt.Errorf("server.trusted_proxies: %w", err))
+	}
+
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url: DATABASE_URL is not set"))
+	} else if parsed, err := url.Parse(c.Database.URL); err != nil {
+		errs = append(errs, errors.New("database.url: not a valid URL"))
+	} else if !slices.Contains(databaseSchemes, parsed.Scheme) {
+		errs = append(errs, fmt.Errorf("database.url: unsupported scheme %q, expected one of %s", parsed.Scheme, strings.Join(databaseSchemes, ", ")))
+	}
+	if c.Database.MaxConns < 1 {
+		errs = append(errs, errors.New("database.max_conns: must be at least 1"))
+	}
+	if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		errs = append(errs, errors.New("database.min_conns: must be between 0 and database.max_conns"))
+	}
+	if c.Database.MaxConnLifetime <= 0 || c.Database.MaxConnIdleTime <= 0 {
+		errs = append(errs, errors.New("database.max_conn_lifetime and database.max_conn_idle_time: must be positive"))
+	}
+	if c.Database.RetryMaxAttempts < 1 {
+		errs = append(errs, errors.New("database.retry_max_attempts: must be at least 1"))
+	}
+	if c.Database.RetryBaseDelay < 0 || c.Database.RetryMaxDelay < c.Database.RetryBaseDelay {
+		errs = append(errs, errors.New("database.retry_base_delay: must be between 0 and database.retry_max_delay"))
+	}
+	if c.Database.ShardConsolidationInterval < 0 {
+		errs = append(errs, errors.New("database.shard_consolidation_interval: must not be negative"))
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level: %q is not one of debug, info, warn, error", c.Log.Level))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q is not one of none, otlp, stdout", c.Tracing.Exporter))
+	}
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.service_name: must not be empty"))
+	}
+
+	if c.Reconciliation.Interval < 0 {
+		errs = append(errs, errors.New("reconciliation.interval: must not be negative"))
+	}
+
+	if c.Funding.DepositClearingAccountID < 0 || c.Funding.WithdrawalClearingAccountID < 0 {
+		errs = append(errs, errors.New("funding clearing accounts: must not be negative"))
+	}
+	if c.Fees.RevenueAccountID < 0 {
+		errs = append(errs, errors.New("fees.revenue_account: must not be negative"))
+	}
+	if c.Interest.ExpenseAccountID < 0 {
+		errs = append(errs, errors.New("interest.expense_account: must not be negative"))
+	}
+	switch c.Interest.DayCount {
+	case "actual/365", "actual/360", "actual/actual":
+	default:
+		errs = append(errs, fmt.Errorf("interest.day_count: %q is not one of actual/365, actual/360, actual/actual", c.Interest.DayCount))
+	}
+	if c.Interest.Interval < 0 {
+		errs = append(errs, errors.New("interest.interval: must not be negative"))
+	}
+
+	if c.Money.Scale < 0 || c.Money.Scale > 4 {
+		errs = append(errs, errors.New("money.scale: must be between 0 and 4"))
+	}
+	if c.Money.MaxAmount != "" {
+		if amount, err := decimal.NewFromString(c.Money.MaxAmount); err != nil || !amount.IsPositive() || amount.GreaterThanOrEqual(decimal.New(1, 15)) {
+			errs = append(errs, fmt.Errorf("money.max_amount: %q is not a positive amount below 10^15", c.Money.MaxAmount))
+		}
+	}
+	switch c.Money.Rounding {
+	case "reject", "half_even", "half_up", "down":
+	default:
+		errs = append(errs, fmt.Errorf("money.rounding: %q is not one of reject, half_even, half_up, down", c.Money.Rounding))
+	}
+	if c.Snapshots.Interval < 0 {
+		errs = append(errs, errors.New("snapshots.interval: must not be negative"))
+	}
+	if c.Imports.ChunkSize < 1 || c.Imports.ChunkSize > 10000 {
+		errs = append(errs, errors.New("imports.chunk_size: must be between 1 and 10000"))
+	}
+	for _, limit := range []struct {
+		key   string
+		value string
+	}{
+		{"funding.max_deposit_amount", c.Funding.MaxDepositAmount},
+		{"funding.max_withdrawal_amount", c.Funding.MaxWithdrawalAmount},
+	} {
+		if limit.value == "" {
+			continue
+		}
+		if amount, err := decimal.NewFromString(limit.value); err != nil || amount.IsNegative() {
+			errs = append(errs, fmt.Errorf("%s: %q is not a non-negative amount", limit.key, limit.value))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c Config) IsDevelopment() bool {
+	return c.Environment == "development" || c.Environment == "dev"
+}
+
+// redactURL hides the password of a connection URL.
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+	if _, ok := parsed.User.Password(); !ok {
+		return value
+	}
+	return parsed.Redacted()
+}
+
+// redactSecret hides a secret entirely while still showing whether it is set.
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "xxxxx"
+}
+
+// ListenAddr is the address the HTTP server binds to.
+func (c Config) ListenAddr() string {
+	return net.JoinHostPort("", c.Server.Port)
+}
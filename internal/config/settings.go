@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type setting struct {
+	key    string
+	env    string
+	help   string
+	target any
+	redact func(string) string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "environment", env: "ENV", help: "deployment environment, development enables human-readable logs", target: &c.Environment},
+
+		{key: "server.port", env: "PORT", help: "HTTP listen port", target: &c.Server.Port},
+		{key: "server.read_timeout", env: "HTTP_READ_TIMEOUT", help: "maximum duration for reading a request", target: &c.Server.ReadTimeout},
+		{key: "server.read_header_timeout", env: "HTTP_READ_HEADER_TIMEOUT", help: "maximum duration for reading request headers", target: &c.Server.ReadHeaderTimeout},
+		{key: "server.write_timeout", env: "HTTP_WRITE_TIMEOUT", help: "maximum duration for writing a response", target: &c.Server.WriteTimeout},
+		{key: "server.idle_timeout", env: "HTTP_IDLE_TIMEOUT", help: "keep-alive idle timeout", target: &c.Server.IdleTimeout},
+		{key: "server.max_body_bytes", env: "HTTP_MAX_BODY_BYTES", help: "maximum accepted request body size in bytes", target: &c.Server.MaxBodyBytes},
+		{key: "server.drain_timeout", env: "DRAIN_TIMEOUT", help: "time allowed for in-flight work to finish on shutdown", target: &c.Server.DrainTimeout},
+		{key: "server.shutdown_delay", env: "SHUTDOWN_DELAY", help: "time to report not ready before closing the listener", target: &c.Server.ShutdownDelay},
+		{key: "server.trusted_proxies", env: "TRUSTED_PROXIES", help: "comma-separated addresses or CIDR ranges of gateways trusted to set X-Actor and X-Forwarded-For, empty trusts none", target: &c.Server.TrustedProxies},
+
+		{key: "database.url", env: "DATABASE_URL", help: "database URL: postgres://..., mysql://..., sqlite://path/to/file.db or memory:// for an in-process store", target: &c.Database.URL, redact: redactURL},
+		{key: "database.max_conns", env: "DB_MAX_CONNS", help: "maximum connections in the pool", target: &c.Database.MaxConns},
+		{key: "database.min_conns", env: "DB_MIN_CONNS", help: "minimum idle connections kept in the pool", target: &c.Database.MinConns},
+		{key: "database.max_conn_lifetime", env: "DB_MAX_CONN_LIFETIME", help: "maximum lifetime of a pooled connection", target: &c.Database.MaxConnLifetime},
+		{key: "database.max_conn_idle_time", env: "DB_MAX_CONN_IDLE_TIME", help: "maximum idle time of a pooled connection", target: &c.Database.MaxConnIdleTime},
+		{key: "database.auto_migrate", env: "DB_AUTO_MIGRATE", help: "apply pending migrations at server start", target: &c.Database.AutoMigrate},
+		{key: "database.retry_max_attempts", env: "DB_RETRY_MAX_ATTEMPTS", help: "attempts at a PostgreSQL transaction aborted by a deadlock or serialization failure, 1 disables retries", target: &c.Database.RetryMaxAttempts},
+		{key: "database.retry_base_delay", env: "DB_RETRY_BASE_DELAY", help: "upper bound of the jittered delay before the first retry, doubled on each further retry", target: &c.Database.RetryBaseDelay},
+		{key: "database.retry_max_delay", env: "DB_RETRY_MAX_DELAY", help: "upper bound of the jittered delay between retries", target: &c.Database.RetryMaxDelay},
+		{key: "database.serializable_transfers", env: "DB_SERIALIZABLE_TRANSFERS", help: "run PostgreSQL transfers and reversals at SERIALIZABLE isolation", target: &c.Database.SerializableTransfers},
+		{key: "database.shard_consolidation_interval", env: "DB_SHARD_CONSOLIDATION_INTERVAL", help: "how often the shards of sharded accounts are evened out, 0 disables consolidation", target: &c.Database.ShardConsolidationInterval},
+
+		{key: "log.level", env: "LOG_LEVEL", help: "minimum log level: debug, info, warn or error", target: &c.Log.Level},
+
+		{key: "tracing.exporter", env: "OTEL_TRACES_EXPORTER", help: "traces exporter: none, otlp or stdout", target: &c.Tracing.Exporter},
+		{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", help: "service name reported on spans", target: &c.Tracing.ServiceName},
+
+		{key: "features.metrics", env: "FEATURE_METRICS", help: "expose the /metrics endpoint", target: &c.Features.Metrics},
+		{key: "features.docs", env: "FEATURE_DOCS", help: "serve the API documentation at /docs", target: &c.Features.Docs},
+
+		{key: "admin.token", env: "ADMIN_TOKEN", help: "bearer token for the /admin endpoints, which are disabled when empty", target: &c.Admin.Token, redact: redactSecret},
+
+		{key: "reconciliation.interval", env: "RECONCILIATION_INTERVAL", help: "how often the ledger is reconciled in the background, 0 disables the schedule", target: &c.Reconciliation.Interval},
+		{key: "reconciliation.report_dir", env: "RECONCILIATION_REPORT_DIR", help: "directory reconciliation reports are written to as JSON, empty keeps them in memory only", target: &c.Reconciliation.ReportDir},
+
+		{key: "funding.deposit_clearing_account", env: "FUNDING_DEPOSIT_CLEARING_ACCOUNT", help: "account deposits are drawn from, 0 disables deposits", target: &c.Funding.DepositClearingAccountID},
+		{key: "funding.withdrawal_clearing_account", env: "FUNDING_WITHDRAWAL_CLEARING_ACCOUNT", help: "account withdrawals are paid into, 0 disables withdrawals", target: &c.Funding.WithdrawalClearingAccountID},
+		{key: "funding.max_deposit_amount", env: "FUNDING_MAX_DEPOSIT_AMOUNT", help: "largest amount of a single deposit, empty for no limit", target: &c.Funding.MaxDepositAmount},
+		{key: "funding.max_withdrawal_amount", env: "FUNDING_MAX_WITHDRAWAL_AMOUNT", help: "largest amount of a single withdrawal, empty for no limit", target: &c.Funding.MaxWithdrawalAmount},
+		{key: "fees.revenue_account", env: "FEES_REVENUE_ACCOUNT", help: "account transfer fees are paid into, 0 disables fees", target: &c.Fees.RevenueAccountID},
+
+		{key: "interest.expense_account", env: "INTEREST_EXPENSE_ACCOUNT", help: "account interest is paid out of, 0 disables interest", target: &c.Interest.ExpenseAccountID},
+		{key: "interest.day_count", env: "INTEREST_DAY_COUNT", help: "day-count convention of daily accruals: actual/365, actual/360 or actual/actual", target: &c.Interest.DayCount},
+		{key: "interest.interval", env: "INTEREST_INTERVAL", help: "how often finished days are checked for interest to accrue, 0 disables the schedule", target: &c.Interest.Interval},
+
+		{key: "money.scale", env: "MONEY_SCALE", help: "decimal places amounts may have, at most 4", target: &c.Money.Scale},
+		{key: "money.max_amount", env: "MONEY_MAX_AMOUNT", help: "largest absolute amount accepted, empty for the largest the database can store", target: &c.Money.MaxAmount},
+		{key: "money.rounding", env: "MONEY_ROUNDING", help: "what to do with amounts that have too many decimal places: reject, half_even, half_up or down", target: &c.Money.Rounding},
+
+		{key: "snapshots.interval", env: "SNAPSHOTS_INTERVAL", help: "how often account balances are snapshotted for point-in-time queries, 0 disables the schedule", target: &c.Snapshots.Interval},
+
+		{key: "imports.dir", env: "IMPORTS_DIR", help: "directory uploaded import files, their progress and their results are kept in, empty disables uploads", target: &c.Imports.Dir},
+		{key: "imports.chunk_size", env: "IMPORTS_CHUNK_SIZE", help: "rows of an import executed between progress checkpoints", target: &c.Imports.ChunkSize},
+
+		{key: "risk.rules_file", env: "RISK_RULES_FILE", help: "YAML file of the risk rules transfers are checked against before they run, empty disables risk checks", target: &c.Risk.RulesFile},
+	}
+}
+
+func (s setting) isBool() bool {
+	_, ok := s.target.(*bool)
+	return ok
+}
+
+func (s setting) set(value string) error {
+	value = strings.TrimSpace(value)
+	switch target := s.target.(type) {
+	case *string:
+		*target = value
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*target = parsed
+	case *int32:
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*target = int32(parsed)
+	case *int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*target = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		*target = parsed
+	default:
+		return fmt.Errorf("unsupported setting type %T", s.target)
+	}
+	return nil
+}
+
+func (s setting) value() any {
+	switch target := s.target.(type) {
+	case *string:
+		if s.redact != nil {
+			return s.redact(*target)
+		}
+		return *target
+	case *bool:
+		return *target
+	case *int32:
+		return *target
+	case *int64:
+		return *target
+	case *time.Duration:
+		return target.String()
+	default:
+		return nil
+	}
+}
+
+func readFile(path string) (map[string]any, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &values)
+	case ".toml":
+		err = toml.Unmarshal(contents, &values)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func applyFileValues(settings []setting, values map[string]any) error {
+	flat := map[string]any{}
+	flatten("", values, flat)
+
+	known := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		known[s.key] = s
+	}
+
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s, ok := known[key]
+		if !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		if err := s.set(fmt.Sprint(flat[key])); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func flatten(prefix string, values map[string]any, out map[string]any) {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = value
+	}
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	tree := map[string]any{}
+	for _, s := range c.settings() {
+		node := tree
+		parts := strings.Split(s.key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = s.value()
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(tree); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
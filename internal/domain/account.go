@@ -1,8 +1,70 @@
 package domain
 
-import "github.com/shopspring/decimal"
+import (
+	"time"
 
+	"github.com/shopspring/decimal"
+)
+
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen"
+
+	// AccountTierStandard is the tier of accounts created without one.
+	AccountTierStandard = "standard"
+)
+
+const (
+	AccountSortID        = "id"
+	AccountSortBalance   = "balance"
+	AccountSortCreatedAt = "created_at"
+)
+
+// Account is an account and its balance. Shards is the number of rows a
+// high-volume account's balance is split across, or 0 when it is kept whole.
+// Version starts at 1 and grows with every write to the account row; credits
+// and debits of a sharded account go to its shards and leave it unchanged.
+// Tier selects the fee rules that apply to transfers out of the account.
+// Tags are free-form labels set when the account is created, kept sorted.
 type Account struct {
-	ID      int64           `db:"id" json:"account_id"`
-	Balance decimal.Decimal `db:"balance" json:"balance"`
+	ID        int64           `db:"id" json:"account_id"`
+	Balance   decimal.Decimal `db:"balance" json:"balance"`
+	Status    string          `db:"status" json:"status"`
+	Tier      string          `db:"tier" json:"tier"`
+	Tags      []string        `db:"tags" json:"tags,omitempty"`
+	Shards    int             `db:"shards" json:"shards,omitempty"`
+	Version   int64           `db:"version" json:"version"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+}
+
+// AccountFilter selects accounts to list. Zero values do not filter, balances
+// are matched inclusively, CreatedSince is inclusive and CreatedUntil is
+// exclusive. An account must carry every one of Tags. Accounts are ordered by
+// Sort, then by ID, and After continues a listing past the last account of
+// its previous page.
+type AccountFilter struct {
+	Status       string
+	MinBalance   *decimal.Decimal
+	MaxBalance   *decimal.Decimal
+	CreatedSince time.Time
+	CreatedUntil time.Time
+	Tags         []string
+	Sort         string
+	Descending   bool
+	After        *AccountCursor
+	Limit        int
+}
+
+// AccountCursor is the position of an account in a listing.
+type AccountCursor struct {
+	ID        int64
+	Balance   decimal.Decimal
+	CreatedAt time.Time
+}
+
+// AccountBatch is the result of looking accounts up by ID. NotFound lists the
+// IDs that matched no account.
+type AccountBatch struct {
+	Accounts []Account `json:"accounts"`
+	NotFound []int64   `json:"not_found,omitempty"`
 }
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditOperationAccountCreate      = "account.create"
+	AuditOperationAccountFreeze      = "account.freeze"
+	AuditOperationAccountUnfreeze    = "account.unfreeze"
+	AuditOperationAccountShards      = "account.shards"
+	AuditOperationInterestRate       = "account.interest_rate"
+	AuditOperationTransfer           = "transfer.create"
+	AuditOperationDeposit            = "deposit.create"
+	AuditOperationWithdrawal         = "withdrawal.create"
+	AuditOperationFee                = "fee.create"
+	AuditOperationInterest           = "interest.create"
+	AuditOperationTransactionReverse = "transaction.reverse"
+	AuditOperationRiskDecision       = "transfer.risk"
+)
+
+// AuditEntry records one committed mutation, or the risk decision on a
+// transfer. Before and After hold the JSON state of the affected records;
+// Before is empty for creations and decisions.
+type AuditEntry struct {
+	ID                    int64           `json:"audit_id"`
+	OccurredAt            time.Time       `json:"occurred_at"`
+	Actor                 string          `json:"actor"`
+	RequestID             string          `json:"request_id,omitempty"`
+	ClientIP              string          `json:"client_ip,omitempty"`
+	Operation             string          `json:"operation"`
+	AccountID             int64           `json:"account_id"`
+	CounterpartyAccountID *int64          `json:"counterparty_account_id,omitempty"`
+	TransactionID         *int64          `json:"transaction_id,omitempty"`
+	Before                json.RawMessage `json:"before,omitempty"`
+	After                 json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries, newest first. Zero values do not filter.
+// AccountID matches either side of a transfer.
+type AuditFilter struct {
+	Actor     string
+	Operation string
+	RequestID string
+	AccountID int64
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64
+	Limit     int
+}
@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type AccountBalance struct {
+	AccountID int64           `json:"account_id"`
+	Balance   decimal.Decimal `json:"balance"`
+}
+
+// BalanceReport holds the balances of accounts at AsOf, after every
+// transaction created up to and including that instant. Accounts created
+// after AsOf have a zero balance; NotFound lists the requested accounts that
+// do not exist.
+type BalanceReport struct {
+	AsOf     time.Time        `json:"as_of"`
+	Balances []AccountBalance `json:"balances"`
+	NotFound []int64          `json:"not_found,omitempty"`
+}
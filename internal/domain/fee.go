@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	FeeKindFlat       = "flat"
+	FeeKindPercentage = "percentage"
+	FeeKindTiered     = "tiered"
+)
+
+// FeeRule charges a fee on transfers out of accounts of AccountTier, or of
+// every tier when it is empty. A flat rule charges Amount. A percentage rule
+// charges Percent of the transfer amount, kept between MinFee and MaxFee when
+// they are set. A tiered rule charges according to the first of its Tiers
+// that covers the transfer amount.
+type FeeRule struct {
+	ID          int64            `json:"rule_id"`
+	Name        string           `json:"name"`
+	Kind        string           `json:"kind"`
+	AccountTier string           `json:"account_tier,omitempty"`
+	Amount      *decimal.Decimal `json:"amount,omitempty"`
+	Percent     *decimal.Decimal `json:"percent,omitempty"`
+	MinFee      *decimal.Decimal `json:"min_fee,omitempty"`
+	MaxFee      *decimal.Decimal `json:"max_fee,omitempty"`
+	Tiers       []FeeTier        `json:"tiers,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// FeeTier covers transfer amounts up to and including UpTo, or every amount
+// when UpTo is nil, and charges Amount plus Percent of the transfer amount.
+type FeeTier struct {
+	UpTo    *decimal.Decimal `json:"up_to,omitempty"`
+	Amount  decimal.Decimal  `json:"amount"`
+	Percent decimal.Decimal  `json:"percent"`
+}
+
+// Fee is charged to the source of a transfer on top of its amount and posted
+// to AccountID as a transaction of type fee, TransactionID.
+type Fee struct {
+	AccountID     int64           `json:"account_id"`
+	TransactionID int64           `json:"transaction_id,omitempty"`
+	Amount        decimal.Decimal `json:"amount"`
+	Breakdown     []FeeCharge     `json:"breakdown"`
+}
+
+// FeeCharge is the part of a fee charged by one rule.
+type FeeCharge struct {
+	RuleID int64           `json:"rule_id"`
+	Rule   string          `json:"rule"`
+	Amount decimal.Decimal `json:"amount"`
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Day-count conventions: the number of days a year of interest is spread
+// over. Actual/actual uses 366 days in leap years.
+const (
+	DayCountActual365    = "actual/365"
+	DayCountActual360    = "actual/360"
+	DayCountActualActual = "actual/actual"
+)
+
+func IsDayCount(value string) bool {
+	switch value {
+	case DayCountActual365, DayCountActual360, DayCountActualActual:
+		return true
+	}
+	return false
+}
+
+// InterestRate is the annual rate, in percent, an account earns on its
+// end-of-day balance.
+type InterestRate struct {
+	AccountID  int64           `json:"account_id"`
+	AnnualRate decimal.Decimal `json:"annual_rate"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// InterestAccrual is the interest an account earned on one day, at midnight
+// UTC of Date. Amount is kept unrounded; the accruals of a month are summed
+// and rounded when they are posted by TransactionID.
+type InterestAccrual struct {
+	AccountID     int64           `json:"account_id"`
+	Date          time.Time       `json:"date"`
+	Balance       decimal.Decimal `json:"balance"`
+	AnnualRate    decimal.Decimal `json:"annual_rate"`
+	DayCount      string          `json:"day_count"`
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID *int64          `json:"transaction_id,omitempty"`
+}
+
+// InterestAccrualFilter selects the accruals from From to To, both
+// inclusive. A zero AccountID matches every account.
+type InterestAccrualFilter struct {
+	AccountID int64
+	From      time.Time
+	To        time.Time
+}
+
+// InterestReport sums the accruals of a period per account. Posted sums the
+// accruals that have been paid out, before rounding; the rounding difference
+// of each posting is carried to the next one. Accruals holds the daily
+// detail when the report is for a single account.
+type InterestReport struct {
+	From         time.Time               `json:"from"`
+	To           time.Time               `json:"to"`
+	Accounts     []InterestAccountReport `json:"accounts"`
+	TotalAccrued decimal.Decimal         `json:"total_accrued"`
+	TotalPosted  decimal.Decimal         `json:"total_posted"`
+	Accruals     []InterestAccrual       `json:"accruals,omitempty"`
+}
+
+type InterestAccountReport struct {
+	AccountID int64           `json:"account_id"`
+	Days      int             `json:"days"`
+	Accrued   decimal.Decimal `json:"accrued"`
+	Posted    decimal.Decimal `json:"posted"`
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "github.com/shopspring/decimal"
+
+// LedgerAccount is one account as seen by reconciliation: the balance it was
+// opened with, its stored balance and the totals of money it received and sent.
+type LedgerAccount struct {
+	ID             int64
+	OpeningBalance decimal.Decimal
+	Balance        decimal.Decimal
+	Credits        decimal.Decimal
+	Debits         decimal.Decimal
+}
+
+// LedgerSnapshot is a consistent view of every account, ordered by ID, and of
+// the transactions that reference an account which does not exist.
+type LedgerSnapshot struct {
+	Accounts           []LedgerAccount
+	TransactionCount   int64
+	OrphanTransactions []Transaction
+}
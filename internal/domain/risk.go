@@ -0,0 +1,28 @@
+package domain
+
+import "github.com/shopspring/decimal"
+
+// A risk rule that matches a transfer either denies it or lets it through
+// flagged for review. A transfer no rule matches is allowed.
+const (
+	RiskAllow  = "allow"
+	RiskReview = "review"
+	RiskDeny   = "deny"
+)
+
+// RiskDecision is the verdict of the risk rules on a transfer, the strictest
+// action of the rules that matched it, with the reason of each.
+type RiskDecision struct {
+	SourceAccountID      int64           `json:"source_account_id"`
+	DestinationAccountID int64           `json:"destination_account_id"`
+	Amount               decimal.Decimal `json:"amount"`
+	Decision             string          `json:"decision"`
+	Reasons              []RiskReason    `json:"reasons,omitempty"`
+}
+
+// RiskReason explains why the rule named Rule matched a transfer.
+type RiskReason struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
@@ -1,10 +1,51 @@
 package domain
 
-import "github.com/shopspring/decimal"
+import (
+	"time"
 
+	"github.com/shopspring/decimal"
+)
+
+const (
+	TransactionTypeTransfer   = "transfer"
+	TransactionTypeReversal   = "reversal"
+	TransactionTypeDeposit    = "deposit"
+	TransactionTypeWithdrawal = "withdrawal"
+	TransactionTypeFee        = "fee"
+	TransactionTypeInterest   = "interest"
+)
+
+func IsTransactionType(value string) bool {
+	switch value {
+	case TransactionTypeTransfer, TransactionTypeReversal, TransactionTypeDeposit, TransactionTypeWithdrawal, TransactionTypeFee, TransactionTypeInterest:
+		return true
+	}
+	return false
+}
+
+// Transaction moves Amount from the source to the destination account. A
+// deposit comes from an external clearing account and a withdrawal goes to
+// one; a reversal undoes the transaction in ReversalOf, and interest is paid
+// out of the interest expense account. Fee is only set on the transfer that
+// charged it. Risk is the decision of the risk rules, which TransferMoney
+// records in the audit log and returns only when the transfer was flagged for
+// review. Neither is stored with the transaction.
 type Transaction struct {
 	ID                   int64           `db:"id" json:"transaction_id"`
+	Type                 string          `db:"type" json:"type"`
 	SourceAccountID      int64           `db:"source_account_id" json:"source_account_id"`
 	DestinationAccountID int64           `db:"destination_account_id" json:"destination_account_id"`
 	Amount               decimal.Decimal `db:"amount" json:"amount"`
+	ReversalOf           *int64          `db:"reversal_of" json:"reversal_of,omitempty"`
+	CreatedAt            time.Time       `db:"created_at" json:"created_at"`
+	Fee                  *Fee            `db:"-" json:"fee,omitempty"`
+	Risk                 *RiskDecision   `db:"-" json:"risk,omitempty"`
+}
+
+// TransactionFilter pages through an account's history. An empty Type
+// matches every type.
+type TransactionFilter struct {
+	Limit    int
+	BeforeID int64
+	Type     string
 }
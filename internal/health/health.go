@@ -0,0 +1,113 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status        string            `json:"status"`
+	Draining      bool              `json:"draining"`
+	StartedAt     time.Time         `json:"started_at"`
+	UptimeSeconds float64           `json:"uptime_seconds"`
+	Components    []ComponentStatus `json:"components"`
+}
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+type Checker struct {
+	mu        sync.RWMutex
+	checks    []namedCheck
+	draining  atomic.Bool
+	startedAt time.Time
+	timeout   time.Duration
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{startedAt: time.Now(), timeout: timeout}
+}
+
+func (c *Checker) Register(name string, check CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+func (c *Checker) SetDraining(draining bool) {
+	c.draining.Store(draining)
+}
+
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Run executes every registered check concurrently, each bounded by the
+// checker timeout, and reports the overall status. A draining checker is
+// never reported as up so that load balancers stop routing to it.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	components := make([]ComponentStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := nc.check(checkCtx)
+			component := ComponentStatus{
+				Name:      nc.name,
+				Status:    StatusUp,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				component.Status = StatusDown
+				component.Error = err.Error()
+			}
+			components[i] = component
+		}(i, nc)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:        StatusUp,
+		Draining:      c.Draining(),
+		StartedAt:     c.startedAt,
+		UptimeSeconds: time.Since(c.startedAt).Seconds(),
+		Components:    components,
+	}
+	for _, component := range components {
+		if component.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	if report.Draining && report.Status == StatusUp {
+		report.Status = StatusDraining
+	}
+	return report
+}
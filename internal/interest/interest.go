@@ -0,0 +1,215 @@
+package interest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// accrualScale is the number of decimal places a daily accrual keeps. The
+// accruals of a month are summed before they are rounded for posting.
+const accrualScale = 10
+
+// settleDelay is how long the schedule waits after midnight UTC before it
+// accrues the day that ended, so that transfers committing around midnight
+// are part of the end-of-day balance.
+const settleDelay = time.Minute
+
+var ErrDayNotOver = errors.New("day is not over yet")
+
+var hundred = decimal.NewFromInt(100)
+
+// DailyInterest is the interest a balance earns in one day at an annual rate
+// in percent. Negative balances earn nothing.
+func DailyInterest(balance, annualRate decimal.Decimal, dayCount string, date time.Time) decimal.Decimal {
+	if !balance.IsPositive() || !annualRate.IsPositive() {
+		return decimal.Zero
+	}
+	days := decimal.NewFromInt(daysInYear(dayCount, date.Year()))
+	return balance.Mul(annualRate).DivRound(hundred.Mul(days), accrualScale)
+}
+
+func daysInYear(dayCount string, year int) int64 {
+	switch dayCount {
+	case domain.DayCountActual360:
+		return 360
+	case domain.DayCountActualActual:
+		if time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay() == 366 {
+			return 366
+		}
+	}
+	return 365
+}
+
+// Day returns midnight UTC of the day t falls on in UTC.
+func Day(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+type Options struct {
+	ExpenseAccountID int64
+	DayCount         string
+}
+
+// Result describes one run. Recorded counts the accruals written by this
+// run and AlreadyRecorded those an earlier run of the same day had written.
+// Postings are only made on the last day of a month.
+type Result struct {
+	Date            time.Time            `json:"date"`
+	Recorded        int                  `json:"recorded"`
+	AlreadyRecorded int                  `json:"already_recorded"`
+	Postings        []domain.Transaction `json:"postings"`
+}
+
+// Job accrues daily interest on the end-of-day balance of every account with
+// a rate and, at the end of each month, posts the month's accruals as one
+// transfer per account from the expense account. Accruals are keyed by
+// account and day in the repository, so running a day again records and
+// posts nothing twice.
+type Job struct {
+	repository repository.Repository
+	options    Options
+	now        func() time.Time
+
+	mu sync.Mutex
+}
+
+func NewJob(repository repository.Repository, options Options) *Job {
+	return &Job{repository: repository, options: options, now: time.Now}
+}
+
+// Run accrues interest for the day date falls on in UTC, which must be over.
+// When that is the last day of a month, the unposted accruals of every
+// account up to it are posted; an account whose posting fails does not stop
+// the others, and the failures are returned together.
+func (j *Job) Run(ctx context.Context, date time.Time) (*Result, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	day := Day(date)
+	end := day.AddDate(0, 0, 1)
+	if j.now().Before(end) {
+		return nil, fmt.Errorf("%w: %s", ErrDayNotOver, day.Format(time.DateOnly))
+	}
+
+	result, err := j.run(ctx, day, end)
+	status := StatusOK
+	if err != nil {
+		status = StatusFailed
+	} else {
+		metrics.InterestLastAccrualDate.Set(float64(day.Unix()))
+	}
+	metrics.InterestRunsTotal.WithLabelValues(status).Inc()
+	return result, err
+}
+
+func (j *Job) run(ctx context.Context, day, end time.Time) (*Result, error) {
+	rates, err := j.repository.ListInterestRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list interest rates: %w", err)
+	}
+
+	accruals := make([]domain.InterestAccrual, 0, len(rates))
+	for _, rate := range rates {
+		if rate.AccountID == j.options.ExpenseAccountID || !rate.AnnualRate.IsPositive() {
+			continue
+		}
+		balance, err := j.repository.BalanceAt(ctx, rate.AccountID, end.Add(-time.Nanosecond))
+		if err != nil {
+			return nil, fmt.Errorf("balance of account %d: %w", rate.AccountID, err)
+		}
+		accruals = append(accruals, domain.InterestAccrual{
+			AccountID:  rate.AccountID,
+			Date:       day,
+			Balance:    balance,
+			AnnualRate: rate.AnnualRate,
+			DayCount:   j.options.DayCount,
+			Amount:     DailyInterest(balance, rate.AnnualRate, j.options.DayCount, day),
+		})
+	}
+
+	result := &Result{Date: day, Postings: []domain.Transaction{}}
+	if len(accruals) > 0 {
+		if result.Recorded, err = j.repository.RecordInterestAccruals(ctx, accruals); err != nil {
+			return nil, fmt.Errorf("record accruals: %w", err)
+		}
+	}
+	result.AlreadyRecorded = len(accruals) - result.Recorded
+	if end.Day() != 1 {
+		return result, nil
+	}
+
+	// A rate set to zero during the month still leaves accruals to post.
+	var errs []error
+	for _, rate := range rates {
+		if rate.AccountID == j.options.ExpenseAccountID {
+			continue
+		}
+		posted, err := j.repository.PostInterest(ctx, rate.AccountID, j.options.ExpenseAccountID, day)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("post interest of account %d: %w", rate.AccountID, err))
+			continue
+		}
+		if posted != nil {
+			result.Postings = append(result.Postings, *posted)
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// Schedule returns a worker that, every interval, accrues each day that has
+// ended since the day before it started. A day that fails is retried on the
+// next tick before any later day is accrued; days missed while the worker
+// was not running have to be run by hand.
+func (j *Job) Schedule(interval time.Duration) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		next := Day(j.now()).AddDate(0, 0, -1)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			for ctx.Err() == nil && !j.now().Before(next.AddDate(0, 0, 1).Add(settleDelay)) {
+				if !j.runLogged(ctx, next) {
+					break
+				}
+				next = next.AddDate(0, 0, 1)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+func (j *Job) runLogged(ctx context.Context, day time.Time) bool {
+	result, err := j.Run(ctx, day)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.L().Error("interest accrual failed", zap.String("date", day.Format(time.DateOnly)), zap.Error(err))
+		}
+		return false
+	}
+	logger.L().Info("interest accrued",
+		zap.String("date", day.Format(time.DateOnly)),
+		zap.Int("recorded", result.Recorded),
+		zap.Int("already_recorded", result.AlreadyRecorded),
+		zap.Int("postings", len(result.Postings)),
+	)
+	return true
+}
@@ -0,0 +1,179 @@
+package interest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func day(value string) time.Time {
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// fixedBalances replaces the end-of-day balances of the memory repository,
+// whose history is timestamped with the wall clock.
+type fixedBalances struct {
+	repository.Repository
+	balances map[int64]decimal.Decimal
+}
+
+func (f fixedBalances) BalanceAt(_ context.Context, accountID int64, _ time.Time) (decimal.Decimal, error) {
+	return f.balances[accountID], nil
+}
+
+func TestDailyInterest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		balance  string
+		rate     string
+		dayCount string
+		date     string
+		want     string
+	}{
+		{"actual_365", "1000", "5", domain.DayCountActual365, "2026-03-01", "0.1369863014"},
+		{"actual_360", "1000", "3.6", domain.DayCountActual360, "2026-03-01", "0.1"},
+		{"actual_actual_common_year", "1000", "5", domain.DayCountActualActual, "2026-03-01", "0.1369863014"},
+		{"actual_actual_leap_year", "1000", "5", domain.DayCountActualActual, "2028-03-01", "0.1366120219"},
+		{"smallest_balance", "0.0001", "1", domain.DayCountActual365, "2026-03-01", "0.0000000027"},
+		{"negative_balance", "-500", "5", domain.DayCountActual365, "2026-03-01", "0"},
+		{"zero_rate", "1000", "0", domain.DayCountActual365, "2026-03-01", "0"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			got := DailyInterest(amount(testCase.balance), amount(testCase.rate), testCase.dayCount, day(testCase.date))
+			if !got.Equal(amount(testCase.want)) {
+				t.Fatalf("expected %s, got %s", testCase.want, got)
+			}
+		})
+	}
+}
+
+func newTestJob(t *testing.T, now time.Time) (*Job, repository.Repository) {
+	t.Helper()
+	ctx := context.Background()
+	memory := repository.NewMemoryRepository()
+	for _, account := range []domain.Account{
+		{ID: 1, Balance: amount("1000")},
+		{ID: 2, Balance: amount("500")},
+		{ID: 3, Balance: amount("0")},
+		{ID: 9, Balance: amount("0")},
+	} {
+		if _, err := memory.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account %d: %v", account.ID, err)
+		}
+	}
+	for accountID, rate := range map[int64]string{1: "5", 2: "0", 3: "2"} {
+		if _, err := memory.SetInterestRate(ctx, accountID, amount(rate)); err != nil {
+			t.Fatalf("set interest rate of account %d: %v", accountID, err)
+		}
+	}
+
+	repo := fixedBalances{Repository: memory, balances: map[int64]decimal.Decimal{1: amount("1000"), 2: amount("500"), 3: amount("-20")}}
+	job := NewJob(repo, Options{ExpenseAccountID: 9, DayCount: domain.DayCountActual365})
+	job.now = func() time.Time { return now }
+	return job, memory
+}
+
+func TestJob_Run_RejectsUnfinishedDay(t *testing.T) {
+	t.Parallel()
+
+	job, _ := newTestJob(t, day("2026-03-10").Add(23*time.Hour))
+	if _, err := job.Run(context.Background(), day("2026-03-10")); !errors.Is(err, ErrDayNotOver) {
+		t.Fatalf("expected ErrDayNotOver, got %v", err)
+	}
+}
+
+func TestJob_Run_AccruesOncePerDay(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	job, repo := newTestJob(t, day("2026-04-01"))
+	result, err := job.Run(ctx, day("2026-03-10").Add(15*time.Hour))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !result.Date.Equal(day("2026-03-10")) || result.Recorded != 2 || result.AlreadyRecorded != 0 || len(result.Postings) != 0 {
+		t.Fatalf("expected accruals for accounts 1 and 3 and no postings, got %+v", result)
+	}
+
+	result, err = job.Run(ctx, day("2026-03-10"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Recorded != 0 || result.AlreadyRecorded != 2 {
+		t.Fatalf("expected the second run to record nothing, got %+v", result)
+	}
+
+	accruals, err := repo.ListInterestAccruals(ctx, domain.InterestAccrualFilter{From: day("2026-03-01"), To: day("2026-03-31")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(accruals) != 2 || accruals[0].AccountID != 1 || !accruals[0].Amount.Equal(amount("0.1369863014")) ||
+		accruals[1].AccountID != 3 || !accruals[1].Amount.IsZero() || !accruals[1].Balance.Equal(amount("-20")) {
+		t.Fatalf("unexpected accruals %+v", accruals)
+	}
+}
+
+func TestJob_Run_PostsAtMonthEnd(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	job, repo := newTestJob(t, day("2026-04-02"))
+	for date := day("2026-03-01"); date.Before(day("2026-04-01")); date = date.AddDate(0, 0, 1) {
+		result, err := job.Run(ctx, date)
+		if err != nil {
+			t.Fatalf("run %s: %v", date.Format(time.DateOnly), err)
+		}
+		if date.Day() < 31 && len(result.Postings) != 0 {
+			t.Fatalf("expected no postings on %s, got %+v", date.Format(time.DateOnly), result.Postings)
+		}
+	}
+
+	// 31 days of 0.1369863014 is 4.2465753434, posted rounded to 4.2466.
+	account, err := repo.GetAccount(ctx, "1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !account.Balance.Equal(amount("1004.2466")) {
+		t.Fatalf("expected balance 1004.2466, got %s", account.Balance)
+	}
+	expense, err := repo.GetAccount(ctx, "9")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !expense.Balance.Equal(amount("-4.2466")) {
+		t.Fatalf("expected the expense account at -4.2466, got %s", expense.Balance)
+	}
+
+	result, err := job.Run(ctx, day("2026-03-31"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Recorded != 0 || len(result.Postings) != 0 {
+		t.Fatalf("expected rerunning the month end to post nothing, got %+v", result)
+	}
+	transactions, err := repo.ListTransactions(ctx, 1, domain.TransactionFilter{Limit: 10, Type: domain.TransactionTypeInterest})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].SourceAccountID != 9 || !transactions[0].Amount.Equal(amount("4.2466")) {
+		t.Fatalf("expected one interest transaction of 4.2466, got %+v", transactions)
+	}
+}
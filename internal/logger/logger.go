@@ -9,11 +9,16 @@ import (
 
 var appLogger *zap.Logger
 
-func Init(environment string) error {
+func Init(environment, level string) error {
 	if appLogger != nil {
 		return nil
 	}
 
+	logLevel, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
 	var config zap.Config
 	if environment == "development" || environment == "dev" {
 		config = zap.NewDevelopmentConfig()
@@ -24,6 +29,7 @@ func Init(environment string) error {
 		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	}
 
+	config.Level = zap.NewAtomicLevelAt(logLevel)
 	config.OutputPaths = []string{"stdout"}
 	config.ErrorOutputPaths = []string{"stderr"}
 
@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shopspring/decimal"
+)
+
+const namespace = "transfer_system"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	TransfersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transfers",
+		Name:      "total",
+		Help:      "Total number of transfer attempts by outcome.",
+	}, []string{"outcome"})
+
+	TransferAmountTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transfers",
+		Name:      "amount_total",
+		Help:      "Sum of requested transfer amounts by outcome.",
+	}, []string{"outcome"})
+
+	DBTransactionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_duration_seconds",
+		Help:      "Duration of database transactions from begin to commit or rollback.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	DBLockWaitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "lock_wait_duration_seconds",
+		Help:      "Time spent acquiring row locks inside database transactions.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation"})
+
+	DBTransactionRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_retries_total",
+		Help:      "Database transactions run again after a deadlock or serialization failure, by operation and reason.",
+	}, []string{"operation", "reason"})
+
+	DBTransactionRetriesExhaustedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_retries_exhausted_total",
+		Help:      "Database transactions that still failed with a transient conflict when the retry budget or deadline ran out.",
+	}, []string{"operation"})
+
+	DBShardDebitFallbacksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "shard_debit_fallbacks_total",
+		Help:      "Debits from sharded accounts that had to wait for every shard because the free ones did not cover the amount.",
+	})
+
+	ReconciliationRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reconciliation",
+		Name:      "runs_total",
+		Help:      "Total number of ledger reconciliation runs by status.",
+	}, []string{"status"})
+
+	ReconciliationDiscrepancies = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reconciliation",
+		Name:      "discrepancies",
+		Help:      "Number of discrepancies found by the last completed reconciliation.",
+	})
+
+	ReconciliationLastRunTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reconciliation",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix time at which the last reconciliation finished.",
+	})
+
+	InterestRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "interest",
+		Name:      "runs_total",
+		Help:      "Total number of daily interest accrual runs by status.",
+	}, []string{"status"})
+
+	InterestLastAccrualDate = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "interest",
+		Name:      "last_accrual_date_seconds",
+		Help:      "Unix time of midnight UTC of the last day interest was accrued for.",
+	})
+
+	SnapshotRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance_snapshots",
+		Name:      "runs_total",
+		Help:      "Total number of balance snapshot runs by status.",
+	}, []string{"status"})
+
+	SnapshotLastTakenTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "balance_snapshots",
+		Name:      "last_taken_timestamp_seconds",
+		Help:      "Unix time the last balance snapshots were taken at.",
+	})
+
+	ImportRowsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "import",
+		Name:      "rows_total",
+		Help:      "Total number of rows of imported files by kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	RiskDecisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "risk",
+		Name:      "decisions_total",
+		Help:      "Total number of risk decisions on transfers by decision.",
+	}, []string{"decision"})
+
+	RiskRuleMatchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "risk",
+		Name:      "rule_matches_total",
+		Help:      "Total number of transfers matched by each risk rule by action.",
+	}, []string{"rule", "action"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		TransfersTotal,
+		TransferAmountTotal,
+		DBTransactionDuration,
+		DBLockWaitDuration,
+		DBTransactionRetriesTotal,
+		DBTransactionRetriesExhaustedTotal,
+		DBShardDebitFallbacksTotal,
+		ReconciliationRunsTotal,
+		ReconciliationDiscrepancies,
+		ReconciliationLastRunTimestamp,
+		InterestRunsTotal,
+		InterestLastAccrualDate,
+		SnapshotRunsTotal,
+		SnapshotLastTakenTimestamp,
+		ImportRowsTotal,
+		RiskDecisionsTotal,
+		RiskRuleMatchesTotal,
+	)
+}
+
+func Register(collector prometheus.Collector) error {
+	return registry.Register(collector)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ObserveTransfer(outcome string, amount decimal.Decimal) {
+	TransfersTotal.WithLabelValues(outcome).Inc()
+	value, _ := amount.Float64()
+	if value > 0 {
+		TransferAmountTotal.WithLabelValues(outcome).Add(value)
+	}
+}
@@ -0,0 +1,244 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusOK            = "ok"
+	StatusDiscrepancies = "discrepancies"
+	StatusFailed        = "failed"
+)
+
+const (
+	DiscrepancyBalanceMismatch = "balance_mismatch"
+	DiscrepancyMissingAccount  = "missing_account"
+	DiscrepancyTotalMismatch   = "total_mismatch"
+)
+
+type Discrepancy struct {
+	Type          string           `json:"type"`
+	AccountID     int64            `json:"account_id,omitempty"`
+	TransactionID int64            `json:"transaction_id,omitempty"`
+	Expected      *decimal.Decimal `json:"expected,omitempty"`
+	Actual        *decimal.Decimal `json:"actual,omitempty"`
+	Message       string           `json:"message"`
+}
+
+type Report struct {
+	Status              string          `json:"status"`
+	StartedAt           time.Time       `json:"started_at"`
+	FinishedAt          time.Time       `json:"finished_at"`
+	AccountsChecked     int             `json:"accounts_checked"`
+	TransactionsChecked int64           `json:"transactions_checked"`
+	OpeningTotal        decimal.Decimal `json:"opening_total"`
+	BalanceTotal        decimal.Decimal `json:"balance_total"`
+	Discrepancies       []Discrepancy   `json:"discrepancies"`
+	Error               string          `json:"error,omitempty"`
+}
+
+// Reconcile checks a ledger snapshot. Every account balance must equal its
+// opening balance plus credits minus debits, every transaction must reference
+// existing accounts, and since transfers only move money the balances must add
+// up to the opening balances.
+func Reconcile(snapshot *domain.LedgerSnapshot) Report {
+	report := Report{
+		Status:              StatusOK,
+		AccountsChecked:     len(snapshot.Accounts),
+		TransactionsChecked: snapshot.TransactionCount,
+		Discrepancies:       []Discrepancy{},
+	}
+
+	for _, account := range snapshot.Accounts {
+		report.OpeningTotal = report.OpeningTotal.Add(account.OpeningBalance)
+		report.BalanceTotal = report.BalanceTotal.Add(account.Balance)
+
+		expected := account.OpeningBalance.Add(account.Credits).Sub(account.Debits)
+		if !expected.Equal(account.Balance) {
+			actual := account.Balance
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Type:      DiscrepancyBalanceMismatch,
+				AccountID: account.ID,
+				Expected:  &expected,
+				Actual:    &actual,
+				Message:   fmt.Sprintf("balance differs from opening balance plus history by %s", actual.Sub(expected)),
+			})
+		}
+	}
+
+	accounts := make(map[int64]bool, len(snapshot.Accounts))
+	for _, account := range snapshot.Accounts {
+		accounts[account.ID] = true
+	}
+	for _, transaction := range snapshot.OrphanTransactions {
+		for _, accountID := range []int64{transaction.SourceAccountID, transaction.DestinationAccountID} {
+			if accounts[accountID] {
+				continue
+			}
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Type:          DiscrepancyMissingAccount,
+				AccountID:     accountID,
+				TransactionID: transaction.ID,
+				Message:       fmt.Sprintf("transaction references account %d, which does not exist", accountID),
+			})
+		}
+	}
+
+	if !report.OpeningTotal.Equal(report.BalanceTotal) {
+		expected, actual := report.OpeningTotal, report.BalanceTotal
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Type:     DiscrepancyTotalMismatch,
+			Expected: &expected,
+			Actual:   &actual,
+			Message:  fmt.Sprintf("sum of balances differs from sum of opening balances by %s", actual.Sub(expected)),
+		})
+	}
+
+	if len(report.Discrepancies) > 0 {
+		report.Status = StatusDiscrepancies
+	}
+	return report
+}
+
+// Job runs reconciliations against a ledger, keeps the latest report in
+// memory and, when a report directory is set, writes each report to it.
+type Job struct {
+	ledger    repository.Ledger
+	reportDir string
+	now       func() time.Time
+
+	mu     sync.RWMutex
+	latest *Report
+}
+
+func NewJob(ledger repository.Ledger, reportDir string) *Job {
+	return &Job{ledger: ledger, reportDir: reportDir, now: time.Now}
+}
+
+// Run reconciles the ledger once. A report is returned and kept as the latest
+// even when the snapshot cannot be read; its status is then failed and the
+// error is returned as well.
+func (j *Job) Run(ctx context.Context) (*Report, error) {
+	startedAt := j.now().UTC()
+
+	var report Report
+	snapshot, err := j.ledger.LedgerSnapshot(ctx)
+	if err != nil {
+		err = fmt.Errorf("read ledger: %w", err)
+		report = Report{Status: StatusFailed, Discrepancies: []Discrepancy{}, Error: err.Error()}
+	} else {
+		report = Reconcile(snapshot)
+	}
+	report.StartedAt = startedAt
+	report.FinishedAt = j.now().UTC()
+
+	j.mu.Lock()
+	j.latest = &report
+	j.mu.Unlock()
+
+	metrics.ReconciliationRunsTotal.WithLabelValues(report.Status).Inc()
+	metrics.ReconciliationLastRunTimestamp.Set(float64(report.FinishedAt.Unix()))
+	if report.Status != StatusFailed {
+		metrics.ReconciliationDiscrepancies.Set(float64(len(report.Discrepancies)))
+	}
+
+	if j.reportDir != "" {
+		if writeErr := j.write(&report); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return &report, err
+}
+
+// Latest returns the report of the most recent run, if there was one.
+func (j *Job) Latest() (*Report, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.latest == nil {
+		return nil, false
+	}
+	report := *j.latest
+	return &report, true
+}
+
+// Schedule returns a worker that reconciles immediately and then every
+// interval until its context is canceled.
+func (j *Job) Schedule(interval time.Duration) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			j.runLogged(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+func (j *Job) runLogged(ctx context.Context) {
+	report, err := j.Run(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.L().Error("reconciliation failed", zap.Error(err))
+		}
+		return
+	}
+	fields := []zap.Field{
+		zap.String("status", report.Status),
+		zap.Int("accounts", report.AccountsChecked),
+		zap.Int64("transactions", report.TransactionsChecked),
+		zap.Int("discrepancies", len(report.Discrepancies)),
+		zap.Duration("duration", report.FinishedAt.Sub(report.StartedAt)),
+	}
+	if report.Status == StatusDiscrepancies {
+		logger.L().Warn("reconciliation found discrepancies", fields...)
+		return
+	}
+	logger.L().Info("reconciliation completed", fields...)
+}
+
+// write stores the report as reconciliation-<finished at>.json, writing to a
+// temporary file first so that readers never see a partial report.
+func (j *Job) write(report *Report) error {
+	if err := os.MkdirAll(j.reportDir, 0o755); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	contents, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	name := filepath.Join(j.reportDir, "reconciliation-"+report.FinishedAt.Format("20060102T150405.000000000Z")+".json")
+	temporary, err := os.CreateTemp(j.reportDir, ".reconciliation-*.json")
+	if err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(append(contents, '\n')); err != nil {
+		temporary.Close()
+		return fmt.Errorf("write report: %w", err)
+	}
+	if err := temporary.Close(); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	if err := os.Rename(temporary.Name(), name); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
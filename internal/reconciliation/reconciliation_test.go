@@ -0,0 +1,163 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+type ledgerFunc func(ctx context.Context) (*domain.LedgerSnapshot, error)
+
+func (f ledgerFunc) LedgerSnapshot(ctx context.Context) (*domain.LedgerSnapshot, error) {
+	return f(ctx)
+}
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestReconcile_ConsistentLedger(t *testing.T) {
+	t.Parallel()
+
+	report := Reconcile(&domain.LedgerSnapshot{
+		Accounts: []domain.LedgerAccount{
+			{ID: 1, OpeningBalance: amount("100"), Balance: amount("89.5"), Credits: amount("0"), Debits: amount("10.5")},
+			{ID: 2, OpeningBalance: amount("0"), Balance: amount("10.5"), Credits: amount("10.5"), Debits: amount("0")},
+		},
+		TransactionCount: 1,
+	})
+
+	if report.Status != StatusOK || len(report.Discrepancies) != 0 {
+		t.Fatalf("expected ok report, got %+v", report)
+	}
+	if report.AccountsChecked != 2 || report.TransactionsChecked != 1 {
+		t.Fatalf("expected 2 accounts and 1 transaction checked, got %d and %d", report.AccountsChecked, report.TransactionsChecked)
+	}
+	if !report.OpeningTotal.Equal(amount("100")) || !report.BalanceTotal.Equal(amount("100")) {
+		t.Fatalf("expected totals of 100, got %s and %s", report.OpeningTotal, report.BalanceTotal)
+	}
+}
+
+func TestReconcile_BalanceMismatch(t *testing.T) {
+	t.Parallel()
+
+	report := Reconcile(&domain.LedgerSnapshot{
+		Accounts: []domain.LedgerAccount{
+			{ID: 1, OpeningBalance: amount("100"), Balance: amount("95"), Credits: amount("0"), Debits: amount("10")},
+			{ID: 2, OpeningBalance: amount("0"), Balance: amount("10"), Credits: amount("10"), Debits: amount("0")},
+		},
+		TransactionCount: 1,
+	})
+
+	if report.Status != StatusDiscrepancies {
+		t.Fatalf("expected status %q, got %q", StatusDiscrepancies, report.Status)
+	}
+	if len(report.Discrepancies) != 2 {
+		t.Fatalf("expected balance and total discrepancies, got %+v", report.Discrepancies)
+	}
+	mismatch := report.Discrepancies[0]
+	if mismatch.Type != DiscrepancyBalanceMismatch || mismatch.AccountID != 1 || !mismatch.Expected.Equal(amount("90")) || !mismatch.Actual.Equal(amount("95")) {
+		t.Fatalf("expected balance mismatch on account 1 of 90 vs 95, got %+v", mismatch)
+	}
+	if total := report.Discrepancies[1]; total.Type != DiscrepancyTotalMismatch || !total.Expected.Equal(amount("100")) || !total.Actual.Equal(amount("105")) {
+		t.Fatalf("expected total mismatch of 100 vs 105, got %+v", total)
+	}
+}
+
+func TestReconcile_MissingAccount(t *testing.T) {
+	t.Parallel()
+
+	report := Reconcile(&domain.LedgerSnapshot{
+		Accounts: []domain.LedgerAccount{
+			{ID: 1, OpeningBalance: amount("100"), Balance: amount("75"), Credits: amount("0"), Debits: amount("25")},
+		},
+		TransactionCount: 1,
+		OrphanTransactions: []domain.Transaction{
+			{ID: 7, SourceAccountID: 1, DestinationAccountID: 9, Amount: amount("25")},
+		},
+	})
+
+	if report.Status != StatusDiscrepancies || len(report.Discrepancies) != 2 {
+		t.Fatalf("expected missing account and total discrepancies, got %+v", report)
+	}
+	missing := report.Discrepancies[0]
+	if missing.Type != DiscrepancyMissingAccount || missing.AccountID != 9 || missing.TransactionID != 7 {
+		t.Fatalf("expected missing account 9 on transaction 7, got %+v", missing)
+	}
+	if report.Discrepancies[1].Type != DiscrepancyTotalMismatch {
+		t.Fatalf("expected total mismatch, got %+v", report.Discrepancies[1])
+	}
+}
+
+func TestJob_RunKeepsAndWritesReport(t *testing.T) {
+	t.Parallel()
+
+	storage := repository.NewMemoryRepository()
+	ctx := context.Background()
+	for _, account := range []domain.Account{{ID: 1, Balance: amount("100")}, {ID: 2, Balance: amount("5")}} {
+		if _, err := storage.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account: %v", err)
+		}
+	}
+	if _, err := storage.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("12.25")}); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+
+	reportDir := t.TempDir()
+	job := NewJob(storage, reportDir)
+	job.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	if _, ok := job.Latest(); ok {
+		t.Fatalf("expected no report before the first run")
+	}
+	report, err := job.Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Status != StatusOK || report.AccountsChecked != 2 || report.TransactionsChecked != 1 {
+		t.Fatalf("expected ok report over 2 accounts and 1 transaction, got %+v", report)
+	}
+	latest, ok := job.Latest()
+	if !ok || latest.Status != StatusOK {
+		t.Fatalf("expected latest report to be kept, got %+v", latest)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(reportDir, "reconciliation-20260102T030405.000000000Z.json"))
+	if err != nil {
+		t.Fatalf("expected report file: %v", err)
+	}
+	var written Report
+	if err := json.Unmarshal(contents, &written); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if written.Status != StatusOK || !written.BalanceTotal.Equal(amount("105")) {
+		t.Fatalf("expected written ok report with total 105, got %+v", written)
+	}
+}
+
+func TestJob_RunRecordsFailure(t *testing.T) {
+	t.Parallel()
+
+	job := NewJob(ledgerFunc(func(context.Context) (*domain.LedgerSnapshot, error) {
+		return nil, errors.New("connection refused")
+	}), "")
+
+	report, err := job.Run(context.Background())
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if report.Status != StatusFailed || report.Error == "" {
+		t.Fatalf("expected failed report with error, got %+v", report)
+	}
+	if latest, ok := job.Latest(); !ok || latest.Status != StatusFailed {
+		t.Fatalf("expected failed report to be kept as latest, got %+v", latest)
+	}
+}
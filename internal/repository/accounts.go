@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// accountTags returns the tags an account is created with, never nil so that
+// an account without tags stores an empty list.
+func accountTags(account domain.Account) []string {
+	if account.Tags == nil {
+		return []string{}
+	}
+	return account.Tags
+}
+
+// pgAccountListColumns selects the columns of pgAccountColumns from a
+// subquery over it, so that listings filter and sort on the balance of the
+// shards too.
+const pgAccountListColumns = `id, balance, status, tier, tags, shards, version, created_at`
+
+// ListAccounts pages through accounts with a keyset on the sort column and
+// the ID, so that deep pages cost no more than the first.
+func (r *PGRepository) ListAccounts(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error) {
+	clauses, args := accountListClauses(filter, pgAccountDialect)
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+pgAccountListColumns+`
+        FROM (SELECT `+pgAccountColumns+` FROM accounts.accounts a) listed
+        `+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]domain.Account, 0, filter.Limit)
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// GetAccounts reads every account in a single statement, ordered by ID.
+func (r *PGRepository) GetAccounts(ctx context.Context, ids []int64) ([]domain.Account, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+pgAccountColumns+` FROM accounts.accounts a WHERE a.id = ANY($1) ORDER BY a.id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]domain.Account, 0, len(ids))
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// accountDialect renders the parts of an account listing that differ between
+// databases: the n-th parameter, a parameter compared with a balance and the
+// condition that an account carries every one of tags.
+type accountDialect struct {
+	placeholder func(n int) string
+	decimal     func(param string) string
+	tagged      func(q *accountQuery, tags []string) string
+}
+
+var pgAccountDialect = accountDialect{
+	placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	decimal:     func(param string) string { return param + "::numeric" },
+	tagged:      func(q *accountQuery, tags []string) string { return "tags @> " + q.arg(tags) + "::text[]" },
+}
+
+// accountQuery collects the parameters of a query as its clauses are
+// rendered.
+type accountQuery struct {
+	dialect accountDialect
+	args    []any
+}
+
+func (q *accountQuery) arg(value any) string {
+	q.args = append(q.args, value)
+	return q.dialect.placeholder(len(q.args))
+}
+
+func (q *accountQuery) decimalArg(value decimal.Decimal) string {
+	return q.dialect.decimal(q.arg(value))
+}
+
+// accountListClauses renders filter as the WHERE, ORDER BY and LIMIT clauses
+// of a query over columns named id, balance, status and created_at.
+func accountListClauses(filter domain.AccountFilter, dialect accountDialect) (string, []any) {
+	q := &accountQuery{dialect: dialect}
+	conditions := []string{"TRUE"}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+q.arg(filter.Status))
+	}
+	if filter.MinBalance != nil {
+		conditions = append(conditions, "balance >= "+q.decimalArg(*filter.MinBalance))
+	}
+	if filter.MaxBalance != nil {
+		conditions = append(conditions, "balance <= "+q.decimalArg(*filter.MaxBalance))
+	}
+	if !filter.CreatedSince.IsZero() {
+		conditions = append(conditions, "created_at >= "+q.arg(filter.CreatedSince.UTC()))
+	}
+	if !filter.CreatedUntil.IsZero() {
+		conditions = append(conditions, "created_at < "+q.arg(filter.CreatedUntil.UTC()))
+	}
+	if len(filter.Tags) > 0 {
+		conditions = append(conditions, dialect.tagged(q, filter.Tags))
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if after := filter.After; after != nil {
+		switch filter.Sort {
+		case domain.AccountSortBalance:
+			conditions = append(conditions, "(balance, id) "+comparison+" ("+q.decimalArg(after.Balance)+", "+q.arg(after.ID)+")")
+		case domain.AccountSortCreatedAt:
+			conditions = append(conditions, "(created_at, id) "+comparison+" ("+q.arg(after.CreatedAt.UTC())+", "+q.arg(after.ID)+")")
+		default:
+			conditions = append(conditions, "id "+comparison+" "+q.arg(after.ID))
+		}
+	}
+
+	order := "id " + direction
+	switch filter.Sort {
+	case domain.AccountSortBalance, domain.AccountSortCreatedAt:
+		order = filter.Sort + " " + direction + ", " + order
+	}
+	return "WHERE " + strings.Join(conditions, " AND ") + " ORDER BY " + order + " LIMIT " + q.arg(filter.Limit), q.args
+}
+
+// listAccounts applies filter to accounts for backends that filter in Go.
+func listAccounts(accounts []domain.Account, filter domain.AccountFilter) []domain.Account {
+	listed := make([]domain.Account, 0, min(len(accounts), filter.Limit))
+	for i := range accounts {
+		if matchesAccountFilter(&accounts[i], filter) {
+			listed = append(listed, accounts[i])
+		}
+	}
+	slices.SortFunc(listed, func(a, b domain.Account) int {
+		return compareAccountPositions(filter, accountPosition(&a), accountPosition(&b))
+	})
+	return listed[:min(len(listed), filter.Limit)]
+}
+
+func matchesAccountFilter(account *domain.Account, filter domain.AccountFilter) bool {
+	switch {
+	case filter.Status != "" && account.Status != filter.Status:
+		return false
+	case filter.MinBalance != nil && account.Balance.LessThan(*filter.MinBalance):
+		return false
+	case filter.MaxBalance != nil && account.Balance.GreaterThan(*filter.MaxBalance):
+		return false
+	case !filter.CreatedSince.IsZero() && account.CreatedAt.Before(filter.CreatedSince):
+		return false
+	case !filter.CreatedUntil.IsZero() && !account.CreatedAt.Before(filter.CreatedUntil):
+		return false
+	case filter.After != nil && compareAccountPositions(filter, accountPosition(account), *filter.After) <= 0:
+		return false
+	}
+	for _, tag := range filter.Tags {
+		if !slices.Contains(account.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func accountPosition(account *domain.Account) domain.AccountCursor {
+	return domain.AccountCursor{ID: account.ID, Balance: account.Balance, CreatedAt: account.CreatedAt}
+}
+
+// compareAccountPositions orders two accounts the way filter lists them.
+func compareAccountPositions(filter domain.AccountFilter, a, b domain.AccountCursor) int {
+	var order int
+	switch filter.Sort {
+	case domain.AccountSortBalance:
+		order = a.Balance.Cmp(b.Balance)
+	case domain.AccountSortCreatedAt:
+		order = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if order == 0 {
+		order = cmp.Compare(a.ID, b.ID)
+	}
+	if filter.Descending {
+		return -order
+	}
+	return order
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// auditRecord describes a mutation. Every backend writes it to the audit log
+// in the same database transaction as the mutation, so the log holds exactly
+// the changes that were committed.
+type auditRecord struct {
+	operation             string
+	accountID             int64
+	counterpartyAccountID *int64
+	transactionID         *int64
+	before                any
+	after                 any
+}
+
+// transferState is the audited state of both sides of a transfer. The
+// transaction is only part of the state after the transfer.
+type transferState struct {
+	Source      domain.Account      `json:"source"`
+	Destination domain.Account      `json:"destination"`
+	Transaction *domain.Transaction `json:"transaction,omitempty"`
+}
+
+func transferAuditRecord(before, after transferState) auditRecord {
+	operation := domain.AuditOperationTransfer
+	switch after.Transaction.Type {
+	case domain.TransactionTypeReversal:
+		operation = domain.AuditOperationTransactionReverse
+	case domain.TransactionTypeDeposit:
+		operation = domain.AuditOperationDeposit
+	case domain.TransactionTypeWithdrawal:
+		operation = domain.AuditOperationWithdrawal
+	case domain.TransactionTypeFee:
+		operation = domain.AuditOperationFee
+	case domain.TransactionTypeInterest:
+		operation = domain.AuditOperationInterest
+	}
+	return auditRecord{
+		operation:             operation,
+		accountID:             after.Source.ID,
+		counterpartyAccountID: &after.Destination.ID,
+		transactionID:         &after.Transaction.ID,
+		before:                before,
+		after:                 after,
+	}
+}
+
+// riskDecisionAuditRecord records the decision on a transfer. A transfer
+// that was allowed or flagged for review is recorded in the transaction that
+// executes it and names it; a denied one names no transaction and is written
+// on its own.
+func riskDecisionAuditRecord(decision domain.RiskDecision, transactionID *int64) auditRecord {
+	return auditRecord{
+		operation:             domain.AuditOperationRiskDecision,
+		accountID:             decision.SourceAccountID,
+		counterpartyAccountID: &decision.DestinationAccountID,
+		transactionID:         transactionID,
+		after:                 decision,
+	}
+}
+
+func accountStatusAuditRecord(before, after *domain.Account) auditRecord {
+	operation := domain.AuditOperationAccountFreeze
+	if after.Status == domain.AccountStatusActive {
+		operation = domain.AuditOperationAccountUnfreeze
+	}
+	return auditRecord{operation: operation, accountID: after.ID, before: before, after: after}
+}
+
+// newAuditEntry resolves the caller from ctx and encodes the before and
+// after states.
+func newAuditEntry(ctx context.Context, record auditRecord) (domain.AuditEntry, error) {
+	metadata := audit.FromContext(ctx)
+	entry := domain.AuditEntry{
+		Actor:                 metadata.Actor,
+		RequestID:             metadata.RequestID,
+		ClientIP:              metadata.ClientIP,
+		Operation:             record.operation,
+		AccountID:             record.accountID,
+		CounterpartyAccountID: record.counterpartyAccountID,
+		TransactionID:         record.transactionID,
+	}
+	var err error
+	if record.before != nil {
+		if entry.Before, err = json.Marshal(record.before); err != nil {
+			return entry, err
+		}
+	}
+	if record.after != nil {
+		if entry.After, err = json.Marshal(record.after); err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+// matchesAuditFilter applies filter to an entry for backends that filter in Go.
+func matchesAuditFilter(entry *domain.AuditEntry, filter domain.AuditFilter) bool {
+	switch {
+	case filter.Actor != "" && entry.Actor != filter.Actor:
+		return false
+	case filter.Operation != "" && entry.Operation != filter.Operation:
+		return false
+	case filter.RequestID != "" && entry.RequestID != filter.RequestID:
+		return false
+	case filter.AccountID != 0 && entry.AccountID != filter.AccountID &&
+		(entry.CounterpartyAccountID == nil || *entry.CounterpartyAccountID != filter.AccountID):
+		return false
+	case !filter.Since.IsZero() && entry.OccurredAt.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && !entry.OccurredAt.Before(filter.Until):
+		return false
+	case filter.BeforeID != 0 && entry.ID >= filter.BeforeID:
+		return false
+	}
+	return true
+}
+
+// nullableJSON maps an absent state to SQL NULL.
+func nullableJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+// nullableTime maps an unset bound to SQL NULL.
+func nullableTime(value time.Time) any {
+	if value.IsZero() {
+		return nil
+	}
+	return value.UTC()
+}
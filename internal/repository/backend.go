@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// Ledger gives reconciliation a consistent view of all balances and history.
+type Ledger interface {
+	LedgerSnapshot(ctx context.Context) (*domain.LedgerSnapshot, error)
+}
+
+// Backend is a Repository together with the lifecycle hooks the server needs
+// for health checks and shutdown.
+type Backend interface {
+	Repository
+	Ledger
+	Ping(ctx context.Context) error
+	CheckSchema(ctx context.Context) error
+	Close()
+}
+
+var (
+	_ Backend = (*PGRepository)(nil)
+	_ Backend = (*MemoryRepository)(nil)
+	_ Backend = (*SQLiteRepository)(nil)
+	_ Backend = (*MySQLRepository)(nil)
+)
+
+// Open selects the storage backend from the scheme of the database URL,
+// applies pending migrations when enabled and refuses to return a backend
+// whose schema is dirty or behind the embedded migrations.
+func Open(ctx context.Context, databaseConfig config.DatabaseConfig) (Backend, error) {
+	parsed, err := url.Parse(databaseConfig.URL)
+	if err != nil {
+		return nil, fmt.Errorf("database url: %w", err)
+	}
+
+	switch parsed.Scheme {
+	case "memory":
+		return NewMemoryRepository(), nil
+	case "sqlite":
+		return openSQLite(ctx, databaseConfig)
+	case "mysql":
+		return openMySQL(ctx, databaseConfig)
+	case "postgres", "postgresql":
+		return openPostgres(ctx, databaseConfig)
+	}
+	return nil, fmt.Errorf("database url: unsupported scheme %q", parsed.Scheme)
+}
+
+func openPostgres(ctx context.Context, databaseConfig config.DatabaseConfig) (*PGRepository, error) {
+	if databaseConfig.AutoMigrate {
+		if err := MigrateUp(databaseConfig.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	pool, err := NewPool(ctx, databaseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	postgresRepository := NewPGRepository(pool, PGOptions{
+		Retry: RetryPolicy{
+			MaxAttempts: int(databaseConfig.RetryMaxAttempts),
+			BaseDelay:   databaseConfig.RetryBaseDelay,
+			MaxDelay:    databaseConfig.RetryMaxDelay,
+		},
+		Serializable: databaseConfig.SerializableTransfers,
+	})
+	if err := postgresRepository.CheckSchema(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("refusing to serve: %w", err)
+	}
+	return postgresRepository, nil
+}
+
+func openSQLite(ctx context.Context, databaseConfig config.DatabaseConfig) (*SQLiteRepository, error) {
+	if databaseConfig.AutoMigrate {
+		if err := MigrateUp(databaseConfig.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := NewSQLiteDB(ctx, databaseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	sqliteRepository := NewSQLiteRepository(db)
+	if err := sqliteRepository.CheckSchema(ctx); err != nil {
+		sqliteRepository.Close()
+		return nil, fmt.Errorf("refusing to serve: %w", err)
+	}
+	return sqliteRepository, nil
+}
+
+func openMySQL(ctx context.Context, databaseConfig config.DatabaseConfig) (*MySQLRepository, error) {
+	if databaseConfig.AutoMigrate {
+		if err := MigrateUp(databaseConfig.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := NewMySQLDB(ctx, databaseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	mysqlRepository := NewMySQLRepository(db)
+	if err := mysqlRepository.CheckSchema(ctx); err != nil {
+		mysqlRepository.Close()
+		return nil, fmt.Errorf("refusing to serve: %w", err)
+	}
+	return mysqlRepository, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// accountBalance picks the balance of one account out of the result of
+// BalancesAt.
+func accountBalance(balances map[int64]decimal.Decimal, accountID int64) (decimal.Decimal, error) {
+	balance, ok := balances[accountID]
+	if !ok {
+		return decimal.Zero, ErrAccountNotFound
+	}
+	return balance, nil
+}
+
+// BalanceAt recomputes the balance of an account after the transactions
+// created up to and including at. It is zero before the account existed.
+func (r *PGRepository) BalanceAt(ctx context.Context, accountID int64, at time.Time) (decimal.Decimal, error) {
+	balances, err := r.BalancesAt(ctx, []int64{accountID}, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return accountBalance(balances, accountID)
+}
+
+// BalancesAt starts each account from its latest snapshot taken at or before
+// at, or from its opening balance, and adds the transactions created after
+// that up to at. A single statement reads every account from the same
+// snapshot of the database.
+func (r *PGRepository) BalancesAt(ctx context.Context, accountIDs []int64, at time.Time) (map[int64]decimal.Decimal, error) {
+	rows, err := r.pool.Query(ctx, `
+        SELECT a.id, CASE WHEN a.created_at > $2 THEN 0 ELSE COALESCE(s.balance, a.opening_balance)
+            + COALESCE((SELECT SUM(t.amount) FROM accounts.transactions t
+                WHERE t.destination_account_id = a.id AND t.created_at <= $2 AND t.created_at > COALESCE(s.taken_at, '-infinity'::timestamptz)), 0)
+            - COALESCE((SELECT SUM(t.amount) FROM accounts.transactions t
+                WHERE t.source_account_id = a.id AND t.created_at <= $2 AND t.created_at > COALESCE(s.taken_at, '-infinity'::timestamptz)), 0) END
+        FROM accounts.accounts a
+        LEFT JOIN LATERAL (
+            SELECT taken_at, balance
+            FROM accounts.balance_snapshots
+            WHERE account_id = a.id AND taken_at <= $2
+            ORDER BY taken_at DESC
+            LIMIT 1
+        ) s ON TRUE
+        WHERE a.id = ANY($1)
+    `, accountIDs, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[int64]decimal.Decimal, len(accountIDs))
+	for rows.Next() {
+		var (
+			accountID int64
+			balance   decimal.Decimal
+		)
+		if err := rows.Scan(&accountID, &balance); err != nil {
+			return nil, err
+		}
+		balances[accountID] = balance
+	}
+	return balances, rows.Err()
+}
+
+// SnapshotCutoff moves at back to just before the oldest open transaction
+// started. Transactions are created at the time their database transaction
+// started, and one that runs long may commit with a time well before the
+// clock. Sessions of other roles are only seen with pg_read_all_stats, but
+// transfers run as this one.
+func (r *PGRepository) SnapshotCutoff(ctx context.Context, at time.Time) (time.Time, error) {
+	var oldest *time.Time
+	if err := r.pool.QueryRow(ctx, `
+        SELECT MIN(xact_start)
+        FROM pg_stat_activity
+        WHERE datname = current_database() AND backend_type = 'client backend' AND pid <> pg_backend_pid()
+    `).Scan(&oldest); err != nil {
+		return time.Time{}, err
+	}
+	if oldest != nil && !oldest.After(at) {
+		return oldest.UTC().Add(-time.Microsecond), nil
+	}
+	return at, nil
+}
+
+// TakeBalanceSnapshots records, at at, the balance of every account that has
+// transactions created since its previous snapshot, and returns how many it
+// recorded. Transactions created up to at must all have committed.
+func (r *PGRepository) TakeBalanceSnapshots(ctx context.Context, at time.Time) (int, error) {
+	tag, err := r.pool.Exec(ctx, `
+        INSERT INTO accounts.balance_snapshots (account_id, taken_at, balance)
+        SELECT a.id, $1::timestamptz, COALESCE(s.balance, a.opening_balance) + d.credits - d.debits
+        FROM accounts.accounts a
+        LEFT JOIN LATERAL (
+            SELECT taken_at, balance
+            FROM accounts.balance_snapshots
+            WHERE account_id = a.id AND taken_at <= $1::timestamptz
+            ORDER BY taken_at DESC
+            LIMIT 1
+        ) s ON TRUE
+        CROSS JOIN LATERAL (
+            SELECT COUNT(*) AS transactions,
+                COALESCE(SUM(t.amount) FILTER (WHERE t.destination_account_id = a.id), 0) AS credits,
+                COALESCE(SUM(t.amount) FILTER (WHERE t.source_account_id = a.id), 0) AS debits
+            FROM accounts.transactions t
+            WHERE (t.source_account_id = a.id OR t.destination_account_id = a.id)
+              AND t.created_at <= $1::timestamptz AND t.created_at > COALESCE(s.taken_at, '-infinity'::timestamptz)
+        ) d
+        WHERE a.created_at <= $1::timestamptz AND d.transactions > 0
+        ON CONFLICT (account_id, taken_at) DO NOTHING
+    `, at)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
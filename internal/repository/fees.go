@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// feeRuleParameters is the JSON stored in the parameters column of a fee
+// rule. Which fields are set depends on the kind of the rule.
+type feeRuleParameters struct {
+	Amount  *decimal.Decimal `json:"amount,omitempty"`
+	Percent *decimal.Decimal `json:"percent,omitempty"`
+	MinFee  *decimal.Decimal `json:"min_fee,omitempty"`
+	MaxFee  *decimal.Decimal `json:"max_fee,omitempty"`
+	Tiers   []domain.FeeTier `json:"tiers,omitempty"`
+}
+
+func encodeFeeRuleParameters(rule domain.FeeRule) ([]byte, error) {
+	return json.Marshal(feeRuleParameters{
+		Amount:  rule.Amount,
+		Percent: rule.Percent,
+		MinFee:  rule.MinFee,
+		MaxFee:  rule.MaxFee,
+		Tiers:   rule.Tiers,
+	})
+}
+
+func decodeFeeRuleParameters(rule *domain.FeeRule, encoded []byte) error {
+	var parameters feeRuleParameters
+	if err := json.Unmarshal(encoded, &parameters); err != nil {
+		return fmt.Errorf("fee rule %d: invalid parameters: %w", rule.ID, err)
+	}
+	rule.Amount = parameters.Amount
+	rule.Percent = parameters.Percent
+	rule.MinFee = parameters.MinFee
+	rule.MaxFee = parameters.MaxFee
+	rule.Tiers = parameters.Tiers
+	return nil
+}
+
+// feeTransfer is the transaction that posts the fee of transaction from its
+// source to the fee account.
+func feeTransfer(transaction domain.Transaction) domain.Transaction {
+	return domain.Transaction{
+		Type:                 domain.TransactionTypeFee,
+		SourceAccountID:      transaction.SourceAccountID,
+		DestinationAccountID: transaction.Fee.AccountID,
+		Amount:               transaction.Fee.Amount,
+	}
+}
+
+// withFeeTransaction returns transfer with its fee pointing at the posted
+// fee transaction.
+func withFeeTransaction(transfer *domain.Transaction, fee *domain.Transaction) *domain.Transaction {
+	charged := *transfer.Fee
+	charged.TransactionID = fee.ID
+	transfer.Fee = &charged
+	return transfer
+}
+
+func accountTier(account domain.Account) string {
+	if account.Tier == "" {
+		return domain.AccountTierStandard
+	}
+	return account.Tier
+}
+
+func (r *PGRepository) ListFeeRules(ctx context.Context) ([]domain.FeeRule, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+feeRuleColumns+` FROM accounts.fee_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []domain.FeeRule{}
+	for rows.Next() {
+		rule, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *PGRepository) CreateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	parameters, err := encodeFeeRuleParameters(rule)
+	if err != nil {
+		return nil, err
+	}
+	return scanFeeRule(r.pool.QueryRow(ctx, `
+        INSERT INTO accounts.fee_rules (name, kind, account_tier, parameters)
+        VALUES ($1, $2, $3, $4::JSONB)
+        RETURNING `+feeRuleColumns,
+		rule.Name, rule.Kind, rule.AccountTier, string(parameters)))
+}
+
+func (r *PGRepository) UpdateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	parameters, err := encodeFeeRuleParameters(rule)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := scanFeeRule(r.pool.QueryRow(ctx, `
+        UPDATE accounts.fee_rules
+        SET name = $2, kind = $3, account_tier = $4, parameters = $5::JSONB, updated_at = NOW()
+        WHERE id = $1
+        RETURNING `+feeRuleColumns,
+		rule.ID, rule.Name, rule.Kind, rule.AccountTier, string(parameters)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrFeeRuleNotFound
+	}
+	return updated, err
+}
+
+func (r *PGRepository) DeleteFeeRule(ctx context.Context, id int64) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM accounts.fee_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFeeRuleNotFound
+	}
+	return nil
+}
+
+const feeRuleColumns = `id, name, kind, account_tier, parameters, created_at, updated_at`
+
+func scanFeeRule(row pgx.Row) (*domain.FeeRule, error) {
+	var (
+		rule       domain.FeeRule
+		parameters []byte
+	)
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.Kind, &rule.AccountTier, &parameters, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := decodeFeeRuleParameters(&rule, parameters); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// interestPostingScale is the scale posted interest is rounded to, the scale
+// balances are stored with. Accruals keep ten decimal places until then.
+const interestPostingScale = 4
+
+// interestTransfer pays amount of interest from the expense account, rounded
+// to interestPostingScale. The rounding difference is carried to the next
+// posting through interestCarry.
+func interestTransfer(accountID, expenseAccountID int64, amount decimal.Decimal) domain.Transaction {
+	return domain.Transaction{
+		Type:                 domain.TransactionTypeInterest,
+		SourceAccountID:      expenseAccountID,
+		DestinationAccountID: accountID,
+		Amount:               amount.Round(interestPostingScale),
+	}
+}
+
+// interestCarry is what the postings of an account paid short of the accruals
+// they covered, or over them when negative, because they were rounded. It is
+// added to the next posting, so that over time an account is paid what it
+// accrued rather than losing the rounding of every posting.
+func interestCarry(ctx context.Context, tx pgx.Tx, accountID int64) (decimal.Decimal, error) {
+	var carry decimal.Decimal
+	err := tx.QueryRow(ctx, `
+        SELECT COALESCE(SUM(amount), 0) - COALESCE((
+            SELECT SUM(t.amount)
+            FROM accounts.transactions t
+            WHERE t.id IN (
+                SELECT transaction_id FROM accounts.interest_accruals WHERE account_id = $1 AND transaction_id IS NOT NULL
+            )
+        ), 0)
+        FROM accounts.interest_accruals
+        WHERE account_id = $1 AND transaction_id IS NOT NULL
+    `, accountID).Scan(&carry)
+	return carry, err
+}
+
+func interestRateAuditRecord(before *domain.InterestRate, after *domain.InterestRate) auditRecord {
+	record := auditRecord{operation: domain.AuditOperationInterestRate, accountID: after.AccountID, after: after}
+	if before != nil {
+		record.before = before
+	}
+	return record
+}
+
+func (r *PGRepository) SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (*domain.InterestRate, error) {
+	var after *domain.InterestRate
+	err := r.inTx(ctx, "set_interest_rate", pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM accounts.accounts WHERE id = $1)`, accountID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrAccountNotFound
+		}
+		before, err := scanInterestRate(tx.QueryRow(ctx, `SELECT `+interestRateColumns+` FROM accounts.interest_rates WHERE account_id = $1 FOR UPDATE`, accountID))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		after, err = scanInterestRate(tx.QueryRow(ctx, `
+            INSERT INTO accounts.interest_rates (account_id, annual_rate)
+            VALUES ($1, $2)
+            ON CONFLICT (account_id) DO UPDATE SET annual_rate = EXCLUDED.annual_rate, updated_at = NOW()
+            RETURNING `+interestRateColumns,
+			accountID, annualRate))
+		if err != nil {
+			return err
+		}
+		return insertAuditEntry(ctx, tx, interestRateAuditRecord(before, after))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func (r *PGRepository) ListInterestRates(ctx context.Context) ([]domain.InterestRate, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+interestRateColumns+` FROM accounts.interest_rates ORDER BY account_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []domain.InterestRate{}
+	for rows.Next() {
+		rate, err := scanInterestRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+	return rates, rows.Err()
+}
+
+// RecordInterestAccruals inserts the accruals that are not recorded yet and
+// returns how many it inserted. An accrual already recorded for the same
+// account and day is kept as it is.
+func (r *PGRepository) RecordInterestAccruals(ctx context.Context, accruals []domain.InterestAccrual) (int, error) {
+	var recorded int
+	err := r.inTx(ctx, "record_interest_accruals", pgx.TxOptions{}, func(tx pgx.Tx) error {
+		recorded = 0
+		for _, accrual := range accruals {
+			tag, err := tx.Exec(ctx, `
+                INSERT INTO accounts.interest_accruals (account_id, accrual_date, balance, annual_rate, day_count, amount)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                ON CONFLICT (account_id, accrual_date) DO NOTHING
+            `, accrual.AccountID, accrual.Date, accrual.Balance, accrual.AnnualRate, accrual.DayCount, accrual.Amount)
+			if err != nil {
+				return err
+			}
+			recorded += int(tag.RowsAffected())
+		}
+		return nil
+	})
+	return recorded, err
+}
+
+// PostInterest pays the unposted accruals of an account up to and including
+// through, with the carry of earlier postings, rounded, out of the expense
+// account, which may go negative, and marks them posted in the same
+// transaction. It returns nil when there is nothing to pay.
+func (r *PGRepository) PostInterest(ctx context.Context, accountID, expenseAccountID int64, through time.Time) (*domain.Transaction, error) {
+	var posted *domain.Transaction
+	err := r.inTx(ctx, "post_interest", r.transferTxOptions(), func(tx pgx.Tx) error {
+		posted = nil
+		rows, err := tx.Query(ctx, `
+            SELECT amount
+            FROM accounts.interest_accruals
+            WHERE account_id = $1 AND transaction_id IS NULL AND accrual_date <= $2
+            FOR UPDATE
+        `, accountID, through)
+		if err != nil {
+			return err
+		}
+		var (
+			unposted int
+			total    = decimal.Zero
+		)
+		for rows.Next() {
+			var amount decimal.Decimal
+			if err := rows.Scan(&amount); err != nil {
+				rows.Close()
+				return err
+			}
+			unposted++
+			total = total.Add(amount)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if unposted == 0 {
+			return nil
+		}
+		carry, err := interestCarry(ctx, tx, accountID)
+		if err != nil {
+			return err
+		}
+
+		transfer := interestTransfer(accountID, expenseAccountID, total.Add(carry))
+		if !transfer.Amount.IsPositive() {
+			return nil
+		}
+		if posted, err = transferInTx(ctx, tx, "post_interest", transfer, true); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+            UPDATE accounts.interest_accruals
+            SET transaction_id = $3
+            WHERE account_id = $1 AND transaction_id IS NULL AND accrual_date <= $2
+        `, accountID, through, posted.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posted, nil
+}
+
+func (r *PGRepository) ListInterestAccruals(ctx context.Context, filter domain.InterestAccrualFilter) ([]domain.InterestAccrual, error) {
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+interestAccrualColumns+`
+        FROM accounts.interest_accruals
+        WHERE ($1::BIGINT = 0 OR account_id = $1)
+          AND accrual_date BETWEEN $2 AND $3
+        ORDER BY account_id, accrual_date
+    `, filter.AccountID, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accruals := []domain.InterestAccrual{}
+	for rows.Next() {
+		var accrual domain.InterestAccrual
+		if err := rows.Scan(&accrual.AccountID, &accrual.Date, &accrual.Balance, &accrual.AnnualRate, &accrual.DayCount, &accrual.Amount, &accrual.TransactionID); err != nil {
+			return nil, err
+		}
+		accruals = append(accruals, accrual)
+	}
+	return accruals, rows.Err()
+}
+
+const (
+	interestRateColumns    = `account_id, annual_rate, updated_at`
+	interestAccrualColumns = `account_id, accrual_date, balance, annual_rate, day_count, amount, transaction_id`
+)
+
+func scanInterestRate(row pgx.Row) (*domain.InterestRate, error) {
+	var rate domain.InterestRate
+	if err := row.Scan(&rate.AccountID, &rate.AnnualRate, &rate.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
@@ -0,0 +1,676 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+)
+
+// MemoryRepository keeps accounts and transactions in process memory. It has
+// the same semantics as PGRepository, including per-account locking taken in
+// ascending ID order, and is meant for local development, demos and tests.
+type MemoryRepository struct {
+	mu           sync.RWMutex
+	accounts     map[int64]*memoryAccount
+	transactions []domain.Transaction
+	reversedBy   map[int64]int64
+	auditLog     []domain.AuditEntry
+	feeRules     []domain.FeeRule
+	nextFeeRule  int64
+
+	interestRates    map[int64]domain.InterestRate
+	interestAccruals map[interestAccrualKey]domain.InterestAccrual
+	balanceSnapshots map[int64][]memorySnapshot
+
+	// reversalMu serializes reversals the way the row lock on the original
+	// transaction does in PostgreSQL, and interestMu serializes interest
+	// postings the way the locks on the accrual rows do.
+	reversalMu sync.Mutex
+	interestMu sync.Mutex
+}
+
+type interestAccrualKey struct {
+	accountID int64
+	date      time.Time
+}
+
+// memorySnapshot is a balance snapshot of an account. Transactions are
+// appended in the order they are created, so next is the position of the
+// first transaction the snapshot leaves out.
+type memorySnapshot struct {
+	takenAt time.Time
+	balance decimal.Decimal
+	next    int
+}
+
+type memoryAccount struct {
+	mu             sync.Mutex
+	account        domain.Account
+	openingBalance decimal.Decimal
+	createdAt      time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		accounts:         map[int64]*memoryAccount{},
+		reversedBy:       map[int64]int64{},
+		interestRates:    map[int64]domain.InterestRate{},
+		interestAccruals: map[interestAccrualKey]domain.InterestAccrual{},
+		balanceSnapshots: map[int64][]memorySnapshot{},
+	}
+}
+
+func (r *MemoryRepository) Ping(context.Context) error {
+	return nil
+}
+
+func (r *MemoryRepository) CheckSchema(context.Context) error {
+	return nil
+}
+
+func (r *MemoryRepository) Close() {}
+
+func (r *MemoryRepository) CreateAccount(ctx context.Context, account domain.Account) (*domain.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[account.ID]; ok {
+		return nil, ErrAccountExists
+	}
+	created := domain.Account{
+		ID:        account.ID,
+		Balance:   account.Balance,
+		Status:    domain.AccountStatusActive,
+		Tier:      accountTier(account),
+		Tags:      slices.Clone(accountTags(account)),
+		Version:   1,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := r.appendAuditEntry(ctx, auditRecord{operation: domain.AuditOperationAccountCreate, accountID: created.ID, after: &created}); err != nil {
+		return nil, err
+	}
+	r.accounts[account.ID] = &memoryAccount{account: created, openingBalance: account.Balance, createdAt: created.CreatedAt}
+	return &created, nil
+}
+
+func (r *MemoryRepository) GetAccount(_ context.Context, id string) (*domain.Account, error) {
+	accountID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	stored, ok := r.lookupAccount(accountID)
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+
+	stored.mu.Lock()
+	defer stored.mu.Unlock()
+	account := stored.account
+	return &account, nil
+}
+
+// ListAccounts reads the accounts one at a time, without holding r.mu, since
+// writers to an account take its lock before r.mu.
+func (r *MemoryRepository) ListAccounts(_ context.Context, filter domain.AccountFilter) ([]domain.Account, error) {
+	r.mu.RLock()
+	all := slices.Collect(maps.Values(r.accounts))
+	r.mu.RUnlock()
+
+	accounts := make([]domain.Account, 0, len(all))
+	for _, stored := range all {
+		stored.mu.Lock()
+		accounts = append(accounts, stored.account)
+		stored.mu.Unlock()
+	}
+	return listAccounts(accounts, filter), nil
+}
+
+func (r *MemoryRepository) GetAccounts(_ context.Context, ids []int64) ([]domain.Account, error) {
+	accounts := make([]domain.Account, 0, len(ids))
+	for _, id := range ids {
+		stored, ok := r.lookupAccount(id)
+		if !ok {
+			continue
+		}
+		stored.mu.Lock()
+		accounts = append(accounts, stored.account)
+		stored.mu.Unlock()
+	}
+	slices.SortFunc(accounts, func(a, b domain.Account) int { return cmp.Compare(a.ID, b.ID) })
+	return accounts, nil
+}
+
+func (r *MemoryRepository) SetAccountStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*domain.Account, error) {
+	stored, ok := r.lookupAccount(id)
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+
+	stored.mu.Lock()
+	defer stored.mu.Unlock()
+	if expectedVersion != 0 && stored.account.Version != expectedVersion {
+		return nil, ErrVersionMismatch
+	}
+	before, after := stored.account, stored.account
+	after.Status = status
+	after.Version++
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.appendAuditEntry(ctx, accountStatusAuditRecord(&before, &after)); err != nil {
+		return nil, err
+	}
+	stored.account = after
+	return &after, nil
+}
+
+// SetAccountShards is not supported. Sharding exists to spread row lock
+// contention in PostgreSQL; here a transfer holds its account locks only for
+// the few instructions that move the money.
+func (r *MemoryRepository) SetAccountShards(ctx context.Context, id int64, shards int, expectedVersion int64) (*domain.Account, error) {
+	return nil, ErrShardingUnsupported
+}
+
+func (r *MemoryRepository) TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	transaction, overdraw := normalizeTransfer(transaction)
+	decision := takeRiskDecision(&transaction)
+	return r.transfer(ctx, transaction, overdraw, decision)
+}
+
+func (r *MemoryRepository) GetTransaction(_ context.Context, id int64) (*domain.Transaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id <= 0 || id > int64(len(r.transactions)) {
+		return nil, ErrTransactionNotFound
+	}
+	transaction := r.transactions[id-1]
+	return &transaction, nil
+}
+
+func (r *MemoryRepository) ListTransactions(_ context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	transactions := []domain.Transaction{}
+	for i := len(r.transactions) - 1; i >= 0 && len(transactions) < filter.Limit; i-- {
+		transaction := r.transactions[i]
+		if filter.BeforeID != 0 && transaction.ID >= filter.BeforeID {
+			continue
+		}
+		if filter.Type != "" && transaction.Type != filter.Type {
+			continue
+		}
+		if transaction.SourceAccountID == accountID || transaction.DestinationAccountID == accountID {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+func (r *MemoryRepository) ReverseTransaction(ctx context.Context, id int64) (*domain.Transaction, error) {
+	r.reversalMu.Lock()
+	defer r.reversalMu.Unlock()
+
+	original, err := r.GetTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if original.ReversalOf != nil {
+		return nil, ErrNotReversible
+	}
+	r.mu.RLock()
+	_, reversed := r.reversedBy[id]
+	r.mu.RUnlock()
+	if reversed {
+		return nil, ErrAlreadyReversed
+	}
+
+	return r.transfer(ctx, domain.Transaction{
+		Type:                 domain.TransactionTypeReversal,
+		SourceAccountID:      original.DestinationAccountID,
+		DestinationAccountID: original.SourceAccountID,
+		Amount:               original.Amount,
+		ReversalOf:           &original.ID,
+	}, original.Type == domain.TransactionTypeWithdrawal, nil)
+}
+
+// LedgerSnapshot locks every account in ascending ID order, the same order
+// transfers use, so that no transfer is half applied while it reads.
+func (r *MemoryRepository) LedgerSnapshot(context.Context) (*domain.LedgerSnapshot, error) {
+	r.mu.RLock()
+	locked := slices.Collect(maps.Values(r.accounts))
+	r.mu.RUnlock()
+	slices.SortFunc(locked, func(a, b *memoryAccount) int {
+		return cmp.Compare(a.account.ID, b.account.ID)
+	})
+	for _, account := range locked {
+		account.mu.Lock()
+		defer account.mu.Unlock()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := &domain.LedgerSnapshot{
+		Accounts:           make([]domain.LedgerAccount, 0, len(locked)),
+		TransactionCount:   int64(len(r.transactions)),
+		OrphanTransactions: []domain.Transaction{},
+	}
+	positions := make(map[int64]int, len(locked))
+	for _, account := range locked {
+		positions[account.account.ID] = len(snapshot.Accounts)
+		snapshot.Accounts = append(snapshot.Accounts, domain.LedgerAccount{
+			ID:             account.account.ID,
+			OpeningBalance: account.openingBalance,
+			Balance:        account.account.Balance,
+		})
+	}
+	for _, transaction := range r.transactions {
+		source, sourceFound := positions[transaction.SourceAccountID]
+		destination, destinationFound := positions[transaction.DestinationAccountID]
+		if !sourceFound || !destinationFound {
+			snapshot.OrphanTransactions = append(snapshot.OrphanTransactions, transaction)
+		}
+		if sourceFound {
+			snapshot.Accounts[source].Debits = snapshot.Accounts[source].Debits.Add(transaction.Amount)
+		}
+		if destinationFound {
+			snapshot.Accounts[destination].Credits = snapshot.Accounts[destination].Credits.Add(transaction.Amount)
+		}
+	}
+	return snapshot, nil
+}
+
+// transfer locks both accounts, and the fee account when the transaction has
+// a fee, in ascending ID order, applies the same checks as transferInTx and
+// records the transaction and its fee while still holding the locks, so that
+// history and balances never disagree.
+func (r *MemoryRepository) transfer(ctx context.Context, transaction domain.Transaction, overdraw bool, decision *domain.RiskDecision) (*domain.Transaction, error) {
+	source, ok := r.lookupAccount(transaction.SourceAccountID)
+	if !ok {
+		return nil, fmt.Errorf("source %w", ErrAccountNotFound)
+	}
+	destination, ok := r.lookupAccount(transaction.DestinationAccountID)
+	if !ok {
+		return nil, fmt.Errorf("destination %w", ErrAccountNotFound)
+	}
+	locked := []*memoryAccount{source, destination}
+	debit := transaction.Amount
+
+	var feeAccount *memoryAccount
+	if transaction.Fee != nil {
+		if feeAccount, ok = r.lookupAccount(transaction.Fee.AccountID); !ok {
+			return nil, fmt.Errorf("fee %w", ErrAccountNotFound)
+		}
+		locked = append(locked, feeAccount)
+		debit = debit.Add(transaction.Fee.Amount)
+	}
+
+	slices.SortFunc(locked, func(a, b *memoryAccount) int {
+		return cmp.Compare(a.account.ID, b.account.ID)
+	})
+	locked = slices.Compact(locked)
+	for _, account := range locked {
+		account.mu.Lock()
+		defer account.mu.Unlock()
+	}
+
+	for _, account := range locked {
+		if account.account.Status == domain.AccountStatusFrozen {
+			return nil, ErrAccountFrozen
+		}
+	}
+	if !overdraw && source.account.Balance.LessThan(debit) {
+		return nil, ErrInsufficientBalance
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	created, err := r.record(ctx, transaction, source, destination)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Fee != nil {
+		fee, err := r.record(ctx, feeTransfer(transaction), source, feeAccount)
+		if err != nil {
+			return nil, err
+		}
+		created = withFeeTransaction(created, fee)
+	}
+	if decision != nil {
+		if err := r.appendAuditEntry(ctx, riskDecisionAuditRecord(*decision, &created.ID)); err != nil {
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+// record applies transaction to the locked accounts and appends it to the
+// history. It must be called with mu held for writing.
+func (r *MemoryRepository) record(ctx context.Context, transaction domain.Transaction, source, destination *memoryAccount) (*domain.Transaction, error) {
+	created := transaction
+	created.ID = int64(len(r.transactions)) + 1
+	created.CreatedAt = time.Now().UTC()
+
+	before := transferState{Source: source.account, Destination: destination.account}
+	after := transferState{Source: source.account, Destination: destination.account, Transaction: &created}
+	after.Source.Balance = after.Source.Balance.Sub(transaction.Amount)
+	after.Destination.Balance = after.Destination.Balance.Add(transaction.Amount)
+	after.Source.Version++
+	after.Destination.Version++
+	if err := r.appendAuditEntry(ctx, transferAuditRecord(before, after)); err != nil {
+		return nil, err
+	}
+
+	source.account = after.Source
+	destination.account = after.Destination
+	stored := created
+	stored.Fee = nil
+	r.transactions = append(r.transactions, stored)
+	if created.ReversalOf != nil {
+		r.reversedBy[*created.ReversalOf] = created.ID
+	}
+	return &created, nil
+}
+
+func (r *MemoryRepository) ListAuditEntries(_ context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := []domain.AuditEntry{}
+	for i := len(r.auditLog) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		if matchesAuditFilter(&r.auditLog[i], filter) {
+			entries = append(entries, r.auditLog[i])
+		}
+	}
+	return entries, nil
+}
+
+func (r *MemoryRepository) RecordRiskDecision(ctx context.Context, decision domain.RiskDecision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.appendAuditEntry(ctx, riskDecisionAuditRecord(decision, nil))
+}
+
+// appendAuditEntry must be called with mu held for writing.
+func (r *MemoryRepository) appendAuditEntry(ctx context.Context, record auditRecord) error {
+	entry, err := newAuditEntry(ctx, record)
+	if err != nil {
+		return err
+	}
+	entry.ID = int64(len(r.auditLog)) + 1
+	entry.OccurredAt = time.Now().UTC()
+	r.auditLog = append(r.auditLog, entry)
+	return nil
+}
+
+func (r *MemoryRepository) lookupAccount(id int64) (*memoryAccount, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	account, ok := r.accounts[id]
+	return account, ok
+}
+
+func (r *MemoryRepository) ListFeeRules(context.Context) ([]domain.FeeRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]domain.FeeRule{}, r.feeRules...), nil
+}
+
+func (r *MemoryRepository) CreateFeeRule(_ context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextFeeRule++
+	rule.ID = r.nextFeeRule
+	rule.CreatedAt = time.Now().UTC()
+	rule.UpdatedAt = rule.CreatedAt
+	r.feeRules = append(r.feeRules, rule)
+	return &rule, nil
+}
+
+func (r *MemoryRepository) UpdateFeeRule(_ context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.feeRules {
+		if r.feeRules[i].ID == rule.ID {
+			rule.CreatedAt = r.feeRules[i].CreatedAt
+			rule.UpdatedAt = time.Now().UTC()
+			r.feeRules[i] = rule
+			return &rule, nil
+		}
+	}
+	return nil, ErrFeeRuleNotFound
+}
+
+func (r *MemoryRepository) DeleteFeeRule(_ context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.feeRules {
+		if r.feeRules[i].ID == id {
+			r.feeRules = slices.Delete(r.feeRules, i, i+1)
+			return nil
+		}
+	}
+	return ErrFeeRuleNotFound
+}
+
+func (r *MemoryRepository) SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (*domain.InterestRate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[accountID]; !ok {
+		return nil, ErrAccountNotFound
+	}
+	var before *domain.InterestRate
+	if previous, ok := r.interestRates[accountID]; ok {
+		before = &previous
+	}
+	after := domain.InterestRate{AccountID: accountID, AnnualRate: annualRate, UpdatedAt: time.Now().UTC()}
+	if err := r.appendAuditEntry(ctx, interestRateAuditRecord(before, &after)); err != nil {
+		return nil, err
+	}
+	r.interestRates[accountID] = after
+	return &after, nil
+}
+
+func (r *MemoryRepository) ListInterestRates(context.Context) ([]domain.InterestRate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rates := slices.Collect(maps.Values(r.interestRates))
+	slices.SortFunc(rates, func(a, b domain.InterestRate) int {
+		return cmp.Compare(a.AccountID, b.AccountID)
+	})
+	if rates == nil {
+		rates = []domain.InterestRate{}
+	}
+	return rates, nil
+}
+
+func (r *MemoryRepository) BalanceAt(ctx context.Context, accountID int64, at time.Time) (decimal.Decimal, error) {
+	balances, err := r.BalancesAt(ctx, []int64{accountID}, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return accountBalance(balances, accountID)
+}
+
+func (r *MemoryRepository) RecordInterestAccruals(_ context.Context, accruals []domain.InterestAccrual) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recorded := 0
+	for _, accrual := range accruals {
+		key := interestAccrualKey{accountID: accrual.AccountID, date: accrual.Date.UTC()}
+		if _, ok := r.interestAccruals[key]; ok {
+			continue
+		}
+		accrual.Date = key.date
+		accrual.TransactionID = nil
+		r.interestAccruals[key] = accrual
+		recorded++
+	}
+	return recorded, nil
+}
+
+func (r *MemoryRepository) PostInterest(ctx context.Context, accountID, expenseAccountID int64, through time.Time) (*domain.Transaction, error) {
+	r.interestMu.Lock()
+	defer r.interestMu.Unlock()
+
+	r.mu.RLock()
+	var (
+		unposted []interestAccrualKey
+		total    decimal.Decimal
+		postings = map[int64]bool{}
+	)
+	for key, accrual := range r.interestAccruals {
+		if key.accountID != accountID {
+			continue
+		}
+		switch {
+		case accrual.TransactionID != nil:
+			// What earlier postings paid short of their accruals is carried.
+			postings[*accrual.TransactionID] = true
+			total = total.Add(accrual.Amount)
+		case !key.date.After(through):
+			unposted = append(unposted, key)
+			total = total.Add(accrual.Amount)
+		}
+	}
+	for id := range postings {
+		total = total.Sub(r.transactions[id-1].Amount)
+	}
+	r.mu.RUnlock()
+	if len(unposted) == 0 {
+		return nil, nil
+	}
+
+	transfer := interestTransfer(accountID, expenseAccountID, total)
+	if !transfer.Amount.IsPositive() {
+		return nil, nil
+	}
+	posted, err := r.transfer(ctx, transfer, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range unposted {
+		accrual := r.interestAccruals[key]
+		accrual.TransactionID = &posted.ID
+		r.interestAccruals[key] = accrual
+	}
+	return posted, nil
+}
+
+func (r *MemoryRepository) ListInterestAccruals(_ context.Context, filter domain.InterestAccrualFilter) ([]domain.InterestAccrual, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	accruals := []domain.InterestAccrual{}
+	for key, accrual := range r.interestAccruals {
+		if (filter.AccountID == 0 || key.accountID == filter.AccountID) && !key.date.Before(filter.From) && !key.date.After(filter.To) {
+			accruals = append(accruals, accrual)
+		}
+	}
+	slices.SortFunc(accruals, func(a, b domain.InterestAccrual) int {
+		return cmp.Or(cmp.Compare(a.AccountID, b.AccountID), a.Date.Compare(b.Date))
+	})
+	return accruals, nil
+}
+
+func (r *MemoryRepository) BalancesAt(_ context.Context, accountIDs []int64, at time.Time) (map[int64]decimal.Decimal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	balances := make(map[int64]decimal.Decimal, len(accountIDs))
+	for _, accountID := range accountIDs {
+		account, ok := r.accounts[accountID]
+		if !ok {
+			continue
+		}
+		if account.createdAt.After(at) {
+			balances[accountID] = decimal.Zero
+			continue
+		}
+		balances[accountID], _, _ = r.balanceAt(accountID, account, at)
+	}
+	return balances, nil
+}
+
+// SnapshotCutoff returns at, since transfers commit under the same lock that
+// creates them.
+func (r *MemoryRepository) SnapshotCutoff(_ context.Context, at time.Time) (time.Time, error) {
+	return at, nil
+}
+
+func (r *MemoryRepository) TakeBalanceSnapshots(_ context.Context, at time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taken := 0
+	for accountID, account := range r.accounts {
+		if account.createdAt.After(at) {
+			continue
+		}
+		balance, next, changed := r.balanceAt(accountID, account, at)
+		if !changed {
+			continue
+		}
+		snapshots := r.balanceSnapshots[accountID]
+		position, _ := slices.BinarySearchFunc(snapshots, at, compareSnapshotTime)
+		r.balanceSnapshots[accountID] = slices.Insert(snapshots, position, memorySnapshot{takenAt: at, balance: balance, next: next})
+		taken++
+	}
+	return taken, nil
+}
+
+// balanceAt starts from the latest snapshot of the account taken at or before
+// at and replays the transactions created after it up to at. It also returns
+// the position of the first transaction left out and whether any replayed
+// transaction moved the account. Callers hold r.mu.
+func (r *MemoryRepository) balanceAt(accountID int64, account *memoryAccount, at time.Time) (decimal.Decimal, int, bool) {
+	balance, next := account.openingBalance, 0
+	snapshots := r.balanceSnapshots[accountID]
+	position, found := slices.BinarySearchFunc(snapshots, at, compareSnapshotTime)
+	if found {
+		position++
+	}
+	if position > 0 {
+		balance, next = snapshots[position-1].balance, snapshots[position-1].next
+	}
+
+	changed := false
+	for ; next < len(r.transactions); next++ {
+		transaction := r.transactions[next]
+		if transaction.CreatedAt.After(at) {
+			break
+		}
+		if transaction.DestinationAccountID == accountID {
+			balance = balance.Add(transaction.Amount)
+			changed = true
+		}
+		if transaction.SourceAccountID == accountID {
+			balance = balance.Sub(transaction.Amount)
+			changed = true
+		}
+	}
+	return balance, next, changed
+}
+
+func compareSnapshotTime(snapshot memorySnapshot, at time.Time) int {
+	return snapshot.takenAt.Compare(at)
+}
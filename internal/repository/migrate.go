@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratemysql "github.com/golang-migrate/migrate/v4/database/mysql"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/migrations"
+	"go.uber.org/zap"
+)
+
+type MigrationStatus struct {
+	Version  uint
+	Dirty    bool
+	Expected uint
+	Pending  []uint
+}
+
+var (
+	expectedVersionOnce sync.Once
+	expectedVersion     uint
+	expectedVersionErr  error
+)
+
+var ErrNoMigrations = errors.New("the memory backend has no schema to migrate")
+
+// migrationSource returns the embedded migrations for the dialect selected by
+// the scheme of databaseURL.
+func migrationSource(databaseURL string) (fs.FS, string, error) {
+	switch {
+	case strings.HasPrefix(databaseURL, "memory:"):
+		return nil, "", ErrNoMigrations
+	case strings.HasPrefix(databaseURL, "sqlite:"):
+		return migrations.SQLite, "sqlite", nil
+	case strings.HasPrefix(databaseURL, "mysql:"):
+		return migrations.MySQL, "mysql", nil
+	}
+	return migrations.FS, ".", nil
+}
+
+func newMigrator(databaseURL string) (*migrate.Migrate, error) {
+	migrationsFS, path, err := migrationSource(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	sourceDriver, err := iofs.New(migrationsFS, path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(databaseURL, "mysql:") {
+		return newMySQLMigrator(sourceDriver, databaseURL)
+	}
+	return migrate.NewWithSourceInstance("iofs", sourceDriver, databaseURL)
+}
+
+// newMySQLMigrator opens the database itself because golang-migrate expects a
+// driver DSN rather than the URL form accepted in DATABASE_URL.
+func newMySQLMigrator(sourceDriver source.Driver, databaseURL string) (*migrate.Migrate, error) {
+	mysqlConfig, err := MySQLConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	mysqlConfig.MultiStatements = true
+	db, err := sql.Open("mysql", mysqlConfig.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	databaseDriver, err := migratemysql.WithInstance(db, &migratemysql.Config{DatabaseName: mysqlConfig.DBName})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return migrate.NewWithInstance("iofs", sourceDriver, "mysql", databaseDriver)
+}
+
+func closeMigrator(migration *migrate.Migrate) {
+	if sourceErr, databaseErr := migration.Close(); sourceErr != nil || databaseErr != nil {
+		logger.L().Warn("closing migrator failed", zap.NamedError("source_error", sourceErr), zap.NamedError("database_error", databaseErr))
+	}
+}
+
+func MigrateUp(databaseURL string) error {
+	migration, err := newMigrator(databaseURL)
+	if err != nil {
+		logger.L().Error("migration setup failed", zap.Error(err))
+		return err
+	}
+	defer closeMigrator(migration)
+
+	if err := migration.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		logger.L().Error("migration up failed", zap.Error(err))
+		return err
+	}
+	logger.L().Info("database migrations applied successfully")
+	return nil
+}
+
+func MigrateDown(databaseURL string, steps int) error {
+	if steps <= 0 {
+		return errors.New("number of migrations to roll back must be positive")
+	}
+	migration, err := newMigrator(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(migration)
+
+	if err := migration.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// ForceMigrationVersion records version as the current schema version and
+// clears the dirty flag without running any migration. It is meant for
+// recovering from a failed migration after fixing the schema by hand.
+func ForceMigrationVersion(databaseURL string, version int) error {
+	migration, err := newMigrator(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(migration)
+
+	return migration.Force(version)
+}
+
+func GetMigrationStatus(databaseURL string) (*MigrationStatus, error) {
+	migration, err := newMigrator(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMigrator(migration)
+
+	status := &MigrationStatus{}
+	version, dirty, err := migration.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, err
+	}
+	status.Version, status.Dirty = version, dirty
+
+	versions, err := migrationVersions(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v > status.Version {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+	if len(versions) > 0 {
+		status.Expected = versions[len(versions)-1]
+	}
+	return status, nil
+}
+
+// ExpectedSchemaVersion returns the version of the newest migration embedded
+// in the binary. Every dialect ships the same versions, which
+// TestMigrationVersions_MatchAcrossDialects enforces.
+func ExpectedSchemaVersion() (uint, error) {
+	expectedVersionOnce.Do(func() {
+		versions, err := migrationVersions("postgres:")
+		if err != nil {
+			expectedVersionErr = err
+			return
+		}
+		if len(versions) == 0 {
+			expectedVersionErr = errors.New("no migrations embedded")
+			return
+		}
+		expectedVersion = versions[len(versions)-1]
+	})
+	return expectedVersion, expectedVersionErr
+}
+
+func migrationVersions(databaseURL string) ([]uint, error) {
+	migrationsFS, path, err := migrationSource(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	sourceDriver, err := iofs.New(migrationsFS, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = sourceDriver.Close() }()
+
+	return collectVersions(sourceDriver)
+}
+
+func collectVersions(sourceDriver source.Driver) ([]uint, error) {
+	version, err := sourceDriver.First()
+	if err != nil {
+		return nil, err
+	}
+	versions := []uint{version}
+	for {
+		next, err := sourceDriver.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, next)
+		version = next
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpectedSchemaVersion_ReadsEmbeddedMigrations(t *testing.T) {
+	versions, err := migrationVersions("postgres:")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(versions) == 0 {
+		t.Fatalf("expected embedded migrations")
+	}
+	for i := 1; i < len(versions); i++ {
+		if versions[i] <= versions[i-1] {
+			t.Fatalf("expected ascending versions, got %v", versions)
+		}
+	}
+
+	expected, err := ExpectedSchemaVersion()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected != versions[len(versions)-1] {
+		t.Fatalf("expected version %d, got %d", versions[len(versions)-1], expected)
+	}
+}
+
+func TestMigrationVersions_MatchAcrossDialects(t *testing.T) {
+	expected, err := migrationVersions("postgres:")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, databaseURL := range []string{"sqlite:", "mysql:"} {
+		versions, err := migrationVersions(databaseURL)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", databaseURL, err)
+		}
+		if !slices.Equal(versions, expected) {
+			t.Fatalf("%s: expected versions %v, got %v", databaseURL, expected, versions)
+		}
+	}
+}
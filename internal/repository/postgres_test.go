@@ -0,0 +1,684 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"github.com/tareqpi/transfer-system/internal/reconciliation"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/repository/repositorytest"
+	"github.com/tareqpi/transfer-system/internal/service"
+)
+
+// TestPGRepository_Conformance runs against the server named by
+// TEST_DATABASE_URL, in a fresh database for every case. It is skipped when
+// the variable is not set.
+func TestPGRepository_Conformance(t *testing.T) {
+	runPGConformance(t, func(*config.DatabaseConfig) {})
+}
+
+// TestPGRepository_SerializableConformance runs the suite with transfers at
+// SERIALIZABLE isolation, where the concurrent cases only pass if the
+// serialization failures they provoke are retried.
+func TestPGRepository_SerializableConformance(t *testing.T) {
+	runPGConformance(t, func(databaseConfig *config.DatabaseConfig) {
+		databaseConfig.SerializableTransfers = true
+		databaseConfig.RetryMaxAttempts = 50
+	})
+}
+
+func runPGConformance(t *testing.T, configure func(*config.DatabaseConfig)) {
+	server := newPostgresServer(t)
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return server.open(t, configure)
+	})
+}
+
+// postgresServer creates the databases of the tests on the server named by
+// TEST_DATABASE_URL, whose role needs the CREATEDB privilege. The database in
+// the URL itself is only connected to. Migrations and queries name the
+// accounts schema, so a test is isolated in a database of its own rather than
+// a schema; every one is a copy of template, migrated once per test.
+type postgresServer struct {
+	url      *url.URL
+	template string
+}
+
+func newPostgresServer(t *testing.T) *postgresServer {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		t.Fatalf("parse TEST_DATABASE_URL: %v", err)
+	}
+
+	server := &postgresServer{url: parsed}
+	templateURL := server.createDatabase(t, "")
+	if err := repository.MigrateUp(templateURL); err != nil {
+		t.Fatalf("migrate template database: %v", err)
+	}
+	server.template = databaseName(templateURL)
+	return server
+}
+
+// createDatabase creates a database, a copy of template unless it is empty,
+// that is dropped when the test ends, and returns its URL.
+func (s *postgresServer) createDatabase(t *testing.T, template string) string {
+	t.Helper()
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := fmt.Sprintf("transfer_test_%d_%s", os.Getpid(), hex.EncodeToString(suffix))
+	statement := "CREATE DATABASE " + pgx.Identifier{name}.Sanitize()
+	if template != "" {
+		statement += " TEMPLATE " + pgx.Identifier{template}.Sanitize()
+	}
+	s.exec(t, statement)
+	t.Cleanup(func() {
+		s.exec(t, "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+" WITH (FORCE)")
+	})
+
+	databaseURL := *s.url
+	databaseURL.Path = "/" + name
+	return databaseURL.String()
+}
+
+func (s *postgresServer) exec(t *testing.T, statement string) {
+	t.Helper()
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, s.url.String())
+	if err != nil {
+		t.Fatalf("connect to TEST_DATABASE_URL: %v", err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, statement); err != nil {
+		t.Fatalf("%s: %v", statement, err)
+	}
+}
+
+// open returns a repository over a fresh copy of the migrated template.
+func (s *postgresServer) open(t *testing.T, configure func(*config.DatabaseConfig)) *repository.PGRepository {
+	t.Helper()
+	return openPostgres(t, s.createDatabase(t, s.template), configure)
+}
+
+func openPostgres(t *testing.T, databaseURL string, configure func(*config.DatabaseConfig)) *repository.PGRepository {
+	t.Helper()
+
+	databaseConfig := config.Default().Database
+	databaseConfig.URL = databaseURL
+	databaseConfig.AutoMigrate = false
+	configure(&databaseConfig)
+	storage, err := repository.Open(context.Background(), databaseConfig)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(storage.Close)
+	return storage.(*repository.PGRepository)
+}
+
+func databaseName(databaseURL string) string {
+	parsed, _ := url.Parse(databaseURL)
+	return strings.TrimPrefix(parsed.Path, "/")
+}
+
+func TestPGRepository_ShardedAccount(t *testing.T) {
+	postgresRepository := newPostgresServer(t).open(t, func(*config.DatabaseConfig) {})
+	ctx := context.Background()
+
+	for _, account := range []domain.Account{
+		{ID: 1, Balance: decimal.RequireFromString("1000")},
+		{ID: 2, Balance: decimal.RequireFromString("100")},
+		{ID: 3, Balance: decimal.RequireFromString("100")},
+	} {
+		if _, err := postgresRepository.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account: %v", err)
+		}
+	}
+
+	sharded, err := postgresRepository.SetAccountShards(ctx, 1, 3, 0)
+	if err != nil {
+		t.Fatalf("set account shards: %v", err)
+	}
+	if sharded.Shards != 3 || !sharded.Balance.Equal(decimal.RequireFromString("1000")) || sharded.Version != 2 {
+		t.Fatalf("expected 3 shards holding 1000 at version 2, got %+v", sharded)
+	}
+	assertBalance := func(id string, want string) {
+		t.Helper()
+		account, err := postgresRepository.GetAccount(ctx, id)
+		if err != nil {
+			t.Fatalf("get account %s: %v", id, err)
+		}
+		if !account.Balance.Equal(decimal.RequireFromString(want)) {
+			t.Fatalf("expected account %s to hold %s, got %s", id, want, account.Balance)
+		}
+	}
+	assertBalance("1", "1000")
+
+	// Each shard holds about 333.33, so this debit has to take from several.
+	if _, err := postgresRepository.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("500")}); err != nil {
+		t.Fatalf("transfer spanning shards: %v", err)
+	}
+	if _, err := postgresRepository.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("500.0001")}); !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+	}
+	assertBalance("1", "500")
+	assertBalance("2", "600")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transaction := domain.Transaction{SourceAccountID: 3, DestinationAccountID: 1, Amount: decimal.RequireFromString("1")}
+			if i%2 == 0 {
+				transaction.SourceAccountID, transaction.DestinationAccountID = 1, 3
+			}
+			if _, err := postgresRepository.TransferMoney(ctx, transaction); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent transfer: %v", err)
+	}
+	assertBalance("1", "500")
+	assertBalance("3", "100")
+
+	if consolidated, err := postgresRepository.ConsolidateShards(ctx); err != nil || consolidated != 1 {
+		t.Fatalf("expected 1 account consolidated, got %d and %v", consolidated, err)
+	}
+	assertBalance("1", "500")
+	// Transfers and consolidation leave the account row, and so its version,
+	// alone.
+	if account, err := postgresRepository.GetAccount(ctx, "1"); err != nil || account.Version != 2 {
+		t.Fatalf("expected account 1 still at version 2, got %+v and %v", account, err)
+	}
+
+	snapshot, err := postgresRepository.LedgerSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("ledger snapshot: %v", err)
+	}
+	if report := reconciliation.Reconcile(snapshot); report.Status != reconciliation.StatusOK {
+		t.Fatalf("expected the ledger to reconcile, got %+v", report.Discrepancies)
+	}
+
+	if _, err := postgresRepository.SetAccountShards(ctx, 1, 0, 1); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	unsharded, err := postgresRepository.SetAccountShards(ctx, 1, 0, 2)
+	if err != nil {
+		t.Fatalf("set account shards: %v", err)
+	}
+	if unsharded.Shards != 0 || !unsharded.Balance.Equal(decimal.RequireFromString("500")) || unsharded.Version != 3 {
+		t.Fatalf("expected 500 back in the account row at version 3, got %+v", unsharded)
+	}
+	assertBalance("1", "500")
+}
+
+// TestPGRepository_OpposingTransfersDoNotDeadlock runs with retries disabled,
+// so a deadlock between transfers that take the locks of the same accounts in
+// opposite order fails a transfer instead of being retried away.
+func TestPGRepository_OpposingTransfersDoNotDeadlock(t *testing.T) {
+	// Account 4 is the revenue account when fees are on, so fee legs credit
+	// an account that is also the source and destination of transfers.
+	// Account 1 is split over two shards when sharded, which its concurrent
+	// debits soon all hold, so the others fall back to waiting for every
+	// shard.
+	testCases := []struct {
+		testName string
+		fees     bool
+		shards   int
+	}{
+		{testName: "plain"},
+		{testName: "fees", fees: true},
+		{testName: "sharded_source", shards: 2},
+		{testName: "sharded_source_with_fees", fees: true, shards: 2},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			postgresRepository := newPostgresServer(t).open(t, func(databaseConfig *config.DatabaseConfig) {
+				databaseConfig.RetryMaxAttempts = 1
+				databaseConfig.MaxConns = 20
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			const accounts, rounds, revenue = 4, 25, 4
+			for id := int64(1); id <= accounts; id++ {
+				if _, err := postgresRepository.CreateAccount(ctx, domain.Account{ID: id, Balance: decimal.RequireFromString("1000")}); err != nil {
+					t.Fatalf("create account: %v", err)
+				}
+			}
+			if testCase.shards > 0 {
+				if _, err := postgresRepository.SetAccountShards(ctx, 1, testCase.shards, 0); err != nil {
+					t.Fatalf("shard account: %v", err)
+				}
+			}
+			var options []service.Option
+			fee := decimal.Zero
+			if testCase.fees {
+				fee = decimal.RequireFromString("0.1")
+				if _, err := postgresRepository.CreateFeeRule(ctx, domain.FeeRule{Name: "flat", Kind: domain.FeeKindFlat, Amount: &fee}); err != nil {
+					t.Fatalf("create fee rule: %v", err)
+				}
+				options = append(options, service.WithFeeAccount(revenue))
+			}
+			transfers := service.NewService(postgresRepository, options...)
+			fallbacks := testutil.ToFloat64(metrics.DBShardDebitFallbacksTotal)
+
+			// Every account sends one unit to and receives one from every
+			// other in each round, so the balances end where they started
+			// but for the fees, which the revenue account does not pay.
+			var wg sync.WaitGroup
+			errs := make(chan error, accounts*(accounts-1)*rounds)
+			for range rounds {
+				for from := int64(1); from <= accounts; from++ {
+					for to := int64(1); to <= accounts; to++ {
+						if from == to {
+							continue
+						}
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							transaction := domain.Transaction{SourceAccountID: from, DestinationAccountID: to, Amount: decimal.RequireFromString("1")}
+							if _, err := transfers.TransferMoney(ctx, transaction); err != nil {
+								errs <- err
+							}
+						}()
+					}
+				}
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatalf("expected every transfer to succeed, got %v", err)
+			}
+
+			paid := fee.Mul(decimal.NewFromInt((accounts - 1) * rounds))
+			for id := int64(1); id <= accounts; id++ {
+				expected := decimal.RequireFromString("1000").Sub(paid)
+				if testCase.fees && id == revenue {
+					expected = decimal.RequireFromString("1000").Add(paid.Mul(decimal.NewFromInt(accounts - 1)))
+				}
+				account, err := postgresRepository.GetAccount(ctx, fmt.Sprint(id))
+				if err != nil {
+					t.Fatalf("get account %d: %v", id, err)
+				}
+				if !account.Balance.Equal(expected) {
+					t.Fatalf("expected account %d to hold %s, got %s", id, expected, account.Balance)
+				}
+			}
+			snapshot, err := postgresRepository.LedgerSnapshot(ctx)
+			if err != nil {
+				t.Fatalf("ledger snapshot: %v", err)
+			}
+			if report := reconciliation.Reconcile(snapshot); report.Status != reconciliation.StatusOK {
+				t.Fatalf("expected the ledger to reconcile, got %+v", report.Discrepancies)
+			}
+			if testCase.shards > 0 && testutil.ToFloat64(metrics.DBShardDebitFallbacksTotal) == fallbacks {
+				t.Fatalf("expected some debits of account 1 to fall back to waiting for every shard")
+			}
+		})
+	}
+}
+
+func TestPGRepository_ShardFallbackDoesNotDeadlock(t *testing.T) {
+	postgresRepository := newPostgresServer(t).open(t, func(databaseConfig *config.DatabaseConfig) {
+		databaseConfig.RetryMaxAttempts = 1
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := postgresRepository.CreateAccount(ctx, domain.Account{ID: 1, Balance: decimal.Zero}); err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+	fallbacks := testutil.ToFloat64(metrics.DBShardDebitFallbacksTotal)
+
+	// Every debit needs two of the four shards of 100, so debits that each
+	// lock one shard first have to fall back to waiting for all of them. Two
+	// debits fit and the rest must be refused, never deadlock.
+	const rounds, workers = 10, 8
+	for round := range int64(rounds) {
+		source := 100 + round
+		if _, err := postgresRepository.CreateAccount(ctx, domain.Account{ID: source, Balance: decimal.RequireFromString("400")}); err != nil {
+			t.Fatalf("create account: %v", err)
+		}
+		if _, err := postgresRepository.SetAccountShards(ctx, source, 4, 0); err != nil {
+			t.Fatalf("shard account: %v", err)
+		}
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				transaction := domain.Transaction{SourceAccountID: source, DestinationAccountID: 1, Amount: decimal.RequireFromString("150")}
+				_, err := postgresRepository.TransferMoney(ctx, transaction)
+				if err != nil && !errors.Is(err, repository.ErrInsufficientBalance) {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != 2 {
+			t.Fatalf("round %d: expected exactly 2 debits to succeed, got %d", round, succeeded)
+		}
+		account, err := postgresRepository.GetAccount(ctx, fmt.Sprint(source))
+		if err != nil {
+			t.Fatalf("get account: %v", err)
+		}
+		if !account.Balance.Equal(decimal.RequireFromString("100")) {
+			t.Fatalf("round %d: expected 100 left, got %s", round, account.Balance)
+		}
+	}
+	if testutil.ToFloat64(metrics.DBShardDebitFallbacksTotal) == fallbacks {
+		t.Fatalf("expected some debits to fall back to waiting for every shard")
+	}
+}
+
+func TestPGRepository_SnapshotsLeaveOpenTransactionsOut(t *testing.T) {
+	server := newPostgresServer(t)
+	databaseURL := server.createDatabase(t, server.template)
+	postgresRepository := openPostgres(t, databaseURL, func(*config.DatabaseConfig) {})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, account := range []domain.Account{{ID: 1, Balance: decimal.RequireFromString("100")}, {ID: 2, Balance: decimal.Zero}} {
+		if _, err := postgresRepository.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account: %v", err)
+		}
+	}
+	if _, err := postgresRepository.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("10")}); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+
+	// A transfer whose transaction is still open was created when it began,
+	// before the snapshot, but commits after it.
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(ctx)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO accounts.transactions (type, source_account_id, destination_account_id, amount) VALUES ('transfer', 1, 2, 30)`); err != nil {
+		t.Fatalf("insert transaction: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	at := time.Now()
+	cutoff, err := postgresRepository.SnapshotCutoff(ctx, at)
+	if err != nil {
+		t.Fatalf("snapshot cutoff: %v", err)
+	}
+	if !cutoff.Before(at) {
+		t.Fatalf("expected the cutoff to move before the open transaction, got %s for %s", cutoff, at)
+	}
+	if _, err := postgresRepository.TakeBalanceSnapshots(ctx, cutoff); err != nil {
+		t.Fatalf("take snapshots: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	balances, err := postgresRepository.BalancesAt(ctx, []int64{1, 2}, time.Now())
+	if err != nil {
+		t.Fatalf("balances: %v", err)
+	}
+	if !balances[1].Equal(decimal.RequireFromString("60")) || !balances[2].Equal(decimal.RequireFromString("40")) {
+		t.Fatalf("expected balances 60 and 40, got %v", balances)
+	}
+	if cutoff, err = postgresRepository.SnapshotCutoff(ctx, at); err != nil || !cutoff.Equal(at) {
+		t.Fatalf("expected the cutoff to stay at %s once nothing is open, got %s, %v", at, cutoff, err)
+	}
+}
+
+func TestPGRepository_InsufficientBalanceUnderRace(t *testing.T) {
+	server := newPostgresServer(t)
+	testCases := []struct {
+		name      string
+		configure func(*config.DatabaseConfig)
+	}{
+		{"ReadCommitted", func(*config.DatabaseConfig) {}},
+		{"Serializable", func(databaseConfig *config.DatabaseConfig) {
+			databaseConfig.SerializableTransfers = true
+			databaseConfig.RetryMaxAttempts = 50
+		}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			postgresRepository := server.open(t, testCase.configure)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			for _, account := range []domain.Account{
+				{ID: 1, Balance: decimal.RequireFromString("100")},
+				{ID: 2, Balance: decimal.Zero},
+				{ID: 3, Balance: decimal.Zero},
+			} {
+				if _, err := postgresRepository.CreateAccount(ctx, account); err != nil {
+					t.Fatalf("create account: %v", err)
+				}
+			}
+
+			// 100 covers 14 transfers of 7, so the other 36 must be refused
+			// however they interleave.
+			const workers = 50
+			var (
+				wg        sync.WaitGroup
+				mu        sync.Mutex
+				succeeded int
+			)
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					transaction := domain.Transaction{SourceAccountID: 1, DestinationAccountID: int64(2 + i%2), Amount: decimal.RequireFromString("7")}
+					_, err := postgresRepository.TransferMoney(ctx, transaction)
+					if err != nil && !errors.Is(err, repository.ErrInsufficientBalance) {
+						t.Errorf("unexpected error: %v", err)
+						return
+					}
+					if err == nil {
+						mu.Lock()
+						succeeded++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if succeeded != 14 {
+				t.Fatalf("expected exactly 14 transfers to succeed, got %d", succeeded)
+			}
+			total := decimal.Zero
+			for id, want := range map[string]string{"1": "2", "2": "", "3": ""} {
+				account, err := postgresRepository.GetAccount(ctx, id)
+				if err != nil {
+					t.Fatalf("get account %s: %v", id, err)
+				}
+				if want != "" && !account.Balance.Equal(decimal.RequireFromString(want)) {
+					t.Fatalf("expected account %s to hold %s, got %s", id, want, account.Balance)
+				}
+				total = total.Add(account.Balance)
+			}
+			if !total.Equal(decimal.RequireFromString("100")) {
+				t.Fatalf("expected the accounts to hold 100 in total, got %s", total)
+			}
+		})
+	}
+}
+
+// TestPGMigrations_RoundTrip rolls back the newest 1, 2, ... migrations of a
+// fresh database and applies them again, checking that every down migration
+// undoes its up migration: the schema is restored each time and nothing of
+// it is left once every migration has been rolled back.
+func TestPGMigrations_RoundTrip(t *testing.T) {
+	server := newPostgresServer(t)
+	databaseURL := server.createDatabase(t, "")
+	expected, err := repository.ExpectedSchemaVersion()
+	if err != nil {
+		t.Fatalf("expected schema version: %v", err)
+	}
+
+	if err := repository.MigrateUp(databaseURL); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	expectMigrationVersion(t, databaseURL, expected)
+	want := postgresSchema(t, databaseURL)
+	if len(want) == 0 {
+		t.Fatalf("expected the migrations to create the accounts schema")
+	}
+
+	for steps := 1; steps <= int(expected); steps++ {
+		if err := repository.MigrateDown(databaseURL, steps); err != nil {
+			t.Fatalf("migrate down %d: %v", steps, err)
+		}
+		expectMigrationVersion(t, databaseURL, expected-uint(steps))
+		if steps == int(expected) {
+			if leftover := postgresSchema(t, databaseURL); len(leftover) > 0 {
+				t.Fatalf("expected nothing left after rolling back every migration, got %v", leftover)
+			}
+		}
+
+		if err := repository.MigrateUp(databaseURL); err != nil {
+			t.Fatalf("migrate up after rolling back %d: %v", steps, err)
+		}
+		expectMigrationVersion(t, databaseURL, expected)
+		if missing, extra := diffLines(want, postgresSchema(t, databaseURL)); len(missing) > 0 || len(extra) > 0 {
+			t.Fatalf("expected rolling back %d migrations and applying them again to restore the schema, missing %v, extra %v", steps, missing, extra)
+		}
+	}
+
+	// The schema left by the round trips serves transfers.
+	postgresRepository := openPostgres(t, databaseURL, func(*config.DatabaseConfig) {})
+	ctx := context.Background()
+	for _, account := range []domain.Account{
+		{ID: 1, Balance: decimal.RequireFromString("100")},
+		{ID: 2, Balance: decimal.Zero},
+	} {
+		if _, err := postgresRepository.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account: %v", err)
+		}
+	}
+	if _, err := postgresRepository.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("40")}); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if account, err := postgresRepository.GetAccount(ctx, "2"); err != nil || !account.Balance.Equal(decimal.RequireFromString("40")) {
+		t.Fatalf("expected account 2 to hold 40, got %+v and %v", account, err)
+	}
+}
+
+func expectMigrationVersion(t *testing.T, databaseURL string, want uint) {
+	t.Helper()
+
+	status, err := repository.GetMigrationStatus(databaseURL)
+	if err != nil {
+		t.Fatalf("migration status: %v", err)
+	}
+	if status.Version != want || status.Dirty {
+		t.Fatalf("expected clean version %d, got %+v", want, status)
+	}
+}
+
+// postgresSchema describes the objects of the accounts schema, one per line
+// in a stable order, so that two schemas can be compared.
+func postgresSchema(t *testing.T, databaseURL string) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `
+		SELECT 'schema ' || nspname FROM pg_namespace WHERE nspname = 'accounts'
+		UNION ALL
+		SELECT 'column ' || table_name || '.' || column_name || ' ' || data_type || ' ' || is_nullable || ' ' || COALESCE(column_default, '')
+		FROM information_schema.columns WHERE table_schema = 'accounts'
+		UNION ALL
+		SELECT 'index ' || indexdef FROM pg_indexes WHERE schemaname = 'accounts'
+		UNION ALL
+		SELECT 'constraint ' || c.conrelid::regclass || ' ' || c.conname || ' ' || pg_get_constraintdef(c.oid)
+		FROM pg_constraint c JOIN pg_namespace n ON n.oid = c.connamespace WHERE n.nspname = 'accounts'
+		UNION ALL
+		SELECT 'trigger ' || tg.tgrelid::regclass || ' ' || tg.tgname
+		FROM pg_trigger tg JOIN pg_class r ON r.oid = tg.tgrelid JOIN pg_namespace n ON n.oid = r.relnamespace
+		WHERE n.nspname = 'accounts' AND NOT tg.tgisinternal
+		UNION ALL
+		SELECT 'function ' || p.oid::regprocedure
+		FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = 'accounts'
+		UNION ALL
+		SELECT 'sequence ' || sequence_name FROM information_schema.sequences WHERE sequence_schema = 'accounts'
+		ORDER BY 1`)
+	if err != nil {
+		t.Fatalf("describe schema: %v", err)
+	}
+	objects, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("describe schema: %v", err)
+	}
+	return objects
+}
+
+// diffLines returns the lines of want missing from got and the lines of got
+// not in want.
+func diffLines(want, got []string) (missing, extra []string) {
+	counts := map[string]int{}
+	for _, line := range want {
+		counts[line]++
+	}
+	for _, line := range got {
+		counts[line]--
+	}
+	for line, count := range counts {
+		for ; count > 0; count-- {
+			missing = append(missing, line)
+		}
+		for ; count < 0; count++ {
+			extra = append(extra, line)
+		}
+	}
+	return missing, extra
+}
@@ -3,184 +3,748 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
 	"github.com/tareqpi/transfer-system/internal/config"
 	"github.com/tareqpi/transfer-system/internal/domain"
-	"github.com/tareqpi/transfer-system/internal/logger"
-	"go.uber.org/zap"
+	"github.com/tareqpi/transfer-system/internal/metrics"
 )
 
-var ErrInsufficientBalance = errors.New("insufficient balance")
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrAccountExists       = errors.New("account already exists")
+	ErrAccountFrozen       = errors.New("account is frozen")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyReversed     = errors.New("transaction has already been reversed")
+	ErrNotReversible       = errors.New("reversal transactions cannot be reversed")
+	ErrTransactionConflict = errors.New("transaction conflicted with a concurrent update")
+	ErrShardingUnsupported = errors.New("storage backend does not support sharded balances")
+	ErrVersionMismatch     = errors.New("account version does not match")
+	ErrFeeRuleNotFound     = errors.New("fee rule not found")
+)
 
+// Repository stores accounts and their transactions. Methods that take an
+// expectedVersion fail with ErrVersionMismatch, without changing anything,
+// when it is not 0 and differs from the account's current version.
+// TransferMoney posts the Fee of a transaction, when it has one, from the
+// same source in the same database transaction. Interest accruals are keyed
+// by account and day, at midnight UTC, so recording a day again keeps the
+// accruals already recorded and posting them again pays nothing twice.
+// GetAccounts and BalancesAt leave out the accounts that do not exist.
+// TransferMoney records the risk decision in Risk, when there is one, in the
+// audit log along with the transaction it creates; RecordRiskDecision writes
+// a decision on its own, for transfers that are denied or fail.
+// BalancesAt is read from balance snapshots where it can; a snapshot must
+// only be taken at a time every transaction created up to has committed.
 type Repository interface {
 	CreateAccount(ctx context.Context, account domain.Account) (*domain.Account, error)
 	GetAccount(ctx context.Context, id string) (*domain.Account, error)
+	SetAccountStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*domain.Account, error)
+	SetAccountShards(ctx context.Context, id int64, shards int, expectedVersion int64) (*domain.Account, error)
 	TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error)
+	GetTransaction(ctx context.Context, id int64) (*domain.Transaction, error)
+	ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error)
+	ReverseTransaction(ctx context.Context, id int64) (*domain.Transaction, error)
+	ListAccounts(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error)
+	GetAccounts(ctx context.Context, ids []int64) ([]domain.Account, error)
+	ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
+	RecordRiskDecision(ctx context.Context, decision domain.RiskDecision) error
+	ListFeeRules(ctx context.Context) ([]domain.FeeRule, error)
+	CreateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error)
+	UpdateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error)
+	DeleteFeeRule(ctx context.Context, id int64) error
+	SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (*domain.InterestRate, error)
+	ListInterestRates(ctx context.Context) ([]domain.InterestRate, error)
+	BalanceAt(ctx context.Context, accountID int64, at time.Time) (decimal.Decimal, error)
+	BalancesAt(ctx context.Context, accountIDs []int64, at time.Time) (map[int64]decimal.Decimal, error)
+	// SnapshotCutoff returns the latest time, no later than at, by which every
+	// transaction created has committed, for TakeBalanceSnapshots.
+	SnapshotCutoff(ctx context.Context, at time.Time) (time.Time, error)
+	TakeBalanceSnapshots(ctx context.Context, at time.Time) (int, error)
+	RecordInterestAccruals(ctx context.Context, accruals []domain.InterestAccrual) (int, error)
+	PostInterest(ctx context.Context, accountID, expenseAccountID int64, through time.Time) (*domain.Transaction, error)
+	ListInterestAccruals(ctx context.Context, filter domain.InterestAccrualFilter) ([]domain.InterestAccrual, error)
 }
 
 type PGRepository struct {
-	pool *pgxpool.Pool
+	pool         *pgxpool.Pool
+	runner       txRunner
+	serializable bool
 }
 
-func NewPGRepository(databasePool *pgxpool.Pool) *PGRepository {
-	return &PGRepository{pool: databasePool}
+// PGOptions tunes how PGRepository runs its read-write transactions.
+// Serializable runs transfers and reversals at SERIALIZABLE isolation instead
+// of READ COMMITTED; the serialization failures this can cause are retried
+// under Retry like deadlocks are.
+type PGOptions struct {
+	Retry        RetryPolicy
+	Serializable bool
 }
 
-func Setup(ctx context.Context) (*pgxpool.Pool, error) {
-	databaseURL := config.Get().DatabaseURL
+func NewPGRepository(databasePool *pgxpool.Pool, options PGOptions) *PGRepository {
+	return &PGRepository{pool: databasePool, runner: newTxRunner(options.Retry), serializable: options.Serializable}
+}
 
-	if databaseURL == "" {
-		return nil, errors.New("databaseURL is empty")
+func NewPool(ctx context.Context, databaseConfig config.DatabaseConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseConfig.URL)
+	if err != nil {
+		return nil, err
 	}
+	poolConfig.MaxConns = databaseConfig.MaxConns
+	poolConfig.MinConns = databaseConfig.MinConns
+	poolConfig.MaxConnLifetime = databaseConfig.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = databaseConfig.MaxConnIdleTime
+	poolConfig.ConnConfig.Tracer = otelpgx.NewTracer()
 
-	if err := migrateDB(databaseURL); err != nil {
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
 		return nil, err
 	}
 
-	pool, err := initPool(ctx, databaseURL)
-	if err != nil {
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, err
 	}
 	return pool, nil
 }
 
-func migrateDB(databaseURL string) error {
-	migration, err := migrate.New("file://../../migrations", databaseURL)
+// Pool exposes the connection pool for metrics collection.
+func (r *PGRepository) Pool() *pgxpool.Pool {
+	return r.pool
+}
+
+func (r *PGRepository) Close() {
+	r.pool.Close()
+}
+
+func (r *PGRepository) Ping(ctx context.Context) error {
+	return r.pool.Ping(ctx)
+}
+
+func (r *PGRepository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	if err := r.pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+// CheckSchema reports an error if the database schema is dirty or older than
+// the newest migration shipped with this binary. A newer schema is accepted so
+// that old and new binaries can run side by side during a rolling deploy.
+func (r *PGRepository) CheckSchema(ctx context.Context) error {
+	return checkSchemaVersion(ctx, r.SchemaVersion)
+}
+
+func checkSchemaVersion(ctx context.Context, schemaVersion func(context.Context) (uint, bool, error)) error {
+	expected, err := ExpectedSchemaVersion()
 	if err != nil {
-		logger.L().Error("migration setup failed", zap.Error(err))
 		return err
 	}
-	if err := migration.Up(); err != nil && err != migrate.ErrNoChange {
-		logger.L().Error("migration up failed", zap.Error(err))
+	version, dirty, err := schemaVersion(ctx)
+	if err != nil {
 		return err
 	}
-	logger.L().Info("database migrations applied successfully")
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version < expected {
+		return fmt.Errorf("schema version %d is behind expected version %d", version, expected)
+	}
 	return nil
 }
 
-func initPool(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
-	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+func (r *PGRepository) CreateAccount(ctx context.Context, account domain.Account) (*domain.Account, error) {
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	const insertSQL = `
+        INSERT INTO accounts.accounts (id, balance, opening_balance, tier, tags)
+        VALUES ($1, $2, $2, $3, $4)
+        RETURNING id, balance, status, tier, tags, shards, version, created_at
+    `
+
+	created, err := scanAccount(tx.QueryRow(ctx, insertSQL, account.ID, account.Balance, accountTier(account), accountTags(account)))
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return nil, ErrAccountExists
+		}
 		return nil, err
 	}
 
-	if err := pool.Ping(ctx); err != nil {
-		pool.Close()
+	if err := insertAuditEntry(ctx, tx, auditRecord{operation: domain.AuditOperationAccountCreate, accountID: created.ID, after: created}); err != nil {
 		return nil, err
 	}
-	return pool, nil
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
-func (r *PGRepository) CreateAccount(ctx context.Context, account domain.Account) (*domain.Account, error) {
-	var (
-		id      int64
-		balance decimal.Decimal
-	)
+func (r *PGRepository) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
+	account, err := scanAccount(r.pool.QueryRow(ctx, `SELECT `+pgAccountColumns+` FROM accounts.accounts a WHERE a.id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	return account, nil
+}
 
-	const insertSQL = `
-        INSERT INTO accounts.accounts (id, balance)
-        VALUES ($1, $2)
-        RETURNING id, balance
-    `
+func (r *PGRepository) SetAccountStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*domain.Account, error) {
+	var after *domain.Account
+	err := r.inTx(ctx, "set_account_status", pgx.TxOptions{}, func(tx pgx.Tx) error {
+		before, err := scanAccount(tx.QueryRow(ctx, `SELECT `+pgAccountColumns+` FROM accounts.accounts a WHERE a.id = $1 FOR UPDATE OF a`, id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrAccountNotFound
+			}
+			return err
+		}
+		if expectedVersion != 0 && before.Version != expectedVersion {
+			return ErrVersionMismatch
+		}
 
-	if err := r.pool.QueryRow(ctx, insertSQL, account.ID, account.Balance).Scan(&id, &balance); err != nil {
+		after, err = scanAccount(tx.QueryRow(ctx, `UPDATE accounts.accounts a SET status = $2, version = a.version + 1 WHERE a.id = $1 RETURNING `+pgAccountColumns, id, status))
+		if err != nil {
+			return err
+		}
+		return insertAuditEntry(ctx, tx, accountStatusAuditRecord(before, after))
+	})
+	if err != nil {
 		return nil, err
 	}
+	return after, nil
+}
 
-	created := &domain.Account{ID: id, Balance: balance}
+func (r *PGRepository) TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	var created *domain.Transaction
+	transaction, overdraw := normalizeTransfer(transaction)
+	decision := takeRiskDecision(&transaction)
+	operation := transferOperation(transaction.Type)
+	err := r.inTx(ctx, operation, r.transferTxOptions(), func(tx pgx.Tx) error {
+		legs := []domain.Transaction{transaction}
+		if transaction.Fee != nil {
+			legs = append(legs, feeTransfer(transaction))
+		}
+		locked, err := lockTransferAccounts(ctx, tx, operation, legs...)
+		if err != nil {
+			return err
+		}
+		if source := locked[transaction.SourceAccountID]; transaction.Fee != nil && source.shards > 0 {
+			// Both legs are taken from the shards at once, so that the fee
+			// never waits for shards while holding those of the transfer.
+			if err := debitShards(ctx, tx, transaction.SourceAccountID, transaction.Amount.Add(transaction.Fee.Amount)); err != nil {
+				return err
+			}
+			source.debited = transaction.Amount.Add(transaction.Fee.Amount)
+		}
+		if created, err = postTransfer(ctx, tx, transaction, locked, overdraw); err != nil {
+			return err
+		}
+		if transaction.Fee != nil {
+			fee, err := postTransfer(ctx, tx, legs[1], locked, false)
+			if err != nil {
+				return err
+			}
+			created = withFeeTransaction(created, fee)
+		}
+		if decision == nil {
+			return nil
+		}
+		return insertAuditEntry(ctx, tx, riskDecisionAuditRecord(*decision, &created.ID))
+	})
+	if err != nil {
+		return nil, err
+	}
 	return created, nil
 }
 
-func (r *PGRepository) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
-	var account domain.Account
+func (r *PGRepository) GetTransaction(ctx context.Context, id int64) (*domain.Transaction, error) {
+	transaction, err := scanTransaction(r.pool.QueryRow(ctx, `
+        SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+        FROM accounts.transactions
+        WHERE id = $1
+    `, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	return transaction, err
+}
 
+func (r *PGRepository) ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error) {
 	const selectSQL = `
-        SELECT id, balance
-        FROM accounts.accounts
-        WHERE id = $1
+        SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+        FROM accounts.transactions
+        WHERE (source_account_id = $1 OR destination_account_id = $1)
+          AND ($2::BIGINT = 0 OR id < $2)
+          AND ($4::TEXT = '' OR type = $4)
+        ORDER BY id DESC
+        LIMIT $3
     `
 
-	if err := r.pool.QueryRow(ctx, selectSQL, id).Scan(&account.ID, &account.Balance); err != nil {
+	rows, err := r.pool.Query(ctx, selectSQL, accountID, filter.BeforeID, filter.Limit, filter.Type)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &account, nil
+	transactions := []domain.Transaction{}
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *transaction)
+	}
+	return transactions, rows.Err()
 }
 
-func (r *PGRepository) TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
-	tx, err := r.pool.Begin(ctx)
+// ReverseTransaction moves the amount of transaction id back from its
+// destination to its source and links the new transaction to the original.
+// The original row is locked so concurrent reversals of it serialize.
+func (r *PGRepository) ReverseTransaction(ctx context.Context, id int64) (*domain.Transaction, error) {
+	var reversal *domain.Transaction
+	err := r.inTx(ctx, "reverse_transaction", r.transferTxOptions(), func(tx pgx.Tx) error {
+		original, err := scanTransaction(tx.QueryRow(ctx, `
+            SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+            FROM accounts.transactions
+            WHERE id = $1
+            FOR UPDATE
+        `, id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTransactionNotFound
+			}
+			return err
+		}
+		if original.ReversalOf != nil {
+			return ErrNotReversible
+		}
+
+		var reversed bool
+		if err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM accounts.transactions WHERE reversal_of = $1)`, id).Scan(&reversed); err != nil {
+			return err
+		}
+		if reversed {
+			return ErrAlreadyReversed
+		}
+
+		reversal, err = transferInTx(ctx, tx, "reverse_transaction", domain.Transaction{
+			Type:                 domain.TransactionTypeReversal,
+			SourceAccountID:      original.DestinationAccountID,
+			DestinationAccountID: original.SourceAccountID,
+			Amount:               original.Amount,
+			ReversalOf:           &original.ID,
+		}, original.Type == domain.TransactionTypeWithdrawal)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// inTx runs fn in a transaction and commits it, running both again from the
+// start when the transaction is aborted by a deadlock or serialization
+// failure. fn must not have effects outside the transaction.
+func (r *PGRepository) inTx(ctx context.Context, operation string, txOptions pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	return r.runner.run(ctx, operation, func(ctx context.Context) error {
+		txStart := time.Now()
+		tx, err := r.pool.BeginTx(ctx, txOptions)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = tx.Rollback(ctx)
+			metrics.DBTransactionDuration.WithLabelValues(operation).Observe(time.Since(txStart).Seconds())
+		}()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+}
+
+func (r *PGRepository) transferTxOptions() pgx.TxOptions {
+	if r.serializable {
+		return pgx.TxOptions{IsoLevel: pgx.Serializable}
+	}
+	return pgx.TxOptions{}
+}
+
+// LedgerSnapshot reads every account with its credit and debit totals, and
+// the transactions that reference a missing account, from a single
+// repeatable-read snapshot so that concurrent transfers cannot skew the result.
+func (r *PGRepository) LedgerSnapshot(ctx context.Context) (*domain.LedgerSnapshot, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	var sourceBalance decimal.Decimal
-	var tmpID int64
+	snapshot := &domain.LedgerSnapshot{Accounts: []domain.LedgerAccount{}, OrphanTransactions: []domain.Transaction{}}
+
+	rows, err := tx.Query(ctx, `
+        SELECT a.id, a.opening_balance, a.balance + COALESCE(shards.total, 0), COALESCE(credits.total, 0), COALESCE(debits.total, 0)
+        FROM accounts.accounts a
+        LEFT JOIN (
+            SELECT account_id, SUM(balance) AS total
+            FROM accounts.account_shards
+            GROUP BY account_id
+        ) shards ON shards.account_id = a.id
+        LEFT JOIN (
+            SELECT destination_account_id AS account_id, SUM(amount) AS total
+            FROM accounts.transactions
+            GROUP BY destination_account_id
+        ) credits ON credits.account_id = a.id
+        LEFT JOIN (
+            SELECT source_account_id AS account_id, SUM(amount) AS total
+            FROM accounts.transactions
+            GROUP BY source_account_id
+        ) debits ON debits.account_id = a.id
+        ORDER BY a.id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var account domain.LedgerAccount
+		if err := rows.Scan(&account.ID, &account.OpeningBalance, &account.Balance, &account.Credits, &account.Debits); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.Accounts = append(snapshot.Accounts, account)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	if transaction.SourceAccountID <= transaction.DestinationAccountID {
-		if err = tx.QueryRow(ctx, `SELECT id, balance FROM accounts.accounts WHERE id = $1 FOR UPDATE`, transaction.SourceAccountID).Scan(&tmpID, &sourceBalance); err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New("source account not found")
-			}
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM accounts.transactions`).Scan(&snapshot.TransactionCount); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, `
+        SELECT t.id, t.type, t.source_account_id, t.destination_account_id, t.amount, t.reversal_of, t.created_at
+        FROM accounts.transactions t
+        WHERE NOT EXISTS (SELECT 1 FROM accounts.accounts a WHERE a.id = t.source_account_id)
+           OR NOT EXISTS (SELECT 1 FROM accounts.accounts a WHERE a.id = t.destination_account_id)
+        ORDER BY t.id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
 			return nil, err
 		}
-		if err = tx.QueryRow(ctx, `SELECT id FROM accounts.accounts WHERE id = $1 FOR UPDATE`, transaction.DestinationAccountID).Scan(&tmpID); err != nil {
+		snapshot.OrphanTransactions = append(snapshot.OrphanTransactions, *transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// normalizeTransfer fills in the type of a transaction created without one
+// and reports whether its source may be overdrawn. Only the clearing account
+// a deposit comes from may go below zero, since it stands for money held
+// outside the ledger; reversing a withdrawal draws on a clearing account too.
+func normalizeTransfer(transaction domain.Transaction) (domain.Transaction, bool) {
+	if transaction.Type == "" {
+		transaction.Type = domain.TransactionTypeTransfer
+	}
+	return transaction, transaction.Type == domain.TransactionTypeDeposit
+}
+
+// takeRiskDecision removes the risk decision from a transfer, so that it is
+// recorded in an audit entry of its own rather than in that of the transfer.
+func takeRiskDecision(transaction *domain.Transaction) *domain.RiskDecision {
+	decision := transaction.Risk
+	transaction.Risk = nil
+	return decision
+}
+
+// transferOperation names the transaction TransferMoney runs for metrics, so
+// that deposits and withdrawals are told apart from transfers.
+func transferOperation(transactionType string) string {
+	switch transactionType {
+	case domain.TransactionTypeDeposit:
+		return "deposit"
+	case domain.TransactionTypeWithdrawal:
+		return "withdrawal"
+	}
+	return "transfer_money"
+}
+
+type lockedAccount struct {
+	balance decimal.Decimal
+	status  string
+	shards  int
+	version int64
+	// debited is what was taken from the shards of the account ahead of the
+	// legs that pay it.
+	debited decimal.Decimal
+}
+
+// transferInTx locks the accounts of a transaction and posts it.
+func transferInTx(ctx context.Context, tx pgx.Tx, operation string, transaction domain.Transaction, overdraw bool) (*domain.Transaction, error) {
+	locked, err := lockTransferAccounts(ctx, tx, operation, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return postTransfer(ctx, tx, transaction, locked, overdraw)
+}
+
+// lockTransferAccounts locks every account of transactions, in one pass in
+// ascending ID order, so that transfers cannot deadlock on each other however
+// many legs they post. A sharded account is only share-locked, which keeps it
+// from being frozen or resharded meanwhile; its money moves through its shard
+// rows instead. The lock wait is recorded under operation.
+func lockTransferAccounts(ctx context.Context, tx pgx.Tx, operation string, transactions ...domain.Transaction) (map[int64]*lockedAccount, error) {
+	roles := accountRoles(transactions)
+	lockOrder := slices.Sorted(maps.Keys(roles))
+
+	shards, err := shardCounts(ctx, tx, lockOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	lockStart := time.Now()
+	locked := make(map[int64]*lockedAccount, len(lockOrder))
+	for _, id := range lockOrder {
+		lockSQL := `SELECT balance, status, shards, version FROM accounts.accounts WHERE id = $1 FOR UPDATE`
+		if shards[id] > 0 {
+			lockSQL = `SELECT balance, status, shards, version FROM accounts.accounts WHERE id = $1 FOR SHARE`
+		}
+		var account lockedAccount
+		if err := tx.QueryRow(ctx, lockSQL, id).Scan(&account.balance, &account.status, &account.shards, &account.version); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New("destination account not found")
+				return nil, fmt.Errorf("%s %w", roles[id], ErrAccountNotFound)
 			}
 			return nil, err
 		}
-	} else {
-		if err = tx.QueryRow(ctx, `SELECT id FROM accounts.accounts WHERE id = $1 FOR UPDATE`, transaction.DestinationAccountID).Scan(&tmpID); err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New("destination account not found")
-			}
+		if account.shards != shards[id] {
+			// Sharding was switched on or off after the lock mode was chosen.
+			return nil, fmt.Errorf("%w: account %d was resharded", ErrTransactionConflict, id)
+		}
+		locked[id] = &account
+	}
+	metrics.DBLockWaitDuration.WithLabelValues(operation).Observe(time.Since(lockStart).Seconds())
+	return locked, nil
+}
+
+// accountRoles names every account of transactions by the side it is first
+// seen on, for the error when it does not exist.
+func accountRoles(transactions []domain.Transaction) map[int64]string {
+	roles := make(map[int64]string, 2*len(transactions))
+	for _, transaction := range transactions {
+		if _, ok := roles[transaction.SourceAccountID]; !ok {
+			roles[transaction.SourceAccountID] = "source"
+		}
+		if _, ok := roles[transaction.DestinationAccountID]; !ok {
+			roles[transaction.DestinationAccountID] = "destination"
+		}
+	}
+	return roles
+}
+
+// postTransfer moves the amount of a transaction between accounts locked by
+// lockTransferAccounts and records it, keeping locked up to date for the
+// next leg. With overdraw the balance of an unsharded source is not checked.
+func postTransfer(ctx context.Context, tx pgx.Tx, transaction domain.Transaction, locked map[int64]*lockedAccount, overdraw bool) (*domain.Transaction, error) {
+	source, destination := locked[transaction.SourceAccountID], locked[transaction.DestinationAccountID]
+	if source.status == domain.AccountStatusFrozen || destination.status == domain.AccountStatusFrozen {
+		return nil, ErrAccountFrozen
+	}
+
+	if source.shards > 0 {
+		if source.debited.GreaterThanOrEqual(transaction.Amount) {
+			source.debited = source.debited.Sub(transaction.Amount)
+		} else if err := debitShards(ctx, tx, transaction.SourceAccountID, transaction.Amount); err != nil {
 			return nil, err
 		}
-		if err = tx.QueryRow(ctx, `SELECT id, balance FROM accounts.accounts WHERE id = $1 FOR UPDATE`, transaction.SourceAccountID).Scan(&tmpID, &sourceBalance); err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New("source account not found")
-			}
+	} else {
+		if !overdraw && source.balance.LessThan(transaction.Amount) {
+			return nil, ErrInsufficientBalance
+		}
+		if _, err := tx.Exec(ctx, `UPDATE accounts.accounts SET balance = balance - $1, version = version + 1 WHERE id = $2`, transaction.Amount, transaction.SourceAccountID); err != nil {
 			return nil, err
 		}
 	}
 
-	if sourceBalance.LessThan(transaction.Amount) {
-		return nil, ErrInsufficientBalance
+	if destination.shards > 0 {
+		if err := creditShard(ctx, tx, transaction.DestinationAccountID, destination.shards, transaction.Amount); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := tx.Exec(ctx, `UPDATE accounts.accounts SET balance = balance + $1, version = version + 1 WHERE id = $2`, transaction.Amount, transaction.DestinationAccountID); err != nil {
+			return nil, err
+		}
 	}
 
-	if _, err = tx.Exec(ctx, `UPDATE accounts.accounts SET balance = balance - $1 WHERE id = $2`, transaction.Amount, transaction.SourceAccountID); err != nil {
+	created := transaction
+	if err := tx.QueryRow(ctx, `
+        INSERT INTO accounts.transactions (type, source_account_id, destination_account_id, amount, reversal_of)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `, transaction.Type, transaction.SourceAccountID, transaction.DestinationAccountID, transaction.Amount, transaction.ReversalOf).Scan(&created.ID, &created.CreatedAt); err != nil {
 		return nil, err
 	}
 
-	if _, err = tx.Exec(ctx, `UPDATE accounts.accounts SET balance = balance + $1 WHERE id = $2`, transaction.Amount, transaction.DestinationAccountID); err != nil {
+	// The balance of a sharded account is not locked as a whole, so it is
+	// read after the change, less what was debited for later legs, and the
+	// state before derived from it. Its row is not written, so its version
+	// stays the same.
+	var err error
+	sourceAfter, destinationAfter := source.balance.Sub(transaction.Amount), destination.balance.Add(transaction.Amount)
+	sourceVersion, destinationVersion := source.version+1, destination.version+1
+	if source.shards > 0 {
+		if sourceAfter, err = shardedBalance(ctx, tx, transaction.SourceAccountID); err != nil {
+			return nil, err
+		}
+		sourceAfter = sourceAfter.Add(source.debited)
+		sourceVersion = source.version
+	}
+	if destination.shards > 0 {
+		if destinationAfter, err = shardedBalance(ctx, tx, transaction.DestinationAccountID); err != nil {
+			return nil, err
+		}
+		destinationVersion = destination.version
+	}
+	before := transferState{
+		Source:      domain.Account{ID: transaction.SourceAccountID, Balance: sourceAfter.Add(transaction.Amount), Status: source.status, Shards: source.shards, Version: source.version},
+		Destination: domain.Account{ID: transaction.DestinationAccountID, Balance: destinationAfter.Sub(transaction.Amount), Status: destination.status, Shards: destination.shards, Version: destination.version},
+	}
+	after := transferState{
+		Source:      domain.Account{ID: transaction.SourceAccountID, Balance: sourceAfter, Status: source.status, Shards: source.shards, Version: sourceVersion},
+		Destination: domain.Account{ID: transaction.DestinationAccountID, Balance: destinationAfter, Status: destination.status, Shards: destination.shards, Version: destinationVersion},
+		Transaction: &created,
+	}
+	if err := insertAuditEntry(ctx, tx, transferAuditRecord(before, after)); err != nil {
 		return nil, err
 	}
+	source.balance, source.version = sourceAfter, sourceVersion
+	destination.balance, destination.version = destinationAfter, destinationVersion
+	return &created, nil
+}
 
-	var id int64
-	if err = tx.QueryRow(ctx, `
-        INSERT INTO accounts.transactions (source_account_id, destination_account_id, amount)
-        VALUES ($1, $2, $3)
-        RETURNING id
-    `, transaction.SourceAccountID, transaction.DestinationAccountID, transaction.Amount).Scan(&id); err != nil {
+func insertAuditEntry(ctx context.Context, tx pgx.Tx, record auditRecord) error {
+	entry, err := newAuditEntry(ctx, record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+        INSERT INTO accounts.audit_log (actor, request_id, client_ip, operation, account_id, counterparty_account_id, transaction_id, before_state, after_state)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8::JSONB, $9::JSONB)
+    `, entry.Actor, entry.RequestID, entry.ClientIP, entry.Operation, entry.AccountID, entry.CounterpartyAccountID, entry.TransactionID, nullableJSON(entry.Before), nullableJSON(entry.After))
+	return err
+}
+
+func (r *PGRepository) RecordRiskDecision(ctx context.Context, decision domain.RiskDecision) error {
+	return r.inTx(ctx, "record_risk_decision", pgx.TxOptions{}, func(tx pgx.Tx) error {
+		return insertAuditEntry(ctx, tx, riskDecisionAuditRecord(decision, nil))
+	})
+}
+
+func (r *PGRepository) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	const selectSQL = `
+        SELECT id, occurred_at, actor, request_id, client_ip, operation, account_id, counterparty_account_id, transaction_id, before_state, after_state
+        FROM accounts.audit_log
+        WHERE ($1::TEXT = '' OR actor = $1)
+          AND ($2::TEXT = '' OR operation = $2)
+          AND ($3::TEXT = '' OR request_id = $3)
+          AND ($4::BIGINT = 0 OR account_id = $4 OR counterparty_account_id = $4)
+          AND ($5::TIMESTAMPTZ IS NULL OR occurred_at >= $5)
+          AND ($6::TIMESTAMPTZ IS NULL OR occurred_at < $6)
+          AND ($7::BIGINT = 0 OR id < $7)
+        ORDER BY id DESC
+        LIMIT $8
+    `
+
+	rows, err := r.pool.Query(ctx, selectSQL, filter.Actor, filter.Operation, filter.RequestID, filter.AccountID,
+		nullableTime(filter.Since), nullableTime(filter.Until), filter.BeforeID, filter.Limit)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var (
+			entry         domain.AuditEntry
+			before, after []byte
+		)
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.OccurredAt,
+			&entry.Actor,
+			&entry.RequestID,
+			&entry.ClientIP,
+			&entry.Operation,
+			&entry.AccountID,
+			&entry.CounterpartyAccountID,
+			&entry.TransactionID,
+			&before,
+			&after,
+		); err != nil {
+			return nil, err
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
 
-	if err = tx.Commit(ctx); err != nil {
+// pgAccountColumns selects an account aliased as a, with the balances of its
+// shards added to its own.
+const pgAccountColumns = `a.id, a.balance + COALESCE((SELECT SUM(s.balance) FROM accounts.account_shards s WHERE s.account_id = a.id), 0) AS balance, a.status, a.tier, a.tags, a.shards, a.version, a.created_at`
+
+func scanAccount(row pgx.Row) (*domain.Account, error) {
+	var account domain.Account
+	if err := row.Scan(&account.ID, &account.Balance, &account.Status, &account.Tier, &account.Tags, &account.Shards, &account.Version, &account.CreatedAt); err != nil {
 		return nil, err
 	}
+	return &account, nil
+}
 
-	return &domain.Transaction{ID: id, SourceAccountID: transaction.SourceAccountID, DestinationAccountID: transaction.DestinationAccountID, Amount: transaction.Amount}, nil
+func scanTransaction(row pgx.Row) (*domain.Transaction, error) {
+	var transaction domain.Transaction
+	if err := row.Scan(
+		&transaction.ID,
+		&transaction.Type,
+		&transaction.SourceAccountID,
+		&transaction.DestinationAccountID,
+		&transaction.Amount,
+		&transaction.ReversalOf,
+		&transaction.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
 }
@@ -0,0 +1,1323 @@
+// Package repositorytest holds the conformance suite every
+// repository.Repository implementation must pass.
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+// Factory returns an empty repository. It is called once per test case.
+type Factory func(t *testing.T) repository.Repository
+
+func Run(t *testing.T, newRepository Factory) {
+	testCases := []struct {
+		name string
+		run  func(t *testing.T, repo repository.Repository)
+	}{
+		{"CreateAndGetAccount", testCreateAndGetAccount},
+		{"DuplicateAccount", testDuplicateAccount},
+		{"AccountNotFound", testAccountNotFound},
+		{"ListAccounts", testListAccounts},
+		{"GetAccounts", testGetAccounts},
+		{"TransferMovesMoney", testTransferMovesMoney},
+		{"DecimalPrecision", testDecimalPrecision},
+		{"TransferInsufficientBalance", testTransferInsufficientBalance},
+		{"TransferMissingAccount", testTransferMissingAccount},
+		{"TransactionIDsIncrease", testTransactionIDsIncrease},
+		{"FrozenAccount", testFrozenAccount},
+		{"AccountVersion", testAccountVersion},
+		{"ConcurrentVersionedUpdates", testConcurrentVersionedUpdates},
+		{"GetTransaction", testGetTransaction},
+		{"ListTransactions", testListTransactions},
+		{"ReverseTransaction", testReverseTransaction},
+		{"FundingTransactions", testFundingTransactions},
+		{"TransferFees", testTransferFees},
+		{"FeeRules", testFeeRules},
+		{"InterestRates", testInterestRates},
+		{"BalanceAt", testBalanceAt},
+		{"BalanceSnapshots", testBalanceSnapshots},
+		{"InterestAccruals", testInterestAccruals},
+		{"ConcurrentOpposingTransfers", testConcurrentOpposingTransfers},
+		{"ConcurrentOverdraw", testConcurrentOverdraw},
+		{"ConcurrentReversals", testConcurrentReversals},
+		{"LedgerSnapshot", testLedgerSnapshot},
+		{"AuditLog", testAuditLog},
+		{"AuditSkipsFailedMutations", testAuditSkipsFailedMutations},
+		{"RiskDecisions", testRiskDecisions},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.run(t, newRepository(t))
+		})
+	}
+}
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func createAccount(t *testing.T, repo repository.Repository, id int64, balance string) {
+	t.Helper()
+	if _, err := repo.CreateAccount(context.Background(), domain.Account{ID: id, Balance: amount(balance)}); err != nil {
+		t.Fatalf("create account %d: %v", id, err)
+	}
+}
+
+func transfer(t *testing.T, repo repository.Repository, from, to int64, value string) *domain.Transaction {
+	t.Helper()
+	created, err := repo.TransferMoney(context.Background(), domain.Transaction{
+		SourceAccountID:      from,
+		DestinationAccountID: to,
+		Amount:               amount(value),
+	})
+	if err != nil {
+		t.Fatalf("transfer %d -> %d: %v", from, to, err)
+	}
+	return created
+}
+
+func expectBalance(t *testing.T, repo repository.Repository, id int64, want string) {
+	t.Helper()
+	account, err := repo.GetAccount(context.Background(), strconv.FormatInt(id, 10))
+	if err != nil {
+		t.Fatalf("get account %d: %v", id, err)
+	}
+	if !account.Balance.Equal(amount(want)) {
+		t.Fatalf("expected account %d balance %s, got %s", id, want, account.Balance)
+	}
+}
+
+func testCreateAndGetAccount(t *testing.T, repo repository.Repository) {
+	created, err := repo.CreateAccount(context.Background(), domain.Account{ID: 1, Balance: amount("100.50")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if created.ID != 1 || !created.Balance.Equal(amount("100.50")) || created.Status != domain.AccountStatusActive {
+		t.Fatalf("unexpected created account %+v", created)
+	}
+
+	account, err := repo.GetAccount(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if account.ID != 1 || !account.Balance.Equal(amount("100.50")) || account.Status != domain.AccountStatusActive {
+		t.Fatalf("unexpected account %+v", account)
+	}
+}
+
+func testDuplicateAccount(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "10")
+	_, err := repo.CreateAccount(context.Background(), domain.Account{ID: 1, Balance: amount("20")})
+	if !errors.Is(err, repository.ErrAccountExists) {
+		t.Fatalf("expected ErrAccountExists, got %v", err)
+	}
+	expectBalance(t, repo, 1, "10")
+}
+
+func testAccountNotFound(t *testing.T, repo repository.Repository) {
+	if _, err := repo.GetAccount(context.Background(), "404"); !errors.Is(err, repository.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound from GetAccount, got %v", err)
+	}
+	if _, err := repo.SetAccountStatus(context.Background(), 404, domain.AccountStatusFrozen, 0); !errors.Is(err, repository.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound from SetAccountStatus, got %v", err)
+	}
+}
+
+func createTaggedAccounts(t *testing.T, repo repository.Repository) {
+	t.Helper()
+	for _, account := range []domain.Account{
+		{ID: 1, Balance: amount("100"), Tags: []string{"retail"}},
+		{ID: 2, Balance: amount("50"), Tags: []string{"retail", "vip"}},
+		{ID: 3, Balance: amount("50"), Tags: []string{"business"}},
+		{ID: 4, Balance: amount("0")},
+		{ID: 5, Balance: amount("250.5"), Tags: []string{"vip"}},
+	} {
+		if _, err := repo.CreateAccount(context.Background(), account); err != nil {
+			t.Fatalf("create account %d: %v", account.ID, err)
+		}
+	}
+	if _, err := repo.SetAccountStatus(context.Background(), 5, domain.AccountStatusFrozen, 0); err != nil {
+		t.Fatalf("freeze account 5: %v", err)
+	}
+}
+
+func listAccountIDs(t *testing.T, repo repository.Repository, filter domain.AccountFilter) ([]int64, []domain.Account) {
+	t.Helper()
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+	accounts, err := repo.ListAccounts(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("list accounts %+v: %v", filter, err)
+	}
+	ids := make([]int64, 0, len(accounts))
+	for _, account := range accounts {
+		ids = append(ids, account.ID)
+	}
+	return ids, accounts
+}
+
+func testListAccounts(t *testing.T, repo repository.Repository) {
+	createTaggedAccounts(t, repo)
+
+	minBalance, maxBalance := amount("50"), amount("100")
+	later := time.Now().Add(time.Hour)
+	testCases := []struct {
+		name   string
+		filter domain.AccountFilter
+		want   []int64
+	}{
+		{"all", domain.AccountFilter{}, []int64{1, 2, 3, 4, 5}},
+		{"descending", domain.AccountFilter{Descending: true}, []int64{5, 4, 3, 2, 1}},
+		{"status", domain.AccountFilter{Status: domain.AccountStatusFrozen}, []int64{5}},
+		{"balance_range", domain.AccountFilter{MinBalance: &minBalance, MaxBalance: &maxBalance}, []int64{1, 2, 3}},
+		{"one_tag", domain.AccountFilter{Tags: []string{"vip"}}, []int64{2, 5}},
+		{"every_tag", domain.AccountFilter{Tags: []string{"retail", "vip"}}, []int64{2}},
+		{"unknown_tag", domain.AccountFilter{Tags: []string{"none"}}, []int64{}},
+		{"by_balance", domain.AccountFilter{Sort: domain.AccountSortBalance}, []int64{4, 2, 3, 1, 5}},
+		{"by_balance_descending", domain.AccountFilter{Sort: domain.AccountSortBalance, Descending: true}, []int64{5, 1, 3, 2, 4}},
+		{"by_created_at", domain.AccountFilter{Sort: domain.AccountSortCreatedAt}, []int64{1, 2, 3, 4, 5}},
+		{"created_until", domain.AccountFilter{CreatedUntil: later}, []int64{1, 2, 3, 4, 5}},
+		{"created_since", domain.AccountFilter{CreatedSince: later}, []int64{}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ids, accounts := listAccountIDs(t, repo, testCase.filter)
+			if len(ids) != len(testCase.want) {
+				t.Fatalf("expected accounts %v, got %v", testCase.want, ids)
+			}
+			for i := range ids {
+				if ids[i] != testCase.want[i] {
+					t.Fatalf("expected accounts %v, got %v", testCase.want, ids)
+				}
+			}
+			if accounts == nil {
+				t.Fatalf("expected a non-nil page")
+			}
+		})
+	}
+
+	_, accounts := listAccountIDs(t, repo, domain.AccountFilter{Tags: []string{"retail"}})
+	if got := accounts[1]; len(got.Tags) != 2 || got.Tags[0] != "retail" || got.Tags[1] != "vip" || got.CreatedAt.IsZero() {
+		t.Fatalf("expected account 2 with its tags and creation time, got %+v", got)
+	}
+
+	for _, sort := range []string{domain.AccountSortID, domain.AccountSortBalance, domain.AccountSortCreatedAt} {
+		for _, descending := range []bool{false, true} {
+			all, _ := listAccountIDs(t, repo, domain.AccountFilter{Sort: sort, Descending: descending})
+			var paged []int64
+			filter := domain.AccountFilter{Sort: sort, Descending: descending, Limit: 2}
+			for {
+				ids, page := listAccountIDs(t, repo, filter)
+				paged = append(paged, ids...)
+				if len(page) < filter.Limit {
+					break
+				}
+				last := page[len(page)-1]
+				filter.After = &domain.AccountCursor{ID: last.ID, Balance: last.Balance, CreatedAt: last.CreatedAt}
+			}
+			if len(paged) != len(all) {
+				t.Fatalf("expected pages sorted by %s (descending %t) to hold %v, got %v", sort, descending, all, paged)
+			}
+			for i := range paged {
+				if paged[i] != all[i] {
+					t.Fatalf("expected pages sorted by %s (descending %t) to hold %v, got %v", sort, descending, all, paged)
+				}
+			}
+		}
+	}
+}
+
+func testGetAccounts(t *testing.T, repo repository.Repository) {
+	createTaggedAccounts(t, repo)
+
+	accounts, err := repo.GetAccounts(context.Background(), []int64{5, 3, 404, 1})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(accounts) != 3 || accounts[0].ID != 1 || accounts[1].ID != 3 || accounts[2].ID != 5 {
+		t.Fatalf("expected accounts 1, 3 and 5 by ID, got %+v", accounts)
+	}
+	if !accounts[2].Balance.Equal(amount("250.5")) || accounts[2].Status != domain.AccountStatusFrozen {
+		t.Fatalf("unexpected account 5 %+v", accounts[2])
+	}
+}
+
+func testTransferMovesMoney(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "5")
+
+	created := transfer(t, repo, 1, 2, "25.25")
+	if created.ID <= 0 || created.CreatedAt.IsZero() {
+		t.Fatalf("expected ID and creation time to be set, got %+v", created)
+	}
+	if created.SourceAccountID != 1 || created.DestinationAccountID != 2 || !created.Amount.Equal(amount("25.25")) || created.ReversalOf != nil {
+		t.Fatalf("unexpected transaction %+v", created)
+	}
+	expectBalance(t, repo, 1, "74.75")
+	expectBalance(t, repo, 2, "30.25")
+}
+
+// testDecimalPrecision uses values that a float64 cannot represent, so a
+// backend that converts through floating point fails it.
+func testDecimalPrecision(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "99999999999999.9999")
+	createAccount(t, repo, 2, "0.0001")
+
+	transfer(t, repo, 1, 2, "0.0003")
+	expectBalance(t, repo, 1, "99999999999999.9996")
+	expectBalance(t, repo, 2, "0.0004")
+}
+
+func testTransferInsufficientBalance(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "10")
+	createAccount(t, repo, 2, "0")
+
+	_, err := repo.TransferMoney(context.Background(), domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("10.01")})
+	if !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+	}
+	expectBalance(t, repo, 1, "10")
+	expectBalance(t, repo, 2, "0")
+
+	transfer(t, repo, 1, 2, "10")
+	expectBalance(t, repo, 1, "0")
+}
+
+func testTransferMissingAccount(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "10")
+
+	for _, transaction := range []domain.Transaction{
+		{SourceAccountID: 1, DestinationAccountID: 404, Amount: amount("1")},
+		{SourceAccountID: 404, DestinationAccountID: 1, Amount: amount("1")},
+	} {
+		if _, err := repo.TransferMoney(context.Background(), transaction); !errors.Is(err, repository.ErrAccountNotFound) {
+			t.Fatalf("expected ErrAccountNotFound for %+v, got %v", transaction, err)
+		}
+	}
+	expectBalance(t, repo, 1, "10")
+}
+
+func testTransactionIDsIncrease(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "10")
+	createAccount(t, repo, 2, "10")
+
+	first := transfer(t, repo, 1, 2, "1")
+	second := transfer(t, repo, 2, 1, "1")
+	if second.ID <= first.ID {
+		t.Fatalf("expected increasing IDs, got %d then %d", first.ID, second.ID)
+	}
+}
+
+func testFrozenAccount(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "10")
+	createAccount(t, repo, 2, "10")
+
+	frozen, err := repo.SetAccountStatus(context.Background(), 2, domain.AccountStatusFrozen, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if frozen.Status != domain.AccountStatusFrozen || !frozen.Balance.Equal(amount("10")) {
+		t.Fatalf("unexpected frozen account %+v", frozen)
+	}
+
+	for _, transaction := range []domain.Transaction{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("1")},
+		{SourceAccountID: 2, DestinationAccountID: 1, Amount: amount("1")},
+	} {
+		if _, err := repo.TransferMoney(context.Background(), transaction); !errors.Is(err, repository.ErrAccountFrozen) {
+			t.Fatalf("expected ErrAccountFrozen for %+v, got %v", transaction, err)
+		}
+	}
+
+	if _, err := repo.SetAccountStatus(context.Background(), 2, domain.AccountStatusActive, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	transfer(t, repo, 1, 2, "1")
+	expectBalance(t, repo, 2, "11")
+}
+
+func expectVersion(t *testing.T, repo repository.Repository, id int64, want int64) {
+	t.Helper()
+	account, err := repo.GetAccount(context.Background(), strconv.FormatInt(id, 10))
+	if err != nil {
+		t.Fatalf("get account %d: %v", id, err)
+	}
+	if account.Version != want {
+		t.Fatalf("expected account %d at version %d, got %d", id, want, account.Version)
+	}
+}
+
+func testAccountVersion(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	created, err := repo.CreateAccount(ctx, domain.Account{ID: 1, Balance: amount("10")})
+	if err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+	if created.Version != 1 {
+		t.Fatalf("expected a new account at version 1, got %d", created.Version)
+	}
+	createAccount(t, repo, 2, "10")
+
+	transfer(t, repo, 1, 2, "1")
+	expectVersion(t, repo, 1, 2)
+	expectVersion(t, repo, 2, 2)
+
+	if _, err := repo.SetAccountStatus(ctx, 1, domain.AccountStatusFrozen, 1); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	account, err := repo.GetAccount(ctx, "1")
+	if err != nil {
+		t.Fatalf("get account: %v", err)
+	}
+	if account.Status != domain.AccountStatusActive || account.Version != 2 {
+		t.Fatalf("expected the rejected update to change nothing, got %+v", account)
+	}
+
+	frozen, err := repo.SetAccountStatus(ctx, 1, domain.AccountStatusFrozen, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if frozen.Status != domain.AccountStatusFrozen || frozen.Version != 3 {
+		t.Fatalf("expected account frozen at version 3, got %+v", frozen)
+	}
+	expectVersion(t, repo, 1, 3)
+
+	// Without an expected version the update is unconditional.
+	active, err := repo.SetAccountStatus(ctx, 1, domain.AccountStatusActive, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if active.Version != 4 {
+		t.Fatalf("expected version 4, got %d", active.Version)
+	}
+}
+
+// testConcurrentVersionedUpdates races updates that all expect the same
+// version; exactly one of them may apply.
+func testConcurrentVersionedUpdates(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "10")
+
+	const workers = 10
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		applied  int
+		rejected int
+	)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := domain.AccountStatusFrozen
+			if i%2 == 0 {
+				status = domain.AccountStatusActive
+			}
+			_, err := repo.SetAccountStatus(context.Background(), 1, status, 1)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				applied++
+			case errors.Is(err, repository.ErrVersionMismatch):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if applied != 1 || rejected != workers-1 {
+		t.Fatalf("expected 1 update applied and %d rejected, got %d and %d", workers-1, applied, rejected)
+	}
+	expectVersion(t, repo, 1, 2)
+}
+
+func testGetTransaction(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "10")
+	createAccount(t, repo, 2, "10")
+	created := transfer(t, repo, 1, 2, "2.5")
+
+	found, err := repo.GetTransaction(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if found.ID != created.ID || found.SourceAccountID != 1 || found.DestinationAccountID != 2 || !found.Amount.Equal(amount("2.5")) {
+		t.Fatalf("unexpected transaction %+v", found)
+	}
+
+	if _, err := repo.GetTransaction(context.Background(), created.ID+1000); !errors.Is(err, repository.ErrTransactionNotFound) {
+		t.Fatalf("expected ErrTransactionNotFound, got %v", err)
+	}
+}
+
+func testListTransactions(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "100")
+	createAccount(t, repo, 3, "100")
+
+	first := transfer(t, repo, 1, 2, "1")
+	transfer(t, repo, 2, 3, "1")
+	third := transfer(t, repo, 3, 1, "1")
+	fourth := transfer(t, repo, 1, 3, "1")
+
+	page, err := repo.ListTransactions(context.Background(), 1, domain.TransactionFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(page) != 2 || page[0].ID != fourth.ID || page[1].ID != third.ID {
+		t.Fatalf("expected newest two transactions of account 1, got %+v", page)
+	}
+
+	page, err = repo.ListTransactions(context.Background(), 1, domain.TransactionFilter{Limit: 2, BeforeID: third.ID})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(page) != 1 || page[0].ID != first.ID {
+		t.Fatalf("expected only the first transaction, got %+v", page)
+	}
+
+	page, err = repo.ListTransactions(context.Background(), 404, domain.TransactionFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if page == nil || len(page) != 0 {
+		t.Fatalf("expected an empty, non-nil page, got %+v", page)
+	}
+}
+
+func testReverseTransaction(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "0")
+	original := transfer(t, repo, 1, 2, "40")
+
+	reversal, err := repo.ReverseTransaction(context.Background(), original.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if reversal.SourceAccountID != 2 || reversal.DestinationAccountID != 1 || !reversal.Amount.Equal(amount("40")) {
+		t.Fatalf("unexpected reversal %+v", reversal)
+	}
+	if reversal.ReversalOf == nil || *reversal.ReversalOf != original.ID {
+		t.Fatalf("expected reversal to reference %d, got %v", original.ID, reversal.ReversalOf)
+	}
+	expectBalance(t, repo, 1, "100")
+	expectBalance(t, repo, 2, "0")
+
+	if _, err := repo.ReverseTransaction(context.Background(), original.ID); !errors.Is(err, repository.ErrAlreadyReversed) {
+		t.Fatalf("expected ErrAlreadyReversed, got %v", err)
+	}
+	if _, err := repo.ReverseTransaction(context.Background(), reversal.ID); !errors.Is(err, repository.ErrNotReversible) {
+		t.Fatalf("expected ErrNotReversible, got %v", err)
+	}
+	if _, err := repo.ReverseTransaction(context.Background(), reversal.ID+1000); !errors.Is(err, repository.ErrTransactionNotFound) {
+		t.Fatalf("expected ErrTransactionNotFound, got %v", err)
+	}
+
+	drained := transfer(t, repo, 1, 2, "100")
+	transfer(t, repo, 2, 1, "100")
+	if _, err := repo.ReverseTransaction(context.Background(), drained.ID); !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance when the destination has spent the money, got %v", err)
+	}
+}
+
+func testFundingTransactions(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	createAccount(t, repo, 1, "0")
+	createAccount(t, repo, 900, "0")
+	createAccount(t, repo, 901, "0")
+
+	deposit, err := repo.TransferMoney(ctx, domain.Transaction{Type: domain.TransactionTypeDeposit, SourceAccountID: 900, DestinationAccountID: 1, Amount: amount("50")})
+	if err != nil {
+		t.Fatalf("expected a deposit to overdraw its clearing account, got %v", err)
+	}
+	if deposit.Type != domain.TransactionTypeDeposit {
+		t.Fatalf("expected type %s, got %s", domain.TransactionTypeDeposit, deposit.Type)
+	}
+	expectBalance(t, repo, 900, "-50")
+
+	if _, err := repo.TransferMoney(ctx, domain.Transaction{Type: domain.TransactionTypeWithdrawal, SourceAccountID: 1, DestinationAccountID: 901, Amount: amount("60")}); !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance for a withdrawal above the balance, got %v", err)
+	}
+	withdrawal, err := repo.TransferMoney(ctx, domain.Transaction{Type: domain.TransactionTypeWithdrawal, SourceAccountID: 1, DestinationAccountID: 901, Amount: amount("20")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	plain := transfer(t, repo, 901, 1, "5")
+	if plain.Type != domain.TransactionTypeTransfer {
+		t.Fatalf("expected an untyped transfer to be stored as %s, got %s", domain.TransactionTypeTransfer, plain.Type)
+	}
+
+	reversal, err := repo.ReverseTransaction(ctx, withdrawal.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if reversal.Type != domain.TransactionTypeReversal {
+		t.Fatalf("expected type %s, got %s", domain.TransactionTypeReversal, reversal.Type)
+	}
+	expectBalance(t, repo, 1, "55")
+	expectBalance(t, repo, 901, "-5")
+
+	stored, err := repo.GetTransaction(ctx, deposit.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stored.Type != domain.TransactionTypeDeposit {
+		t.Fatalf("expected stored type %s, got %s", domain.TransactionTypeDeposit, stored.Type)
+	}
+
+	for transactionType, want := range map[string]int64{
+		domain.TransactionTypeDeposit:    deposit.ID,
+		domain.TransactionTypeWithdrawal: withdrawal.ID,
+		domain.TransactionTypeTransfer:   plain.ID,
+		domain.TransactionTypeReversal:   reversal.ID,
+	} {
+		page, err := repo.ListTransactions(ctx, 1, domain.TransactionFilter{Limit: 10, Type: transactionType})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(page) != 1 || page[0].ID != want || page[0].Type != transactionType {
+			t.Fatalf("expected only transaction %d of type %s, got %+v", want, transactionType, page)
+		}
+	}
+}
+
+func testTransferFees(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "0")
+	createAccount(t, repo, 99, "0")
+
+	fee := &domain.Fee{AccountID: 99, Amount: amount("1.5"), Breakdown: []domain.FeeCharge{{RuleID: 1, Rule: "flat", Amount: amount("1.5")}}}
+	if _, err := repo.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("99"), Fee: fee}); !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance when the fee does not fit, got %v", err)
+	}
+	expectBalance(t, repo, 1, "100")
+	expectBalance(t, repo, 99, "0")
+
+	created, err := repo.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("40"), Fee: fee})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if created.Fee == nil || created.Fee.TransactionID == 0 || created.Fee.TransactionID == created.ID || len(created.Fee.Breakdown) != 1 {
+		t.Fatalf("expected the fee to be posted as its own transaction, got %+v", created.Fee)
+	}
+	expectBalance(t, repo, 1, "58.5")
+	expectBalance(t, repo, 2, "40")
+	expectBalance(t, repo, 99, "1.5")
+
+	charged, err := repo.GetTransaction(ctx, created.Fee.TransactionID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if charged.Type != domain.TransactionTypeFee || charged.SourceAccountID != 1 || charged.DestinationAccountID != 99 || !charged.Amount.Equal(amount("1.5")) {
+		t.Fatalf("unexpected fee transaction %+v", charged)
+	}
+	page, err := repo.ListTransactions(ctx, 1, domain.TransactionFilter{Limit: 10, Type: domain.TransactionTypeFee})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(page) != 1 || page[0].ID != charged.ID {
+		t.Fatalf("expected only fee transaction %d, got %+v", charged.ID, page)
+	}
+
+	entries, err := repo.ListAuditEntries(ctx, domain.AuditFilter{Operation: domain.AuditOperationFee, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 1 || entries[0].TransactionID == nil || *entries[0].TransactionID != charged.ID {
+		t.Fatalf("expected one %s audit entry for transaction %d, got %+v", domain.AuditOperationFee, charged.ID, entries)
+	}
+}
+
+func testFeeRules(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	created, err := repo.CreateAccount(ctx, domain.Account{ID: 1, Balance: amount("1")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if created.Tier != domain.AccountTierStandard {
+		t.Fatalf("expected default tier %s, got %q", domain.AccountTierStandard, created.Tier)
+	}
+	if _, err := repo.CreateAccount(ctx, domain.Account{ID: 2, Balance: amount("1"), Tier: "premium"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if account, err := repo.GetAccount(ctx, "2"); err != nil || account.Tier != "premium" {
+		t.Fatalf("expected tier premium, got %+v (%v)", account, err)
+	}
+
+	upTo := amount("100")
+	percent := amount("0.25")
+	rule, err := repo.CreateFeeRule(ctx, domain.FeeRule{Name: "tiered", Kind: domain.FeeKindTiered, AccountTier: "premium", Tiers: []domain.FeeTier{
+		{UpTo: &upTo, Amount: amount("1")},
+		{Percent: amount("0.5")},
+	}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rule.ID == 0 || rule.CreatedAt.IsZero() {
+		t.Fatalf("unexpected created rule %+v", rule)
+	}
+	flat := amount("2")
+	second, err := repo.CreateFeeRule(ctx, domain.FeeRule{Name: "flat", Kind: domain.FeeKindFlat, Amount: &flat})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	rule.Name = "percentage"
+	rule.Kind = domain.FeeKindPercentage
+	rule.Tiers = nil
+	rule.Percent = &percent
+	if _, err := repo.UpdateFeeRule(ctx, *rule); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	rules, err := repo.ListFeeRules(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(rules) != 2 || rules[0].ID != rule.ID || rules[0].Kind != domain.FeeKindPercentage || rules[0].Percent == nil ||
+		!rules[0].Percent.Equal(percent) || len(rules[0].Tiers) != 0 || rules[0].AccountTier != "premium" || rules[1].Amount == nil || !rules[1].Amount.Equal(flat) {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	if err := repo.DeleteFeeRule(ctx, second.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := repo.DeleteFeeRule(ctx, second.ID); !errors.Is(err, repository.ErrFeeRuleNotFound) {
+		t.Fatalf("expected ErrFeeRuleNotFound, got %v", err)
+	}
+	if _, err := repo.UpdateFeeRule(ctx, *second); !errors.Is(err, repository.ErrFeeRuleNotFound) {
+		t.Fatalf("expected ErrFeeRuleNotFound, got %v", err)
+	}
+}
+
+func testInterestRates(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	if _, err := repo.SetInterestRate(ctx, 1, amount("2.5")); !errors.Is(err, repository.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "100")
+
+	if _, err := repo.SetInterestRate(ctx, 2, amount("1")); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := repo.SetInterestRate(ctx, 1, amount("2.5")); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	updated, err := repo.SetInterestRate(ctx, 1, amount("3.125"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if updated.AccountID != 1 || !updated.AnnualRate.Equal(amount("3.125")) || updated.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected rate %+v", updated)
+	}
+
+	rates, err := repo.ListInterestRates(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(rates) != 2 || rates[0].AccountID != 1 || !rates[0].AnnualRate.Equal(amount("3.125")) || rates[1].AccountID != 2 {
+		t.Fatalf("unexpected rates %+v", rates)
+	}
+
+	entries, err := repo.ListAuditEntries(ctx, domain.AuditFilter{Operation: domain.AuditOperationInterestRate, AccountID: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 2 || len(entries[0].Before) == 0 || len(entries[1].Before) != 0 {
+		t.Fatalf("expected an update and a creation in the audit log, got %+v", entries)
+	}
+}
+
+func balanceAt(t *testing.T, repo repository.Repository, id int64, at time.Time) decimal.Decimal {
+	t.Helper()
+	balance, err := repo.BalanceAt(context.Background(), id, at)
+	if err != nil {
+		t.Fatalf("balance of account %d at %s: %v", id, at, err)
+	}
+	return balance
+}
+
+func testBalanceAt(t *testing.T, repo repository.Repository) {
+	if _, err := repo.BalanceAt(context.Background(), 1, time.Now()); !errors.Is(err, repository.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+	beforeCreation := time.Now().Add(-time.Hour)
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "0")
+
+	// The pauses keep the marks strictly between the timestamps the backends
+	// store, which may be truncated to milliseconds.
+	time.Sleep(10 * time.Millisecond)
+	opened := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	transfer(t, repo, 1, 2, "30")
+	time.Sleep(10 * time.Millisecond)
+	afterFirst := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	transfer(t, repo, 2, 1, "5.5")
+
+	for _, testCase := range []struct {
+		id   int64
+		at   time.Time
+		want string
+	}{
+		{1, beforeCreation, "0"},
+		{1, opened, "100"},
+		{2, opened, "0"},
+		{1, afterFirst, "70"},
+		{2, afterFirst, "30"},
+		{1, time.Now().Add(time.Hour), "75.5"},
+		{2, time.Now().Add(time.Hour), "24.5"},
+	} {
+		if got := balanceAt(t, repo, testCase.id, testCase.at); !got.Equal(amount(testCase.want)) {
+			t.Fatalf("expected account %d balance %s at %s, got %s", testCase.id, testCase.want, testCase.at, got)
+		}
+	}
+}
+
+func testBalanceSnapshots(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "0")
+	createAccount(t, repo, 3, "50")
+
+	// The pauses keep the marks strictly between the timestamps the backends
+	// store, which may be truncated to milliseconds.
+	time.Sleep(10 * time.Millisecond)
+	transfer(t, repo, 1, 2, "30")
+	time.Sleep(10 * time.Millisecond)
+	first := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	transfer(t, repo, 2, 1, "5.5")
+	time.Sleep(10 * time.Millisecond)
+	second := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	transfer(t, repo, 1, 2, "10")
+
+	takeSnapshots := func(at time.Time, want int) {
+		t.Helper()
+		taken, err := repo.TakeBalanceSnapshots(ctx, at)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if taken != want {
+			t.Fatalf("expected %d snapshots at %s, got %d", want, at, taken)
+		}
+	}
+	checkBalances := func(at time.Time, want map[int64]string) {
+		t.Helper()
+		balances, err := repo.BalancesAt(ctx, []int64{1, 2, 3, 4}, at)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(balances) != len(want) {
+			t.Fatalf("expected balances of %d accounts at %s, got %v", len(want), at, balances)
+		}
+		for id, balance := range want {
+			if got, ok := balances[id]; !ok || !got.Equal(amount(balance)) {
+				t.Fatalf("expected account %d balance %s at %s, got %v", id, balance, at, balances)
+			}
+		}
+	}
+
+	// Account 3 has no transactions, so its opening balance needs no snapshot.
+	takeSnapshots(first, 2)
+	takeSnapshots(first, 0)
+	checkBalances(first, map[int64]string{1: "70", 2: "30", 3: "50"})
+	checkBalances(second, map[int64]string{1: "75.5", 2: "24.5", 3: "50"})
+
+	takeSnapshots(second, 2)
+	checkBalances(first, map[int64]string{1: "70", 2: "30", 3: "50"})
+	checkBalances(second, map[int64]string{1: "75.5", 2: "24.5", 3: "50"})
+	checkBalances(time.Now().Add(time.Hour), map[int64]string{1: "65.5", 2: "34.5", 3: "50"})
+	checkBalances(first.Add(-time.Hour), map[int64]string{1: "0", 2: "0", 3: "0"})
+
+	if _, err := repo.BalanceAt(ctx, 4, time.Now()); !errors.Is(err, repository.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+func testInterestAccruals(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	createAccount(t, repo, 1, "1000")
+	createAccount(t, repo, 9, "0")
+
+	day := func(value string) time.Time {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			t.Fatalf("parse %s: %v", value, err)
+		}
+		return parsed
+	}
+	accrual := func(date, value string) domain.InterestAccrual {
+		return domain.InterestAccrual{
+			AccountID:  1,
+			Date:       day(date),
+			Balance:    amount("1000"),
+			AnnualRate: amount("5"),
+			DayCount:   domain.DayCountActual365,
+			Amount:     amount(value),
+		}
+	}
+
+	recorded, err := repo.RecordInterestAccruals(ctx, []domain.InterestAccrual{accrual("2026-01-30", "0.1369863014"), accrual("2026-01-31", "0.1369863014")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if recorded != 2 {
+		t.Fatalf("expected 2 accruals recorded, got %d", recorded)
+	}
+	recorded, err = repo.RecordInterestAccruals(ctx, []domain.InterestAccrual{accrual("2026-01-31", "99"), accrual("2026-02-01", "0.1369863014")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if recorded != 1 {
+		t.Fatalf("expected only the new accrual to be recorded, got %d", recorded)
+	}
+
+	posted, err := repo.PostInterest(ctx, 1, 9, day("2026-01-31"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if posted == nil || posted.Type != domain.TransactionTypeInterest || posted.SourceAccountID != 9 || posted.DestinationAccountID != 1 || !posted.Amount.Equal(amount("0.274")) {
+		t.Fatalf("unexpected interest transaction %+v", posted)
+	}
+	expectBalance(t, repo, 1, "1000.274")
+	expectBalance(t, repo, 9, "-0.274")
+
+	again, err := repo.PostInterest(ctx, 1, 9, day("2026-01-31"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if again != nil {
+		t.Fatalf("expected nothing left to post, got %+v", again)
+	}
+	expectBalance(t, repo, 1, "1000.274")
+
+	accruals, err := repo.ListInterestAccruals(ctx, domain.InterestAccrualFilter{AccountID: 1, From: day("2026-01-01"), To: day("2026-02-28")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(accruals) != 3 {
+		t.Fatalf("expected 3 accruals, got %+v", accruals)
+	}
+	for i, want := range []string{"2026-01-30", "2026-01-31", "2026-02-01"} {
+		if !accruals[i].Date.Equal(day(want)) || !accruals[i].Amount.Equal(amount("0.1369863014")) || accruals[i].DayCount != domain.DayCountActual365 {
+			t.Fatalf("unexpected accrual %d: %+v", i, accruals[i])
+		}
+	}
+	if accruals[0].TransactionID == nil || *accruals[0].TransactionID != posted.ID || accruals[1].TransactionID == nil || accruals[2].TransactionID != nil {
+		t.Fatalf("expected only the January accruals to be posted, got %+v", accruals)
+	}
+	if accruals, err := repo.ListInterestAccruals(ctx, domain.InterestAccrualFilter{From: day("2026-02-01"), To: day("2026-02-01")}); err != nil || len(accruals) != 1 {
+		t.Fatalf("expected one accrual on 2026-02-01, got %+v (%v)", accruals, err)
+	}
+
+	entries, err := repo.ListAuditEntries(ctx, domain.AuditFilter{Operation: domain.AuditOperationInterest, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 1 || entries[0].TransactionID == nil || *entries[0].TransactionID != posted.ID {
+		t.Fatalf("expected one %s audit entry for transaction %d, got %+v", domain.AuditOperationInterest, posted.ID, entries)
+	}
+
+	// Postings are rounded, and what each one pays short of or over its
+	// accruals is carried to the next, so nothing accrued is lost.
+	createAccount(t, repo, 2, "0")
+	for i, want := range []string{"0.0001", "0.0002", "0.0001"} {
+		date := day("2026-03-01").AddDate(0, 0, i)
+		residual := accrual("2026-03-01", "0.00014")
+		residual.AccountID, residual.Date = 2, date
+		if _, err := repo.RecordInterestAccruals(ctx, []domain.InterestAccrual{residual}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		posted, err := repo.PostInterest(ctx, 2, 9, date)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if posted == nil || !posted.Amount.Equal(amount(want)) {
+			t.Fatalf("expected posting %d of %s, got %+v", i, want, posted)
+		}
+	}
+	expectBalance(t, repo, 2, "0.0004")
+}
+
+func testConcurrentOpposingTransfers(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "1000")
+	createAccount(t, repo, 2, "1000")
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := range workers {
+		from, to := int64(1), int64(2)
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.TransferMoney(context.Background(), domain.Transaction{SourceAccountID: from, DestinationAccountID: to, Amount: amount("10")})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected every transfer to succeed, got %v", err)
+		}
+	}
+	expectBalance(t, repo, 1, "1000")
+	expectBalance(t, repo, 2, "1000")
+}
+
+func testConcurrentOverdraw(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "50")
+	createAccount(t, repo, 2, "0")
+
+	const workers = 10
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.TransferMoney(context.Background(), domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("10")})
+			if err != nil && !errors.Is(err, repository.ErrInsufficientBalance) {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 5 {
+		t.Fatalf("expected exactly 5 transfers to succeed, got %d", succeeded)
+	}
+	expectBalance(t, repo, 1, "0")
+	expectBalance(t, repo, 2, "50")
+}
+
+func testConcurrentReversals(t *testing.T, repo repository.Repository) {
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "100")
+	original := transfer(t, repo, 1, 2, "10")
+
+	const workers = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.ReverseTransaction(context.Background(), original.ID)
+			if err != nil && !errors.Is(err, repository.ErrAlreadyReversed) {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one reversal, got %d", succeeded)
+	}
+	expectBalance(t, repo, 1, "100")
+	expectBalance(t, repo, 2, "100")
+}
+
+func testLedgerSnapshot(t *testing.T, repo repository.Repository) {
+	ledger, ok := repo.(repository.Ledger)
+	if !ok {
+		t.Skip("repository does not implement repository.Ledger")
+	}
+	ctx := context.Background()
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "50.25")
+	createAccount(t, repo, 3, "0")
+	first := transfer(t, repo, 1, 2, "10.5")
+	transfer(t, repo, 2, 3, "20")
+	if _, err := repo.ReverseTransaction(ctx, first.ID); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+
+	snapshot, err := ledger.LedgerSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("ledger snapshot: %v", err)
+	}
+	if snapshot.TransactionCount != 3 {
+		t.Fatalf("expected 3 transactions, got %d", snapshot.TransactionCount)
+	}
+	if len(snapshot.OrphanTransactions) != 0 {
+		t.Fatalf("expected no orphan transactions, got %+v", snapshot.OrphanTransactions)
+	}
+
+	want := []struct {
+		id                                int64
+		opening, balance, credits, debits string
+	}{
+		{1, "100", "100", "10.5", "10.5"},
+		{2, "50.25", "30.25", "10.5", "30.5"},
+		{3, "0", "20", "20", "0"},
+	}
+	if len(snapshot.Accounts) != len(want) {
+		t.Fatalf("expected %d accounts, got %+v", len(want), snapshot.Accounts)
+	}
+	for i, expected := range want {
+		got := snapshot.Accounts[i]
+		if got.ID != expected.id ||
+			!got.OpeningBalance.Equal(amount(expected.opening)) ||
+			!got.Balance.Equal(amount(expected.balance)) ||
+			!got.Credits.Equal(amount(expected.credits)) ||
+			!got.Debits.Equal(amount(expected.debits)) {
+			t.Fatalf("expected account %+v, got %+v", expected, got)
+		}
+	}
+}
+
+// auditContext attaches caller metadata with a request ID unique to the test,
+// since some backends keep their audit log across test cases.
+func auditContext(t *testing.T) (context.Context, string) {
+	requestID := t.Name() + "/" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	return audit.WithMetadata(context.Background(), audit.Metadata{Actor: "alice", RequestID: requestID, ClientIP: "203.0.113.7"}), requestID
+}
+
+func testAuditLog(t *testing.T, repo repository.Repository) {
+	ctx, requestID := auditContext(t)
+	for _, account := range []domain.Account{{ID: 1, Balance: amount("100")}, {ID: 2, Balance: amount("5")}} {
+		if _, err := repo.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account %d: %v", account.ID, err)
+		}
+	}
+	created, err := repo.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("40.5")})
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if _, err := repo.ReverseTransaction(ctx, created.ID); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if _, err := repo.SetAccountStatus(ctx, 2, domain.AccountStatusFrozen, 0); err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+
+	entries, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	wantOperations := []string{
+		domain.AuditOperationAccountFreeze,
+		domain.AuditOperationTransactionReverse,
+		domain.AuditOperationTransfer,
+		domain.AuditOperationAccountCreate,
+		domain.AuditOperationAccountCreate,
+	}
+	if len(entries) != len(wantOperations) {
+		t.Fatalf("expected %d audit entries, got %+v", len(wantOperations), entries)
+	}
+	for i, entry := range entries {
+		if entry.Operation != wantOperations[i] {
+			t.Fatalf("expected entry %d to be %s, got %s", i, wantOperations[i], entry.Operation)
+		}
+		if entry.Actor != "alice" || entry.ClientIP != "203.0.113.7" || entry.RequestID != requestID {
+			t.Fatalf("expected caller metadata on entry %d, got %+v", i, entry)
+		}
+		if entry.OccurredAt.IsZero() {
+			t.Fatalf("expected occurred_at on entry %d", i)
+		}
+		if i > 0 && entry.ID >= entries[i-1].ID {
+			t.Fatalf("expected entries newest first, got IDs %d then %d", entries[i-1].ID, entry.ID)
+		}
+	}
+
+	transferEntry := entries[2]
+	if transferEntry.AccountID != 1 || transferEntry.CounterpartyAccountID == nil || *transferEntry.CounterpartyAccountID != 2 ||
+		transferEntry.TransactionID == nil || *transferEntry.TransactionID != created.ID {
+		t.Fatalf("expected transfer entry for 1 -> 2 and transaction %d, got %+v", created.ID, transferEntry)
+	}
+	var before, after struct {
+		Source      domain.Account      `json:"source"`
+		Destination domain.Account      `json:"destination"`
+		Transaction *domain.Transaction `json:"transaction"`
+	}
+	if err := json.Unmarshal(transferEntry.Before, &before); err != nil {
+		t.Fatalf("decode before: %v", err)
+	}
+	if err := json.Unmarshal(transferEntry.After, &after); err != nil {
+		t.Fatalf("decode after: %v", err)
+	}
+	if !before.Source.Balance.Equal(amount("100")) || !before.Destination.Balance.Equal(amount("5")) || before.Transaction != nil {
+		t.Fatalf("expected balances 100 and 5 before the transfer, got %+v", before)
+	}
+	if !after.Source.Balance.Equal(amount("59.5")) || !after.Destination.Balance.Equal(amount("45.5")) || after.Transaction == nil || after.Transaction.ID != created.ID {
+		t.Fatalf("expected balances 59.5 and 45.5 and the transaction after the transfer, got %+v", after)
+	}
+
+	createEntry := entries[4]
+	if len(createEntry.Before) != 0 || len(createEntry.After) == 0 {
+		t.Fatalf("expected only an after state on account creation, got %+v", createEntry)
+	}
+
+	freezeEntry := entries[0]
+	var frozenBefore, frozenAfter domain.Account
+	if err := json.Unmarshal(freezeEntry.Before, &frozenBefore); err != nil {
+		t.Fatalf("decode before: %v", err)
+	}
+	if err := json.Unmarshal(freezeEntry.After, &frozenAfter); err != nil {
+		t.Fatalf("decode after: %v", err)
+	}
+	if frozenBefore.Status != domain.AccountStatusActive || frozenAfter.Status != domain.AccountStatusFrozen {
+		t.Fatalf("expected status active -> frozen, got %s -> %s", frozenBefore.Status, frozenAfter.Status)
+	}
+
+	byAccount, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, AccountID: 2, Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(byAccount) != 4 {
+		t.Fatalf("expected 4 entries touching account 2, got %d", len(byAccount))
+	}
+	byOperation, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, Operation: domain.AuditOperationAccountCreate, Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(byOperation) != 2 {
+		t.Fatalf("expected 2 account creations, got %d", len(byOperation))
+	}
+	page, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, BeforeID: entries[1].ID, Limit: 2})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != entries[2].ID || page[1].ID != entries[3].ID {
+		t.Fatalf("expected the page after entry %d to hold entries %d and %d, got %+v", entries[1].ID, entries[2].ID, entries[3].ID, page)
+	}
+	inFuture, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, Since: time.Now().Add(time.Hour), Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(inFuture) != 0 {
+		t.Fatalf("expected no entries in the future, got %d", len(inFuture))
+	}
+	inPast, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, Since: time.Now().Add(-time.Hour), Until: time.Now().Add(time.Hour), Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(inPast) != len(entries) {
+		t.Fatalf("expected %d entries in the last hour, got %d", len(entries), len(inPast))
+	}
+}
+
+func testAuditSkipsFailedMutations(t *testing.T, repo repository.Repository) {
+	ctx, requestID := auditContext(t)
+	createAccount(t, repo, 1, "10")
+	createAccount(t, repo, 2, "0")
+	if _, err := repo.CreateAccount(ctx, domain.Account{ID: 1, Balance: amount("1")}); !errors.Is(err, repository.ErrAccountExists) {
+		t.Fatalf("expected ErrAccountExists, got %v", err)
+	}
+	if _, err := repo.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("11")}); !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+	}
+	if _, err := repo.SetAccountStatus(ctx, 99, domain.AccountStatusFrozen, 0); !errors.Is(err, repository.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+
+	entries, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected failed mutations to leave no audit entries, got %+v", entries)
+	}
+}
+
+func testRiskDecisions(t *testing.T, repo repository.Repository) {
+	ctx, requestID := auditContext(t)
+	decision := domain.RiskDecision{
+		SourceAccountID:      1,
+		DestinationAccountID: 2,
+		Amount:               amount("5000"),
+		Decision:             domain.RiskDeny,
+		Reasons:              []domain.RiskReason{{Rule: "large", Action: domain.RiskDeny, Reason: "amount 5000 exceeds 1000"}},
+	}
+	if err := repo.RecordRiskDecision(ctx, decision); err != nil {
+		t.Fatalf("record risk decision: %v", err)
+	}
+
+	entries, err := repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, Operation: domain.AuditOperationRiskDecision, Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one risk decision entry, got %+v", entries)
+	}
+	entry := entries[0]
+	if entry.AccountID != 1 || entry.CounterpartyAccountID == nil || *entry.CounterpartyAccountID != 2 || entry.TransactionID != nil || entry.Actor != "alice" {
+		t.Fatalf("unexpected risk decision entry: %+v", entry)
+	}
+	var recorded domain.RiskDecision
+	if err := json.Unmarshal(entry.After, &recorded); err != nil {
+		t.Fatalf("decode risk decision: %v", err)
+	}
+	if recorded.Decision != domain.RiskDeny || !recorded.Amount.Equal(decision.Amount) || len(recorded.Reasons) != 1 || recorded.Reasons[0] != decision.Reasons[0] {
+		t.Fatalf("expected the recorded decision %+v, got %+v", decision, recorded)
+	}
+
+	// Any other decision is recorded by the transfer it was made on, naming
+	// its transaction, and not at all when the transfer fails.
+	createAccount(t, repo, 1, "100")
+	createAccount(t, repo, 2, "0")
+	review := domain.RiskDecision{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("500"), Decision: domain.RiskReview}
+	if _, err := repo.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("500"), Risk: &review}); !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+	}
+	review.Amount = amount("40")
+	created, err := repo.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("40"), Risk: &review})
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	entries, err = repo.ListAuditEntries(context.Background(), domain.AuditFilter{RequestID: requestID, Operation: domain.AuditOperationRiskDecision, Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].TransactionID == nil || *entries[0].TransactionID != created.ID {
+		t.Fatalf("expected the review decision to name transaction %d, got %+v", created.ID, entries)
+	}
+	if err := json.Unmarshal(entries[0].After, &recorded); err != nil {
+		t.Fatalf("decode risk decision: %v", err)
+	}
+	if recorded.Decision != domain.RiskReview || !recorded.Amount.Equal(amount("40")) {
+		t.Fatalf("expected the recorded decision %+v, got %+v", review, recorded)
+	}
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tareqpi/transfer-system/internal/audit"
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	retryReasonDeadlock      = "deadlock"
+	retryReasonSerialization = "serialization_failure"
+	retryReasonConflict      = "conflict"
+)
+
+// RetryPolicy bounds how often a transaction aborted by a transient conflict
+// is run again. Attempts of one or less disable retries. The delay before
+// retry n is drawn uniformly from [0, min(MaxDelay, BaseDelay*2^(n-1))].
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// txRunner runs a database transaction and retries it from the start when it
+// fails with a deadlock or serialization failure.
+type txRunner struct {
+	policy RetryPolicy
+	jitter func(time.Duration) time.Duration
+	sleep  func(context.Context, time.Duration) error
+}
+
+func newTxRunner(policy RetryPolicy) txRunner {
+	return txRunner{policy: policy, jitter: fullJitter, sleep: sleepContext}
+}
+
+// run calls fn until it succeeds, fails with an error that is not transient or
+// the budget is spent. A retry is skipped when its delay would outlast the
+// context deadline. When the last attempt failed with a transient error, the
+// returned error wraps ErrTransactionConflict.
+func (r txRunner) run(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		reason, retryable := retryReason(err)
+		if !retryable {
+			return err
+		}
+
+		if attempt >= r.policy.MaxAttempts {
+			return r.giveUp(ctx, operation, attempt, err)
+		}
+		delay := r.jitter(r.backoff(attempt))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return r.giveUp(ctx, operation, attempt, err)
+		}
+
+		metrics.DBTransactionRetriesTotal.WithLabelValues(operation, reason).Inc()
+		logger.L().Warn("retrying database transaction",
+			zap.String("operation", operation),
+			zap.String("reason", reason),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.String("request_id", audit.FromContext(ctx).RequestID),
+			zap.Error(err),
+		)
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// giveUp stops retrying. Retries only count as exhausted when there were any,
+// not when retries are disabled or the deadline left no room for one.
+func (r txRunner) giveUp(ctx context.Context, operation string, attempts int, err error) error {
+	if attempts > 1 {
+		metrics.DBTransactionRetriesExhaustedTotal.WithLabelValues(operation).Inc()
+		logger.L().Warn("database transaction retries exhausted",
+			zap.String("operation", operation),
+			zap.Int("attempts", attempts),
+			zap.String("request_id", audit.FromContext(ctx).RequestID),
+			zap.Error(err),
+		)
+	}
+	if errors.Is(err, ErrTransactionConflict) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTransactionConflict, err)
+}
+
+func (r txRunner) backoff(attempt int) time.Duration {
+	delay := r.policy.BaseDelay
+	for i := 1; i < attempt && delay < r.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, r.policy.MaxDelay)
+}
+
+// retryReason reports whether err is a transient conflict that may succeed
+// when the whole transaction is run again.
+func retryReason(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.DeadlockDetected:
+			return retryReasonDeadlock, true
+		case pgerrcode.SerializationFailure:
+			return retryReasonSerialization, true
+		}
+	}
+	if errors.Is(err, ErrTransactionConflict) {
+		return retryReasonConflict, true
+	}
+	return "", false
+}
+
+func fullJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay + 1)
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+)
+
+func testRunner(policy RetryPolicy) (txRunner, *[]time.Duration) {
+	var delays []time.Duration
+	return txRunner{
+		policy: policy,
+		jitter: func(delay time.Duration) time.Duration { return delay },
+		sleep: func(_ context.Context, delay time.Duration) error {
+			delays = append(delays, delay)
+			return nil
+		},
+	}, &delays
+}
+
+func TestTxRunner_RetriesTransientErrors(t *testing.T) {
+	runner, delays := testRunner(RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 25 * time.Millisecond})
+
+	failures := []error{
+		&pgconn.PgError{Code: pgerrcode.DeadlockDetected},
+		&pgconn.PgError{Code: pgerrcode.SerializationFailure},
+		ErrTransactionConflict,
+	}
+	attempts := 0
+	err := runner.run(context.Background(), "transfer_money", func(context.Context) error {
+		attempts++
+		if attempts <= len(failures) {
+			return failures[attempts-1]
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 4 {
+		t.Fatalf("expected 4 attempts, got %d", attempts)
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}
+	if len(*delays) != len(want) {
+		t.Fatalf("expected delays %v, got %v", want, *delays)
+	}
+	for i := range want {
+		if (*delays)[i] != want[i] {
+			t.Fatalf("expected delays %v, got %v", want, *delays)
+		}
+	}
+}
+
+func TestTxRunner_DoesNotRetryOtherErrors(t *testing.T) {
+	runner, delays := testRunner(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	for _, failure := range []error{ErrInsufficientBalance, &pgconn.PgError{Code: pgerrcode.UniqueViolation}} {
+		attempts := 0
+		err := runner.run(context.Background(), "transfer_money", func(context.Context) error {
+			attempts++
+			return failure
+		})
+		if !errors.Is(err, failure) || attempts != 1 {
+			t.Fatalf("expected %v after 1 attempt, got %v after %d", failure, err, attempts)
+		}
+	}
+	if len(*delays) != 0 {
+		t.Fatalf("expected no delays, got %v", *delays)
+	}
+}
+
+func TestTxRunner_GivesUpWhenBudgetIsSpent(t *testing.T) {
+	runner, delays := testRunner(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	deadlock := &pgconn.PgError{Code: pgerrcode.DeadlockDetected}
+	attempts := 0
+	err := runner.run(context.Background(), "transfer_money", func(context.Context) error {
+		attempts++
+		return deadlock
+	})
+	if !errors.Is(err, ErrTransactionConflict) {
+		t.Fatalf("expected ErrTransactionConflict, got %v", err)
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgerrcode.DeadlockDetected {
+		t.Fatalf("expected the deadlock to stay in the chain, got %v", err)
+	}
+	if attempts != 3 || len(*delays) != 2 {
+		t.Fatalf("expected 3 attempts and 2 delays, got %d and %d", attempts, len(*delays))
+	}
+}
+
+func TestTxRunner_CountsExhaustedRetriesOnlyAfterRetrying(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: pgerrcode.DeadlockDetected}
+	for _, testCase := range []struct {
+		operation   string
+		maxAttempts int
+		exhausted   float64
+	}{
+		{"exhausted_without_retries", 1, 0},
+		{"exhausted_after_retries", 2, 1},
+	} {
+		runner, _ := testRunner(RetryPolicy{MaxAttempts: testCase.maxAttempts, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+		_ = runner.run(context.Background(), testCase.operation, func(context.Context) error { return deadlock })
+		if got := testutil.ToFloat64(metrics.DBTransactionRetriesExhaustedTotal.WithLabelValues(testCase.operation)); got != testCase.exhausted {
+			t.Fatalf("%s: expected %v exhausted retries, got %v", testCase.operation, testCase.exhausted, got)
+		}
+	}
+}
+
+func TestTxRunner_GivesUpBeforeTheDeadline(t *testing.T) {
+	runner, delays := testRunner(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	attempts := 0
+	err := runner.run(ctx, "transfer_money", func(context.Context) error {
+		attempts++
+		return &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+	})
+	if !errors.Is(err, ErrTransactionConflict) {
+		t.Fatalf("expected ErrTransactionConflict, got %v", err)
+	}
+	if attempts != 1 || len(*delays) != 0 {
+		t.Fatalf("expected to give up after 1 attempt without waiting, got %d attempts and delays %v", attempts, *delays)
+	}
+}
+
+func TestFullJitter(t *testing.T) {
+	for range 100 {
+		if delay := fullJitter(10 * time.Millisecond); delay < 0 || delay > 10*time.Millisecond {
+			t.Fatalf("expected a delay within [0, 10ms], got %v", delay)
+		}
+	}
+	if delay := fullJitter(0); delay != 0 {
+		t.Fatalf("expected no delay, got %v", delay)
+	}
+}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// SetAccountShards splits the balance of an account evenly across shards
+// rows, or gathers it back into the account row when shards is 0. The account
+// row is locked for update, so transfers touching the account wait until the
+// balance has moved.
+func (r *PGRepository) SetAccountShards(ctx context.Context, id int64, shards int, expectedVersion int64) (*domain.Account, error) {
+	var after domain.Account
+	err := r.inTx(ctx, "set_account_shards", pgx.TxOptions{}, func(tx pgx.Tx) error {
+		before, err := scanAccount(tx.QueryRow(ctx, `SELECT `+pgAccountColumns+` FROM accounts.accounts a WHERE a.id = $1 FOR UPDATE OF a`, id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrAccountNotFound
+			}
+			return err
+		}
+		if expectedVersion != 0 && before.Version != expectedVersion {
+			return ErrVersionMismatch
+		}
+
+		if err := redistributeShards(ctx, tx, id, shards); err != nil {
+			return err
+		}
+		after = *before
+		after.Shards = shards
+		if err := tx.QueryRow(ctx, `UPDATE accounts.accounts SET version = version + 1 WHERE id = $1 RETURNING version`, id).Scan(&after.Version); err != nil {
+			return err
+		}
+		return insertAuditEntry(ctx, tx, auditRecord{operation: domain.AuditOperationAccountShards, accountID: id, before: before, after: &after})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// ConsolidateShards evens out the shards of every sharded account. Credits
+// land on random shards while debits drain the largest ones, so over time the
+// money spreads unevenly and debits have to lock more shards to cover their
+// amount. Balances do not change, so account versions are left alone. It
+// returns the number of accounts consolidated.
+func (r *PGRepository) ConsolidateShards(ctx context.Context) (int, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id FROM accounts.accounts WHERE shards > 0 ORDER BY id`)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, err
+	}
+
+	consolidated := 0
+	for _, id := range ids {
+		err := r.inTx(ctx, "consolidate_shards", pgx.TxOptions{}, func(tx pgx.Tx) error {
+			var shards int
+			if err := tx.QueryRow(ctx, `SELECT shards FROM accounts.accounts WHERE id = $1 FOR UPDATE`, id).Scan(&shards); err != nil {
+				return err
+			}
+			if shards == 0 {
+				return nil
+			}
+			return redistributeShards(ctx, tx, id, shards)
+		})
+		if err != nil {
+			return consolidated, fmt.Errorf("consolidate shards of account %d: %w", id, err)
+		}
+		consolidated++
+	}
+	return consolidated, nil
+}
+
+// ShardConsolidationWorker returns a worker that consolidates shards every
+// interval until its context is canceled.
+func (r *PGRepository) ShardConsolidationWorker(interval time.Duration) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			consolidated, err := r.ConsolidateShards(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.L().Error("shard consolidation failed", zap.Error(err))
+				}
+				continue
+			}
+			if consolidated > 0 {
+				logger.L().Debug("shards consolidated", zap.Int("accounts", consolidated))
+			}
+		}
+	}
+}
+
+// redistributeShards moves the whole balance of an account into shards rows
+// of equal size, or into the account row when shards is 0. The caller must
+// hold the account row for update, which keeps transfers off its shards.
+func redistributeShards(ctx context.Context, tx pgx.Tx, accountID int64, shards int) error {
+	total, err := shardedBalance(ctx, tx, accountID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM accounts.account_shards WHERE account_id = $1 AND shard >= $2`, accountID, shards); err != nil {
+		return err
+	}
+	if shards == 0 {
+		_, err := tx.Exec(ctx, `UPDATE accounts.accounts SET balance = $2, shards = 0 WHERE id = $1`, accountID, total)
+		return err
+	}
+
+	// Every shard gets an equal share rounded down to the stored precision;
+	// the first one also takes what the rounding left over.
+	count := decimal.NewFromInt(int64(shards))
+	share := total.Div(count).Truncate(4)
+	first := total.Sub(share.Mul(count.Sub(decimal.NewFromInt(1))))
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO accounts.account_shards (account_id, shard, balance)
+        SELECT $1::BIGINT, shard, CASE WHEN shard = 0 THEN $3::NUMERIC ELSE $2::NUMERIC END
+        FROM generate_series(0, $4::INTEGER - 1) AS shard
+        ON CONFLICT (account_id, shard) DO UPDATE SET balance = EXCLUDED.balance
+    `, accountID, share, first, shards); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE accounts.accounts SET balance = 0, shards = $2 WHERE id = $1`, accountID, shards)
+	return err
+}
+
+// shardCounts reads, without locking, how many shards each account has, so
+// that transferInTx can choose how to lock it. Missing accounts are absent.
+func shardCounts(ctx context.Context, tx pgx.Tx, ids []int64) (map[int64]int, error) {
+	rows, err := tx.Query(ctx, `SELECT id, shards FROM accounts.accounts WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shards := make(map[int64]int, len(ids))
+	for rows.Next() {
+		var (
+			id    int64
+			count int
+		)
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		shards[id] = count
+	}
+	return shards, rows.Err()
+}
+
+type shardDebit struct {
+	shard  int32
+	amount decimal.Decimal
+}
+
+// debitShards takes amount from the shards of an account. It locks shards
+// one at a time, largest first, skipping those other transactions hold, until
+// they cover the amount. Only when the free shards fall short does it wait
+// for all of them, which also tells a real shortfall apart from busy shards.
+// The shards locked before falling short are released first by rolling back
+// to a savepoint: two debits each holding a shard the other then waits for
+// would deadlock, while waiting in shard order cannot.
+func debitShards(ctx context.Context, tx pgx.Tx, accountID int64, amount decimal.Decimal) error {
+	var (
+		debits  []shardDebit
+		covered decimal.Decimal
+		taken   = []int32{}
+	)
+	if _, err := tx.Exec(ctx, `SAVEPOINT debit_shards`); err != nil {
+		return err
+	}
+	for covered.LessThan(amount) {
+		var (
+			shard   int32
+			balance decimal.Decimal
+		)
+		err := tx.QueryRow(ctx, `
+            SELECT shard, balance
+            FROM accounts.account_shards
+            WHERE account_id = $1 AND balance > 0 AND NOT (shard = ANY($2))
+            ORDER BY balance DESC
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        `, accountID, taken).Scan(&shard, &balance)
+		if errors.Is(err, pgx.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		take := decimal.Min(balance, amount.Sub(covered))
+		taken = append(taken, shard)
+		debits = append(debits, shardDebit{shard: shard, amount: take})
+		covered = covered.Add(take)
+	}
+
+	if covered.LessThan(amount) {
+		metrics.DBShardDebitFallbacksTotal.Inc()
+		if _, err := tx.Exec(ctx, `ROLLBACK TO SAVEPOINT debit_shards`); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, `
+            SELECT shard, balance
+            FROM accounts.account_shards
+            WHERE account_id = $1
+            ORDER BY shard
+            FOR UPDATE
+        `, accountID)
+		if err != nil {
+			return err
+		}
+		debits, covered = debits[:0], decimal.Zero
+		for rows.Next() {
+			var (
+				shard   int32
+				balance decimal.Decimal
+			)
+			if err := rows.Scan(&shard, &balance); err != nil {
+				rows.Close()
+				return err
+			}
+			if take := decimal.Min(balance, amount.Sub(covered)); take.IsPositive() {
+				debits = append(debits, shardDebit{shard: shard, amount: take})
+				covered = covered.Add(take)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if covered.LessThan(amount) {
+			return ErrInsufficientBalance
+		}
+	}
+	if _, err := tx.Exec(ctx, `RELEASE SAVEPOINT debit_shards`); err != nil {
+		return err
+	}
+
+	for _, debit := range debits {
+		if _, err := tx.Exec(ctx, `UPDATE accounts.account_shards SET balance = balance - $3 WHERE account_id = $1 AND shard = $2`, accountID, debit.shard, debit.amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// creditShard adds amount to one shard of an account, picked at random so
+// that concurrent credits rarely wait for each other.
+func creditShard(ctx context.Context, tx pgx.Tx, accountID int64, shards int, amount decimal.Decimal) error {
+	shard := rand.IntN(shards)
+	tag, err := tx.Exec(ctx, `UPDATE accounts.account_shards SET balance = balance + $3 WHERE account_id = $1 AND shard = $2`, accountID, shard, amount)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() != 1 {
+		return fmt.Errorf("shard %d of account %d does not exist", shard, accountID)
+	}
+	return nil
+}
+
+// shardedBalance sums the balance of an account and its shards as of now.
+func shardedBalance(ctx context.Context, tx pgx.Tx, accountID int64) (decimal.Decimal, error) {
+	account, err := scanAccount(tx.QueryRow(ctx, `SELECT `+pgAccountColumns+` FROM accounts.accounts a WHERE a.id = $1`, accountID))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return account.Balance, nil
+}
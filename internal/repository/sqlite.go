@@ -0,0 +1,1160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteScale matches the NUMERIC(19, 4) columns of the PostgreSQL schema so
+// that both backends store the same values.
+const sqliteScale = 4
+
+// SQLiteRepository stores balances and amounts as decimal strings and does
+// all arithmetic in Go. Every transaction is opened with BEGIN IMMEDIATE, so
+// writers take the database lock up front and transfers are serialized.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+// NewSQLiteDB opens the file named by a sqlite:// URL. A relative path follows
+// the scheme directly (sqlite://data/transfer.db) and an absolute path adds a
+// third slash (sqlite:///var/lib/transfer.db).
+func NewSQLiteDB(ctx context.Context, databaseConfig config.DatabaseConfig) (*sql.DB, error) {
+	dsn, err := sqliteDSN(databaseConfig.URL)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(int(databaseConfig.MaxConns))
+	db.SetMaxIdleConns(int(databaseConfig.MaxConns))
+	db.SetConnMaxLifetime(databaseConfig.MaxConnLifetime)
+	db.SetConnMaxIdleTime(databaseConfig.MaxConnIdleTime)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func sqliteDSN(databaseURL string) (string, error) {
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(databaseURL, "sqlite://"), "?")
+	if path == "" {
+		return "", errors.New("database url: sqlite URL has no file path")
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("database url: %w", err)
+	}
+	for key := range query {
+		if strings.HasPrefix(key, "x-") {
+			query.Del(key)
+		}
+	}
+	if !query.Has("_txlock") {
+		query.Set("_txlock", "immediate")
+	}
+	query.Add("_pragma", "busy_timeout(10000)")
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	return path + "?" + query.Encode(), nil
+}
+
+func (r *SQLiteRepository) Close() {
+	_ = r.db.Close()
+}
+
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *SQLiteRepository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	if err := r.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+func (r *SQLiteRepository) CheckSchema(ctx context.Context) error {
+	return checkSchemaVersion(ctx, r.SchemaVersion)
+}
+
+func (r *SQLiteRepository) CreateAccount(ctx context.Context, account domain.Account) (*domain.Account, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	tags, err := json.Marshal(accountTags(account))
+	if err != nil {
+		return nil, err
+	}
+	created, err := scanSQLiteAccount(tx.QueryRowContext(ctx, `
+        INSERT INTO accounts (id, balance, opening_balance, tier, tags)
+        VALUES (?, ?, ?, ?, ?)
+        RETURNING `+sqliteAccountColumns+`
+    `, account.ID, account.Balance.Round(sqliteScale).String(), account.Balance.Round(sqliteScale).String(), accountTier(account), string(tags)))
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE) {
+			return nil, ErrAccountExists
+		}
+		return nil, err
+	}
+
+	if err := insertSQLiteAuditEntry(ctx, tx, auditRecord{operation: domain.AuditOperationAccountCreate, accountID: created.ID, after: created}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *SQLiteRepository) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
+	account, err := scanSQLiteAccount(r.db.QueryRowContext(ctx, `SELECT `+sqliteAccountColumns+` FROM accounts WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	return account, err
+}
+
+// ListAccounts filters and sorts in Go, since balances are stored as text and
+// do not compare as numbers in SQL.
+func (r *SQLiteRepository) ListAccounts(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+sqliteAccountColumns+` FROM accounts`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []domain.Account
+	for rows.Next() {
+		account, err := scanSQLiteAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return listAccounts(accounts, filter), nil
+}
+
+func (r *SQLiteRepository) GetAccounts(ctx context.Context, ids []int64) ([]domain.Account, error) {
+	accounts := make([]domain.Account, 0, len(ids))
+	if len(ids) == 0 {
+		return accounts, nil
+	}
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+sqliteAccountColumns+`
+        FROM accounts
+        WHERE id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+        ORDER BY id
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		account, err := scanSQLiteAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+func (r *SQLiteRepository) SetAccountStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*domain.Account, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	before, err := scanSQLiteAccount(tx.QueryRowContext(ctx, `SELECT `+sqliteAccountColumns+` FROM accounts WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return nil, ErrVersionMismatch
+	}
+	after, err := scanSQLiteAccount(tx.QueryRowContext(ctx, `
+        UPDATE accounts
+        SET status = ?, version = version + 1
+        WHERE id = ?
+        RETURNING `+sqliteAccountColumns+`
+    `, status, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertSQLiteAuditEntry(ctx, tx, accountStatusAuditRecord(before, after)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// SetAccountShards is not supported. SQLite serializes every write
+// transaction on the database lock, so splitting a balance would not reduce
+// contention.
+func (r *SQLiteRepository) SetAccountShards(ctx context.Context, id int64, shards int, expectedVersion int64) (*domain.Account, error) {
+	return nil, ErrShardingUnsupported
+}
+
+func (r *SQLiteRepository) TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	transaction, overdraw := normalizeTransfer(transaction)
+	decision := takeRiskDecision(&transaction)
+	return r.inTx(ctx, transferOperation(transaction.Type), func(tx *sql.Tx) (*domain.Transaction, error) {
+		created, err := sqliteTransferInTx(ctx, tx, transaction, overdraw)
+		if err != nil {
+			return nil, err
+		}
+		if transaction.Fee != nil {
+			fee, err := sqliteTransferInTx(ctx, tx, feeTransfer(transaction), false)
+			if err != nil {
+				return nil, err
+			}
+			created = withFeeTransaction(created, fee)
+		}
+		if decision != nil {
+			if err := insertSQLiteAuditEntry(ctx, tx, riskDecisionAuditRecord(*decision, &created.ID)); err != nil {
+				return nil, err
+			}
+		}
+		return created, nil
+	})
+}
+
+func (r *SQLiteRepository) GetTransaction(ctx context.Context, id int64) (*domain.Transaction, error) {
+	transaction, err := scanSQLiteTransaction(r.db.QueryRowContext(ctx, `
+        SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+        FROM transactions
+        WHERE id = ?
+    `, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	return transaction, err
+}
+
+func (r *SQLiteRepository) ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+        FROM transactions
+        WHERE (source_account_id = ?1 OR destination_account_id = ?1)
+          AND (?2 = 0 OR id < ?2)
+          AND (?4 = '' OR type = ?4)
+        ORDER BY id DESC
+        LIMIT ?3
+    `, accountID, filter.BeforeID, filter.Limit, filter.Type)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []domain.Transaction{}
+	for rows.Next() {
+		transaction, err := scanSQLiteTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *transaction)
+	}
+	return transactions, rows.Err()
+}
+
+func (r *SQLiteRepository) ReverseTransaction(ctx context.Context, id int64) (*domain.Transaction, error) {
+	return r.inTx(ctx, "reverse_transaction", func(tx *sql.Tx) (*domain.Transaction, error) {
+		original, err := scanSQLiteTransaction(tx.QueryRowContext(ctx, `
+            SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+            FROM transactions
+            WHERE id = ?
+        `, id))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrTransactionNotFound
+			}
+			return nil, err
+		}
+		if original.ReversalOf != nil {
+			return nil, ErrNotReversible
+		}
+
+		var reversed bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM transactions WHERE reversal_of = ?)`, id).Scan(&reversed); err != nil {
+			return nil, err
+		}
+		if reversed {
+			return nil, ErrAlreadyReversed
+		}
+
+		return sqliteTransferInTx(ctx, tx, domain.Transaction{
+			Type:                 domain.TransactionTypeReversal,
+			SourceAccountID:      original.DestinationAccountID,
+			DestinationAccountID: original.SourceAccountID,
+			Amount:               original.Amount,
+			ReversalOf:           &original.ID,
+		}, original.Type == domain.TransactionTypeWithdrawal)
+	})
+}
+
+// LedgerSnapshot sums credits and debits in Go, since SQLite would add the
+// decimal strings as floating point. The transaction holds the write lock, so
+// no transfer can commit between reading the accounts and the history.
+func (r *SQLiteRepository) LedgerSnapshot(ctx context.Context) (*domain.LedgerSnapshot, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	snapshot := &domain.LedgerSnapshot{Accounts: []domain.LedgerAccount{}, OrphanTransactions: []domain.Transaction{}}
+	positions := map[int64]int{}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, opening_balance, balance FROM accounts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			account                 domain.LedgerAccount
+			openingBalance, balance string
+		)
+		if err := rows.Scan(&account.ID, &openingBalance, &balance); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if account.OpeningBalance, err = decimal.NewFromString(openingBalance); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("account %d: invalid opening balance %q: %w", account.ID, openingBalance, err)
+		}
+		if account.Balance, err = decimal.NewFromString(balance); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("account %d: invalid balance %q: %w", account.ID, balance, err)
+		}
+		positions[account.ID] = len(snapshot.Accounts)
+		snapshot.Accounts = append(snapshot.Accounts, account)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+        SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+        FROM transactions
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		transaction, err := scanSQLiteTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.TransactionCount++
+
+		source, sourceFound := positions[transaction.SourceAccountID]
+		destination, destinationFound := positions[transaction.DestinationAccountID]
+		if !sourceFound || !destinationFound {
+			snapshot.OrphanTransactions = append(snapshot.OrphanTransactions, *transaction)
+		}
+		if sourceFound {
+			snapshot.Accounts[source].Debits = snapshot.Accounts[source].Debits.Add(transaction.Amount)
+		}
+		if destinationFound {
+			snapshot.Accounts[destination].Credits = snapshot.Accounts[destination].Credits.Add(transaction.Amount)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// inTx runs fn in a write transaction. Beginning the transaction acquires the
+// database write lock, so the wait is recorded as lock wait time.
+func (r *SQLiteRepository) inTx(ctx context.Context, operation string, fn func(tx *sql.Tx) (*domain.Transaction, error)) (*domain.Transaction, error) {
+	txStart := time.Now()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	metrics.DBLockWaitDuration.WithLabelValues(operation).Observe(time.Since(txStart).Seconds())
+	defer func() {
+		_ = tx.Rollback()
+		metrics.DBTransactionDuration.WithLabelValues(operation).Observe(time.Since(txStart).Seconds())
+	}()
+
+	result, err := fn(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func sqliteTransferInTx(ctx context.Context, tx *sql.Tx, transaction domain.Transaction, overdraw bool) (*domain.Transaction, error) {
+	transaction.Amount = transaction.Amount.Round(sqliteScale)
+
+	source, err := scanSQLiteAccount(tx.QueryRowContext(ctx, `SELECT `+sqliteAccountColumns+` FROM accounts WHERE id = ?`, transaction.SourceAccountID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("source %w", ErrAccountNotFound)
+		}
+		return nil, err
+	}
+	destination, err := scanSQLiteAccount(tx.QueryRowContext(ctx, `SELECT `+sqliteAccountColumns+` FROM accounts WHERE id = ?`, transaction.DestinationAccountID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("destination %w", ErrAccountNotFound)
+		}
+		return nil, err
+	}
+
+	if source.Status == domain.AccountStatusFrozen || destination.Status == domain.AccountStatusFrozen {
+		return nil, ErrAccountFrozen
+	}
+	if !overdraw && source.Balance.LessThan(transaction.Amount) {
+		return nil, ErrInsufficientBalance
+	}
+
+	const updateSQL = `UPDATE accounts SET balance = ?, version = version + 1 WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, updateSQL, source.Balance.Sub(transaction.Amount).String(), source.ID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, updateSQL, destination.Balance.Add(transaction.Amount).String(), destination.ID); err != nil {
+		return nil, err
+	}
+
+	created := transaction
+	created.CreatedAt = time.Now().UTC()
+	if err := tx.QueryRowContext(ctx, `
+        INSERT INTO transactions (type, source_account_id, destination_account_id, amount, reversal_of, created_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+        RETURNING id
+    `, transaction.Type, transaction.SourceAccountID, transaction.DestinationAccountID, transaction.Amount.String(), transaction.ReversalOf, created.CreatedAt.Format(time.RFC3339Nano)).Scan(&created.ID); err != nil {
+		return nil, err
+	}
+
+	before := transferState{Source: *source, Destination: *destination}
+	after := transferState{Source: *source, Destination: *destination, Transaction: &created}
+	after.Source.Balance = source.Balance.Sub(transaction.Amount)
+	after.Destination.Balance = destination.Balance.Add(transaction.Amount)
+	after.Source.Version++
+	after.Destination.Version++
+	if err := insertSQLiteAuditEntry(ctx, tx, transferAuditRecord(before, after)); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// sqliteAuditTimeLayout has a fixed width, so that the text timestamps of the
+// audit log sort and compare in time order.
+const sqliteAuditTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func insertSQLiteAuditEntry(ctx context.Context, tx *sql.Tx, record auditRecord) error {
+	entry, err := newAuditEntry(ctx, record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO audit_log (occurred_at, actor, request_id, client_ip, operation, account_id, counterparty_account_id, transaction_id, before_state, after_state)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, time.Now().UTC().Format(sqliteAuditTimeLayout), entry.Actor, entry.RequestID, entry.ClientIP, entry.Operation, entry.AccountID,
+		entry.CounterpartyAccountID, entry.TransactionID, nullableJSON(entry.Before), nullableJSON(entry.After))
+	return err
+}
+
+func (r *SQLiteRepository) RecordRiskDecision(ctx context.Context, decision domain.RiskDecision) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := insertSQLiteAuditEntry(ctx, tx, riskDecisionAuditRecord(decision, nil)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepository) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	var since, until any
+	if !filter.Since.IsZero() {
+		since = filter.Since.UTC().Format(sqliteAuditTimeLayout)
+	}
+	if !filter.Until.IsZero() {
+		until = filter.Until.UTC().Format(sqliteAuditTimeLayout)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, occurred_at, actor, request_id, client_ip, operation, account_id, counterparty_account_id, transaction_id, before_state, after_state
+        FROM audit_log
+        WHERE (?1 = '' OR actor = ?1)
+          AND (?2 = '' OR operation = ?2)
+          AND (?3 = '' OR request_id = ?3)
+          AND (?4 = 0 OR account_id = ?4 OR counterparty_account_id = ?4)
+          AND (?5 IS NULL OR occurred_at >= ?5)
+          AND (?6 IS NULL OR occurred_at < ?6)
+          AND (?7 = 0 OR id < ?7)
+        ORDER BY id DESC
+        LIMIT ?8
+    `, filter.Actor, filter.Operation, filter.RequestID, filter.AccountID, since, until, filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var (
+			entry                       domain.AuditEntry
+			occurredAt                  string
+			counterparty, transactionID sql.NullInt64
+			before, after               sql.NullString
+		)
+		if err := rows.Scan(
+			&entry.ID,
+			&occurredAt,
+			&entry.Actor,
+			&entry.RequestID,
+			&entry.ClientIP,
+			&entry.Operation,
+			&entry.AccountID,
+			&counterparty,
+			&transactionID,
+			&before,
+			&after,
+		); err != nil {
+			return nil, err
+		}
+		if entry.OccurredAt, err = time.Parse(time.RFC3339Nano, occurredAt); err != nil {
+			return nil, fmt.Errorf("audit entry %d: invalid occurred_at %q: %w", entry.ID, occurredAt, err)
+		}
+		if counterparty.Valid {
+			entry.CounterpartyAccountID = &counterparty.Int64
+		}
+		if transactionID.Valid {
+			entry.TransactionID = &transactionID.Int64
+		}
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+type sqliteRow interface {
+	Scan(dest ...any) error
+}
+
+const sqliteAccountColumns = `id, balance, status, tier, tags, version, created_at`
+
+func scanSQLiteAccount(row sqliteRow) (*domain.Account, error) {
+	var (
+		account   domain.Account
+		balance   string
+		tags      string
+		createdAt string
+	)
+	if err := row.Scan(&account.ID, &balance, &account.Status, &account.Tier, &tags, &account.Version, &createdAt); err != nil {
+		return nil, err
+	}
+	parsed, err := decimal.NewFromString(balance)
+	if err != nil {
+		return nil, fmt.Errorf("account %d: invalid balance %q: %w", account.ID, balance, err)
+	}
+	account.Balance = parsed
+	if err := json.Unmarshal([]byte(tags), &account.Tags); err != nil {
+		return nil, fmt.Errorf("account %d: invalid tags %q: %w", account.ID, tags, err)
+	}
+	if account.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("account %d: invalid created_at %q: %w", account.ID, createdAt, err)
+	}
+	return &account, nil
+}
+
+func scanSQLiteTransaction(row sqliteRow) (*domain.Transaction, error) {
+	var (
+		transaction domain.Transaction
+		amount      string
+		reversalOf  sql.NullInt64
+		createdAt   string
+	)
+	if err := row.Scan(
+		&transaction.ID,
+		&transaction.Type,
+		&transaction.SourceAccountID,
+		&transaction.DestinationAccountID,
+		&amount,
+		&reversalOf,
+		&createdAt,
+	); err != nil {
+		return nil, err
+	}
+
+	parsed, err := decimal.NewFromString(amount)
+	if err != nil {
+		return nil, fmt.Errorf("transaction %d: invalid amount %q: %w", transaction.ID, amount, err)
+	}
+	transaction.Amount = parsed
+	if reversalOf.Valid {
+		transaction.ReversalOf = &reversalOf.Int64
+	}
+	if transaction.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("transaction %d: invalid created_at %q: %w", transaction.ID, createdAt, err)
+	}
+	return &transaction, nil
+}
+
+func (r *SQLiteRepository) ListFeeRules(ctx context.Context) ([]domain.FeeRule, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+feeRuleColumns+` FROM fee_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []domain.FeeRule{}
+	for rows.Next() {
+		rule, err := scanSQLiteFeeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *SQLiteRepository) CreateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	parameters, err := encodeFeeRuleParameters(rule)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	return scanSQLiteFeeRule(r.db.QueryRowContext(ctx, `
+        INSERT INTO fee_rules (name, kind, account_tier, parameters, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+        RETURNING `+feeRuleColumns,
+		rule.Name, rule.Kind, rule.AccountTier, string(parameters), now, now))
+}
+
+func (r *SQLiteRepository) UpdateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	parameters, err := encodeFeeRuleParameters(rule)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := scanSQLiteFeeRule(r.db.QueryRowContext(ctx, `
+        UPDATE fee_rules
+        SET name = ?, kind = ?, account_tier = ?, parameters = ?, updated_at = ?
+        WHERE id = ?
+        RETURNING `+feeRuleColumns,
+		rule.Name, rule.Kind, rule.AccountTier, string(parameters), time.Now().UTC().Format(time.RFC3339Nano), rule.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFeeRuleNotFound
+	}
+	return updated, err
+}
+
+func (r *SQLiteRepository) DeleteFeeRule(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM fee_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrFeeRuleNotFound
+	}
+	return nil
+}
+
+func scanSQLiteFeeRule(row sqliteRow) (*domain.FeeRule, error) {
+	var (
+		rule                 domain.FeeRule
+		parameters           string
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.Kind, &rule.AccountTier, &parameters, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	if err := decodeFeeRuleParameters(&rule, []byte(parameters)); err != nil {
+		return nil, err
+	}
+	var err error
+	if rule.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("fee rule %d: invalid created_at %q: %w", rule.ID, createdAt, err)
+	}
+	if rule.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return nil, fmt.Errorf("fee rule %d: invalid updated_at %q: %w", rule.ID, updatedAt, err)
+	}
+	return &rule, nil
+}
+
+// sqliteDateLayout is the text form of accrual dates.
+const sqliteDateLayout = "2006-01-02"
+
+func (r *SQLiteRepository) SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (*domain.InterestRate, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM accounts WHERE id = ?)`, accountID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+	before, err := scanSQLiteInterestRate(tx.QueryRowContext(ctx, `SELECT `+interestRateColumns+` FROM interest_rates WHERE account_id = ?`, accountID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	after, err := scanSQLiteInterestRate(tx.QueryRowContext(ctx, `
+        INSERT INTO interest_rates (account_id, annual_rate, updated_at)
+        VALUES (?, ?, ?)
+        ON CONFLICT (account_id) DO UPDATE SET annual_rate = excluded.annual_rate, updated_at = excluded.updated_at
+        RETURNING `+interestRateColumns,
+		accountID, annualRate.String(), time.Now().UTC().Format(time.RFC3339Nano)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertSQLiteAuditEntry(ctx, tx, interestRateAuditRecord(before, after)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func (r *SQLiteRepository) ListInterestRates(ctx context.Context) ([]domain.InterestRate, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+interestRateColumns+` FROM interest_rates ORDER BY account_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []domain.InterestRate{}
+	for rows.Next() {
+		rate, err := scanSQLiteInterestRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+	return rates, rows.Err()
+}
+
+func (r *SQLiteRepository) BalanceAt(ctx context.Context, accountID int64, at time.Time) (decimal.Decimal, error) {
+	balances, err := r.BalancesAt(ctx, []int64{accountID}, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return accountBalance(balances, accountID)
+}
+
+func (r *SQLiteRepository) RecordInterestAccruals(ctx context.Context, accruals []domain.InterestAccrual) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	recorded := 0
+	for _, accrual := range accruals {
+		result, err := tx.ExecContext(ctx, `
+            INSERT INTO interest_accruals (account_id, accrual_date, balance, annual_rate, day_count, amount)
+            VALUES (?, ?, ?, ?, ?, ?)
+            ON CONFLICT (account_id, accrual_date) DO NOTHING
+        `, accrual.AccountID, accrual.Date.UTC().Format(sqliteDateLayout), accrual.Balance.String(), accrual.AnnualRate.String(), accrual.DayCount, accrual.Amount.String())
+		if err != nil {
+			return 0, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		recorded += int(inserted)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return recorded, nil
+}
+
+func (r *SQLiteRepository) PostInterest(ctx context.Context, accountID, expenseAccountID int64, through time.Time) (*domain.Transaction, error) {
+	date := through.UTC().Format(sqliteDateLayout)
+	return r.inTx(ctx, "post_interest", func(tx *sql.Tx) (*domain.Transaction, error) {
+		rows, err := tx.QueryContext(ctx, `
+            SELECT amount
+            FROM interest_accruals
+            WHERE account_id = ? AND transaction_id IS NULL AND accrual_date <= ?
+        `, accountID, date)
+		if err != nil {
+			return nil, err
+		}
+		total, unposted, err := sqliteSumAmounts(rows, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if unposted == 0 {
+			return nil, nil
+		}
+		// The carry of earlier postings is summed in Go like the accruals,
+		// since amounts are stored as text.
+		rows, err = tx.QueryContext(ctx, `
+            SELECT amount FROM interest_accruals WHERE account_id = ? AND transaction_id IS NOT NULL
+            UNION ALL
+            SELECT '-' || amount FROM transactions
+            WHERE id IN (SELECT transaction_id FROM interest_accruals WHERE account_id = ? AND transaction_id IS NOT NULL)
+        `, accountID, accountID)
+		if err != nil {
+			return nil, err
+		}
+		carry, _, err := sqliteSumAmounts(rows, accountID)
+		if err != nil {
+			return nil, err
+		}
+
+		transfer := interestTransfer(accountID, expenseAccountID, total.Add(carry))
+		if !transfer.Amount.IsPositive() {
+			return nil, nil
+		}
+		posted, err := sqliteTransferInTx(ctx, tx, transfer, true)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `
+            UPDATE interest_accruals
+            SET transaction_id = ?
+            WHERE account_id = ? AND transaction_id IS NULL AND accrual_date <= ?
+        `, posted.ID, accountID, date); err != nil {
+			return nil, err
+		}
+		return posted, nil
+	})
+}
+
+// sqliteSumAmounts sums and counts the text amounts of rows, closing them.
+func sqliteSumAmounts(rows *sql.Rows, accountID int64) (decimal.Decimal, int, error) {
+	defer rows.Close()
+	var (
+		total = decimal.Zero
+		count int
+	)
+	for rows.Next() {
+		var amount string
+		if err := rows.Scan(&amount); err != nil {
+			return decimal.Zero, 0, err
+		}
+		parsed, err := decimal.NewFromString(amount)
+		if err != nil {
+			return decimal.Zero, 0, fmt.Errorf("account %d: invalid accrual amount %q: %w", accountID, amount, err)
+		}
+		total = total.Add(parsed)
+		count++
+	}
+	return total, count, rows.Err()
+}
+
+func (r *SQLiteRepository) ListInterestAccruals(ctx context.Context, filter domain.InterestAccrualFilter) ([]domain.InterestAccrual, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+interestAccrualColumns+`
+        FROM interest_accruals
+        WHERE (? = 0 OR account_id = ?)
+          AND accrual_date BETWEEN ? AND ?
+        ORDER BY account_id, accrual_date
+    `, filter.AccountID, filter.AccountID, filter.From.UTC().Format(sqliteDateLayout), filter.To.UTC().Format(sqliteDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accruals := []domain.InterestAccrual{}
+	for rows.Next() {
+		var (
+			accrual                     domain.InterestAccrual
+			date, balance, rate, amount string
+			transactionID               sql.NullInt64
+		)
+		if err := rows.Scan(&accrual.AccountID, &date, &balance, &rate, &accrual.DayCount, &amount, &transactionID); err != nil {
+			return nil, err
+		}
+		if accrual.Date, err = time.Parse(sqliteDateLayout, date); err != nil {
+			return nil, fmt.Errorf("account %d: invalid accrual date %q: %w", accrual.AccountID, date, err)
+		}
+		for _, field := range []struct {
+			value  string
+			target *decimal.Decimal
+		}{
+			{balance, &accrual.Balance},
+			{rate, &accrual.AnnualRate},
+			{amount, &accrual.Amount},
+		} {
+			if *field.target, err = decimal.NewFromString(field.value); err != nil {
+				return nil, fmt.Errorf("account %d: invalid accrual on %s: %w", accrual.AccountID, date, err)
+			}
+		}
+		if transactionID.Valid {
+			accrual.TransactionID = &transactionID.Int64
+		}
+		accruals = append(accruals, accrual)
+	}
+	return accruals, rows.Err()
+}
+
+func scanSQLiteInterestRate(row sqliteRow) (*domain.InterestRate, error) {
+	var (
+		rate                domain.InterestRate
+		annualRate, updated string
+	)
+	if err := row.Scan(&rate.AccountID, &annualRate, &updated); err != nil {
+		return nil, err
+	}
+	var err error
+	if rate.AnnualRate, err = decimal.NewFromString(annualRate); err != nil {
+		return nil, fmt.Errorf("account %d: invalid interest rate %q: %w", rate.AccountID, annualRate, err)
+	}
+	if rate.UpdatedAt, err = time.Parse(time.RFC3339Nano, updated); err != nil {
+		return nil, fmt.Errorf("account %d: invalid updated_at %q: %w", rate.AccountID, updated, err)
+	}
+	return &rate, nil
+}
+
+// sqliteSnapshotTimeLayout has a fixed width, so that the text timestamps of
+// balance snapshots sort and compare in time order.
+const sqliteSnapshotTimeLayout = sqliteAuditTimeLayout
+
+// sqliteOpening is what an account's history is replayed from.
+type sqliteOpening struct {
+	accountID int64
+	balance   decimal.Decimal
+	createdAt time.Time
+}
+
+func scanSQLiteOpening(row sqliteRow) (*sqliteOpening, error) {
+	var (
+		opening   sqliteOpening
+		balance   string
+		createdAt string
+	)
+	if err := row.Scan(&opening.accountID, &balance, &createdAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if opening.balance, err = decimal.NewFromString(balance); err != nil {
+		return nil, fmt.Errorf("account %d: invalid opening balance %q: %w", opening.accountID, balance, err)
+	}
+	if opening.createdAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("account %d: invalid created_at %q: %w", opening.accountID, createdAt, err)
+	}
+	return &opening, nil
+}
+
+// BalancesAt reads every account in one transaction, so that they all see
+// the same history.
+func (r *SQLiteRepository) BalancesAt(ctx context.Context, accountIDs []int64, at time.Time) (map[int64]decimal.Decimal, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	balances := make(map[int64]decimal.Decimal, len(accountIDs))
+	for _, accountID := range accountIDs {
+		opening, err := scanSQLiteOpening(tx.QueryRowContext(ctx, `SELECT id, opening_balance, created_at FROM accounts WHERE id = ?`, accountID))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if opening.createdAt.After(at) {
+			balances[accountID] = decimal.Zero
+			continue
+		}
+		if balances[accountID], _, _, err = sqliteBalanceAt(ctx, tx, opening, at); err != nil {
+			return nil, err
+		}
+	}
+	return balances, nil
+}
+
+// SnapshotCutoff returns at, since TakeBalanceSnapshots waits for the
+// database lock that every write holds.
+func (r *SQLiteRepository) SnapshotCutoff(_ context.Context, at time.Time) (time.Time, error) {
+	return at, nil
+}
+
+// TakeBalanceSnapshots holds the database lock, so every transaction created
+// up to at has already committed.
+func (r *SQLiteRepository) TakeBalanceSnapshots(ctx context.Context, at time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, opening_balance, created_at FROM accounts ORDER BY id`)
+	if err != nil {
+		return 0, err
+	}
+	var openings []*sqliteOpening
+	for rows.Next() {
+		opening, err := scanSQLiteOpening(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !opening.createdAt.After(at) {
+			openings = append(openings, opening)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	taken := 0
+	for _, opening := range openings {
+		balance, lastTransactionID, replayed, err := sqliteBalanceAt(ctx, tx, opening, at)
+		if err != nil {
+			return 0, err
+		}
+		if !replayed {
+			continue
+		}
+		result, err := tx.ExecContext(ctx, `
+            INSERT INTO balance_snapshots (account_id, taken_at, balance, last_transaction_id)
+            VALUES (?, ?, ?, ?)
+            ON CONFLICT (account_id, taken_at) DO NOTHING
+        `, opening.accountID, at.UTC().Format(sqliteSnapshotTimeLayout), balance.String(), lastTransactionID)
+		if err != nil {
+			return 0, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		taken += int(inserted)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return taken, nil
+}
+
+// sqliteBalanceAt starts from the latest snapshot of the account taken at or
+// before at and replays the transactions after it in Go, since the text
+// timestamps of transactions do not compare in time order. Transactions are
+// created in ID order under the database lock, so the replay stops at the
+// first one created after at. It also returns the ID of the last transaction
+// the balance includes and whether any was replayed.
+func sqliteBalanceAt(ctx context.Context, tx *sql.Tx, opening *sqliteOpening, at time.Time) (decimal.Decimal, int64, bool, error) {
+	balance := opening.balance
+	var (
+		snapshotBalance   string
+		lastTransactionID int64
+	)
+	err := tx.QueryRowContext(ctx, `
+        SELECT balance, last_transaction_id
+        FROM balance_snapshots
+        WHERE account_id = ? AND taken_at <= ?
+        ORDER BY taken_at DESC
+        LIMIT 1
+    `, opening.accountID, at.UTC().Format(sqliteSnapshotTimeLayout)).Scan(&snapshotBalance, &lastTransactionID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return decimal.Zero, 0, false, err
+	default:
+		if balance, err = decimal.NewFromString(snapshotBalance); err != nil {
+			return decimal.Zero, 0, false, fmt.Errorf("account %d: invalid snapshot balance %q: %w", opening.accountID, snapshotBalance, err)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, type, source_account_id, destination_account_id, amount, reversal_of, created_at
+        FROM transactions
+        WHERE (source_account_id = ? OR destination_account_id = ?) AND id > ?
+        ORDER BY id
+    `, opening.accountID, opening.accountID, lastTransactionID)
+	if err != nil {
+		return decimal.Zero, 0, false, err
+	}
+	defer rows.Close()
+
+	replayed := false
+	for rows.Next() {
+		transaction, err := scanSQLiteTransaction(rows)
+		if err != nil {
+			return decimal.Zero, 0, false, err
+		}
+		if transaction.CreatedAt.After(at) {
+			break
+		}
+		if transaction.DestinationAccountID == opening.accountID {
+			balance = balance.Add(transaction.Amount)
+		}
+		if transaction.SourceAccountID == opening.accountID {
+			balance = balance.Sub(transaction.Amount)
+		}
+		lastTransactionID = transaction.ID
+		replayed = true
+	}
+	return balance, lastTransactionID, replayed, rows.Err()
+}
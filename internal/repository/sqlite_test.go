@@ -0,0 +1,133 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/repository/repositorytest"
+)
+
+func openSQLite(t *testing.T) (repository.Backend, string) {
+	t.Helper()
+
+	databaseConfig := config.Default().Database
+	databaseConfig.URL = "sqlite://" + filepath.Join(t.TempDir(), "transfer.db")
+	storage, err := repository.Open(context.Background(), databaseConfig)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(storage.Close)
+	return storage, databaseConfig.URL
+}
+
+func TestSQLiteRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		storage, _ := openSQLite(t)
+		return storage
+	})
+}
+
+func TestSQLiteRepository_MigrationsRoundTrip(t *testing.T) {
+	storage, databaseURL := openSQLite(t)
+	if err := storage.CheckSchema(context.Background()); err != nil {
+		t.Fatalf("expected schema to be current, got %v", err)
+	}
+
+	expected, err := repository.ExpectedSchemaVersion()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repository.MigrateDown(databaseURL, int(expected)); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if err := storage.CheckSchema(context.Background()); err == nil {
+		t.Fatalf("expected schema check to fail after rolling back")
+	}
+	if err := repository.MigrateUp(databaseURL); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if err := storage.CheckSchema(context.Background()); err != nil {
+		t.Fatalf("expected schema to be current again, got %v", err)
+	}
+}
+
+func TestSQLiteRepository_OpeningBalanceBackfill(t *testing.T) {
+	storage, databaseURL := openSQLite(t)
+	ctx := context.Background()
+	for _, account := range []domain.Account{
+		{ID: 1, Balance: decimal.RequireFromString("100.1234")},
+		{ID: 2, Balance: decimal.RequireFromString("0.5")},
+	} {
+		if _, err := storage.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account: %v", err)
+		}
+	}
+	for _, transaction := range []domain.Transaction{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("40.0034")},
+		{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.RequireFromString("0.75")},
+	} {
+		if _, err := storage.TransferMoney(ctx, transaction); err != nil {
+			t.Fatalf("transfer: %v", err)
+		}
+	}
+
+	expected, err := repository.ExpectedSchemaVersion()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Roll back to the schema before opening balances were recorded, so the
+	// up migration has to derive them from balances and history.
+	if err := repository.MigrateDown(databaseURL, int(expected)-2); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if err := repository.MigrateUp(databaseURL); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	snapshot, err := storage.LedgerSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("ledger snapshot: %v", err)
+	}
+	for i, want := range []string{"100.1234", "0.5"} {
+		if got := snapshot.Accounts[i].OpeningBalance; !got.Equal(decimal.RequireFromString(want)) {
+			t.Fatalf("expected account %d opening balance %s, got %s", snapshot.Accounts[i].ID, want, got)
+		}
+	}
+}
+
+func TestSQLiteRepository_AuditLogIsAppendOnly(t *testing.T) {
+	storage, databaseURL := openSQLite(t)
+	ctx := context.Background()
+	if _, err := storage.CreateAccount(ctx, domain.Account{ID: 1, Balance: decimal.RequireFromString("10")}); err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+
+	databaseConfig := config.Default().Database
+	databaseConfig.URL = databaseURL
+	db, err := repository.NewSQLiteDB(ctx, databaseConfig)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	for _, statement := range []string{
+		`UPDATE audit_log SET actor = 'mallory'`,
+		`DELETE FROM audit_log`,
+	} {
+		if _, err := db.ExecContext(ctx, statement); err == nil {
+			t.Fatalf("expected %q to be rejected", statement)
+		}
+	}
+	entries, err := storage.ListAuditEntries(ctx, domain.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "anonymous" {
+		t.Fatalf("expected the original entry to survive, got %+v", entries)
+	}
+}
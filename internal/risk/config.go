@@ -0,0 +1,166 @@
+package risk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Types of the built-in rules in a rules file.
+const (
+	TypeAmountThreshold     = "amount_threshold"
+	TypeNewAccount          = "new_account"
+	TypeUnusualCounterparty = "unusual_counterparty"
+	TypeVelocity            = "velocity"
+)
+
+// maxLookback bounds how many transactions the unusual counterparty rule reads.
+const maxLookback = 1000
+
+type rulesFile struct {
+	Rules []ruleConfig `yaml:"rules"`
+}
+
+// ruleConfig is a rule as written in a rules file. Amounts are strings so that
+// they are read exactly, durations are Go durations such as 72h.
+type ruleConfig struct {
+	Name      string `yaml:"name"`
+	Type      string `yaml:"type"`
+	Action    string `yaml:"action"`
+	Amount    string `yaml:"amount"`
+	MinAmount string `yaml:"min_amount"`
+	Period    string `yaml:"period"`
+	Side      string `yaml:"side"`
+	Lookback  int    `yaml:"lookback"`
+	Window    string `yaml:"window"`
+	MaxCount  int    `yaml:"max_count"`
+	MaxAmount string `yaml:"max_amount"`
+}
+
+// LoadRules reads the YAML rules file at path.
+func LoadRules(path string) ([]Rule, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read risk rules: %w", err)
+	}
+	rules, err := ParseRules(bytes.NewReader(contents))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRules reads a rules file: a list of rules under the key rules, each
+// with a unique name, one of the built-in types, an action of deny or review
+// and the settings of its type. Unknown keys are rejected.
+func ParseRules(input io.Reader) ([]Rule, error) {
+	decoder := yaml.NewDecoder(input)
+	decoder.KnownFields(true)
+	var file rulesFile
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+
+	rules := make([]Rule, 0, len(file.Rules))
+	names := map[string]bool{}
+	for i, config := range file.Rules {
+		rule, err := config.rule()
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d (%s): %v", ErrInvalidRules, i+1, config.Name, err)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("%w: rule %d: name %q is used twice", ErrInvalidRules, i+1, config.Name)
+		}
+		names[config.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (c ruleConfig) rule() (Rule, error) {
+	if c.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if c.Action != domain.RiskDeny && c.Action != domain.RiskReview {
+		return nil, fmt.Errorf("action %q is not deny or review", c.Action)
+	}
+	base := RuleBase{RuleName: c.Name, RuleAction: c.Action}
+	minAmount, err := optionalAmount("min_amount", c.MinAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case TypeAmountThreshold:
+		amount, err := optionalAmount("amount", c.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if !amount.IsPositive() {
+			return nil, errors.New("amount is required")
+		}
+		return AmountThreshold{RuleBase: base, Amount: amount}, nil
+	case TypeNewAccount:
+		period, err := requiredDuration("period", c.Period)
+		if err != nil {
+			return nil, err
+		}
+		side := c.Side
+		switch side {
+		case "":
+			side = SideSource
+		case SideSource, SideDestination, SideBoth:
+		default:
+			return nil, fmt.Errorf("side %q is not source, destination or both", c.Side)
+		}
+		return NewAccount{RuleBase: base, Period: period, MinAmount: minAmount, Side: side}, nil
+	case TypeUnusualCounterparty:
+		if c.Lookback < 1 || c.Lookback > maxLookback {
+			return nil, fmt.Errorf("lookback must be between 1 and %d", maxLookback)
+		}
+		return UnusualCounterparty{RuleBase: base, Lookback: c.Lookback, MinAmount: minAmount}, nil
+	case TypeVelocity:
+		window, err := requiredDuration("window", c.Window)
+		if err != nil {
+			return nil, err
+		}
+		maxAmount, err := optionalAmount("max_amount", c.MaxAmount)
+		if err != nil {
+			return nil, err
+		}
+		if c.MaxCount < 0 {
+			return nil, errors.New("max_count must not be negative")
+		}
+		if c.MaxCount == 0 && maxAmount.IsZero() {
+			return nil, errors.New("max_count or max_amount is required")
+		}
+		return Velocity{RuleBase: base, Window: window, MaxCount: c.MaxCount, MaxAmount: maxAmount}, nil
+	}
+	return nil, fmt.Errorf("type %q is not one of %s, %s, %s, %s", c.Type, TypeAmountThreshold, TypeNewAccount, TypeUnusualCounterparty, TypeVelocity)
+}
+
+func optionalAmount(key, value string) (decimal.Decimal, error) {
+	if value == "" {
+		return decimal.Zero, nil
+	}
+	amount, err := decimal.NewFromString(value)
+	if err != nil || amount.IsNegative() {
+		return decimal.Zero, fmt.Errorf("%s: %q is not a non-negative amount", key, value)
+	}
+	return amount, nil
+}
+
+func requiredDuration(key, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%s: %q is not a positive duration", key, value)
+	}
+	return duration, nil
+}
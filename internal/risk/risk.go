@@ -0,0 +1,95 @@
+// Package risk decides, before a transfer is executed, whether it is allowed,
+// denied, or let through flagged for review. Every rule of an engine looks at
+// the transfer and, when it matches, contributes its action and a reason; the
+// strictest action wins.
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+)
+
+var ErrInvalidRules = errors.New("invalid risk rules")
+
+// Transfer is what the rules decide on. At is when the decision is made.
+type Transfer struct {
+	SourceAccountID      int64
+	DestinationAccountID int64
+	Amount               decimal.Decimal
+	At                   time.Time
+}
+
+// Ledger is the part of the repository rules read accounts and their history
+// from.
+type Ledger interface {
+	GetAccount(ctx context.Context, id string) (*domain.Account, error)
+	ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error)
+}
+
+// Rule is one check of a transfer. Match returns why the transfer matches the
+// rule, or an empty string when it does not. Action is domain.RiskDeny or
+// domain.RiskReview.
+type Rule interface {
+	Name() string
+	Action() string
+	Match(ctx context.Context, ledger Ledger, transfer Transfer) (string, error)
+}
+
+type Engine struct {
+	ledger Ledger
+	rules  []Rule
+	now    func() time.Time
+}
+
+func NewEngine(ledger Ledger, rules ...Rule) *Engine {
+	return &Engine{ledger: ledger, rules: rules, now: time.Now}
+}
+
+// Evaluate runs every rule, so that the decision lists all the reasons against
+// a transfer, and fails when a rule cannot read the ledger.
+func (e *Engine) Evaluate(ctx context.Context, transaction domain.Transaction) (*domain.RiskDecision, error) {
+	transfer := Transfer{
+		SourceAccountID:      transaction.SourceAccountID,
+		DestinationAccountID: transaction.DestinationAccountID,
+		Amount:               transaction.Amount,
+		At:                   e.now(),
+	}
+	decision := &domain.RiskDecision{
+		SourceAccountID:      transfer.SourceAccountID,
+		DestinationAccountID: transfer.DestinationAccountID,
+		Amount:               transfer.Amount,
+		Decision:             domain.RiskAllow,
+	}
+	for _, rule := range e.rules {
+		reason, err := rule.Match(ctx, e.ledger, transfer)
+		if err != nil {
+			return nil, fmt.Errorf("risk rule %s: %w", rule.Name(), err)
+		}
+		if reason == "" {
+			continue
+		}
+		metrics.RiskRuleMatchesTotal.WithLabelValues(rule.Name(), rule.Action()).Inc()
+		decision.Reasons = append(decision.Reasons, domain.RiskReason{Rule: rule.Name(), Action: rule.Action(), Reason: reason})
+		if severity(rule.Action()) > severity(decision.Decision) {
+			decision.Decision = rule.Action()
+		}
+	}
+	metrics.RiskDecisionsTotal.WithLabelValues(decision.Decision).Inc()
+	return decision, nil
+}
+
+func severity(action string) int {
+	switch action {
+	case domain.RiskDeny:
+		return 2
+	case domain.RiskReview:
+		return 1
+	}
+	return 0
+}
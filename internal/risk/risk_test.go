@@ -0,0 +1,248 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+// newLedger returns a memory repository holding accounts 1 to 4, opened now,
+// and transfers of 10 between the given pairs of accounts.
+func newLedger(t *testing.T, history ...[2]int64) *repository.MemoryRepository {
+	t.Helper()
+	ctx := context.Background()
+	ledger := repository.NewMemoryRepository()
+	for id := int64(1); id <= 4; id++ {
+		if _, err := ledger.CreateAccount(ctx, domain.Account{ID: id, Balance: amount("10000")}); err != nil {
+			t.Fatalf("create account %d: %v", id, err)
+		}
+	}
+	for _, pair := range history {
+		if _, err := ledger.TransferMoney(ctx, domain.Transaction{SourceAccountID: pair[0], DestinationAccountID: pair[1], Amount: amount("10")}); err != nil {
+			t.Fatalf("transfer: %v", err)
+		}
+	}
+	return ledger
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rules       string
+		history     [][2]int64
+		later       time.Duration
+		source      int64
+		destination int64
+		value       string
+		decision    string
+		matched     []string
+	}{
+		{
+			name:   "no_rule_matches",
+			rules:  `{name: large, type: amount_threshold, action: review, amount: "1000"}`,
+			source: 1, destination: 2, value: "1000",
+			decision: domain.RiskAllow,
+		},
+		{
+			name:   "amount_threshold",
+			rules:  `{name: large, type: amount_threshold, action: review, amount: "1000"}`,
+			source: 1, destination: 2, value: "1000.01",
+			decision: domain.RiskReview,
+			matched:  []string{"large"},
+		},
+		{
+			name:   "new_source_account",
+			rules:  `{name: new, type: new_account, action: deny, period: 24h, min_amount: "100"}`,
+			source: 1, destination: 2, value: "100",
+			decision: domain.RiskDeny,
+			matched:  []string{"new"},
+		},
+		{
+			name:   "new_account_below_min_amount",
+			rules:  `{name: new, type: new_account, action: deny, period: 24h, min_amount: "100"}`,
+			source: 1, destination: 2, value: "99.99",
+			decision: domain.RiskAllow,
+		},
+		{
+			name:   "cooling_period_over",
+			rules:  `{name: new, type: new_account, action: deny, period: 24h}`,
+			later:  25 * time.Hour,
+			source: 1, destination: 2, value: "100",
+			decision: domain.RiskAllow,
+		},
+		{
+			name:   "new_destination_account",
+			rules:  `{name: new, type: new_account, action: review, period: 24h, side: destination}`,
+			source: 1, destination: 2, value: "100",
+			decision: domain.RiskReview,
+			matched:  []string{"new"},
+		},
+		{
+			name:   "missing_account_left_to_the_transfer",
+			rules:  `{name: new, type: new_account, action: deny, period: 24h, side: both}`,
+			source: 9, destination: 8, value: "100",
+			decision: domain.RiskAllow,
+		},
+		{
+			name:    "unusual_counterparty",
+			rules:   `{name: unusual, type: unusual_counterparty, action: review, lookback: 10, min_amount: "50"}`,
+			history: [][2]int64{{1, 2}, {3, 1}},
+			source:  1, destination: 3, value: "50",
+			decision: domain.RiskReview,
+			matched:  []string{"unusual"},
+		},
+		{
+			name:    "known_counterparty",
+			rules:   `{name: unusual, type: unusual_counterparty, action: review, lookback: 10, min_amount: "50"}`,
+			history: [][2]int64{{1, 2}, {3, 1}},
+			source:  1, destination: 2, value: "50",
+			decision: domain.RiskAllow,
+		},
+		{
+			name:    "counterparty_beyond_lookback",
+			rules:   `{name: unusual, type: unusual_counterparty, action: review, lookback: 2}`,
+			history: [][2]int64{{1, 2}, {1, 3}, {1, 4}},
+			source:  1, destination: 2, value: "50",
+			decision: domain.RiskReview,
+			matched:  []string{"unusual"},
+		},
+		{
+			name:    "velocity_count",
+			rules:   `{name: velocity, type: velocity, action: deny, window: 1h, max_count: 3}`,
+			history: [][2]int64{{1, 2}, {1, 3}, {2, 1}, {1, 4}},
+			source:  1, destination: 2, value: "10",
+			decision: domain.RiskDeny,
+			matched:  []string{"velocity"},
+		},
+		{
+			name:    "velocity_window_passed",
+			rules:   `{name: velocity, type: velocity, action: deny, window: 1h, max_count: 3}`,
+			history: [][2]int64{{1, 2}, {1, 3}, {1, 4}},
+			later:   2 * time.Hour,
+			source:  1, destination: 2, value: "10",
+			decision: domain.RiskAllow,
+		},
+		{
+			name:    "velocity_amount",
+			rules:   `{name: velocity, type: velocity, action: review, window: 1h, max_amount: "25"}`,
+			history: [][2]int64{{1, 2}},
+			source:  1, destination: 2, value: "15.01",
+			decision: domain.RiskReview,
+			matched:  []string{"velocity"},
+		},
+		{
+			name: "strictest_action_wins",
+			rules: `{name: large, type: amount_threshold, action: review, amount: "1000"}
+  - {name: new, type: new_account, action: deny, period: 24h}
+  - {name: velocity, type: velocity, action: review, window: 1h, max_count: 5}`,
+			source: 1, destination: 2, value: "5000",
+			decision: domain.RiskDeny,
+			matched:  []string{"large", "new"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rules, err := ParseRules(strings.NewReader("rules:\n  - " + testCase.rules + "\n"))
+			if err != nil {
+				t.Fatalf("parse rules: %v", err)
+			}
+			engine := NewEngine(newLedger(t, testCase.history...), rules...)
+			engine.now = func() time.Time { return time.Now().Add(testCase.later) }
+
+			decision, err := engine.Evaluate(context.Background(), domain.Transaction{
+				SourceAccountID:      testCase.source,
+				DestinationAccountID: testCase.destination,
+				Amount:               amount(testCase.value),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decision.Decision != testCase.decision {
+				t.Fatalf("expected %s, got %s with %+v", testCase.decision, decision.Decision, decision.Reasons)
+			}
+			var matched []string
+			for _, reason := range decision.Reasons {
+				if reason.Reason == "" {
+					t.Fatalf("expected rule %s to give a reason", reason.Rule)
+				}
+				matched = append(matched, reason.Rule)
+			}
+			if !slices.Equal(matched, testCase.matched) {
+				t.Fatalf("expected rules %v to match, got %v", testCase.matched, matched)
+			}
+			if decision.SourceAccountID != testCase.source || decision.DestinationAccountID != testCase.destination || !decision.Amount.Equal(amount(testCase.value)) {
+				t.Fatalf("expected the decision to describe the transfer, got %+v", decision)
+			}
+		})
+	}
+}
+
+type failingLedger struct {
+	repository.Repository
+}
+
+func (failingLedger) ListTransactions(context.Context, int64, domain.TransactionFilter) ([]domain.Transaction, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestEngine_Evaluate_FailsWhenTheLedgerFails(t *testing.T) {
+	engine := NewEngine(failingLedger{}, Velocity{RuleBase: RuleBase{RuleName: "velocity", RuleAction: domain.RiskDeny}, Window: time.Hour, MaxCount: 1})
+	_, err := engine.Evaluate(context.Background(), domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount("1")})
+	if err == nil || !strings.Contains(err.Error(), "velocity") {
+		t.Fatalf("expected an error naming the rule, got %v", err)
+	}
+}
+
+func TestParseRules_RejectsInvalidRules(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{"unknown_key", `[{name: a, type: amount_threshold, action: deny, amount: "1", limit: 2}]`, "limit"},
+		{"unknown_type", `[{name: a, type: geography, action: deny}]`, "geography"},
+		{"missing_name", `[{type: amount_threshold, action: deny, amount: "1"}]`, "name is required"},
+		{"bad_action", `[{name: a, type: amount_threshold, action: block, amount: "1"}]`, "action"},
+		{"missing_amount", `[{name: a, type: amount_threshold, action: deny}]`, "amount is required"},
+		{"bad_amount", `[{name: a, type: amount_threshold, action: deny, amount: lots}]`, "amount"},
+		{"bad_period", `[{name: a, type: new_account, action: deny, period: 3d}]`, "period"},
+		{"bad_side", `[{name: a, type: new_account, action: deny, period: 1h, side: sender}]`, "side"},
+		{"lookback_too_large", `[{name: a, type: unusual_counterparty, action: review, lookback: 5000}]`, "lookback"},
+		{"velocity_without_limits", `[{name: a, type: velocity, action: deny, window: 1h}]`, "max_count or max_amount"},
+		{"duplicate_name", `[{name: a, type: amount_threshold, action: deny, amount: "1"}, {name: a, type: amount_threshold, action: review, amount: "2"}]`, "used twice"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := ParseRules(strings.NewReader("rules: " + testCase.rules))
+			if !errors.Is(err, ErrInvalidRules) || !strings.Contains(err.Error(), testCase.want) {
+				t.Fatalf("expected ErrInvalidRules mentioning %q, got %v", testCase.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadRules_ReadsTheExampleFile(t *testing.T) {
+	rules, err := LoadRules("../../risk-rules.example.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules, got %d", len(rules))
+	}
+	if _, err := LoadRules("missing.yaml"); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,162 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+// historyPageSize is how many transactions rules read from the ledger at a
+// time.
+const historyPageSize = 100
+
+// Sides of a transfer the new account rule checks.
+const (
+	SideSource      = "source"
+	SideDestination = "destination"
+	SideBoth        = "both"
+)
+
+// RuleBase holds the name and action every rule is configured with.
+type RuleBase struct {
+	RuleName   string
+	RuleAction string
+}
+
+func (b RuleBase) Name() string   { return b.RuleName }
+func (b RuleBase) Action() string { return b.RuleAction }
+
+// AmountThreshold matches transfers of more than Amount.
+type AmountThreshold struct {
+	RuleBase
+	Amount decimal.Decimal
+}
+
+func (r AmountThreshold) Match(_ context.Context, _ Ledger, transfer Transfer) (string, error) {
+	if transfer.Amount.GreaterThan(r.Amount) {
+		return fmt.Sprintf("amount %s exceeds %s", transfer.Amount, r.Amount), nil
+	}
+	return "", nil
+}
+
+// NewAccount matches transfers of at least MinAmount that involve an account,
+// on Side of the transfer, opened less than Period ago. An account that does
+// not exist is left for the transfer itself to reject.
+type NewAccount struct {
+	RuleBase
+	Period    time.Duration
+	MinAmount decimal.Decimal
+	Side      string
+}
+
+func (r NewAccount) Match(ctx context.Context, ledger Ledger, transfer Transfer) (string, error) {
+	if transfer.Amount.LessThan(r.MinAmount) {
+		return "", nil
+	}
+	sides := []struct {
+		name string
+		id   int64
+	}{{SideSource, transfer.SourceAccountID}, {SideDestination, transfer.DestinationAccountID}}
+	for _, side := range sides {
+		if r.Side != SideBoth && r.Side != side.name {
+			continue
+		}
+		account, err := ledger.GetAccount(ctx, strconv.FormatInt(side.id, 10))
+		if errors.Is(err, repository.ErrAccountNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if age := transfer.At.Sub(account.CreatedAt); age < r.Period {
+			return fmt.Sprintf("%s account %d was opened %s ago, within the %s cooling period", side.name, side.id, age.Round(time.Second), r.Period), nil
+		}
+	}
+	return "", nil
+}
+
+// UnusualCounterparty matches transfers of at least MinAmount to an account
+// the source sent none of its last Lookback transactions to. The history is
+// read before the transfer's database transaction, so concurrent first
+// transfers to the same account are each checked without the others.
+type UnusualCounterparty struct {
+	RuleBase
+	Lookback  int
+	MinAmount decimal.Decimal
+}
+
+func (r UnusualCounterparty) Match(ctx context.Context, ledger Ledger, transfer Transfer) (string, error) {
+	if transfer.Amount.LessThan(r.MinAmount) {
+		return "", nil
+	}
+	transactions, err := ledger.ListTransactions(ctx, transfer.SourceAccountID, domain.TransactionFilter{Limit: r.Lookback})
+	if err != nil {
+		return "", err
+	}
+	for _, transaction := range transactions {
+		if transaction.Type == domain.TransactionTypeTransfer &&
+			transaction.SourceAccountID == transfer.SourceAccountID &&
+			transaction.DestinationAccountID == transfer.DestinationAccountID {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("account %d sent nothing to account %d in its last %d transactions", transfer.SourceAccountID, transfer.DestinationAccountID, r.Lookback), nil
+}
+
+// Velocity matches a transfer that would take the transfers out of its source
+// within the last Window above MaxCount, or their total above MaxAmount. A
+// zero limit is not checked. The history is read newest first and only as far
+// as needed, before the transfer's database transaction: transfers from the
+// same source that run at the same time do not see each other, so a burst of
+// them can go past MaxCount or MaxAmount.
+type Velocity struct {
+	RuleBase
+	Window    time.Duration
+	MaxCount  int
+	MaxAmount decimal.Decimal
+}
+
+func (r Velocity) Match(ctx context.Context, ledger Ledger, transfer Transfer) (string, error) {
+	since := transfer.At.Add(-r.Window)
+	count, total := 1, transfer.Amount
+	var beforeID int64
+	for {
+		page, err := ledger.ListTransactions(ctx, transfer.SourceAccountID, domain.TransactionFilter{Limit: historyPageSize, BeforeID: beforeID, Type: domain.TransactionTypeTransfer})
+		if err != nil {
+			return "", err
+		}
+		for _, transaction := range page {
+			if transaction.CreatedAt.Before(since) {
+				return r.reason(transfer, count, total), nil
+			}
+			if transaction.SourceAccountID != transfer.SourceAccountID {
+				continue
+			}
+			count++
+			total = total.Add(transaction.Amount)
+			if reason := r.reason(transfer, count, total); reason != "" {
+				return reason, nil
+			}
+		}
+		if len(page) < historyPageSize {
+			return r.reason(transfer, count, total), nil
+		}
+		beforeID = page[len(page)-1].ID
+	}
+}
+
+func (r Velocity) reason(transfer Transfer, count int, total decimal.Decimal) string {
+	switch {
+	case r.MaxCount > 0 && count > r.MaxCount:
+		return fmt.Sprintf("account %d would make %d transfers within %s, more than %d", transfer.SourceAccountID, count, r.Window, r.MaxCount)
+	case r.MaxAmount.IsPositive() && total.GreaterThan(r.MaxAmount):
+		return fmt.Sprintf("account %d would send %s within %s, more than %s", transfer.SourceAccountID, total, r.Window, r.MaxAmount)
+	}
+	return ""
+}
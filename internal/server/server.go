@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/health"
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"go.uber.org/zap"
+)
+
+var ErrDrainTimeout = errors.New("drain timeout exceeded")
+
+type Options struct {
+	Addr    string
+	Handler http.Handler
+	Checker *health.Checker
+
+	// DrainTimeout bounds how long in-flight requests and background workers
+	// may take to finish once shutdown starts.
+	DrainTimeout time.Duration
+	// ShutdownDelay keeps serving while reporting not ready, giving load
+	// balancers time to observe the readiness change before the listener closes.
+	ShutdownDelay time.Duration
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+type Server struct {
+	options    Options
+	httpServer *http.Server
+	workers    []worker
+	closers    []closer
+}
+
+func New(options Options) *Server {
+	return &Server{
+		options: options,
+		httpServer: &http.Server{
+			Addr:              options.Addr,
+			Handler:           options.Handler,
+			ReadTimeout:       options.ReadTimeout,
+			ReadHeaderTimeout: options.ReadHeaderTimeout,
+			WriteTimeout:      options.WriteTimeout,
+			IdleTimeout:       options.IdleTimeout,
+		},
+	}
+}
+
+// AddWorker registers a background worker. It is started with Run and its
+// context is canceled once the HTTP server has drained.
+func (s *Server) AddWorker(name string, run func(ctx context.Context)) {
+	s.workers = append(s.workers, worker{name: name, run: run})
+}
+
+// OnShutdown registers a cleanup step run after workers have stopped.
+// Steps run in reverse registration order.
+func (s *Server) OnShutdown(name string, close func(ctx context.Context) error) {
+	s.closers = append(s.closers, closer{name: name, close: close})
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.options.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts connections on listener until ctx is canceled and then shuts
+// down gracefully.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+
+	var workersDone sync.WaitGroup
+	for _, w := range s.workers {
+		workersDone.Add(1)
+		go func(w worker) {
+			defer workersDone.Done()
+			w.run(workerCtx)
+			logger.L().Info("background worker stopped", zap.String("worker", w.name))
+		}(w)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.L().Info("http server listening", zap.String("addr", listener.Addr().String()))
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger.L().Info("shutdown signal received, draining")
+	case err := <-serveErr:
+		if err != nil {
+			logger.L().Error("http server failed", zap.Error(err))
+			runErr = err
+		}
+	}
+
+	if s.options.Checker != nil {
+		s.options.Checker.SetDraining(true)
+	}
+	if s.options.ShutdownDelay > 0 && runErr == nil {
+		time.Sleep(s.options.ShutdownDelay)
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), s.options.DrainTimeout)
+	defer cancelDrain()
+
+	if err := s.httpServer.Shutdown(drainCtx); err != nil {
+		logger.L().Error("http server did not drain in time, closing connections", zap.Error(err))
+		_ = s.httpServer.Close()
+		if runErr == nil {
+			runErr = ErrDrainTimeout
+		}
+	}
+
+	cancelWorkers()
+	workersStopped := make(chan struct{})
+	go func() {
+		workersDone.Wait()
+		close(workersStopped)
+	}()
+	select {
+	case <-workersStopped:
+	case <-drainCtx.Done():
+		logger.L().Error("background workers did not stop in time")
+		if runErr == nil {
+			runErr = ErrDrainTimeout
+		}
+	}
+
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), s.options.DrainTimeout)
+	defer cancelClose()
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		c := s.closers[i]
+		if err := c.close(closeCtx); err != nil {
+			logger.L().Error("shutdown step failed", zap.String("step", c.name), zap.Error(err))
+		}
+	}
+
+	logger.L().Info("shutdown complete")
+	return runErr
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/health"
+)
+
+func startServer(t *testing.T, srv *Server) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, listener) }()
+	return "http://" + listener.Addr().String(), cancel, done
+}
+
+func TestServer_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+
+	checker := health.NewChecker(time.Second)
+	srv := New(Options{Handler: mux, Checker: checker, DrainTimeout: 5 * time.Second})
+
+	var workerStopped, poolClosed atomic.Bool
+	srv.AddWorker("test", func(ctx context.Context) {
+		<-ctx.Done()
+		workerStopped.Store(true)
+	})
+	srv.OnShutdown("pool", func(context.Context) error {
+		if !workerStopped.Load() {
+			t.Errorf("expected workers to stop before shutdown steps run")
+		}
+		poolClosed.Store(true)
+		return nil
+	})
+
+	baseURL, cancel, done := startServer(t, srv)
+
+	responseBody := make(chan string, 1)
+	go func() {
+		response, err := http.Get(baseURL + "/slow")
+		if err != nil {
+			responseBody <- "error: " + err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		responseBody <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !checker.Draining() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected checker to report draining after shutdown started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("server returned before in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-responseBody; got != "done" {
+		t.Fatalf("expected in-flight request to complete, got %q", got)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+	if !workerStopped.Load() || !poolClosed.Load() {
+		t.Fatalf("expected worker stopped and pool closed, got worker=%v pool=%v", workerStopped.Load(), poolClosed.Load())
+	}
+}
+
+func TestServer_DrainTimeoutExceeded(t *testing.T) {
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	srv := New(Options{Handler: mux, DrainTimeout: 100 * time.Millisecond})
+	var closed atomic.Bool
+	srv.OnShutdown("pool", func(context.Context) error {
+		closed.Store(true)
+		return nil
+	})
+
+	baseURL, cancel, done := startServer(t, srv)
+	go func() {
+		response, err := http.Get(baseURL + "/stuck")
+		if err == nil {
+			response.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrDrainTimeout) {
+			t.Fatalf("expected ErrDrainTimeout, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("server did not stop after drain timeout")
+	}
+	if !closed.Load() {
+		t.Fatalf("expected shutdown steps to run after drain timeout")
+	}
+}
+
+func TestServer_RejectsNewConnectionsAfterShutdown(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	srv := New(Options{Handler: mux, DrainTimeout: time.Second})
+	baseURL, cancel, done := startServer(t, srv)
+
+	response, err := http.Get(baseURL + "/")
+	if err != nil {
+		t.Fatalf("request before shutdown failed: %v", err)
+	}
+	response.Body.Close()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	client := http.Client{Timeout: time.Second, Transport: &http.Transport{DisableKeepAlives: true}}
+	if _, err := client.Get(baseURL + "/"); err == nil {
+		t.Fatalf("expected request after shutdown to fail")
+	}
+}
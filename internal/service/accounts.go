@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// MaxAccountTags bounds how many tags an account carries and a listing
+	// filters on.
+	MaxAccountTags = 16
+	// MaxAccountTagLength bounds the length of a tag in bytes.
+	MaxAccountTagLength = 64
+	// MaxBatchGetAccounts bounds how many accounts one batch lookup covers.
+	MaxBatchGetAccounts = 100
+)
+
+// ListAccounts pages through the accounts that match filter, by ID unless
+// filter sorts them otherwise.
+func (s DefaultService) ListAccounts(ctx context.Context, filter domain.AccountFilter) (_ []domain.Account, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.ListAccounts", trace.WithAttributes(
+		attribute.String("accounts.sort", filter.Sort),
+	))
+	defer func() { endSpan(span, err) }()
+
+	switch filter.Status {
+	case "", domain.AccountStatusActive, domain.AccountStatusFrozen:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidAccountQuery, filter.Status)
+	}
+	switch filter.Sort {
+	case "":
+		filter.Sort = domain.AccountSortID
+	case domain.AccountSortID, domain.AccountSortBalance, domain.AccountSortCreatedAt:
+	default:
+		return nil, fmt.Errorf("%w: sort must be %s, %s or %s", ErrInvalidAccountQuery, domain.AccountSortID, domain.AccountSortBalance, domain.AccountSortCreatedAt)
+	}
+	if filter.MinBalance != nil && filter.MaxBalance != nil && filter.MinBalance.GreaterThan(*filter.MaxBalance) {
+		return nil, fmt.Errorf("%w: min_balance is greater than max_balance", ErrInvalidAccountQuery)
+	}
+	if !filter.CreatedSince.IsZero() && !filter.CreatedUntil.IsZero() && !filter.CreatedSince.Before(filter.CreatedUntil) {
+		return nil, fmt.Errorf("%w: created_since must be before created_until", ErrInvalidAccountQuery)
+	}
+	if filter.Tags, err = normalizeAccountTags(filter.Tags); err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	accounts, err := s.repository.ListAccounts(ctx, filter)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return accounts, nil
+}
+
+// BatchGetAccounts looks up to MaxBatchGetAccounts accounts up at once and
+// returns them in the order they were asked for without repeats.
+func (s DefaultService) BatchGetAccounts(ctx context.Context, accountIDs []int64) (_ *domain.AccountBatch, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.BatchGetAccounts", trace.WithAttributes(
+		attribute.Int("accounts", len(accountIDs)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	unique, err := uniqueAccountIDs(accountIDs, MaxBatchGetAccounts, ErrInvalidAccountQuery)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.repository.GetAccounts(ctx, unique)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	byID := make(map[int64]domain.Account, len(accounts))
+	for _, account := range accounts {
+		byID[account.ID] = account
+	}
+	batch := &domain.AccountBatch{Accounts: make([]domain.Account, 0, len(accounts))}
+	for _, accountID := range unique {
+		account, ok := byID[accountID]
+		if !ok {
+			batch.NotFound = append(batch.NotFound, accountID)
+			continue
+		}
+		batch.Accounts = append(batch.Accounts, account)
+	}
+	return batch, nil
+}
+
+// uniqueAccountIDs checks that between 1 and limit positive IDs were asked
+// for and drops the repeats, keeping the order of the rest.
+func uniqueAccountIDs(accountIDs []int64, limit int, invalid error) ([]int64, error) {
+	if len(accountIDs) == 0 || len(accountIDs) > limit {
+		return nil, fmt.Errorf("%w: ask for 1 to %d accounts", invalid, limit)
+	}
+	unique := make([]int64, 0, len(accountIDs))
+	seen := make(map[int64]bool, len(accountIDs))
+	for _, accountID := range accountIDs {
+		if accountID <= 0 {
+			return nil, ErrInvalidAccountIDs
+		}
+		if !seen[accountID] {
+			seen[accountID] = true
+			unique = append(unique, accountID)
+		}
+	}
+	return unique, nil
+}
+
+// normalizeAccountTags checks tags and returns them sorted without repeats.
+// A tag is 1 to MaxAccountTagLength letters, digits and any of "-_.:".
+func normalizeAccountTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	normalized := slices.Clone(tags)
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > MaxAccountTags {
+		return nil, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidAccountTags, MaxAccountTags)
+	}
+	for _, tag := range normalized {
+		if !isAccountTag(tag) {
+			return nil, fmt.Errorf("%w: %q must be 1 to %d letters, digits or any of -_.:", ErrInvalidAccountTags, tag, MaxAccountTagLength)
+		}
+	}
+	return normalized, nil
+}
+
+func isAccountTag(tag string) bool {
+	if tag == "" || len(tag) > MaxAccountTagLength {
+		return false
+	}
+	for _, r := range tag {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
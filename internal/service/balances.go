@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MaxBalanceAccounts bounds how many accounts one balance query covers.
+const MaxBalanceAccounts = 100
+
+func (s DefaultService) BalanceAt(ctx context.Context, accountID int64, asOf time.Time) (_ *domain.AccountBalance, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.BalanceAt", trace.WithAttributes(
+		attribute.Int64("account.id", accountID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if accountID <= 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+	balance, err := s.repository.BalanceAt(ctx, accountID, asOf)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &domain.AccountBalance{AccountID: accountID, Balance: balance}, nil
+}
+
+// BalancesAt returns the balances of up to MaxBalanceAccounts accounts at the
+// same instant, in the order they were asked for without repeats.
+func (s DefaultService) BalancesAt(ctx context.Context, accountIDs []int64, asOf time.Time) (_ *domain.BalanceReport, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.BalancesAt", trace.WithAttributes(
+		attribute.Int("accounts", len(accountIDs)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	unique, err := uniqueAccountIDs(accountIDs, MaxBalanceAccounts, ErrInvalidBalanceQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := s.repository.BalancesAt(ctx, unique, asOf)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	report := &domain.BalanceReport{AsOf: asOf, Balances: make([]domain.AccountBalance, 0, len(balances))}
+	for _, accountID := range unique {
+		balance, ok := balances[accountID]
+		if !ok {
+			report.NotFound = append(report.NotFound, accountID)
+			continue
+		}
+		report.Balances = append(report.Balances, domain.AccountBalance{AccountID: accountID, Balance: balance})
+	}
+	return report, nil
+}
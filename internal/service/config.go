@@ -0,0 +1,50 @@
+package service
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/config"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/risk"
+)
+
+// NewFromConfig builds the application service with the funding, fee,
+// precision and risk settings of appConfig. It fails when the risk rules
+// file cannot be read or is invalid.
+func NewFromConfig(storage repository.Repository, appConfig *config.Config) (Service, error) {
+	options := []Option{
+		WithFunding(Funding{
+			DepositClearingAccountID:    appConfig.Funding.DepositClearingAccountID,
+			WithdrawalClearingAccountID: appConfig.Funding.WithdrawalClearingAccountID,
+			MaxDepositAmount:            amountLimit(appConfig.Funding.MaxDepositAmount),
+			MaxWithdrawalAmount:         amountLimit(appConfig.Funding.MaxWithdrawalAmount),
+		}),
+		WithFeeAccount(appConfig.Fees.RevenueAccountID),
+		WithPrecision(moneyPrecision(appConfig.Money)),
+	}
+	if appConfig.Risk.RulesFile != "" {
+		rules, err := risk.LoadRules(appConfig.Risk.RulesFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithRiskEngine(risk.NewEngine(storage, rules...)))
+	}
+	return NewService(storage, options...), nil
+}
+
+// moneyPrecision builds the precision policy from a validated configuration.
+func moneyPrecision(money config.MoneyConfig) Precision {
+	precision := Precision{Scale: money.Scale, MaxAmount: MaxStorageAmount, Rounding: money.Rounding}
+	if money.MaxAmount != "" {
+		precision.MaxAmount = decimal.RequireFromString(money.MaxAmount)
+	}
+	return precision
+}
+
+// amountLimit parses a limit the configuration has already validated; empty
+// means no limit.
+func amountLimit(value string) decimal.Decimal {
+	if value == "" {
+		return decimal.Zero
+	}
+	return decimal.RequireFromString(value)
+}
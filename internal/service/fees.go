@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+func (s DefaultService) ListFeeRules(ctx context.Context) (_ []domain.FeeRule, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.ListFeeRules")
+	defer func() { endSpan(span, err) }()
+
+	rules, err := s.repository.ListFeeRules(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return rules, nil
+}
+
+func (s DefaultService) CreateFeeRule(ctx context.Context, rule domain.FeeRule) (_ *domain.FeeRule, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.CreateFeeRule", trace.WithAttributes(
+		attribute.String("fee_rule.kind", rule.Kind),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := validateFeeRule(rule); err != nil {
+		return nil, err
+	}
+	created, err := s.repository.CreateFeeRule(ctx, rule)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return created, nil
+}
+
+func (s DefaultService) UpdateFeeRule(ctx context.Context, rule domain.FeeRule) (_ *domain.FeeRule, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.UpdateFeeRule", trace.WithAttributes(
+		attribute.Int64("fee_rule.id", rule.ID),
+		attribute.String("fee_rule.kind", rule.Kind),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if rule.ID <= 0 {
+		return nil, ErrFeeRuleNotFound
+	}
+	if err := validateFeeRule(rule); err != nil {
+		return nil, err
+	}
+	updated, err := s.repository.UpdateFeeRule(ctx, rule)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return updated, nil
+}
+
+func (s DefaultService) DeleteFeeRule(ctx context.Context, ruleID int64) (err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.DeleteFeeRule", trace.WithAttributes(
+		attribute.Int64("fee_rule.id", ruleID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if ruleID <= 0 {
+		return ErrFeeRuleNotFound
+	}
+	return translateError(s.repository.DeleteFeeRule(ctx, ruleID))
+}
+
+// transferFee evaluates the fee rules against a transfer. It returns nil when
+// no fee account is configured, the fee account itself is paying, or no rule
+// charges anything. The tier of the source is read before the transfer's
+// database transaction; that cannot race because a tier is fixed when the
+// account is created.
+func (s DefaultService) transferFee(ctx context.Context, transaction domain.Transaction) (*domain.Fee, error) {
+	if s.feeAccountID == 0 || transaction.SourceAccountID == s.feeAccountID {
+		return nil, nil
+	}
+	rules, err := s.repository.ListFeeRules(ctx)
+	if err != nil || len(rules) == 0 {
+		return nil, translateError(err)
+	}
+	source, err := s.repository.GetAccount(ctx, strconv.FormatInt(transaction.SourceAccountID, 10))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	fee := &domain.Fee{AccountID: s.feeAccountID, Breakdown: []domain.FeeCharge{}}
+	for _, rule := range rules {
+		if rule.AccountTier != "" && rule.AccountTier != source.Tier {
+			continue
+		}
+		charge := feeCharge(rule, transaction.Amount, s.precision.Scale)
+		if !charge.IsPositive() {
+			continue
+		}
+		fee.Amount = fee.Amount.Add(charge)
+		fee.Breakdown = append(fee.Breakdown, domain.FeeCharge{RuleID: rule.ID, Rule: rule.Name, Amount: charge})
+	}
+	if !fee.Amount.IsPositive() {
+		return nil, nil
+	}
+	return fee, nil
+}
+
+// feeCharge is what rule charges on a transfer of amount, rounded to scale
+// decimal places.
+func feeCharge(rule domain.FeeRule, amount decimal.Decimal, scale int32) decimal.Decimal {
+	var charge decimal.Decimal
+	switch rule.Kind {
+	case domain.FeeKindFlat:
+		charge = *rule.Amount
+	case domain.FeeKindPercentage:
+		charge = amount.Mul(*rule.Percent).Div(hundred)
+		if rule.MinFee != nil {
+			charge = decimal.Max(charge, *rule.MinFee)
+		}
+		if rule.MaxFee != nil {
+			charge = decimal.Min(charge, *rule.MaxFee)
+		}
+	case domain.FeeKindTiered:
+		for _, tier := range rule.Tiers {
+			if tier.UpTo == nil || amount.LessThanOrEqual(*tier.UpTo) {
+				charge = tier.Amount.Add(amount.Mul(tier.Percent).Div(hundred))
+				break
+			}
+		}
+	}
+	return charge.Round(scale)
+}
+
+func validateFeeRule(rule domain.FeeRule) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidFeeRule, fmt.Sprintf(format, args...))
+	}
+	if rule.Name == "" {
+		return invalid("name is required")
+	}
+
+	switch rule.Kind {
+	case domain.FeeKindFlat:
+		if rule.Amount == nil || !rule.Amount.IsPositive() {
+			return invalid("a flat rule needs a positive amount")
+		}
+		if rule.Percent != nil || rule.MinFee != nil || rule.MaxFee != nil || len(rule.Tiers) > 0 {
+			return invalid("a flat rule only takes an amount")
+		}
+	case domain.FeeKindPercentage:
+		if rule.Percent == nil || !validPercent(*rule.Percent) || rule.Percent.IsZero() {
+			return invalid("a percentage rule needs a percent above 0 and at most 100")
+		}
+		if rule.Amount != nil || len(rule.Tiers) > 0 {
+			return invalid("a percentage rule takes a percent, min_fee and max_fee")
+		}
+		if (rule.MinFee != nil && rule.MinFee.IsNegative()) || (rule.MaxFee != nil && rule.MaxFee.IsNegative()) {
+			return invalid("min_fee and max_fee must not be negative")
+		}
+		if rule.MinFee != nil && rule.MaxFee != nil && rule.MinFee.GreaterThan(*rule.MaxFee) {
+			return invalid("min_fee must not exceed max_fee")
+		}
+	case domain.FeeKindTiered:
+		if len(rule.Tiers) == 0 {
+			return invalid("a tiered rule needs at least one tier")
+		}
+		if rule.Amount != nil || rule.Percent != nil || rule.MinFee != nil || rule.MaxFee != nil {
+			return invalid("a tiered rule only takes tiers")
+		}
+		for i, tier := range rule.Tiers {
+			if tier.Amount.IsNegative() || !validPercent(tier.Percent) {
+				return invalid("tier %d: amount must not be negative and percent must be between 0 and 100", i+1)
+			}
+			if tier.UpTo == nil {
+				if i != len(rule.Tiers)-1 {
+					return invalid("tier %d: only the last tier may leave up_to open", i+1)
+				}
+				continue
+			}
+			if !tier.UpTo.IsPositive() || (i > 0 && !tier.UpTo.GreaterThan(*rule.Tiers[i-1].UpTo)) {
+				return invalid("tier %d: up_to must be positive and greater than that of the tier before", i+1)
+			}
+		}
+	default:
+		return invalid("kind must be one of %s, %s, %s", domain.FeeKindFlat, domain.FeeKindPercentage, domain.FeeKindTiered)
+	}
+	return nil
+}
+
+func validPercent(percent decimal.Decimal) bool {
+	return !percent.IsNegative() && percent.LessThanOrEqual(hundred)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// interestRateScale matches the NUMERIC(9, 6) column rates are stored in.
+	interestRateScale = 6
+
+	// MaxInterestReportDays bounds the period of an interest report.
+	MaxInterestReportDays = 366
+)
+
+func (s DefaultService) SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (_ *domain.InterestRate, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.SetInterestRate", trace.WithAttributes(
+		attribute.Int64("account.id", accountID),
+		attribute.String("interest.annual_rate", annualRate.String()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if accountID <= 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+	if annualRate.IsNegative() || annualRate.GreaterThan(hundred) || !annualRate.Equal(annualRate.Round(interestRateScale)) {
+		return nil, ErrInvalidInterestRate
+	}
+	rate, err := s.repository.SetInterestRate(ctx, accountID, annualRate)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return rate, nil
+}
+
+func (s DefaultService) ListInterestRates(ctx context.Context) (_ []domain.InterestRate, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.ListInterestRates")
+	defer func() { endSpan(span, err) }()
+
+	rates, err := s.repository.ListInterestRates(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return rates, nil
+}
+
+// InterestReport sums the interest accrued and posted per account over the
+// days from filter.From to filter.To, both inclusive, and lists the daily
+// accruals when the report is for one account.
+func (s DefaultService) InterestReport(ctx context.Context, filter domain.InterestAccrualFilter) (_ *domain.InterestReport, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.InterestReport", trace.WithAttributes(
+		attribute.Int64("account.id", filter.AccountID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if filter.AccountID < 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+	filter.From, filter.To = startOfDay(filter.From), startOfDay(filter.To)
+	if filter.From.IsZero() || filter.To.Before(filter.From) || filter.To.Sub(filter.From) >= MaxInterestReportDays*24*time.Hour {
+		return nil, ErrInvalidInterestQuery
+	}
+
+	accruals, err := s.repository.ListInterestAccruals(ctx, filter)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	report := &domain.InterestReport{From: filter.From, To: filter.To, Accounts: []domain.InterestAccountReport{}}
+	for _, accrual := range accruals {
+		// Accruals are ordered by account.
+		if len(report.Accounts) == 0 || report.Accounts[len(report.Accounts)-1].AccountID != accrual.AccountID {
+			report.Accounts = append(report.Accounts, domain.InterestAccountReport{AccountID: accrual.AccountID})
+		}
+		account := &report.Accounts[len(report.Accounts)-1]
+		account.Days++
+		account.Accrued = account.Accrued.Add(accrual.Amount)
+		report.TotalAccrued = report.TotalAccrued.Add(accrual.Amount)
+		if accrual.TransactionID != nil {
+			account.Posted = account.Posted.Add(accrual.Amount)
+			report.TotalPosted = report.TotalPosted.Add(accrual.Amount)
+		}
+	}
+	if filter.AccountID != 0 {
+		report.Accruals = accruals
+	}
+	return report, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rounding modes for amounts with more decimal places than the precision
+// policy allows. RoundingReject refuses such amounts instead.
+const (
+	RoundingReject   = "reject"
+	RoundingHalfEven = "half_even"
+	RoundingHalfUp   = "half_up"
+	RoundingDown     = "down"
+)
+
+// StorageScale and MaxStorageAmount are the limits of the NUMERIC(19, 4)
+// columns amounts and balances are stored in.
+const StorageScale = 4
+
+var MaxStorageAmount = decimal.New(1, 15).Sub(decimal.New(1, -StorageScale))
+
+// Precision is the money precision policy applied to every amount the
+// service accepts. The ledger holds a single currency, so one policy covers
+// every account. Scale is the number of decimal places an amount may have,
+// at most StorageScale, and MaxAmount bounds its absolute value, at most
+// MaxStorageAmount. Amounts with more decimal places are rounded toward
+// Scale with Rounding, or rejected when Rounding is RoundingReject.
+type Precision struct {
+	Scale     int32
+	MaxAmount decimal.Decimal
+	Rounding  string
+}
+
+func DefaultPrecision() Precision {
+	return Precision{Scale: StorageScale, MaxAmount: MaxStorageAmount, Rounding: RoundingReject}
+}
+
+func IsRounding(value string) bool {
+	switch value {
+	case RoundingReject, RoundingHalfEven, RoundingHalfUp, RoundingDown:
+		return true
+	}
+	return false
+}
+
+// apply brings amount within the policy, returning ErrInvalidAmountPrecision
+// or ErrAmountTooLarge when it cannot.
+func (p Precision) apply(amount decimal.Decimal) (decimal.Decimal, error) {
+	if amount.Abs().GreaterThan(p.MaxAmount) {
+		return amount, fmt.Errorf("%w: the largest allowed amount is %s", ErrAmountTooLarge, p.MaxAmount)
+	}
+	if amount.Equal(amount.Truncate(p.Scale)) {
+		return amount, nil
+	}
+	switch p.Rounding {
+	case RoundingHalfEven:
+		amount = amount.RoundBank(p.Scale)
+	case RoundingHalfUp:
+		amount = amount.Round(p.Scale)
+	case RoundingDown:
+		amount = amount.Truncate(p.Scale)
+	default:
+		return amount, fmt.Errorf("%w: amounts may have at most %d decimal places", ErrInvalidAmountPrecision, p.Scale)
+	}
+	// Rounding up can carry the amount past the limit.
+	if amount.Abs().GreaterThan(p.MaxAmount) {
+		return amount, fmt.Errorf("%w: the largest allowed amount is %s", ErrAmountTooLarge, p.MaxAmount)
+	}
+	return amount, nil
+}
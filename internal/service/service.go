@@ -3,66 +3,551 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/metrics"
 	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/risk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/tareqpi/transfer-system/internal/service")
+
 var (
 	ErrSameSourceAndDestination = errors.New("source and destination account IDs cannot be the same")
 	ErrNonPositiveAmount        = errors.New("amount should be greater than zero")
 	ErrInvalidAccountIDs        = errors.New("invalid account IDs")
 	ErrInsufficientBalance      = errors.New("insufficient balance")
+	ErrAccountNotFound          = errors.New("account not found")
+	ErrAccountExists            = errors.New("account already exists")
+	ErrAccountFrozen            = errors.New("account is frozen")
+	ErrTransactionNotFound      = errors.New("transaction not found")
+	ErrAlreadyReversed          = errors.New("transaction has already been reversed")
+	ErrNotReversible            = errors.New("reversal transactions cannot be reversed")
+	ErrTransactionConflict      = errors.New("transaction conflicted with a concurrent update, retry the request")
+	ErrInvalidAuditFilter       = errors.New("invalid audit filter")
+	ErrInvalidShardCount        = errors.New("shard count must be between 0 and 64")
+	ErrShardingUnsupported      = errors.New("sharded balances are not supported by this storage backend")
+	ErrVersionMismatch          = errors.New("account has been modified since the given version")
+	ErrFundingUnavailable       = errors.New("no clearing account is configured for this operation")
+	ErrClearingAccount          = errors.New("clearing accounts can only be used through deposits and withdrawals")
+	ErrAmountLimitExceeded      = errors.New("amount exceeds the limit for this operation")
+	ErrInvalidTransactionType   = errors.New("invalid transaction type")
+	ErrInvalidFeeRule           = errors.New("invalid fee rule")
+	ErrFeeRuleNotFound          = errors.New("fee rule not found")
+	ErrInvalidInterestRate      = errors.New("annual rate must be between 0 and 100 percent with at most 6 decimal places")
+	ErrInvalidInterestQuery     = errors.New("invalid interest report period")
+	ErrInvalidAmountPrecision   = errors.New("amount has too many decimal places")
+	ErrAmountTooLarge           = errors.New("amount is too large")
+	ErrInvalidBalanceQuery      = errors.New("invalid balance query")
+	ErrInvalidAccountTags       = errors.New("invalid account tags")
+	ErrInvalidAccountQuery      = errors.New("invalid account query")
+	ErrTransferDenied           = errors.New("transfer denied by risk rules")
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+
+	// MaxAccountShards bounds how many rows a balance can be split across.
+	// More shards spread contention further but make debits that exceed one
+	// shard, and GetAccount, more expensive.
+	MaxAccountShards = 64
 )
 
 type Service interface {
 	CreateAccount(ctx context.Context, newAccount domain.Account) (*domain.Account, error)
 	GetAccount(ctx context.Context, accountID string) (*domain.Account, error)
-	TransferMoney(ctx context.Context, transaction domain.Transaction) error
+	ListAccounts(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error)
+	BatchGetAccounts(ctx context.Context, accountIDs []int64) (*domain.AccountBatch, error)
+	TransferMoney(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error)
+	ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error)
+	ReverseTransaction(ctx context.Context, transactionID int64) (*domain.Transaction, error)
+	// Deposit and Withdraw move money between an account and the configured
+	// clearing accounts.
+	Deposit(ctx context.Context, accountID int64, amount decimal.Decimal) (*domain.Transaction, error)
+	Withdraw(ctx context.Context, accountID int64, amount decimal.Decimal) (*domain.Transaction, error)
+	// FreezeAccount, UnfreezeAccount and SetAccountShards fail with
+	// ErrVersionMismatch when expectedVersion is not 0 and the account has
+	// moved on from it.
+	FreezeAccount(ctx context.Context, accountID int64, expectedVersion int64) (*domain.Account, error)
+	UnfreezeAccount(ctx context.Context, accountID int64, expectedVersion int64) (*domain.Account, error)
+	SetAccountShards(ctx context.Context, accountID int64, shards int, expectedVersion int64) (*domain.Account, error)
+	ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
+	ListFeeRules(ctx context.Context) ([]domain.FeeRule, error)
+	CreateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error)
+	UpdateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error)
+	DeleteFeeRule(ctx context.Context, ruleID int64) error
+	SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (*domain.InterestRate, error)
+	ListInterestRates(ctx context.Context) ([]domain.InterestRate, error)
+	InterestReport(ctx context.Context, filter domain.InterestAccrualFilter) (*domain.InterestReport, error)
+	// BalanceAt and BalancesAt recompute balances at an instant from the
+	// transaction history.
+	BalanceAt(ctx context.Context, accountID int64, asOf time.Time) (*domain.AccountBalance, error)
+	BalancesAt(ctx context.Context, accountIDs []int64, asOf time.Time) (*domain.BalanceReport, error)
+	// ValidateAccount and ValidateTransfer run the checks of CreateAccount
+	// and TransferMoney that do not read the ledger.
+	ValidateAccount(newAccount domain.Account) error
+	ValidateTransfer(transaction domain.Transaction) error
 }
 
 type DefaultService struct {
-	repository repository.Repository
+	repository   repository.Repository
+	funding      Funding
+	feeAccountID int64
+	precision    Precision
+	risk         *risk.Engine
+}
+
+// Funding names the clearing accounts that stand for money outside the
+// ledger. Deposits are drawn from DepositClearingAccountID, which may go
+// negative, and withdrawals are paid into WithdrawalClearingAccountID. A zero
+// account disables the operation and a zero limit means no limit.
+type Funding struct {
+	DepositClearingAccountID    int64
+	WithdrawalClearingAccountID int64
+	MaxDepositAmount            decimal.Decimal
+	MaxWithdrawalAmount         decimal.Decimal
+}
+
+type Option func(*DefaultService)
+
+func WithFunding(funding Funding) Option {
+	return func(s *DefaultService) {
+		s.funding = funding
+	}
+}
+
+// WithFeeAccount turns on transfer fees, which are paid into the revenue
+// account accountID. A zero account charges no fees.
+func WithFeeAccount(accountID int64) Option {
+	return func(s *DefaultService) {
+		s.feeAccountID = accountID
+	}
+}
+
+// WithPrecision replaces the default precision policy, which rejects
+// amounts the database cannot store exactly.
+func WithPrecision(precision Precision) Option {
+	return func(s *DefaultService) {
+		s.precision = precision
+	}
+}
+
+// WithRiskEngine runs every transfer through the rules of engine before it is
+// executed. Every decision is recorded in the audit log, that of a transfer
+// which runs in the same database transaction. A denied transfer fails with
+// ErrTransferDenied and one flagged for review is executed with the decision
+// attached.
+func WithRiskEngine(engine *risk.Engine) Option {
+	return func(s *DefaultService) {
+		s.risk = engine
+	}
 }
 
-func NewService(dataRepository repository.Repository) Service {
-	return &DefaultService{repository: dataRepository}
+func NewService(dataRepository repository.Repository, options ...Option) Service {
+	s := &DefaultService{repository: dataRepository, precision: DefaultPrecision()}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
-func (s DefaultService) CreateAccount(ctx context.Context, newAccount domain.Account) (*domain.Account, error) {
+func (s DefaultService) CreateAccount(ctx context.Context, newAccount domain.Account) (_ *domain.Account, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.CreateAccount", trace.WithAttributes(
+		attribute.Int64("account.id", newAccount.ID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if newAccount, err = s.prepareAccount(newAccount); err != nil {
+		return nil, err
+	}
 	account, err := s.repository.CreateAccount(ctx, newAccount)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return account, nil
 }
 
-func (s DefaultService) GetAccount(ctx context.Context, accountID string) (*domain.Account, error) {
+func (s DefaultService) ValidateAccount(newAccount domain.Account) error {
+	_, err := s.prepareAccount(newAccount)
+	return err
+}
+
+// prepareAccount brings the balance within the precision policy and
+// normalizes the tags of an account to be created.
+func (s DefaultService) prepareAccount(newAccount domain.Account) (_ domain.Account, err error) {
+	if newAccount.Balance, err = s.precision.apply(newAccount.Balance); err != nil {
+		return newAccount, err
+	}
+	if newAccount.Tags, err = normalizeAccountTags(newAccount.Tags); err != nil {
+		return newAccount, err
+	}
+	return newAccount, nil
+}
+
+func (s DefaultService) GetAccount(ctx context.Context, accountID string) (_ *domain.Account, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.GetAccount", trace.WithAttributes(
+		attribute.String("account.id", accountID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if id, parseErr := strconv.ParseInt(accountID, 10, 64); parseErr != nil || id <= 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+
 	account, err := s.repository.GetAccount(ctx, accountID)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return account, nil
 }
 
-func (s DefaultService) TransferMoney(ctx context.Context, transaction domain.Transaction) error {
+func (s DefaultService) TransferMoney(ctx context.Context, transaction domain.Transaction) (_ *domain.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.TransferMoney", trace.WithAttributes(
+		attribute.Int64("transfer.source_account_id", transaction.SourceAccountID),
+		attribute.Int64("transfer.destination_account_id", transaction.DestinationAccountID),
+		attribute.String("transfer.amount", transaction.Amount.String()),
+	))
+	defer func() {
+		outcome := transferOutcome(err)
+		span.SetAttributes(attribute.String("transfer.outcome", outcome))
+		endSpan(span, err)
+		metrics.ObserveTransfer(outcome, transaction.Amount)
+	}()
+
+	if transaction, err = s.prepareTransfer(transaction); err != nil {
+		return nil, err
+	}
+	transaction.Type = domain.TransactionTypeTransfer
+	decision, err := s.assessRisk(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Fee, err = s.transferFee(ctx, transaction); err != nil {
+		return nil, s.recordFailedDecision(ctx, decision, err)
+	}
+	transaction.Risk = decision
+	created, err := s.repository.TransferMoney(ctx, transaction)
+	if err != nil {
+		return nil, s.recordFailedDecision(ctx, decision, translateError(err))
+	}
+	created.Risk = nil
+	if decision != nil && decision.Decision == domain.RiskReview {
+		created.Risk = decision
+	}
+	return created, nil
+}
+
+// assessRisk evaluates the risk rules on a transfer. A denial is recorded
+// here and fails the transfer; any other decision is returned for
+// TransferMoney to record with the transaction it creates, or on its own
+// when the transfer fails. It returns nil when no engine is configured.
+func (s DefaultService) assessRisk(ctx context.Context, transaction domain.Transaction) (*domain.RiskDecision, error) {
+	if s.risk == nil {
+		return nil, nil
+	}
+	decision, err := s.risk.Evaluate(ctx, transaction)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if decision.Decision != domain.RiskDeny {
+		return decision, nil
+	}
+	if err := s.repository.RecordRiskDecision(ctx, *decision); err != nil {
+		return nil, translateError(err)
+	}
+	reasons := make([]string, len(decision.Reasons))
+	for i, reason := range decision.Reasons {
+		reasons[i] = reason.Rule + ": " + reason.Reason
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTransferDenied, strings.Join(reasons, "; "))
+}
+
+// recordFailedDecision records the decision on a transfer that failed with
+// err after the risk rules let it through, since the transfer's database
+// transaction did not record it.
+func (s DefaultService) recordFailedDecision(ctx context.Context, decision *domain.RiskDecision, err error) error {
+	if decision == nil {
+		return err
+	}
+	if recordErr := s.repository.RecordRiskDecision(ctx, *decision); recordErr != nil {
+		return errors.Join(err, translateError(recordErr))
+	}
+	return err
+}
+
+func (s DefaultService) ValidateTransfer(transaction domain.Transaction) error {
+	_, err := s.prepareTransfer(transaction)
+	return err
+}
+
+// prepareTransfer checks the accounts and brings the amount of a transfer
+// within the precision policy.
+func (s DefaultService) prepareTransfer(transaction domain.Transaction) (_ domain.Transaction, err error) {
 	if transaction.SourceAccountID == transaction.DestinationAccountID {
-		return ErrSameSourceAndDestination
+		return transaction, ErrSameSourceAndDestination
+	}
+	if transaction.Amount, err = s.precision.apply(transaction.Amount); err != nil {
+		return transaction, err
 	}
 	if transaction.Amount.LessThanOrEqual(decimal.Zero) {
-		return ErrNonPositiveAmount
+		return transaction, ErrNonPositiveAmount
 	}
 	if transaction.SourceAccountID <= 0 || transaction.DestinationAccountID <= 0 {
-		return ErrInvalidAccountIDs
+		return transaction, ErrInvalidAccountIDs
+	}
+	if s.isClearingAccount(transaction.SourceAccountID) || s.isClearingAccount(transaction.DestinationAccountID) {
+		return transaction, ErrClearingAccount
+	}
+	return transaction, nil
+}
+
+func (s DefaultService) ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) (_ []domain.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.ListTransactions", trace.WithAttributes(
+		attribute.Int64("account.id", accountID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if accountID <= 0 || filter.BeforeID < 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+	if filter.Type != "" && !domain.IsTransactionType(filter.Type) {
+		return nil, ErrInvalidTransactionType
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
 	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	if _, err := s.repository.GetAccount(ctx, strconv.FormatInt(accountID, 10)); err != nil {
+		return nil, translateError(err)
+	}
+
+	transactions, err := s.repository.ListTransactions(ctx, accountID, filter)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return transactions, nil
+}
+
+func (s DefaultService) ReverseTransaction(ctx context.Context, transactionID int64) (_ *domain.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.ReverseTransaction", trace.WithAttributes(
+		attribute.Int64("transaction.id", transactionID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if transactionID <= 0 {
+		return nil, ErrTransactionNotFound
+	}
+
+	reversal, err := s.repository.ReverseTransaction(ctx, transactionID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return reversal, nil
+}
+
+func (s DefaultService) Deposit(ctx context.Context, accountID int64, amount decimal.Decimal) (*domain.Transaction, error) {
+	return s.fund(ctx, domain.Transaction{
+		Type:                 domain.TransactionTypeDeposit,
+		SourceAccountID:      s.funding.DepositClearingAccountID,
+		DestinationAccountID: accountID,
+		Amount:               amount,
+	}, accountID, s.funding.MaxDepositAmount)
+}
+
+func (s DefaultService) Withdraw(ctx context.Context, accountID int64, amount decimal.Decimal) (*domain.Transaction, error) {
+	return s.fund(ctx, domain.Transaction{
+		Type:                 domain.TransactionTypeWithdrawal,
+		SourceAccountID:      accountID,
+		DestinationAccountID: s.funding.WithdrawalClearingAccountID,
+		Amount:               amount,
+	}, accountID, s.funding.MaxWithdrawalAmount)
+}
 
-	_, err := s.repository.TransferMoney(ctx, transaction)
+// fund records a deposit or withdrawal of a customer account against the
+// clearing account already set on transaction.
+func (s DefaultService) fund(ctx context.Context, transaction domain.Transaction, accountID int64, limit decimal.Decimal) (_ *domain.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.Fund", trace.WithAttributes(
+		attribute.String("transaction.type", transaction.Type),
+		attribute.Int64("account.id", accountID),
+		attribute.String("transaction.amount", transaction.Amount.String()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if transaction.SourceAccountID == 0 || transaction.DestinationAccountID == 0 {
+		return nil, ErrFundingUnavailable
+	}
+	if accountID <= 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+	if s.isClearingAccount(accountID) {
+		return nil, ErrClearingAccount
+	}
+	if transaction.Amount, err = s.precision.apply(transaction.Amount); err != nil {
+		return nil, err
+	}
+	if transaction.Amount.LessThanOrEqual(decimal.Zero) {
+		return nil, ErrNonPositiveAmount
+	}
+	if limit.IsPositive() && transaction.Amount.GreaterThan(limit) {
+		return nil, ErrAmountLimitExceeded
+	}
+
+	created, err := s.repository.TransferMoney(ctx, transaction)
 	if err != nil {
-		switch {
-		case errors.Is(err, repository.ErrInsufficientBalance):
-			return ErrInsufficientBalance
-		}
+		return nil, translateError(err)
+	}
+	return created, nil
+}
+
+func (s DefaultService) isClearingAccount(accountID int64) bool {
+	return accountID == s.funding.DepositClearingAccountID || accountID == s.funding.WithdrawalClearingAccountID
+}
+
+func (s DefaultService) FreezeAccount(ctx context.Context, accountID int64, expectedVersion int64) (*domain.Account, error) {
+	return s.setAccountStatus(ctx, accountID, domain.AccountStatusFrozen, expectedVersion)
+}
+
+func (s DefaultService) UnfreezeAccount(ctx context.Context, accountID int64, expectedVersion int64) (*domain.Account, error) {
+	return s.setAccountStatus(ctx, accountID, domain.AccountStatusActive, expectedVersion)
+}
+
+func (s DefaultService) setAccountStatus(ctx context.Context, accountID int64, status string, expectedVersion int64) (_ *domain.Account, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.SetAccountStatus", trace.WithAttributes(
+		attribute.Int64("account.id", accountID),
+		attribute.String("account.status", status),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if accountID <= 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+
+	account, err := s.repository.SetAccountStatus(ctx, accountID, status, expectedVersion)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return account, nil
+}
+
+func (s DefaultService) SetAccountShards(ctx context.Context, accountID int64, shards int, expectedVersion int64) (_ *domain.Account, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.SetAccountShards", trace.WithAttributes(
+		attribute.Int64("account.id", accountID),
+		attribute.Int("account.shards", shards),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if accountID <= 0 {
+		return nil, ErrInvalidAccountIDs
+	}
+	if shards < 0 || shards > MaxAccountShards {
+		return nil, ErrInvalidShardCount
+	}
+
+	account, err := s.repository.SetAccountShards(ctx, accountID, shards, expectedVersion)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return account, nil
+}
+
+func (s DefaultService) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) (_ []domain.AuditEntry, err error) {
+	ctx, span := tracer.Start(ctx, "DefaultService.ListAuditEntries")
+	defer func() { endSpan(span, err) }()
+
+	if filter.AccountID < 0 || filter.BeforeID < 0 {
+		return nil, ErrInvalidAuditFilter
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, ErrInvalidAuditFilter
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	entries, err := s.repository.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return entries, nil
+}
+
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		return ErrInsufficientBalance
+	case errors.Is(err, repository.ErrAccountNotFound):
+		return ErrAccountNotFound
+	case errors.Is(err, repository.ErrAccountExists):
+		return ErrAccountExists
+	case errors.Is(err, repository.ErrAccountFrozen):
+		return ErrAccountFrozen
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		return ErrTransactionNotFound
+	case errors.Is(err, repository.ErrAlreadyReversed):
+		return ErrAlreadyReversed
+	case errors.Is(err, repository.ErrNotReversible):
+		return ErrNotReversible
+	case errors.Is(err, repository.ErrTransactionConflict):
+		return ErrTransactionConflict
+	case errors.Is(err, repository.ErrShardingUnsupported):
+		return ErrShardingUnsupported
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return ErrVersionMismatch
+	case errors.Is(err, repository.ErrFeeRuleNotFound):
+		return ErrFeeRuleNotFound
 	}
 	return err
 }
+
+func transferOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrSameSourceAndDestination):
+		return "same_account"
+	case errors.Is(err, ErrNonPositiveAmount):
+		return "invalid_amount"
+	case errors.Is(err, ErrInvalidAmountPrecision):
+		return "invalid_amount_precision"
+	case errors.Is(err, ErrAmountTooLarge):
+		return "amount_too_large"
+	case errors.Is(err, ErrInvalidAccountIDs):
+		return "invalid_account_ids"
+	case errors.Is(err, ErrInsufficientBalance):
+		return "insufficient_balance"
+	case errors.Is(err, ErrAccountNotFound):
+		return "account_not_found"
+	case errors.Is(err, ErrAccountFrozen):
+		return "account_frozen"
+	case errors.Is(err, ErrTransactionConflict):
+		return "conflict"
+	case errors.Is(err, ErrTransferDenied):
+		return "denied"
+	default:
+		return "error"
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -3,23 +3,44 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/tareqpi/transfer-system/internal/domain"
 	"github.com/tareqpi/transfer-system/internal/repository"
+	"github.com/tareqpi/transfer-system/internal/risk"
 )
 
 type mockRepository struct {
 	createAccountFn func(ctx context.Context, account domain.Account) (*domain.Account, error)
 	getAccountFn    func(ctx context.Context, id string) (*domain.Account, error)
 	transferMoneyFn func(ctx context.Context, tx domain.Transaction) (*domain.Transaction, error)
+	setStatusFn     func(ctx context.Context, id int64, status string, expectedVersion int64) (*domain.Account, error)
+	setShardsFn     func(ctx context.Context, id int64, shards int, expectedVersion int64) (*domain.Account, error)
+	listFn          func(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error)
+	reverseFn       func(ctx context.Context, id int64) (*domain.Transaction, error)
+	auditFn         func(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
+	feeRulesFn      func(ctx context.Context) ([]domain.FeeRule, error)
+	accrualsFn      func(ctx context.Context, filter domain.InterestAccrualFilter) ([]domain.InterestAccrual, error)
+	balancesFn      func(ctx context.Context, accountIDs []int64, at time.Time) (map[int64]decimal.Decimal, error)
+	listAccountsFn  func(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error)
+	getAccountsFn   func(ctx context.Context, ids []int64) ([]domain.Account, error)
 
 	createAccountCalls int
 	getAccountCalls    int
 	transferMoneyCalls int
+	listCalls          int
+
+	lastFilter domain.TransactionFilter
 
 	lastTransferTx domain.Transaction
+
+	riskDecisions []domain.RiskDecision
 }
 
 func (m *mockRepository) CreateAccount(ctx context.Context, account domain.Account) (*domain.Account, error) {
@@ -47,6 +68,139 @@ func (m *mockRepository) TransferMoney(ctx context.Context, tx domain.Transactio
 	return &tx, nil
 }
 
+func (m *mockRepository) SetAccountStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*domain.Account, error) {
+	if m.setStatusFn != nil {
+		return m.setStatusFn(ctx, id, status, expectedVersion)
+	}
+	return &domain.Account{ID: id, Status: status}, nil
+}
+
+func (m *mockRepository) SetAccountShards(ctx context.Context, id int64, shards int, expectedVersion int64) (*domain.Account, error) {
+	if m.setShardsFn != nil {
+		return m.setShardsFn(ctx, id, shards, expectedVersion)
+	}
+	return &domain.Account{ID: id, Shards: shards}, nil
+}
+
+func (m *mockRepository) GetTransaction(ctx context.Context, id int64) (*domain.Transaction, error) {
+	return nil, repository.ErrTransactionNotFound
+}
+
+func (m *mockRepository) ListTransactions(ctx context.Context, accountID int64, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	m.listCalls++
+	m.lastFilter = filter
+	if m.listFn != nil {
+		return m.listFn(ctx, accountID, filter)
+	}
+	return []domain.Transaction{}, nil
+}
+
+func (m *mockRepository) ReverseTransaction(ctx context.Context, id int64) (*domain.Transaction, error) {
+	if m.reverseFn != nil {
+		return m.reverseFn(ctx, id)
+	}
+	return nil, repository.ErrTransactionNotFound
+}
+
+func (m *mockRepository) ListAccounts(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error) {
+	if m.listAccountsFn != nil {
+		return m.listAccountsFn(ctx, filter)
+	}
+	return []domain.Account{}, nil
+}
+
+func (m *mockRepository) GetAccounts(ctx context.Context, ids []int64) ([]domain.Account, error) {
+	if m.getAccountsFn != nil {
+		return m.getAccountsFn(ctx, ids)
+	}
+	return []domain.Account{}, nil
+}
+
+func (m *mockRepository) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	if m.auditFn != nil {
+		return m.auditFn(ctx, filter)
+	}
+	return []domain.AuditEntry{}, nil
+}
+
+func (m *mockRepository) RecordRiskDecision(ctx context.Context, decision domain.RiskDecision) error {
+	m.riskDecisions = append(m.riskDecisions, decision)
+	return nil
+}
+
+func (m *mockRepository) ListFeeRules(ctx context.Context) ([]domain.FeeRule, error) {
+	if m.feeRulesFn != nil {
+		return m.feeRulesFn(ctx)
+	}
+	return []domain.FeeRule{}, nil
+}
+
+func (m *mockRepository) CreateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	rule.ID = 1
+	return &rule, nil
+}
+
+func (m *mockRepository) UpdateFeeRule(ctx context.Context, rule domain.FeeRule) (*domain.FeeRule, error) {
+	return &rule, nil
+}
+
+func (m *mockRepository) DeleteFeeRule(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) SetInterestRate(ctx context.Context, accountID int64, annualRate decimal.Decimal) (*domain.InterestRate, error) {
+	return &domain.InterestRate{AccountID: accountID, AnnualRate: annualRate}, nil
+}
+
+func (m *mockRepository) ListInterestRates(ctx context.Context) ([]domain.InterestRate, error) {
+	return []domain.InterestRate{}, nil
+}
+
+func (m *mockRepository) BalanceAt(ctx context.Context, accountID int64, at time.Time) (decimal.Decimal, error) {
+	if m.balancesFn != nil {
+		balances, err := m.balancesFn(ctx, []int64{accountID}, at)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		balance, ok := balances[accountID]
+		if !ok {
+			return decimal.Zero, repository.ErrAccountNotFound
+		}
+		return balance, nil
+	}
+	return decimal.Zero, nil
+}
+
+func (m *mockRepository) BalancesAt(ctx context.Context, accountIDs []int64, at time.Time) (map[int64]decimal.Decimal, error) {
+	if m.balancesFn != nil {
+		return m.balancesFn(ctx, accountIDs, at)
+	}
+	return map[int64]decimal.Decimal{}, nil
+}
+
+func (m *mockRepository) SnapshotCutoff(ctx context.Context, at time.Time) (time.Time, error) {
+	return at, nil
+}
+
+func (m *mockRepository) TakeBalanceSnapshots(ctx context.Context, at time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) RecordInterestAccruals(ctx context.Context, accruals []domain.InterestAccrual) (int, error) {
+	return len(accruals), nil
+}
+
+func (m *mockRepository) PostInterest(ctx context.Context, accountID, expenseAccountID int64, through time.Time) (*domain.Transaction, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ListInterestAccruals(ctx context.Context, filter domain.InterestAccrualFilter) ([]domain.InterestAccrual, error) {
+	if m.accrualsFn != nil {
+		return m.accrualsFn(ctx, filter)
+	}
+	return []domain.InterestAccrual{}, nil
+}
+
 func TestDefaultService_CreateAccount_Success(t *testing.T) {
 	t.Parallel()
 
@@ -71,6 +225,96 @@ func TestDefaultService_CreateAccount_Success(t *testing.T) {
 	}
 }
 
+func TestPrecision_Apply(t *testing.T) {
+	t.Parallel()
+
+	maxAmount := decimal.RequireFromString("1000")
+	testCases := []struct {
+		name     string
+		scale    int32
+		rounding string
+		amount   string
+		want     string
+		wantErr  error
+	}{
+		{"within_scale", 2, RoundingReject, "10.25", "10.25", nil},
+		{"trailing_zeros", 2, RoundingReject, "10.2500", "10.25", nil},
+		{"reject", 2, RoundingReject, "10.255", "", ErrInvalidAmountPrecision},
+		{"half_even", 2, RoundingHalfEven, "10.245", "10.24", nil},
+		{"half_up", 2, RoundingHalfUp, "10.245", "10.25", nil},
+		{"down", 2, RoundingDown, "10.249", "10.24", nil},
+		{"whole_units", 0, RoundingHalfUp, "10.5", "11", nil},
+		{"at_max", 2, RoundingReject, "1000", "1000", nil},
+		{"too_large", 2, RoundingReject, "1000.01", "", ErrAmountTooLarge},
+		{"negative_too_large", 2, RoundingReject, "-1000.01", "", ErrAmountTooLarge},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			precision := Precision{Scale: testCase.scale, MaxAmount: maxAmount, Rounding: testCase.rounding}
+			got, err := precision.apply(decimal.RequireFromString(testCase.amount))
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("expected error %v, got %v", testCase.wantErr, err)
+			}
+			if err == nil && !got.Equal(decimal.RequireFromString(testCase.want)) {
+				t.Fatalf("expected %s, got %s", testCase.want, got)
+			}
+		})
+	}
+}
+
+func TestDefaultService_Precision(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var transferred domain.Transaction
+	mockRepo := &mockRepository{
+		createAccountFn: func(ctx context.Context, account domain.Account) (*domain.Account, error) {
+			created := account
+			return &created, nil
+		},
+		transferMoneyFn: func(ctx context.Context, tx domain.Transaction) (*domain.Transaction, error) {
+			transferred = tx
+			return &tx, nil
+		},
+	}
+
+	strict := NewService(mockRepo)
+	if _, err := strict.CreateAccount(ctx, domain.Account{ID: 1, Balance: decimal.RequireFromString("0.00001")}); !errors.Is(err, ErrInvalidAmountPrecision) {
+		t.Fatalf("expected ErrInvalidAmountPrecision, got %v", err)
+	}
+	if _, err := strict.CreateAccount(ctx, domain.Account{ID: 1, Balance: decimal.New(1, 15)}); !errors.Is(err, ErrAmountTooLarge) {
+		t.Fatalf("expected ErrAmountTooLarge, got %v", err)
+	}
+	tx := domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("1.00005")}
+	if _, err := strict.TransferMoney(ctx, tx); !errors.Is(err, ErrInvalidAmountPrecision) {
+		t.Fatalf("expected ErrInvalidAmountPrecision, got %v", err)
+	}
+	if mockRepo.createAccountCalls != 0 || mockRepo.transferMoneyCalls != 0 {
+		t.Fatalf("rejected amounts should not hit the repository; calls=%d/%d", mockRepo.createAccountCalls, mockRepo.transferMoneyCalls)
+	}
+
+	rounding := NewService(mockRepo, WithPrecision(Precision{Scale: 2, MaxAmount: MaxStorageAmount, Rounding: RoundingHalfEven}))
+	account, err := rounding.CreateAccount(ctx, domain.Account{ID: 1, Balance: decimal.RequireFromString("10.125")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !account.Balance.Equal(decimal.RequireFromString("10.12")) {
+		t.Fatalf("expected balance 10.12, got %s", account.Balance)
+	}
+	if _, err := rounding.TransferMoney(ctx, tx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !transferred.Amount.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("expected the amount rounded to 1, got %s", transferred.Amount)
+	}
+	tx.Amount = decimal.RequireFromString("0.004")
+	if _, err := rounding.TransferMoney(ctx, tx); !errors.Is(err, ErrNonPositiveAmount) {
+		t.Fatalf("expected an amount rounded to zero to be rejected, got %v", err)
+	}
+}
+
 func TestDefaultService_CreateAccount_RepositoryError(t *testing.T) {
 	t.Parallel()
 
@@ -174,7 +418,7 @@ func TestDefaultService_TransferMoney_InputValidation(t *testing.T) {
 			mockRepo := &mockRepository{}
 			svc := NewService(mockRepo)
 
-			err := svc.TransferMoney(context.Background(), tc.tx)
+			_, err := svc.TransferMoney(context.Background(), tc.tx)
 			if !errors.Is(err, tc.wantErr) {
 				t.Fatalf("error mismatch: got=%v want=%v", err, tc.wantErr)
 			}
@@ -196,7 +440,7 @@ func TestDefaultService_TransferMoney_InsufficientBalance(t *testing.T) {
 	svc := NewService(mockRepo)
 
 	tx := domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)}
-	err := svc.TransferMoney(context.Background(), tx)
+	_, err := svc.TransferMoney(context.Background(), tx)
 	if !errors.Is(err, ErrInsufficientBalance) {
 		t.Fatalf("error mismatch: got=%v want=%v", err, ErrInsufficientBalance)
 	}
@@ -217,7 +461,7 @@ func TestDefaultService_TransferMoney_RepositoryError(t *testing.T) {
 	svc := NewService(mockRepo)
 
 	tx := domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)}
-	err := svc.TransferMoney(context.Background(), tx)
+	_, err := svc.TransferMoney(context.Background(), tx)
 	if !errors.Is(err, wantErr) {
 		t.Fatalf("error mismatch: got=%v want=%v", err, wantErr)
 	}
@@ -234,7 +478,7 @@ func TestDefaultService_TransferMoney_Success(t *testing.T) {
 	svc := NewService(mockRepo)
 
 	tx := domain.Transaction{SourceAccountID: 10, DestinationAccountID: 20, Amount: decimal.NewFromInt(99)}
-	err := svc.TransferMoney(context.Background(), tx)
+	_, err := svc.TransferMoney(context.Background(), tx)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -247,3 +491,847 @@ func TestDefaultService_TransferMoney_Success(t *testing.T) {
 		t.Fatalf("transaction mismatch: got=%+v want=%+v", mockRepo.lastTransferTx, tx)
 	}
 }
+
+func TestDefaultService_TransferMoney_RiskRules(t *testing.T) {
+	t.Parallel()
+
+	rules := []risk.Rule{
+		risk.AmountThreshold{RuleBase: risk.RuleBase{RuleName: "large", RuleAction: domain.RiskReview}, Amount: decimal.NewFromInt(100)},
+		risk.AmountThreshold{RuleBase: risk.RuleBase{RuleName: "huge", RuleAction: domain.RiskDeny}, Amount: decimal.NewFromInt(1000)},
+	}
+	testCases := []struct {
+		name      string
+		amount    int64
+		decision  string
+		transfers int
+	}{
+		{"allow", 100, domain.RiskAllow, 1},
+		{"review", 101, domain.RiskReview, 1},
+		{"deny", 1001, domain.RiskDeny, 0},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := &mockRepository{}
+			svc := NewService(mockRepo, WithRiskEngine(risk.NewEngine(mockRepo, rules...)))
+
+			created, err := svc.TransferMoney(context.Background(), domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(testCase.amount)})
+			if testCase.decision == domain.RiskDeny {
+				if !errors.Is(err, ErrTransferDenied) || !strings.Contains(err.Error(), "huge") {
+					t.Fatalf("expected ErrTransferDenied naming the rule, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if mockRepo.transferMoneyCalls != testCase.transfers {
+				t.Fatalf("TransferMoney calls: got=%d want=%d", mockRepo.transferMoneyCalls, testCase.transfers)
+			}
+			if testCase.decision == domain.RiskDeny {
+				if len(mockRepo.riskDecisions) != 1 || mockRepo.riskDecisions[0].Decision != domain.RiskDeny {
+					t.Fatalf("expected the denial to be recorded on its own, got %+v", mockRepo.riskDecisions)
+				}
+				return
+			}
+			if len(mockRepo.riskDecisions) != 0 {
+				t.Fatalf("expected no decision to be recorded on its own, got %+v", mockRepo.riskDecisions)
+			}
+			if decision := mockRepo.lastTransferTx.Risk; decision == nil || decision.Decision != testCase.decision {
+				t.Fatalf("expected the %s decision to be passed with the transfer, got %+v", testCase.decision, decision)
+			}
+			if testCase.decision == domain.RiskReview && (created.Risk == nil || created.Risk.Decision != domain.RiskReview) {
+				t.Fatalf("expected the transfer to carry the review decision, got %+v", created)
+			}
+			if testCase.decision == domain.RiskAllow && created.Risk != nil {
+				t.Fatalf("expected no risk decision on an allowed transfer, got %+v", created.Risk)
+			}
+		})
+	}
+}
+
+func TestDefaultService_TransferMoney_RecordsRiskDecisionOfFailedTransfer(t *testing.T) {
+	t.Parallel()
+
+	rules := []risk.Rule{
+		risk.AmountThreshold{RuleBase: risk.RuleBase{RuleName: "large", RuleAction: domain.RiskReview}, Amount: decimal.NewFromInt(100)},
+	}
+	testCases := []struct {
+		name     string
+		amount   int64
+		decision string
+	}{
+		{"allow", 100, domain.RiskAllow},
+		{"review", 101, domain.RiskReview},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := &mockRepository{
+				transferMoneyFn: func(context.Context, domain.Transaction) (*domain.Transaction, error) {
+					return nil, repository.ErrInsufficientBalance
+				},
+			}
+			svc := NewService(mockRepo, WithRiskEngine(risk.NewEngine(mockRepo, rules...)))
+
+			_, err := svc.TransferMoney(context.Background(), domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(testCase.amount)})
+			if !errors.Is(err, ErrInsufficientBalance) {
+				t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+			}
+			if len(mockRepo.riskDecisions) != 1 || mockRepo.riskDecisions[0].Decision != testCase.decision {
+				t.Fatalf("expected the %s decision to be recorded on its own, got %+v", testCase.decision, mockRepo.riskDecisions)
+			}
+		})
+	}
+}
+
+func TestDefaultService_TransferMoney_TranslatesRepositoryErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		repoErr error
+		wantErr error
+	}{
+		{name: "source not found", repoErr: fmt.Errorf("source %w", repository.ErrAccountNotFound), wantErr: ErrAccountNotFound},
+		{name: "frozen", repoErr: repository.ErrAccountFrozen, wantErr: ErrAccountFrozen},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &mockRepository{
+				transferMoneyFn: func(ctx context.Context, tx domain.Transaction) (*domain.Transaction, error) {
+					return nil, tc.repoErr
+				},
+			}
+			svc := NewService(mockRepo)
+
+			tx := domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)}
+			if _, err := svc.TransferMoney(context.Background(), tx); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("error mismatch: got=%v want=%v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultService_GetAccount_InvalidID(t *testing.T) {
+	t.Parallel()
+
+	mockRepo := &mockRepository{}
+	svc := NewService(mockRepo)
+
+	for _, id := range []string{"abc", "0", "-3"} {
+		if _, err := svc.GetAccount(context.Background(), id); !errors.Is(err, ErrInvalidAccountIDs) {
+			t.Fatalf("id %q: error mismatch: got=%v want=%v", id, err, ErrInvalidAccountIDs)
+		}
+	}
+	if mockRepo.getAccountCalls != 0 {
+		t.Fatalf("GetAccount should not hit repository on invalid id; calls=%d", mockRepo.getAccountCalls)
+	}
+}
+
+func TestDefaultService_ListTransactions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown account", func(t *testing.T) {
+		t.Parallel()
+		mockRepo := &mockRepository{
+			getAccountFn: func(ctx context.Context, id string) (*domain.Account, error) {
+				return nil, repository.ErrAccountNotFound
+			},
+		}
+		svc := NewService(mockRepo)
+
+		if _, err := svc.ListTransactions(context.Background(), 9, domain.TransactionFilter{}); !errors.Is(err, ErrAccountNotFound) {
+			t.Fatalf("error mismatch: got=%v want=%v", err, ErrAccountNotFound)
+		}
+		if mockRepo.listCalls != 0 {
+			t.Fatalf("ListTransactions calls: got=%d want=0", mockRepo.listCalls)
+		}
+	})
+
+	t.Run("page size defaults and clamps", func(t *testing.T) {
+		t.Parallel()
+		mockRepo := &mockRepository{
+			getAccountFn: func(ctx context.Context, id string) (*domain.Account, error) {
+				return &domain.Account{ID: 1}, nil
+			},
+		}
+		svc := NewService(mockRepo)
+
+		if _, err := svc.ListTransactions(context.Background(), 1, domain.TransactionFilter{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if mockRepo.lastFilter.Limit != DefaultPageSize {
+			t.Fatalf("limit: got=%d want=%d", mockRepo.lastFilter.Limit, DefaultPageSize)
+		}
+		if _, err := svc.ListTransactions(context.Background(), 1, domain.TransactionFilter{Limit: MaxPageSize + 1}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if mockRepo.lastFilter.Limit != MaxPageSize {
+			t.Fatalf("limit: got=%d want=%d", mockRepo.lastFilter.Limit, MaxPageSize)
+		}
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		t.Parallel()
+		mockRepo := &mockRepository{}
+		svc := NewService(mockRepo)
+
+		if _, err := svc.ListTransactions(context.Background(), 1, domain.TransactionFilter{Type: "refund"}); !errors.Is(err, ErrInvalidTransactionType) {
+			t.Fatalf("error mismatch: got=%v want=%v", err, ErrInvalidTransactionType)
+		}
+		if mockRepo.listCalls != 0 {
+			t.Fatalf("ListTransactions calls: got=%d want=0", mockRepo.listCalls)
+		}
+	})
+}
+
+func TestDefaultService_DepositAndWithdraw(t *testing.T) {
+	t.Parallel()
+
+	funding := Funding{
+		DepositClearingAccountID:    900,
+		WithdrawalClearingAccountID: 901,
+		MaxDepositAmount:            decimal.NewFromInt(1000),
+	}
+
+	t.Run("deposit draws from the deposit clearing account", func(t *testing.T) {
+		t.Parallel()
+		mockRepo := &mockRepository{}
+		svc := NewService(mockRepo, WithFunding(funding))
+
+		if _, err := svc.Deposit(context.Background(), 1, decimal.NewFromInt(1000)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := domain.Transaction{Type: domain.TransactionTypeDeposit, SourceAccountID: 900, DestinationAccountID: 1}
+		got := mockRepo.lastTransferTx
+		if got.Type != want.Type || got.SourceAccountID != want.SourceAccountID || got.DestinationAccountID != want.DestinationAccountID {
+			t.Fatalf("unexpected transaction: got=%+v want=%+v", got, want)
+		}
+	})
+
+	t.Run("withdrawal pays into the withdrawal clearing account", func(t *testing.T) {
+		t.Parallel()
+		mockRepo := &mockRepository{}
+		svc := NewService(mockRepo, WithFunding(funding))
+
+		if _, err := svc.Withdraw(context.Background(), 1, decimal.NewFromInt(5000)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := domain.Transaction{Type: domain.TransactionTypeWithdrawal, SourceAccountID: 1, DestinationAccountID: 901}
+		got := mockRepo.lastTransferTx
+		if got.Type != want.Type || got.SourceAccountID != want.SourceAccountID || got.DestinationAccountID != want.DestinationAccountID {
+			t.Fatalf("unexpected transaction: got=%+v want=%+v", got, want)
+		}
+	})
+
+	cases := []struct {
+		name    string
+		funding Funding
+		call    func(Service) error
+		wantErr error
+	}{
+		{
+			name:    "deposit over the limit",
+			funding: funding,
+			call: func(svc Service) error {
+				_, err := svc.Deposit(context.Background(), 1, decimal.RequireFromString("1000.01"))
+				return err
+			},
+			wantErr: ErrAmountLimitExceeded,
+		},
+		{
+			name:    "deposit into a clearing account",
+			funding: funding,
+			call: func(svc Service) error {
+				_, err := svc.Deposit(context.Background(), 901, decimal.NewFromInt(1))
+				return err
+			},
+			wantErr: ErrClearingAccount,
+		},
+		{
+			name:    "non-positive withdrawal",
+			funding: funding,
+			call: func(svc Service) error {
+				_, err := svc.Withdraw(context.Background(), 1, decimal.Zero)
+				return err
+			},
+			wantErr: ErrNonPositiveAmount,
+		},
+		{
+			name:    "withdrawals not configured",
+			funding: Funding{DepositClearingAccountID: 900},
+			call: func(svc Service) error {
+				_, err := svc.Withdraw(context.Background(), 1, decimal.NewFromInt(1))
+				return err
+			},
+			wantErr: ErrFundingUnavailable,
+		},
+		{
+			name:    "transfer out of a clearing account",
+			funding: funding,
+			call: func(svc Service) error {
+				_, err := svc.TransferMoney(context.Background(), domain.Transaction{SourceAccountID: 900, DestinationAccountID: 1, Amount: decimal.NewFromInt(1)})
+				return err
+			},
+			wantErr: ErrClearingAccount,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &mockRepository{}
+			svc := NewService(mockRepo, WithFunding(tc.funding))
+
+			if err := tc.call(svc); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("error mismatch: got=%v want=%v", err, tc.wantErr)
+			}
+			if mockRepo.transferMoneyCalls != 0 {
+				t.Fatalf("TransferMoney calls: got=%d want=0", mockRepo.transferMoneyCalls)
+			}
+		})
+	}
+}
+
+func TestDefaultService_ReverseTransaction_TranslatesErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		repoErr error
+		wantErr error
+	}{
+		{repository.ErrTransactionNotFound, ErrTransactionNotFound},
+		{repository.ErrAlreadyReversed, ErrAlreadyReversed},
+		{repository.ErrNotReversible, ErrNotReversible},
+		{fmt.Errorf("%w: deadlock", repository.ErrTransactionConflict), ErrTransactionConflict},
+		{repository.ErrInsufficientBalance, ErrInsufficientBalance},
+	}
+
+	for _, tc := range cases {
+		mockRepo := &mockRepository{
+			reverseFn: func(ctx context.Context, id int64) (*domain.Transaction, error) {
+				return nil, tc.repoErr
+			},
+		}
+		svc := NewService(mockRepo)
+
+		if _, err := svc.ReverseTransaction(context.Background(), 1); !errors.Is(err, tc.wantErr) {
+			t.Fatalf("error mismatch: got=%v want=%v", err, tc.wantErr)
+		}
+	}
+}
+
+func TestDefaultService_FreezeAccount(t *testing.T) {
+	t.Parallel()
+
+	var (
+		gotStatus  string
+		gotVersion int64
+	)
+	mockRepo := &mockRepository{
+		setStatusFn: func(ctx context.Context, id int64, status string, expectedVersion int64) (*domain.Account, error) {
+			gotStatus, gotVersion = status, expectedVersion
+			if expectedVersion == 9 {
+				return nil, repository.ErrVersionMismatch
+			}
+			return &domain.Account{ID: id, Status: status}, nil
+		},
+	}
+	svc := NewService(mockRepo)
+
+	account, err := svc.FreezeAccount(context.Background(), 3, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotStatus != domain.AccountStatusFrozen || account.Status != domain.AccountStatusFrozen {
+		t.Fatalf("status: got=%s want=%s", gotStatus, domain.AccountStatusFrozen)
+	}
+	if gotVersion != 4 {
+		t.Fatalf("expected version: got=%d want=4", gotVersion)
+	}
+	if _, err := svc.FreezeAccount(context.Background(), 0, 0); !errors.Is(err, ErrInvalidAccountIDs) {
+		t.Fatalf("error mismatch: got=%v want=%v", err, ErrInvalidAccountIDs)
+	}
+	if _, err := svc.FreezeAccount(context.Background(), 3, 9); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("error mismatch: got=%v want=%v", err, ErrVersionMismatch)
+	}
+}
+
+func TestDefaultService_SetAccountShards(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		accountID   int64
+		shards      int
+		repoErr     error
+		expectedErr error
+	}{
+		{"enable", 7, 8, nil, nil},
+		{"disable", 7, 0, nil, nil},
+		{"invalid_account", 0, 8, nil, ErrInvalidAccountIDs},
+		{"negative_shards", 7, -1, nil, ErrInvalidShardCount},
+		{"too_many_shards", 7, MaxAccountShards + 1, nil, ErrInvalidShardCount},
+		{"unsupported_backend", 7, 8, repository.ErrShardingUnsupported, ErrShardingUnsupported},
+		{"missing_account", 7, 8, repository.ErrAccountNotFound, ErrAccountNotFound},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &mockRepository{setShardsFn: func(ctx context.Context, id int64, shards int, expectedVersion int64) (*domain.Account, error) {
+				if testCase.repoErr != nil {
+					return nil, testCase.repoErr
+				}
+				return &domain.Account{ID: id, Shards: shards}, nil
+			}}
+			account, err := NewService(repo).SetAccountShards(context.Background(), testCase.accountID, testCase.shards, 0)
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("error mismatch: got=%v want=%v", err, testCase.expectedErr)
+			}
+			if err == nil && account.Shards != testCase.shards {
+				t.Fatalf("shards: got=%d want=%d", account.Shards, testCase.shards)
+			}
+		})
+	}
+}
+
+func TestDefaultService_ListAuditEntries(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		filter        domain.AuditFilter
+		expectedErr   error
+		expectedLimit int
+	}{
+		{"default_limit", domain.AuditFilter{}, nil, DefaultPageSize},
+		{"capped_limit", domain.AuditFilter{Limit: MaxPageSize + 1}, nil, MaxPageSize},
+		{"negative_account", domain.AuditFilter{AccountID: -1}, ErrInvalidAuditFilter, 0},
+		{"empty_time_range", domain.AuditFilter{Since: since, Until: since}, ErrInvalidAuditFilter, 0},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotFilter domain.AuditFilter
+			repo := &mockRepository{auditFn: func(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+				gotFilter = filter
+				return []domain.AuditEntry{}, nil
+			}}
+			_, err := NewService(repo).ListAuditEntries(context.Background(), testCase.filter)
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("expected error %v, got %v", testCase.expectedErr, err)
+			}
+			if gotFilter.Limit != testCase.expectedLimit {
+				t.Fatalf("expected limit %d, got %d", testCase.expectedLimit, gotFilter.Limit)
+			}
+		})
+	}
+}
+
+func TestDefaultService_TransferMoney_Fees(t *testing.T) {
+	t.Parallel()
+
+	dec := func(value string) *decimal.Decimal {
+		d := decimal.RequireFromString(value)
+		return &d
+	}
+	rules := []domain.FeeRule{
+		{ID: 1, Name: "flat", Kind: domain.FeeKindFlat, Amount: dec("0.50")},
+		{ID: 2, Name: "percentage", Kind: domain.FeeKindPercentage, Percent: dec("1"), MinFee: dec("1"), MaxFee: dec("5")},
+		{ID: 3, Name: "tiered", Kind: domain.FeeKindTiered, Tiers: []domain.FeeTier{
+			{UpTo: dec("100"), Amount: decimal.NewFromInt(1)},
+			{Percent: decimal.RequireFromString("0.125")},
+		}},
+		{ID: 4, Name: "premium", Kind: domain.FeeKindFlat, AccountTier: "premium", Amount: dec("10")},
+	}
+
+	testCases := []struct {
+		name        string
+		feeAccount  int64
+		source      int64
+		amount      string
+		expectedFee string
+		charges     int
+	}{
+		{"small_transfer", 99, 1, "50", "2.5", 3},
+		{"large_transfer", 99, 1, "1000.01", "6.75", 3},
+		{"fees_disabled", 0, 1, "50", "", 0},
+		{"fee_account_pays_nothing", 99, 99, "50", "", 0},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepo := &mockRepository{
+				feeRulesFn: func(ctx context.Context) ([]domain.FeeRule, error) { return rules, nil },
+				getAccountFn: func(ctx context.Context, id string) (*domain.Account, error) {
+					return &domain.Account{Tier: domain.AccountTierStandard}, nil
+				},
+			}
+			svc := NewService(mockRepo, WithFeeAccount(testCase.feeAccount))
+			_, err := svc.TransferMoney(context.Background(), domain.Transaction{
+				SourceAccountID:      testCase.source,
+				DestinationAccountID: 2,
+				Amount:               decimal.RequireFromString(testCase.amount),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			fee := mockRepo.lastTransferTx.Fee
+			if testCase.expectedFee == "" {
+				if fee != nil {
+					t.Fatalf("expected no fee, got %+v", fee)
+				}
+				return
+			}
+			if fee == nil {
+				t.Fatalf("expected fee %s, got none", testCase.expectedFee)
+			}
+			if fee.AccountID != testCase.feeAccount || len(fee.Breakdown) != testCase.charges {
+				t.Fatalf("unexpected fee: %+v", fee)
+			}
+			if !fee.Amount.Equal(decimal.RequireFromString(testCase.expectedFee)) {
+				t.Fatalf("expected fee %s, got %s", testCase.expectedFee, fee.Amount)
+			}
+		})
+	}
+}
+
+func TestValidateFeeRule(t *testing.T) {
+	t.Parallel()
+
+	dec := func(value string) *decimal.Decimal {
+		d := decimal.RequireFromString(value)
+		return &d
+	}
+	testCases := []struct {
+		name  string
+		rule  domain.FeeRule
+		valid bool
+	}{
+		{"flat", domain.FeeRule{Name: "f", Kind: domain.FeeKindFlat, Amount: dec("1")}, true},
+		{"missing_name", domain.FeeRule{Kind: domain.FeeKindFlat, Amount: dec("1")}, false},
+		{"unknown_kind", domain.FeeRule{Name: "f", Kind: "bonus", Amount: dec("1")}, false},
+		{"flat_with_percent", domain.FeeRule{Name: "f", Kind: domain.FeeKindFlat, Amount: dec("1"), Percent: dec("1")}, false},
+		{"percentage_over_100", domain.FeeRule{Name: "p", Kind: domain.FeeKindPercentage, Percent: dec("101")}, false},
+		{"min_above_max", domain.FeeRule{Name: "p", Kind: domain.FeeKindPercentage, Percent: dec("1"), MinFee: dec("5"), MaxFee: dec("1")}, false},
+		{"tiers_out_of_order", domain.FeeRule{Name: "t", Kind: domain.FeeKindTiered, Tiers: []domain.FeeTier{
+			{UpTo: dec("100")}, {UpTo: dec("50")},
+		}}, false},
+		{"open_tier_not_last", domain.FeeRule{Name: "t", Kind: domain.FeeKindTiered, Tiers: []domain.FeeTier{
+			{}, {UpTo: dec("50")},
+		}}, false},
+		{"tiered", domain.FeeRule{Name: "t", Kind: domain.FeeKindTiered, Tiers: []domain.FeeTier{
+			{UpTo: dec("50"), Amount: decimal.NewFromInt(1)}, {Percent: decimal.NewFromInt(1)},
+		}}, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateFeeRule(testCase.rule)
+			if testCase.valid && err != nil {
+				t.Fatalf("expected valid rule, got %v", err)
+			}
+			if !testCase.valid && !errors.Is(err, ErrInvalidFeeRule) {
+				t.Fatalf("expected %v, got %v", ErrInvalidFeeRule, err)
+			}
+		})
+	}
+}
+
+func TestDefaultService_SetInterestRate_Validation(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		accountID int64
+		rate      string
+		expected  error
+	}{
+		{"valid", 1, "2.125", nil},
+		{"zero", 1, "0", nil},
+		{"six_decimal_places", 1, "0.000001", nil},
+		{"invalid_account", 0, "1", ErrInvalidAccountIDs},
+		{"negative", 1, "-0.5", ErrInvalidInterestRate},
+		{"above_hundred", 1, "100.01", ErrInvalidInterestRate},
+		{"too_precise", 1, "1.0000001", ErrInvalidInterestRate},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewService(&mockRepository{})
+			rate, err := svc.SetInterestRate(context.Background(), testCase.accountID, decimal.RequireFromString(testCase.rate))
+			if !errors.Is(err, testCase.expected) {
+				t.Fatalf("expected error %v, got %v", testCase.expected, err)
+			}
+			if err == nil && !rate.AnnualRate.Equal(decimal.RequireFromString(testCase.rate)) {
+				t.Fatalf("expected rate %s, got %s", testCase.rate, rate.AnnualRate)
+			}
+		})
+	}
+}
+
+func TestDefaultService_InterestReport(t *testing.T) {
+	t.Parallel()
+
+	day := func(value string) time.Time {
+		parsed, _ := time.Parse(time.DateOnly, value)
+		return parsed
+	}
+	posted := int64(7)
+	var lastFilter domain.InterestAccrualFilter
+	mockRepo := &mockRepository{
+		accrualsFn: func(ctx context.Context, filter domain.InterestAccrualFilter) ([]domain.InterestAccrual, error) {
+			lastFilter = filter
+			return []domain.InterestAccrual{
+				{AccountID: 1, Date: day("2026-03-30"), Amount: decimal.RequireFromString("0.25"), TransactionID: &posted},
+				{AccountID: 1, Date: day("2026-03-31"), Amount: decimal.RequireFromString("0.25"), TransactionID: &posted},
+				{AccountID: 1, Date: day("2026-04-01"), Amount: decimal.RequireFromString("0.3")},
+				{AccountID: 2, Date: day("2026-04-01"), Amount: decimal.RequireFromString("1.125")},
+			}, nil
+		},
+	}
+	svc := NewService(mockRepo)
+
+	report, err := svc.InterestReport(context.Background(), domain.InterestAccrualFilter{From: day("2026-03-30").Add(13 * time.Hour), To: day("2026-04-01")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !lastFilter.From.Equal(day("2026-03-30")) {
+		t.Fatalf("expected the period to start at midnight, got %s", lastFilter.From)
+	}
+	if len(report.Accounts) != 2 || report.Accounts[0].Days != 3 || !report.Accounts[0].Accrued.Equal(decimal.RequireFromString("0.8")) ||
+		!report.Accounts[0].Posted.Equal(decimal.RequireFromString("0.5")) || !report.Accounts[1].Posted.IsZero() {
+		t.Fatalf("unexpected accounts %+v", report.Accounts)
+	}
+	if !report.TotalAccrued.Equal(decimal.RequireFromString("1.925")) || !report.TotalPosted.Equal(decimal.RequireFromString("0.5")) || report.Accruals != nil {
+		t.Fatalf("unexpected totals %+v", report)
+	}
+
+	for _, filter := range []domain.InterestAccrualFilter{
+		{To: day("2026-04-01")},
+		{From: day("2026-04-02"), To: day("2026-04-01")},
+		{From: day("2025-01-01"), To: day("2026-01-02")},
+	} {
+		if _, err := svc.InterestReport(context.Background(), filter); !errors.Is(err, ErrInvalidInterestQuery) {
+			t.Fatalf("expected ErrInvalidInterestQuery for %+v, got %v", filter, err)
+		}
+	}
+}
+
+func TestDefaultService_BalancesAt(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	asOf := time.Date(2026, time.January, 31, 23, 59, 59, 0, time.UTC)
+
+	var asked []int64
+	mockRepo := &mockRepository{
+		balancesFn: func(ctx context.Context, accountIDs []int64, at time.Time) (map[int64]decimal.Decimal, error) {
+			if !at.Equal(asOf) {
+				t.Errorf("expected balances at %s, got %s", asOf, at)
+			}
+			asked = accountIDs
+			return map[int64]decimal.Decimal{1: decimal.NewFromInt(70), 2: decimal.NewFromInt(30)}, nil
+		},
+	}
+	svc := NewService(mockRepo)
+
+	report, err := svc.BalancesAt(ctx, []int64{2, 3, 2, 1}, asOf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(asked, []int64{2, 3, 1}) {
+		t.Fatalf("expected the repository to be asked for 2, 3, 1, got %v", asked)
+	}
+	if !report.AsOf.Equal(asOf) || len(report.Balances) != 2 || report.Balances[0].AccountID != 2 || !report.Balances[0].Balance.Equal(decimal.NewFromInt(30)) ||
+		report.Balances[1].AccountID != 1 || !slices.Equal(report.NotFound, []int64{3}) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	balance, err := svc.BalanceAt(ctx, 1, asOf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if balance.AccountID != 1 || !balance.Balance.Equal(decimal.NewFromInt(70)) {
+		t.Fatalf("unexpected balance %+v", balance)
+	}
+	if _, err := svc.BalanceAt(ctx, 3, asOf); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+
+	tooMany := make([]int64, MaxBalanceAccounts+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+	for _, accountIDs := range [][]int64{nil, tooMany} {
+		if _, err := svc.BalancesAt(ctx, accountIDs, asOf); !errors.Is(err, ErrInvalidBalanceQuery) {
+			t.Fatalf("expected ErrInvalidBalanceQuery for %d accounts, got %v", len(accountIDs), err)
+		}
+	}
+	if _, err := svc.BalancesAt(ctx, []int64{1, 0}, asOf); !errors.Is(err, ErrInvalidAccountIDs) {
+		t.Fatalf("expected ErrInvalidAccountIDs, got %v", err)
+	}
+}
+
+func TestDefaultService_CreateAccount_Tags(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var stored []string
+	mockRepo := &mockRepository{
+		createAccountFn: func(ctx context.Context, account domain.Account) (*domain.Account, error) {
+			stored = account.Tags
+			return &account, nil
+		},
+	}
+	svc := NewService(mockRepo)
+
+	if _, err := svc.CreateAccount(ctx, domain.Account{ID: 1, Balance: decimal.NewFromInt(10), Tags: []string{"vip", "region:eu", "vip"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(stored, []string{"region:eu", "vip"}) {
+		t.Fatalf("expected sorted tags without repeats, got %v", stored)
+	}
+
+	tooMany := make([]string, MaxAccountTags+1)
+	for i := range tooMany {
+		tooMany[i] = "tag" + strconv.Itoa(i)
+	}
+	for _, tags := range [][]string{{""}, {"has space"}, {strings.Repeat("a", MaxAccountTagLength+1)}, tooMany} {
+		if _, err := svc.CreateAccount(ctx, domain.Account{ID: 1, Balance: decimal.NewFromInt(10), Tags: tags}); !errors.Is(err, ErrInvalidAccountTags) {
+			t.Fatalf("expected ErrInvalidAccountTags for %q, got %v", tags, err)
+		}
+	}
+}
+
+func TestDefaultService_ListAccounts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var listed domain.AccountFilter
+	mockRepo := &mockRepository{
+		listAccountsFn: func(ctx context.Context, filter domain.AccountFilter) ([]domain.Account, error) {
+			listed = filter
+			return []domain.Account{{ID: 1}}, nil
+		},
+	}
+	svc := NewService(mockRepo)
+
+	if _, err := svc.ListAccounts(ctx, domain.AccountFilter{Tags: []string{"vip", "retail"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listed.Sort != domain.AccountSortID || listed.Limit != DefaultPageSize || !slices.Equal(listed.Tags, []string{"retail", "vip"}) {
+		t.Fatalf("expected the default sort and page size with sorted tags, got %+v", listed)
+	}
+	if _, err := svc.ListAccounts(ctx, domain.AccountFilter{Limit: MaxPageSize + 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listed.Limit != MaxPageSize {
+		t.Fatalf("expected the page size to be capped at %d, got %d", MaxPageSize, listed.Limit)
+	}
+
+	low, high := decimal.NewFromInt(10), decimal.NewFromInt(20)
+	now := time.Now()
+	testCases := []struct {
+		name   string
+		filter domain.AccountFilter
+		err    error
+	}{
+		{"unknown_status", domain.AccountFilter{Status: "closed"}, ErrInvalidAccountQuery},
+		{"unknown_sort", domain.AccountFilter{Sort: "tier"}, ErrInvalidAccountQuery},
+		{"empty_balance_range", domain.AccountFilter{MinBalance: &high, MaxBalance: &low}, ErrInvalidAccountQuery},
+		{"empty_created_range", domain.AccountFilter{CreatedSince: now, CreatedUntil: now}, ErrInvalidAccountQuery},
+		{"invalid_tag", domain.AccountFilter{Tags: []string{"a/b"}}, ErrInvalidAccountTags},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := svc.ListAccounts(ctx, testCase.filter); !errors.Is(err, testCase.err) {
+				t.Fatalf("expected %v, got %v", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestDefaultService_BatchGetAccounts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var asked []int64
+	mockRepo := &mockRepository{
+		getAccountsFn: func(ctx context.Context, ids []int64) ([]domain.Account, error) {
+			asked = ids
+			return []domain.Account{{ID: 1}, {ID: 2}}, nil
+		},
+	}
+	svc := NewService(mockRepo)
+
+	batch, err := svc.BatchGetAccounts(ctx, []int64{2, 3, 2, 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(asked, []int64{2, 3, 1}) {
+		t.Fatalf("expected the repository to be asked for 2, 3, 1, got %v", asked)
+	}
+	if len(batch.Accounts) != 2 || batch.Accounts[0].ID != 2 || batch.Accounts[1].ID != 1 || !slices.Equal(batch.NotFound, []int64{3}) {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+
+	tooMany := make([]int64, MaxBatchGetAccounts+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+	for _, accountIDs := range [][]int64{nil, tooMany} {
+		if _, err := svc.BatchGetAccounts(ctx, accountIDs); !errors.Is(err, ErrInvalidAccountQuery) {
+			t.Fatalf("expected ErrInvalidAccountQuery for %d accounts, got %v", len(accountIDs), err)
+		}
+	}
+	if _, err := svc.BatchGetAccounts(ctx, []int64{-1}); !errors.Is(err, ErrInvalidAccountIDs) {
+		t.Fatalf("expected ErrInvalidAccountIDs, got %v", err)
+	}
+}
+
+func TestDefaultService_ValidateTransfer(t *testing.T) {
+	t.Parallel()
+	svc := NewService(&mockRepository{}, WithFunding(Funding{DepositClearingAccountID: 9}))
+
+	testCases := []struct {
+		testName    string
+		transaction domain.Transaction
+		expectedErr error
+	}{
+		{"valid", domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)}, nil},
+		{"same_account", domain.Transaction{SourceAccountID: 1, DestinationAccountID: 1, Amount: decimal.NewFromInt(5)}, ErrSameSourceAndDestination},
+		{"zero_amount", domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2}, ErrNonPositiveAmount},
+		{"too_precise", domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("0.00001")}, ErrInvalidAmountPrecision},
+		{"clearing_account", domain.Transaction{SourceAccountID: 9, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)}, ErrClearingAccount},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			if err := svc.ValidateTransfer(testCase.transaction); !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("expected %v, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+
+	if err := svc.ValidateAccount(domain.Account{ID: 1, Balance: decimal.NewFromInt(1), Tags: []string{"has space"}}); !errors.Is(err, ErrInvalidAccountTags) {
+		t.Fatalf("expected ErrInvalidAccountTags, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tareqpi/transfer-system/internal/logger"
+	"github.com/tareqpi/transfer-system/internal/metrics"
+	"github.com/tareqpi/transfer-system/internal/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// settleDelay is how far behind the clock snapshots are taken, so that
+// transfers still committing when a run starts are created after the
+// snapshot rather than inside it. The repository moves the snapshot further
+// back past transactions still open.
+const settleDelay = time.Minute
+
+// Result describes one run. Accounts counts the snapshots taken, one per
+// account with transactions since its previous snapshot.
+type Result struct {
+	TakenAt  time.Time `json:"taken_at"`
+	Accounts int       `json:"accounts"`
+}
+
+// Job snapshots account balances so that point-in-time balance queries only
+// replay the transactions created since the latest snapshot instead of the
+// whole history of an account.
+type Job struct {
+	repository repository.Repository
+	now        func() time.Time
+
+	mu sync.Mutex
+}
+
+func NewJob(repository repository.Repository) *Job {
+	return &Job{repository: repository, now: time.Now}
+}
+
+func (j *Job) Run(ctx context.Context) (*Result, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	takenAt, err := j.repository.SnapshotCutoff(ctx, j.now().UTC().Add(-settleDelay))
+	if err != nil {
+		metrics.SnapshotRunsTotal.WithLabelValues(StatusFailed).Inc()
+		return nil, fmt.Errorf("find snapshot cutoff: %w", err)
+	}
+	accounts, err := j.repository.TakeBalanceSnapshots(ctx, takenAt)
+	if err != nil {
+		metrics.SnapshotRunsTotal.WithLabelValues(StatusFailed).Inc()
+		return nil, fmt.Errorf("take balance snapshots: %w", err)
+	}
+	metrics.SnapshotRunsTotal.WithLabelValues(StatusOK).Inc()
+	metrics.SnapshotLastTakenTimestamp.Set(float64(takenAt.Unix()))
+	return &Result{TakenAt: takenAt, Accounts: accounts}, nil
+}
+
+// Schedule returns a worker that takes snapshots every interval, starting one
+// interval after it is started.
+func (j *Job) Schedule(interval time.Duration) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			j.runLogged(ctx)
+		}
+	}
+}
+
+func (j *Job) runLogged(ctx context.Context) {
+	result, err := j.Run(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.L().Error("balance snapshots failed", zap.Error(err))
+		}
+		return
+	}
+	logger.L().Info("balance snapshots taken",
+		zap.Time("taken_at", result.TakenAt),
+		zap.Int("accounts", result.Accounts),
+	)
+}
@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tareqpi/transfer-system/internal/domain"
+	"github.com/tareqpi/transfer-system/internal/repository"
+)
+
+func TestJob_Run_TakesSnapshotsBehindTheClock(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := repository.NewMemoryRepository()
+	for _, account := range []domain.Account{
+		{ID: 1, Balance: decimal.NewFromInt(100)},
+		{ID: 2, Balance: decimal.Zero},
+	} {
+		if _, err := repo.CreateAccount(ctx, account); err != nil {
+			t.Fatalf("create account %d: %v", account.ID, err)
+		}
+	}
+	if _, err := repo.TransferMoney(ctx, domain.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)}); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+
+	now := time.Now()
+	job := NewJob(repo)
+	job.now = func() time.Time { return now }
+
+	// The transfer is not yet a minute old, so it is left to the next run.
+	result, err := job.Run(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !result.TakenAt.Equal(now.Add(-settleDelay)) || result.Accounts != 0 {
+		t.Fatalf("expected no snapshots a minute before now, got %+v", result)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if result, err = job.Run(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Accounts != 2 {
+		t.Fatalf("expected snapshots of both accounts, got %+v", result)
+	}
+	if result, err = job.Run(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Accounts != 0 {
+		t.Fatalf("expected no snapshots without new transactions, got %+v", result)
+	}
+}
+
+// openTransactionRepository reports a transaction open since openedAt.
+type openTransactionRepository struct {
+	*repository.MemoryRepository
+	openedAt time.Time
+}
+
+func (r openTransactionRepository) SnapshotCutoff(_ context.Context, at time.Time) (time.Time, error) {
+	if r.openedAt.Before(at) {
+		return r.openedAt.Add(-time.Microsecond), nil
+	}
+	return at, nil
+}
+
+func TestJob_Run_WaitsForOpenTransactions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	openedAt := now.Add(-time.Hour)
+	job := NewJob(openTransactionRepository{MemoryRepository: repository.NewMemoryRepository(), openedAt: openedAt})
+	job.now = func() time.Time { return now }
+
+	result, err := job.Run(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !result.TakenAt.Equal(openedAt.Add(-time.Microsecond)) {
+		t.Fatalf("expected snapshots before the open transaction at %s, got %s", openedAt, result.TakenAt)
+	}
+}
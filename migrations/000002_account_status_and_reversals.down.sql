@@ -0,0 +1,9 @@
+-- down migration for account status, transaction reversals and history indexes
+
+DROP INDEX IF EXISTS accounts.transactions_destination_account_id_id_idx;
+DROP INDEX IF EXISTS accounts.transactions_source_account_id_id_idx;
+DROP INDEX IF EXISTS accounts.transactions_reversal_of_key;
+
+ALTER TABLE accounts.transactions DROP COLUMN IF EXISTS reversal_of;
+
+ALTER TABLE accounts.accounts DROP COLUMN IF EXISTS status;
@@ -0,0 +1,21 @@
+-- up migration adding account status, transaction reversals and history indexes
+
+-- 1. Accounts can be frozen by operators; frozen accounts cannot send or receive money
+ALTER TABLE accounts.accounts
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+    CONSTRAINT accounts_status_check CHECK (status IN ('active', 'frozen'));
+
+-- 2. A reversal references the transaction it undoes; each transaction can be reversed once
+ALTER TABLE accounts.transactions
+    ADD COLUMN IF NOT EXISTS reversal_of BIGINT REFERENCES accounts.transactions (id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_reversal_of_key
+    ON accounts.transactions (reversal_of)
+    WHERE reversal_of IS NOT NULL;
+
+-- 3. Indexes backing per-account history listings
+CREATE INDEX IF NOT EXISTS transactions_source_account_id_id_idx
+    ON accounts.transactions (source_account_id, id);
+
+CREATE INDEX IF NOT EXISTS transactions_destination_account_id_id_idx
+    ON accounts.transactions (destination_account_id, id);
@@ -0,0 +1,4 @@
+-- down migration dropping the opening balance column
+
+ALTER TABLE accounts.accounts
+    DROP COLUMN IF EXISTS opening_balance;
@@ -0,0 +1,4 @@
+-- down migration dropping the audit log
+
+DROP TABLE IF EXISTS accounts.audit_log;
+DROP FUNCTION IF EXISTS accounts.audit_log_reject_change();
@@ -0,0 +1,51 @@
+-- up migration creating the append-only audit log
+
+-- 1. One row per committed mutation, written in the same transaction as the mutation
+CREATE TABLE IF NOT EXISTS accounts.audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    client_ip TEXT NOT NULL DEFAULT '',
+    operation TEXT NOT NULL,
+    account_id BIGINT NOT NULL,
+    counterparty_account_id BIGINT,
+    transaction_id BIGINT,
+    before_state JSONB,
+    after_state JSONB
+);
+
+-- 2. Indexes backing the admin query filters
+CREATE INDEX IF NOT EXISTS audit_log_account_id_id_idx
+    ON accounts.audit_log (account_id, id);
+
+CREATE INDEX IF NOT EXISTS audit_log_counterparty_account_id_id_idx
+    ON accounts.audit_log (counterparty_account_id, id)
+    WHERE counterparty_account_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS audit_log_request_id_idx
+    ON accounts.audit_log (request_id);
+
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx
+    ON accounts.audit_log (occurred_at);
+
+-- 3. Reject every UPDATE, DELETE and TRUNCATE so that the log is append-only
+CREATE OR REPLACE FUNCTION accounts.audit_log_reject_change()
+RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only: % is not allowed', TG_OP
+    USING ERRCODE = 'insufficient_privilege';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_reject_update_delete ON accounts.audit_log;
+CREATE TRIGGER audit_log_reject_update_delete
+BEFORE UPDATE OR DELETE ON accounts.audit_log
+FOR EACH ROW
+EXECUTE PROCEDURE accounts.audit_log_reject_change();
+
+DROP TRIGGER IF EXISTS audit_log_reject_truncate ON accounts.audit_log;
+CREATE TRIGGER audit_log_reject_truncate
+BEFORE TRUNCATE ON accounts.audit_log
+FOR EACH STATEMENT
+EXECUTE PROCEDURE accounts.audit_log_reject_change();
@@ -0,0 +1,15 @@
+-- down migration folding shard balances back into their accounts
+
+UPDATE accounts.accounts AS a
+SET balance = a.balance + s.total
+FROM (
+    SELECT account_id, SUM(balance) AS total
+    FROM accounts.account_shards
+    GROUP BY account_id
+) s
+WHERE s.account_id = a.id;
+
+DROP TABLE IF EXISTS accounts.account_shards;
+
+ALTER TABLE accounts.accounts
+    DROP COLUMN IF EXISTS shards;
@@ -0,0 +1,17 @@
+-- up migration adding sharded sub-balances for high-volume accounts
+
+-- 1. Number of shard rows holding the account's balance; 0 keeps it in the account row
+ALTER TABLE accounts.accounts
+    ADD COLUMN IF NOT EXISTS shards INTEGER NOT NULL DEFAULT 0
+    CONSTRAINT accounts_shards_check CHECK (shards >= 0);
+
+-- 2. The balance of an account is its own balance plus the sum of its shards.
+-- Transfers credit one random shard and debit only as many shards as they
+-- need, so concurrent transfers touching the account do not queue on one row.
+CREATE TABLE IF NOT EXISTS accounts.account_shards (
+    account_id BIGINT NOT NULL REFERENCES accounts.accounts (id),
+    shard INTEGER NOT NULL,
+    balance NUMERIC(19, 4) NOT NULL DEFAULT 0
+        CONSTRAINT account_shards_balance_check CHECK (balance >= 0),
+    PRIMARY KEY (account_id, shard)
+);
@@ -0,0 +1,7 @@
+-- up migration adding an optimistic concurrency version to accounts
+
+-- 1. Incremented by every write to the account row and served as its ETag.
+-- Existing accounts start at version 1, like new ones.
+ALTER TABLE accounts.accounts
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1
+    CONSTRAINT accounts_version_check CHECK (version > 0);
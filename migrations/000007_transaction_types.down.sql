@@ -0,0 +1,4 @@
+-- down migration dropping transaction types
+
+ALTER TABLE accounts.transactions
+    DROP COLUMN IF EXISTS type;
@@ -0,0 +1,12 @@
+-- up migration adding transaction types for deposits and withdrawals
+
+-- 1. Transfers move money between customer accounts; deposits and withdrawals
+-- move it between a customer account and an external clearing account
+ALTER TABLE accounts.transactions
+    ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'transfer'
+    CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal'));
+
+-- 2. Backfill the reversals recorded so far
+UPDATE accounts.transactions
+SET type = 'reversal'
+WHERE reversal_of IS NOT NULL;
@@ -0,0 +1,16 @@
+-- down migration dropping fee rules, fee transactions and account tiers
+
+DROP TABLE IF EXISTS accounts.fee_rules;
+
+UPDATE accounts.transactions
+SET type = 'transfer'
+WHERE type = 'fee';
+
+ALTER TABLE accounts.transactions
+    DROP CONSTRAINT IF EXISTS transactions_type_check;
+
+ALTER TABLE accounts.transactions
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal'));
+
+ALTER TABLE accounts.accounts
+    DROP COLUMN IF EXISTS tier;
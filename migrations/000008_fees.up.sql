@@ -0,0 +1,24 @@
+-- up migration adding account tiers, fee transactions and fee rules
+
+-- 1. Fee rules can be limited to the accounts of one tier
+ALTER TABLE accounts.accounts
+    ADD COLUMN IF NOT EXISTS tier TEXT NOT NULL DEFAULT 'standard';
+
+-- 2. The fee of a transfer is posted as a transaction of its own
+ALTER TABLE accounts.transactions
+    DROP CONSTRAINT IF EXISTS transactions_type_check;
+
+ALTER TABLE accounts.transactions
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee'));
+
+-- 3. Fee rules managed through the admin API. The parameters of a rule
+-- depend on its kind and are kept as JSON.
+CREATE TABLE IF NOT EXISTS accounts.fee_rules (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL CONSTRAINT fee_rules_kind_check CHECK (kind IN ('flat', 'percentage', 'tiered')),
+    account_tier TEXT NOT NULL DEFAULT '',
+    parameters JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+-- down migration dropping interest accruals, rates and interest transactions
+
+DROP TABLE IF EXISTS accounts.interest_accruals;
+
+DROP TABLE IF EXISTS accounts.interest_rates;
+
+UPDATE accounts.transactions
+SET type = 'transfer'
+WHERE type = 'interest';
+
+ALTER TABLE accounts.transactions
+    DROP CONSTRAINT IF EXISTS transactions_type_check;
+
+ALTER TABLE accounts.transactions
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee'));
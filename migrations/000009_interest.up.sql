@@ -0,0 +1,35 @@
+-- up migration adding interest rates, daily accruals and interest transactions
+
+-- 1. Interest is posted as a transaction from the interest expense account
+ALTER TABLE accounts.transactions
+    DROP CONSTRAINT IF EXISTS transactions_type_check;
+
+ALTER TABLE accounts.transactions
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee', 'interest'));
+
+-- 2. Annual rate, in percent, of the accounts that earn interest
+CREATE TABLE IF NOT EXISTS accounts.interest_rates (
+    account_id BIGINT PRIMARY KEY,
+    annual_rate NUMERIC(9, 6) NOT NULL CONSTRAINT interest_rates_annual_rate_check CHECK (annual_rate >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 3. One row per account and day. The amount keeps ten decimal places so
+-- that rounding happens once, when a month of accruals is posted.
+CREATE TABLE IF NOT EXISTS accounts.interest_accruals (
+    account_id BIGINT NOT NULL,
+    accrual_date DATE NOT NULL,
+    balance NUMERIC(19, 4) NOT NULL,
+    annual_rate NUMERIC(9, 6) NOT NULL,
+    day_count TEXT NOT NULL,
+    amount NUMERIC(29, 10) NOT NULL,
+    transaction_id BIGINT,
+    PRIMARY KEY (account_id, accrual_date)
+);
+
+CREATE INDEX IF NOT EXISTS interest_accruals_accrual_date_idx
+    ON accounts.interest_accruals (accrual_date);
+
+CREATE INDEX IF NOT EXISTS interest_accruals_unposted_idx
+    ON accounts.interest_accruals (account_id, accrual_date)
+    WHERE transaction_id IS NULL;
@@ -0,0 +1,6 @@
+-- down migration dropping balance snapshots
+
+DROP INDEX IF EXISTS accounts.transactions_destination_account_id_created_at_idx;
+DROP INDEX IF EXISTS accounts.transactions_source_account_id_created_at_idx;
+
+DROP TABLE IF EXISTS accounts.balance_snapshots;
@@ -0,0 +1,7 @@
+-- down migration dropping account tags
+
+DROP INDEX IF EXISTS accounts.accounts_created_at_id_idx;
+DROP INDEX IF EXISTS accounts.accounts_tags_idx;
+
+ALTER TABLE accounts.accounts
+    DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,12 @@
+-- up migration adding account tags and the indexes behind account listings
+
+-- 1. Free-form labels set when an account is created, kept sorted
+ALTER TABLE accounts.accounts
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- 2. Indexes backing the tag and creation time filters of account listings
+CREATE INDEX IF NOT EXISTS accounts_tags_idx
+    ON accounts.accounts USING GIN (tags);
+
+CREATE INDEX IF NOT EXISTS accounts_created_at_id_idx
+    ON accounts.accounts (created_at, id);
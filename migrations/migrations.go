@@ -0,0 +1,15 @@
+// Package migrations embeds the SQL migrations so the binary can apply them
+// regardless of its working directory. PostgreSQL migrations live at the top
+// level; every other dialect has its own directory with the same versions.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var SQLite embed.FS
+
+//go:embed mysql/*.sql
+var MySQL embed.FS
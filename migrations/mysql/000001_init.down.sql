@@ -0,0 +1,5 @@
+-- down migration for the accounts and transactions tables
+
+DROP TABLE IF EXISTS transactions;
+
+DROP TABLE IF EXISTS accounts;
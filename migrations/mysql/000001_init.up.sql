@@ -0,0 +1,18 @@
+-- up migration creating the accounts and transactions tables for MySQL 8
+
+-- 1. Create the accounts table; updated_at is maintained by ON UPDATE instead of a trigger
+CREATE TABLE IF NOT EXISTS accounts (
+    id BIGINT NOT NULL PRIMARY KEY,
+    balance DECIMAL(19, 4) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6)
+) ENGINE = InnoDB;
+
+-- 2. Create the transactions table
+CREATE TABLE IF NOT EXISTS transactions (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    source_account_id BIGINT NOT NULL,
+    destination_account_id BIGINT NOT NULL,
+    amount DECIMAL(19, 4) NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE = InnoDB;
@@ -0,0 +1,15 @@
+-- down migration for account status, transaction reversals and history indexes
+
+DROP INDEX transactions_destination_account_id_id_idx ON transactions;
+DROP INDEX transactions_source_account_id_id_idx ON transactions;
+
+ALTER TABLE transactions
+    DROP FOREIGN KEY transactions_reversal_of_fkey;
+
+ALTER TABLE transactions
+    DROP INDEX transactions_reversal_of_key,
+    DROP COLUMN reversal_of;
+
+ALTER TABLE accounts
+    DROP CHECK accounts_status_check,
+    DROP COLUMN status;
@@ -0,0 +1,20 @@
+-- up migration adding account status, transaction reversals and history indexes
+
+-- 1. Accounts can be frozen by operators; frozen accounts cannot send or receive money
+ALTER TABLE accounts
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active',
+    ADD CONSTRAINT accounts_status_check CHECK (status IN ('active', 'frozen'));
+
+-- 2. A reversal references the transaction it undoes; each transaction can be reversed once.
+-- A unique key admits any number of NULLs, like the partial index in PostgreSQL.
+ALTER TABLE transactions
+    ADD COLUMN reversal_of BIGINT NULL,
+    ADD UNIQUE KEY transactions_reversal_of_key (reversal_of),
+    ADD CONSTRAINT transactions_reversal_of_fkey FOREIGN KEY (reversal_of) REFERENCES transactions (id);
+
+-- 3. Indexes backing per-account history listings
+CREATE INDEX transactions_source_account_id_id_idx
+    ON transactions (source_account_id, id);
+
+CREATE INDEX transactions_destination_account_id_id_idx
+    ON transactions (destination_account_id, id);
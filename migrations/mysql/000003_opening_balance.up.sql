@@ -0,0 +1,12 @@
+-- up migration recording the balance each account was opened with
+
+-- 1. Reconciliation recomputes balances as opening balance + credits - debits
+ALTER TABLE accounts
+    ADD COLUMN opening_balance DECIMAL(19, 4) NOT NULL DEFAULT 0;
+
+-- 2. Backfill existing accounts from their current balance and history, so the
+-- ledger reconciles as of this migration and later drift is reported
+UPDATE accounts AS a
+SET opening_balance = a.balance
+    - COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.destination_account_id = a.id), 0)
+    + COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.source_account_id = a.id), 0);
@@ -0,0 +1,32 @@
+-- up migration creating the append-only audit log
+
+-- 1. One row per committed mutation, written in the same transaction as the mutation
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    occurred_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    operation VARCHAR(64) NOT NULL,
+    account_id BIGINT NOT NULL,
+    counterparty_account_id BIGINT NULL,
+    transaction_id BIGINT NULL,
+    before_state JSON NULL,
+    after_state JSON NULL,
+    KEY audit_log_account_id_id_idx (account_id, id),
+    KEY audit_log_counterparty_account_id_id_idx (counterparty_account_id, id),
+    KEY audit_log_request_id_idx (request_id),
+    KEY audit_log_occurred_at_idx (occurred_at)
+) ENGINE = InnoDB;
+
+-- 2. Reject every UPDATE and DELETE so that the log is append-only. Triggers do
+-- not fire on TRUNCATE, so the application user should not be granted DROP.
+CREATE TRIGGER audit_log_reject_update
+BEFORE UPDATE ON audit_log
+FOR EACH ROW
+SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only: UPDATE is not allowed';
+
+CREATE TRIGGER audit_log_reject_delete
+BEFORE DELETE ON audit_log
+FOR EACH ROW
+SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only: DELETE is not allowed';
@@ -0,0 +1,6 @@
+-- down migration dropping sharded sub-balances
+
+DROP TABLE IF EXISTS account_shards;
+
+ALTER TABLE accounts
+    DROP COLUMN shards;
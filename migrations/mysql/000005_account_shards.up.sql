@@ -0,0 +1,16 @@
+-- up migration adding sharded sub-balances for high-volume accounts
+-- Only the PostgreSQL backend shards balances; the schema is kept the same so
+-- that migration versions line up across dialects.
+
+-- 1. Number of shard rows holding the account's balance; 0 keeps it in the account row
+ALTER TABLE accounts
+    ADD COLUMN shards INT NOT NULL DEFAULT 0;
+
+-- 2. The balance of an account is its own balance plus the sum of its shards
+CREATE TABLE IF NOT EXISTS account_shards (
+    account_id BIGINT NOT NULL,
+    shard INT NOT NULL,
+    balance DECIMAL(19, 4) NOT NULL DEFAULT 0,
+    PRIMARY KEY (account_id, shard),
+    CONSTRAINT account_shards_account_id_fkey FOREIGN KEY (account_id) REFERENCES accounts (id)
+) ENGINE = InnoDB;
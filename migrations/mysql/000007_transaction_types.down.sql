@@ -0,0 +1,5 @@
+-- down migration dropping transaction types
+
+ALTER TABLE transactions
+    DROP CHECK transactions_type_check,
+    DROP COLUMN type;
@@ -0,0 +1,12 @@
+-- up migration adding transaction types for deposits and withdrawals
+
+-- 1. Transfers move money between customer accounts; deposits and withdrawals
+-- move it between a customer account and an external clearing account
+ALTER TABLE transactions
+    ADD COLUMN type VARCHAR(16) NOT NULL DEFAULT 'transfer',
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal'));
+
+-- 2. Backfill the reversals recorded so far
+UPDATE transactions
+SET type = 'reversal'
+WHERE reversal_of IS NOT NULL;
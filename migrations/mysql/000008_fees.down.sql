@@ -0,0 +1,14 @@
+-- down migration dropping fee rules, fee transactions and account tiers
+
+DROP TABLE IF EXISTS fee_rules;
+
+UPDATE transactions
+SET type = 'transfer'
+WHERE type = 'fee';
+
+ALTER TABLE transactions
+    DROP CHECK transactions_type_check,
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal'));
+
+ALTER TABLE accounts
+    DROP COLUMN tier;
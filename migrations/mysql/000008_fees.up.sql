@@ -0,0 +1,23 @@
+-- up migration adding account tiers, fee transactions and fee rules
+
+-- 1. Fee rules can be limited to the accounts of one tier
+ALTER TABLE accounts
+    ADD COLUMN tier VARCHAR(64) NOT NULL DEFAULT 'standard';
+
+-- 2. The fee of a transfer is posted as a transaction of its own
+ALTER TABLE transactions
+    DROP CHECK transactions_type_check,
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee'));
+
+-- 3. Fee rules managed through the admin API. The parameters of a rule
+-- depend on its kind and are kept as JSON.
+CREATE TABLE IF NOT EXISTS fee_rules (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    account_tier VARCHAR(64) NOT NULL DEFAULT '',
+    parameters JSON NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT fee_rules_kind_check CHECK (kind IN ('flat', 'percentage', 'tiered'))
+) ENGINE = InnoDB;
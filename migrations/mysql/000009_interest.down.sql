@@ -0,0 +1,13 @@
+-- down migration dropping interest accruals, rates and interest transactions
+
+DROP TABLE IF EXISTS interest_accruals;
+
+DROP TABLE IF EXISTS interest_rates;
+
+UPDATE transactions
+SET type = 'transfer'
+WHERE type = 'interest';
+
+ALTER TABLE transactions
+    DROP CHECK transactions_type_check,
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee'));
@@ -0,0 +1,28 @@
+-- up migration adding interest rates, daily accruals and interest transactions
+
+-- 1. Interest is posted as a transaction from the interest expense account
+ALTER TABLE transactions
+    DROP CHECK transactions_type_check,
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee', 'interest'));
+
+-- 2. Annual rate, in percent, of the accounts that earn interest
+CREATE TABLE IF NOT EXISTS interest_rates (
+    account_id BIGINT NOT NULL PRIMARY KEY,
+    annual_rate DECIMAL(9, 6) NOT NULL,
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT interest_rates_annual_rate_check CHECK (annual_rate >= 0)
+) ENGINE = InnoDB;
+
+-- 3. One row per account and day. The amount keeps ten decimal places so
+-- that rounding happens once, when a month of accruals is posted.
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    account_id BIGINT NOT NULL,
+    accrual_date DATE NOT NULL,
+    balance DECIMAL(19, 4) NOT NULL,
+    annual_rate DECIMAL(9, 6) NOT NULL,
+    day_count VARCHAR(16) NOT NULL,
+    amount DECIMAL(29, 10) NOT NULL,
+    transaction_id BIGINT NULL,
+    PRIMARY KEY (account_id, accrual_date),
+    KEY interest_accruals_accrual_date_idx (accrual_date)
+) ENGINE = InnoDB;
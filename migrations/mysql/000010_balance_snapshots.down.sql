@@ -0,0 +1,6 @@
+-- down migration dropping balance snapshots
+
+DROP INDEX transactions_destination_account_id_created_at_idx ON transactions;
+DROP INDEX transactions_source_account_id_created_at_idx ON transactions;
+
+DROP TABLE IF EXISTS balance_snapshots;
@@ -0,0 +1,18 @@
+-- up migration adding balance snapshots for point-in-time balance queries
+
+-- 1. The balance of an account after every transaction created up to and
+-- including taken_at. Point-in-time queries start from the latest snapshot
+-- and only add the transactions created after it.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    account_id BIGINT NOT NULL,
+    taken_at TIMESTAMP(6) NOT NULL,
+    balance DECIMAL(19, 4) NOT NULL,
+    PRIMARY KEY (account_id, taken_at)
+) ENGINE = InnoDB;
+
+-- 2. Indexes backing the sums of an account's transactions over a period
+CREATE INDEX transactions_source_account_id_created_at_idx
+    ON transactions (source_account_id, created_at);
+
+CREATE INDEX transactions_destination_account_id_created_at_idx
+    ON transactions (destination_account_id, created_at);
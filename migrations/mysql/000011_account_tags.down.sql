@@ -0,0 +1,6 @@
+-- down migration dropping account tags
+
+DROP INDEX accounts_created_at_id_idx ON accounts;
+
+ALTER TABLE accounts
+    DROP COLUMN tags;
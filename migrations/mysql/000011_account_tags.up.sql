@@ -0,0 +1,10 @@
+-- up migration adding account tags and the indexes behind account listings
+
+-- 1. Free-form labels set when an account is created, kept sorted as a JSON
+-- array of strings
+ALTER TABLE accounts
+    ADD COLUMN tags JSON NOT NULL DEFAULT (JSON_ARRAY());
+
+-- 2. Index backing the creation time filter of account listings
+CREATE INDEX accounts_created_at_id_idx
+    ON accounts (created_at, id);
@@ -0,0 +1,7 @@
+-- down migration for the accounts and transactions tables
+
+DROP TRIGGER IF EXISTS set_timestamp;
+
+DROP TABLE IF EXISTS transactions;
+
+DROP TABLE IF EXISTS accounts;
@@ -0,0 +1,28 @@
+-- up migration creating the accounts and transactions tables for SQLite
+-- Balances and amounts are stored as TEXT so that they keep their exact
+-- decimal value; all arithmetic happens in the application.
+
+-- 1. Create the accounts table with created_at and updated_at columns
+CREATE TABLE IF NOT EXISTS accounts (
+    id INTEGER PRIMARY KEY,
+    balance TEXT NOT NULL DEFAULT '0',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- 2. Keep updated_at current on every update
+CREATE TRIGGER IF NOT EXISTS set_timestamp
+AFTER UPDATE ON accounts
+FOR EACH ROW
+BEGIN
+    UPDATE accounts SET updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE id = NEW.id;
+END;
+
+-- 3. Create the transactions table
+CREATE TABLE IF NOT EXISTS transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    amount TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
@@ -0,0 +1,20 @@
+-- down migration for account status, transaction reversals and history indexes
+
+DROP INDEX IF EXISTS transactions_destination_account_id_id_idx;
+DROP INDEX IF EXISTS transactions_source_account_id_id_idx;
+DROP INDEX IF EXISTS transactions_reversal_of_key;
+
+-- SQLite cannot drop a column that carries a foreign key, so the table is rebuilt
+CREATE TABLE transactions_rebuild (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    amount TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+INSERT INTO transactions_rebuild (id, source_account_id, destination_account_id, amount, created_at)
+    SELECT id, source_account_id, destination_account_id, amount, created_at FROM transactions;
+DROP TABLE transactions;
+ALTER TABLE transactions_rebuild RENAME TO transactions;
+
+ALTER TABLE accounts DROP COLUMN status;
@@ -0,0 +1,32 @@
+-- up migration recording the balance each account was opened with
+
+-- 1. Reconciliation recomputes balances as opening balance + credits - debits
+ALTER TABLE accounts
+    ADD COLUMN opening_balance TEXT NOT NULL DEFAULT '0';
+
+-- 2. Backfill existing accounts from their current balance and history, so the
+-- ledger reconciles as of this migration and later drift is reported. Values
+-- are decimal strings with at most four fractional digits; they are summed as
+-- integer ten-thousandths so that the backfill stays exact.
+WITH amounts AS (
+    SELECT
+        source_account_id,
+        destination_account_id,
+        CAST(CASE WHEN instr(amount, '.') > 0 THEN substr(amount, 1, instr(amount, '.') - 1) ELSE amount END AS INTEGER) * 10000
+            + CAST(CASE WHEN instr(amount, '.') > 0 THEN substr(substr(amount, instr(amount, '.') + 1) || '0000', 1, 4) ELSE '0' END AS INTEGER) AS units
+    FROM transactions
+), opening AS (
+    SELECT
+        a.id,
+        CAST(CASE WHEN instr(a.balance, '.') > 0 THEN substr(a.balance, 1, instr(a.balance, '.') - 1) ELSE a.balance END AS INTEGER) * 10000
+            + CAST(CASE WHEN instr(a.balance, '.') > 0 THEN substr(substr(a.balance, instr(a.balance, '.') + 1) || '0000', 1, 4) ELSE '0' END AS INTEGER)
+            - COALESCE((SELECT SUM(units) FROM amounts WHERE destination_account_id = a.id), 0)
+            + COALESCE((SELECT SUM(units) FROM amounts WHERE source_account_id = a.id), 0) AS units
+    FROM accounts a
+)
+UPDATE accounts
+SET opening_balance = (
+    SELECT CASE WHEN units < 0 THEN '-' ELSE '' END || (abs(units) / 10000) || '.' || printf('%04d', abs(units) % 10000)
+    FROM opening
+    WHERE opening.id = accounts.id
+);
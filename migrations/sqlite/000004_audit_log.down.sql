@@ -0,0 +1,3 @@
+-- down migration dropping the audit log
+
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,45 @@
+-- up migration creating the append-only audit log
+
+-- 1. One row per committed mutation, written in the same transaction as the
+-- mutation. The application writes occurred_at with a fixed number of
+-- fractional digits so that the text compares in time order.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    actor TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    client_ip TEXT NOT NULL DEFAULT '',
+    operation TEXT NOT NULL,
+    account_id INTEGER NOT NULL,
+    counterparty_account_id INTEGER,
+    transaction_id INTEGER,
+    before_state TEXT,
+    after_state TEXT
+);
+
+-- 2. Indexes backing the admin query filters
+CREATE INDEX IF NOT EXISTS audit_log_account_id_id_idx
+    ON audit_log (account_id, id);
+
+CREATE INDEX IF NOT EXISTS audit_log_counterparty_account_id_id_idx
+    ON audit_log (counterparty_account_id, id)
+    WHERE counterparty_account_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS audit_log_request_id_idx
+    ON audit_log (request_id);
+
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx
+    ON audit_log (occurred_at);
+
+-- 3. Reject every UPDATE and DELETE so that the log is append-only
+CREATE TRIGGER IF NOT EXISTS audit_log_reject_update
+BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only: UPDATE is not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_reject_delete
+BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only: DELETE is not allowed');
+END;
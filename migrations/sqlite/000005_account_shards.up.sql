@@ -0,0 +1,15 @@
+-- up migration adding sharded sub-balances for high-volume accounts
+-- Only the PostgreSQL backend shards balances; SQLite serializes all writes
+-- anyway. The schema is kept the same so that migration versions line up.
+
+-- 1. Number of shard rows holding the account's balance; 0 keeps it in the account row
+ALTER TABLE accounts
+    ADD COLUMN shards INTEGER NOT NULL DEFAULT 0;
+
+-- 2. The balance of an account is its own balance plus the sum of its shards
+CREATE TABLE IF NOT EXISTS account_shards (
+    account_id INTEGER NOT NULL REFERENCES accounts (id),
+    shard INTEGER NOT NULL,
+    balance TEXT NOT NULL DEFAULT '0',
+    PRIMARY KEY (account_id, shard)
+);
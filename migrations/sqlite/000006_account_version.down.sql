@@ -0,0 +1,4 @@
+-- down migration dropping the account version
+
+ALTER TABLE accounts
+    DROP COLUMN version;
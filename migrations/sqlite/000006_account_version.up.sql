@@ -0,0 +1,6 @@
+-- up migration adding an optimistic concurrency version to accounts
+
+-- 1. Incremented by every write to the account row and served as its ETag.
+-- Existing accounts start at version 1, like new ones.
+ALTER TABLE accounts
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
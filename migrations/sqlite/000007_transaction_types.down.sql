@@ -0,0 +1,4 @@
+-- down migration dropping transaction types
+
+ALTER TABLE transactions
+    DROP COLUMN type;
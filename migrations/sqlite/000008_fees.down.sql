@@ -0,0 +1,19 @@
+-- down migration dropping fee rules, fee transactions and account tiers
+
+DROP TABLE IF EXISTS fee_rules;
+
+ALTER TABLE transactions
+    RENAME COLUMN type TO previous_type;
+
+ALTER TABLE transactions
+    ADD COLUMN type TEXT NOT NULL DEFAULT 'transfer'
+    CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal'));
+
+UPDATE transactions
+SET type = CASE previous_type WHEN 'fee' THEN 'transfer' ELSE previous_type END;
+
+ALTER TABLE transactions
+    DROP COLUMN previous_type;
+
+ALTER TABLE accounts
+    DROP COLUMN tier;
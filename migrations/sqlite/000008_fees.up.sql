@@ -0,0 +1,32 @@
+-- up migration adding account tiers, fee transactions and fee rules
+
+-- 1. Fee rules can be limited to the accounts of one tier
+ALTER TABLE accounts
+    ADD COLUMN tier TEXT NOT NULL DEFAULT 'standard';
+
+-- 2. The fee of a transfer is posted as a transaction of its own. SQLite
+-- cannot alter a CHECK constraint, so the type column is replaced.
+ALTER TABLE transactions
+    RENAME COLUMN type TO previous_type;
+
+ALTER TABLE transactions
+    ADD COLUMN type TEXT NOT NULL DEFAULT 'transfer'
+    CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee'));
+
+UPDATE transactions
+SET type = previous_type;
+
+ALTER TABLE transactions
+    DROP COLUMN previous_type;
+
+-- 3. Fee rules managed through the admin API. The parameters of a rule
+-- depend on its kind and are kept as JSON.
+CREATE TABLE IF NOT EXISTS fee_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL CONSTRAINT fee_rules_kind_check CHECK (kind IN ('flat', 'percentage', 'tiered')),
+    account_tier TEXT NOT NULL DEFAULT '',
+    parameters TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
@@ -0,0 +1,18 @@
+-- down migration dropping interest accruals, rates and interest transactions
+
+DROP TABLE IF EXISTS interest_accruals;
+
+DROP TABLE IF EXISTS interest_rates;
+
+ALTER TABLE transactions
+    RENAME COLUMN type TO previous_type;
+
+ALTER TABLE transactions
+    ADD COLUMN type TEXT NOT NULL DEFAULT 'transfer'
+    CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee'));
+
+UPDATE transactions
+SET type = CASE previous_type WHEN 'interest' THEN 'transfer' ELSE previous_type END;
+
+ALTER TABLE transactions
+    DROP COLUMN previous_type;
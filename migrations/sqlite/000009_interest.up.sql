@@ -0,0 +1,40 @@
+-- up migration adding interest rates, daily accruals and interest transactions
+
+-- 1. Interest is posted as a transaction from the interest expense account.
+-- SQLite cannot alter a CHECK constraint, so the type column is replaced.
+ALTER TABLE transactions
+    RENAME COLUMN type TO previous_type;
+
+ALTER TABLE transactions
+    ADD COLUMN type TEXT NOT NULL DEFAULT 'transfer'
+    CONSTRAINT transactions_type_check CHECK (type IN ('transfer', 'reversal', 'deposit', 'withdrawal', 'fee', 'interest'));
+
+UPDATE transactions
+SET type = previous_type;
+
+ALTER TABLE transactions
+    DROP COLUMN previous_type;
+
+-- 2. Annual rate, in percent, of the accounts that earn interest
+CREATE TABLE IF NOT EXISTS interest_rates (
+    account_id INTEGER PRIMARY KEY,
+    annual_rate TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- 3. One row per account and day, with the date as YYYY-MM-DD. The amount
+-- keeps ten decimal places so that rounding happens once, when a month of
+-- accruals is posted.
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    account_id INTEGER NOT NULL,
+    accrual_date TEXT NOT NULL,
+    balance TEXT NOT NULL,
+    annual_rate TEXT NOT NULL,
+    day_count TEXT NOT NULL,
+    amount TEXT NOT NULL,
+    transaction_id INTEGER,
+    PRIMARY KEY (account_id, accrual_date)
+);
+
+CREATE INDEX IF NOT EXISTS interest_accruals_accrual_date_idx
+    ON interest_accruals (accrual_date);
@@ -0,0 +1,3 @@
+-- down migration dropping balance snapshots
+
+DROP TABLE IF EXISTS balance_snapshots;
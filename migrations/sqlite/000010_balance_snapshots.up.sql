@@ -0,0 +1,15 @@
+-- up migration adding balance snapshots for point-in-time balance queries
+
+-- 1. The balance of an account after every transaction created up to and
+-- including taken_at, a fixed-width UTC timestamp so that snapshots sort in
+-- time order. Transactions are serialized, so they are created in ID order
+-- and last_transaction_id is the last one the snapshot includes;
+-- point-in-time queries start from the latest snapshot and only replay the
+-- transactions after it.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    account_id INTEGER NOT NULL,
+    taken_at TEXT NOT NULL,
+    balance TEXT NOT NULL,
+    last_transaction_id INTEGER NOT NULL,
+    PRIMARY KEY (account_id, taken_at)
+);
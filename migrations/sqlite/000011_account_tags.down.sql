@@ -0,0 +1,4 @@
+-- down migration dropping account tags
+
+ALTER TABLE accounts
+    DROP COLUMN tags;
@@ -0,0 +1,6 @@
+-- up migration adding account tags
+
+-- 1. Free-form labels set when an account is created, kept sorted as a JSON
+-- array of strings
+ALTER TABLE accounts
+    ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';